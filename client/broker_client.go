@@ -3,121 +3,535 @@ package client
 import (
 	"net/http"
 	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"io"
 	"fmt"
-	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 /* ---------- client helpers ---------- */
 
 type HttpError struct {
 	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
 }
 
-func logHTTPErrorThenExit(resp *http.Response) error {
+// an unsuccessful response from the broker
+type BrokerError struct {
+	StatusCode int
+	Status     string
+	Message    string
+	RequestID  string
+}
+
+func (e *BrokerError) Error() string {
+	return "Status code: " + e.Status + " - " + e.Message
+}
+
+func newBrokerError(resp *http.Response) error {
+	brokerErr := &BrokerError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Message:    http.StatusText(resp.StatusCode),
+		RequestID:  resp.Header.Get("X-Request-Id"),
+	}
+
 	var respBody HttpError
 	err := json.NewDecoder(resp.Body).Decode(&respBody)
-	if err != nil {
-		return err
+	if err == nil && len(respBody.Error) != 0 {
+		brokerErr.Message = respBody.Error
 	}
 
-	fmt.Printf("Status code: %v\n", resp.Status)
-	log.Fatal(respBody.Error)
+	if resp.StatusCode == http.StatusUnauthorized {
+		if resp.Request != nil && len(resp.Request.Header.Get("Authorization")) != 0 {
+			brokerErr.Message = brokerErr.Message + "\n\nThe broker rejected the token that was sent. Run signet login again with a valid --token, or set " + tokenEnvVar + "."
+		} else {
+			brokerErr.Message = brokerErr.Message + "\n\nThe broker requires a token. Run signet login --broker-url <url> --token <token>, or set " + tokenEnvVar + "."
+		}
+	}
 
-	return nil
+	return brokerErr
 }
 
 type DeployGuardResponse struct {
 	Status bool `json:"status"`
 	Errors []DeployGuardError `json:"errors"`
+	Unverified []UnverifiedContract `json:"unverified,omitempty"`
+}
+
+type UnverifiedContract struct {
+	ConsumerName string `json:"consumerName"`
+	ProviderName string `json:"providerName"`
 }
 
 type DeployGuardError struct {
 	Title string `json:"title"`
 	Details string `json:"details"`
+	// the incompatibility is with a contract that has not been verified yet
+	Pending bool `json:"pending,omitempty"`
+}
+
+type Capabilities struct {
+	ContractSchemaVersions []int `json:"contractSchemaVersions"`
+	ContractTTL            bool  `json:"contractTtl"`
+}
+
+type Environment struct {
+	EnvironmentName string            `json:"environmentName"`
+	Tags            map[string]string `json:"tags"`
+}
+
+// a contract or spec that a participant version published to the broker
+type ContractSummary struct {
+	ParticipantName    string `json:"participantName"`
+	ParticipantVersion string `json:"participantVersion"`
+	ParticipantBranch  string `json:"participantBranch"`
+	ContractType       string `json:"contractType"`
+	ContractFormat     string `json:"contractFormat"`
+	PublishedAt        string `json:"publishedAt"`
+}
+
+// a participant version that the broker records as deployed to an environment
+type Deployment struct {
+	ParticipantName    string `json:"participantName"`
+	ParticipantVersion string `json:"participantVersion"`
+	EnvironmentName    string `json:"environmentName"`
+}
+
+// the health of the broker, as reported by its health endpoint
+type BrokerHealth struct {
+	Status  string `json:"status"`
+	Version string `json:"version"`
 }
 
 /* ---------- client pkg ---------- */
 
-func PublishToBroker(brokerURL string, jsonData []byte) error {
-	resp, err := http.Post(brokerURL, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return err
+// capabilities are looked up once per broker for the life of the process, guarded by capabilitiesMu since the signet package can publish from several goroutines at once
+var capabilitiesCache = map[string]Capabilities{}
+var capabilitiesMu sync.Mutex
+
+// when set, fetched specs and contracts must carry a valid signature from this key
+var SigningKey ed25519.PublicKey
+
+// retry settings for every request to the broker, set from --retry and --retry-timeout
+var Retries int
+var RetryTimeout time.Duration
+var RetryOutput io.Writer = os.Stderr
+
+// when set, every request to the broker and its response are logged to it, set from --verbose
+var VerboseOutput io.Writer
+
+// extra headers sent with every request to the broker, set from --header and broker.headers
+var Headers http.Header
+
+// the wait before the first retry, which doubles after every attempt
+var initialBackoff = 500 * time.Millisecond
+
+// the longest that a single request to the broker waits for its response, each retry waits as long again
+const RequestTimeout = 30 * time.Second
+
+var httpClient = &http.Client{Timeout: RequestTimeout}
+
+/*
+sends a request to the broker, retrying connection errors and 5xx responses
+up to Retries times with exponential backoff. 4xx responses are never
+retried, and no retry is started that would end after RetryTimeout.
+*/
+func sendRequest(req *http.Request) (*http.Response, error) {
+	for headerName, values := range Headers {
+		req.Header[headerName] = values
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 201 {
-		var respBody HttpError
-		err = json.NewDecoder(resp.Body).Decode(&respBody)
+	if token := tokenFor(req.URL.String()); len(token) != 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	deadline := time.Now().Add(RetryTimeout)
+	backoff := initialBackoff
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			req.Body, _ = req.GetBody()
+		}
+
+		logRequest(req)
+		resp, err := httpClient.Do(req)
+		logResponse(resp, err)
+		if err == nil && resp.StatusCode < 500 {
+			return resp, nil
+		}
+
+		remaining := Retries - attempt
+		if remaining <= 0 || (RetryTimeout > 0 && time.Now().Add(backoff).After(deadline)) {
+			return resp, err
+		}
+
+		reason := ""
 		if err != nil {
-			return err
+			reason = "could not reach the broker: " + err.Error()
+		} else {
+			reason = "the broker responded " + resp.Status
+			resp.Body.Close()
 		}
 
-		if respBody.Error == "Participant version already exists" {
-			respBody.Error = respBody.Error + "\n\nA new consumer version must be set whenever a contract is published."
+		fmt.Fprintf(RetryOutput, "Retrying - %s, %d attempts remaining, next attempt in %s\n", reason, remaining, backoff)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoff):
 		}
+		backoff *= 2
+	}
+}
+
+// logs the method, URL, headers, and body of a request to VerboseOutput, without the values of the Authorization header and of Headers
+func logRequest(req *http.Request) {
+	if VerboseOutput == nil {
+		return
+	}
+
+	fmt.Fprintf(VerboseOutput, "> %s %s\n", req.Method, req.URL.String())
+
+	headerNames := make([]string, 0, len(req.Header))
+	for headerName := range req.Header {
+		headerNames = append(headerNames, headerName)
+	}
+	sort.Strings(headerNames)
+
+	for _, headerName := range headerNames {
+		value := strings.Join(req.Header.Values(headerName), ", ")
+		if http.CanonicalHeaderKey(headerName) == "Authorization" || len(Headers.Values(headerName)) != 0 {
+			value = "[REDACTED]"
+		}
+		fmt.Fprintf(VerboseOutput, "> %s: %s\n", headerName, value)
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err == nil {
+			bodyBytes, _ := io.ReadAll(body)
+			if len(bodyBytes) != 0 {
+				fmt.Fprintf(VerboseOutput, "> %s\n", bodyBytes)
+			}
+		}
+	}
+}
+
+/*
+logs the status and body of a response to VerboseOutput. The body is read in
+full, and replaced so that it can still be read by the caller.
+*/
+func logResponse(resp *http.Response, err error) {
+	if VerboseOutput == nil {
+		return
+	}
+
+	if err != nil {
+		fmt.Fprintf(VerboseOutput, "< request failed: %s\n", err.Error())
+		return
+	}
+
+	fmt.Fprintf(VerboseOutput, "< %s\n", resp.Status)
 
-		fmt.Printf("Status code: %v\n", resp.Status)
-		log.Fatal(respBody.Error)
+	bodyBytes, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	if readErr != nil {
+		fmt.Fprintf(VerboseOutput, "< failed to read the response body: %s\n", readErr.Error())
+	} else if len(bodyBytes) != 0 {
+		fmt.Fprintf(VerboseOutput, "< %s\n", bodyBytes)
 	}
+}
+
+func get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return sendRequest(req)
+}
+
+func post(ctx context.Context, url string, jsonData []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	return sendRequest(req)
+}
+
+// the environment variable that overrides the tokens stored by signet login
+const tokenEnvVar = "SIGNET_TOKEN"
+
+// the file that signet login stores a token for each broker URL in
+func CredentialsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".signet", "credentials"), nil
+}
+
+// reads the stored tokens, keyed by broker URL. A missing file has no tokens.
+func readCredentials() (map[string]string, error) {
+	credentials := map[string]string{}
+
+	credentialsPath, err := CredentialsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	credentialsBytes, err := os.ReadFile(credentialsPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return credentials, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(credentialsBytes, &credentials)
+	if err != nil {
+		return nil, errors.New("could not parse " + credentialsPath + ": " + err.Error())
+	}
+
+	return credentials, nil
+}
+
+/*
+stores the token for a broker URL in the credentials file, replacing any
+token already stored for it, and returns the path of the file. The file is
+only readable by the current user.
+*/
+func SaveToken(brokerURL, token string) (string, error) {
+	credentials, err := readCredentials()
+	if err != nil {
+		return "", err
+	}
+
+	credentials[strings.TrimRight(brokerURL, "/")] = token
+
+	credentialsBytes, err := json.MarshalIndent(credentials, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	credentialsPath, _ := CredentialsPath()
+	err = os.MkdirAll(filepath.Dir(credentialsPath), 0700)
+	if err != nil {
+		return "", err
+	}
+
+	return credentialsPath, os.WriteFile(credentialsPath, credentialsBytes, 0600)
+}
+
+/*
+returns the token sent with a request: SIGNET_TOKEN when it is set, and
+otherwise the token stored for the longest broker URL that the request URL
+starts with
+*/
+func tokenFor(requestURL string) string {
+	if token := os.Getenv(tokenEnvVar); len(token) != 0 {
+		return token
+	}
+
+	credentials, err := readCredentials()
+	if err != nil {
+		return ""
+	}
+
+	token, matched := "", ""
+	for storedURL, storedToken := range credentials {
+		if len(storedURL) <= len(matched) {
+			continue
+		}
+
+		rest, found := strings.CutPrefix(requestURL, storedURL)
+		if found && (len(rest) == 0 || rest[0] == '/' || rest[0] == '?') {
+			token, matched = storedToken, storedURL
+		}
+	}
+
+	return token
+}
+
+// the header that a signing broker sends the base64 encoded detached signature of a spec or contract in
+const signatureHeader = "X-Signet-Signature"
+
+func verifySignature(body []byte, signature string) error {
+	if SigningKey == nil {
+		return nil
+	}
+
+	if len(signature) == 0 {
+		return errors.New("the broker did not send a " + signatureHeader + " header, so the signature cannot be verified")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(SigningKey, body, decoded) {
+		return errors.New("signature verification failed - the broker's response was not signed by --signing-key")
+	}
+
 	return nil
 }
 
-func RegisterEnvWithBroker(brokerURL string, jsonData []byte) error {
-	resp, err := http.Post(brokerURL + "/api/environments", "application/json", bytes.NewBuffer(jsonData))
+func PublishToBroker(ctx context.Context, brokerURL string, jsonData []byte) error {
+	resp, err := post(ctx, brokerURL, jsonData)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 201 {
-		err = logHTTPErrorThenExit(resp)
-		if err != nil {
-			return err
+		err = newBrokerError(resp)
+		if brokerErr := err.(*BrokerError); brokerErr.Message == "Participant version already exists" {
+			brokerErr.Message = brokerErr.Message + "\n\nA new consumer version must be set whenever a contract is published."
 		}
+		return err
 	}
 	return nil
 }
 
-func UpdateDeploymentWithBroker(brokerURL string, jsonData []byte) error {
-	req, err := http.NewRequest(http.MethodPatch, brokerURL + "/api/participants", bytes.NewBuffer(jsonData))
+// how long a --webhook notification can take before it is abandoned
+const webhookTimeout = 10 * time.Second
+
+/*
+posts a JSON payload to a webhook outside the broker. Unlike requests to the
+broker, no token is sent and the request is not retried.
+*/
+func NotifyWebhook(webhookURL string, jsonData []byte) error {
+	webhookClient := http.Client{Timeout: webhookTimeout}
+	resp, err := webhookClient.Post(webhookURL, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("the webhook responded " + resp.Status)
+	}
+	return nil
+}
+
+func RegisterEnvWithBroker(ctx context.Context, brokerURL string, jsonData []byte) error {
+	resp, err := post(ctx, brokerURL + "/api/environments", jsonData)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 201 {
+		return newBrokerError(resp)
+	}
+	return nil
+}
+
+// the header that lets the broker recognize a deployment it has already recorded
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+/*
+the error code of the 409 Conflict the broker responds with when it has
+already recorded the request with the same idempotency key
+*/
+const IdempotencyKeyReusedCode = "idempotency_key_reused"
+
+/*
+records a deployment with the broker, and reports whether the broker had
+already recorded it. Every retry sends the same idempotency key, so a retry
+whose earlier attempt reached the broker gets a 409 Conflict with
+IdempotencyKeyReusedCode, which is not an error. Any other 409 Conflict is.
+*/
+func UpdateDeploymentWithBroker(ctx context.Context, brokerURL string, jsonData []byte, idempotencyKey string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, brokerURL + "/api/participants", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return false, err
+	}
 
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IdempotencyKeyHeader, idempotencyKey)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := sendRequest(req)
 	if err != nil {
-			return err
+			return false, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		err = logHTTPErrorThenExit(resp)
+	if resp.StatusCode == http.StatusConflict {
+		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return err
+			return false, err
 		}
+
+		var respBody HttpError
+		if json.Unmarshal(body, &respBody) == nil && respBody.Code == IdempotencyKeyReusedCode {
+			return true, nil
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
 	}
-	return nil
+
+	if resp.StatusCode != 200 {
+		return false, newBrokerError(resp)
+	}
+	return false, nil
 }
 
-func GetLatestSpec(brokerURL, name string) ([]byte, error) {
+func GetLatestSpec(ctx context.Context, brokerURL, name string, useCache bool) ([]byte, error) {
 	specURL := brokerURL + "/api/specs?provider=" + name
 
-	resp, err := http.Get(specURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, specURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var cachedSpec, cachedSignature []byte
+	specPath, etagPath, lastModifiedPath, signaturePath := specCachePaths(brokerURL, name)
+	if useCache && len(specPath) != 0 {
+		cachedSpec, _ = os.ReadFile(specPath)
+		cachedETag, _ := os.ReadFile(etagPath)
+		cachedLastModified, _ := os.ReadFile(lastModifiedPath)
+		cachedSignature, _ = os.ReadFile(signaturePath)
+		if len(cachedSpec) != 0 && len(cachedETag) != 0 {
+			req.Header.Set("If-None-Match", string(cachedETag))
+		} else if len(cachedSpec) != 0 && len(cachedLastModified) != 0 {
+			// brokers that do not send an ETag can still answer by modification time
+			req.Header.Set("If-Modified-Since", string(cachedLastModified))
+		}
+	}
+
+	resp, err := sendRequest(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		err = logHTTPErrorThenExit(resp)
+	if resp.StatusCode == http.StatusNotModified && len(cachedSpec) != 0 {
+		signature := resp.Header.Get(signatureHeader)
+		if len(signature) == 0 {
+			signature = string(cachedSignature)
+		}
+
+		err = verifySignature(cachedSpec, signature)
 		if err != nil {
 			return nil, err
 		}
+		return cachedSpec, nil
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, newBrokerError(resp)
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
@@ -125,30 +539,314 @@ func GetLatestSpec(brokerURL, name string) ([]byte, error) {
 		return nil, err
 	}
 
+	signature := resp.Header.Get(signatureHeader)
+	err = verifySignature(bodyBytes, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if useCache && len(specPath) != 0 && (len(etag) != 0 || len(lastModified) != 0) {
+		// a failed cache write only means the next run downloads the spec again
+		if os.MkdirAll(filepath.Dir(specPath), os.ModePerm) == nil {
+			os.WriteFile(specPath, bodyBytes, 0644)
+			os.WriteFile(etagPath, []byte(etag), 0644)
+			os.WriteFile(lastModifiedPath, []byte(lastModified), 0644)
+			os.WriteFile(signaturePath, []byte(signature), 0644)
+		}
+	}
+
 	return bodyBytes, nil
 }
 
-func CheckDeployGuard(brokerURL, name, version, environment string) (bool, error) {
+/*
+returns the paths of the cached spec, its ETag, its Last-Modified time, and
+its signature, keyed by the broker URL and provider name. The paths are empty
+if there is no user cache directory.
+*/
+func specCachePaths(brokerURL, name string) (string, string, string, string) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", "", "", ""
+	}
+
+	key := sha256.Sum256([]byte(brokerURL + "\n" + name))
+	base := filepath.Join(cacheDir, "signet", "specs", hex.EncodeToString(key[:]))
+	return base + ".spec", base + ".etag", base + ".last-modified", base + ".sig"
+}
+
+// fetches the contract a consumer version published for a provider
+func GetContract(ctx context.Context, brokerURL, consumerName, providerName, consumerVersion string) ([]byte, error) {
+	query := url.Values{}
+	query.Set("consumer", consumerName)
+	query.Set("provider", providerName)
+	query.Set("consumerVersion", consumerVersion)
+
+	resp, err := get(ctx, brokerURL + "/api/contracts?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, newBrokerError(resp)
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifySignature(bodyBytes, resp.Header.Get(signatureHeader))
+	if err != nil {
+		return nil, err
+	}
+
+	return bodyBytes, nil
+}
+
+// fetches the latest contract that each consumer of a provider has published
+func GetConsumerContracts(ctx context.Context, brokerURL, providerName string) ([]json.RawMessage, error) {
+	query := url.Values{}
+	query.Set("provider", providerName)
+
+	resp, err := get(ctx, brokerURL + "/api/contracts/latest?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, newBrokerError(resp)
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	err = verifySignature(bodyBytes, resp.Header.Get(signatureHeader))
+	if err != nil {
+		return nil, err
+	}
+
+	var contracts []json.RawMessage
+	err = json.Unmarshal(bodyBytes, &contracts)
+	if err != nil {
+		return nil, errors.New("the consumer contracts of " + providerName + " could not be parsed: " + err.Error())
+	}
+
+	return contracts, nil
+}
+
+/*
+asks the broker whether a participant version is safe to deploy to an
+environment. With includePending, the broker also checks the version against
+contracts which have not been verified yet.
+*/
+func CheckDeployGuard(ctx context.Context, brokerURL, name, version, environment string, tags []string, includePending bool) (DeployGuardResponse, error) {
 	deployGuardURL := brokerURL + "/api/deploy?participantName=" + name + "&participantVersion=" + version + "&environmentName=" + environment
+	for _, tag := range tags {
+		deployGuardURL += "&tag=" + url.QueryEscape(tag)
+	}
+	if includePending {
+		deployGuardURL += "&includePending=true"
+	}
 
-	resp, err := http.Get(deployGuardURL)
+	resp, err := get(ctx, deployGuardURL)
 	if err != nil {
-		return false, err
+		return DeployGuardResponse{}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		err = logHTTPErrorThenExit(resp)
-		if err != nil {
-			return false, err
-		}
+		return DeployGuardResponse{}, newBrokerError(resp)
 	}
 
 	var respBody DeployGuardResponse
 	err = json.NewDecoder(resp.Body).Decode(&respBody)
 	if err != nil {
-		return false, err
+		return DeployGuardResponse{}, err
+	}
+
+	return respBody, nil
+}
+
+func ListEnvironments(ctx context.Context, brokerURL string) ([]Environment, error) {
+	resp, err := get(ctx, brokerURL + "/api/environments")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, newBrokerError(resp)
 	}
 
-	return respBody.Status, nil
-}
\ No newline at end of file
+	var environments []Environment
+	err = json.NewDecoder(resp.Body).Decode(&environments)
+	if err != nil {
+		return nil, err
+	}
+
+	return environments, nil
+}
+
+// lists the contracts and specs that every version of a participant published, newest first
+func ListContracts(ctx context.Context, brokerURL, name string) ([]ContractSummary, error) {
+	query := url.Values{}
+	query.Set("participant", name)
+
+	resp, err := get(ctx, brokerURL + "/api/contracts/versions?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, newBrokerError(resp)
+	}
+
+	var contracts []ContractSummary
+	err = json.NewDecoder(resp.Body).Decode(&contracts)
+	if err != nil {
+		return nil, err
+	}
+
+	return contracts, nil
+}
+
+/*
+deletes the contract or spec that a version of a participant published. The
+type is either consumer or provider.
+*/
+func DeleteContract(ctx context.Context, brokerURL, name, version, contractType string) error {
+	query := url.Values{}
+	query.Set("participant", name)
+	query.Set("version", version)
+	query.Set("type", contractType)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, brokerURL+"/api/contracts/versions?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := sendRequest(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 && resp.StatusCode != 204 {
+		return newBrokerError(resp)
+	}
+	return nil
+}
+
+// lists the participant versions that are currently deployed to an environment
+func GetDeployments(ctx context.Context, brokerURL, environment string) ([]Deployment, error) {
+	query := url.Values{}
+	query.Set("environmentName", environment)
+
+	resp, err := get(ctx, brokerURL + "/api/participants?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, newBrokerError(resp)
+	}
+
+	var deployments []Deployment
+	err = json.NewDecoder(resp.Body).Decode(&deployments)
+	if err != nil {
+		return nil, err
+	}
+
+	return deployments, nil
+}
+
+/*
+checks that the broker is reachable and healthy, and returns the version it
+reports along with the round-trip time of the request
+*/
+func Ping(ctx context.Context, brokerURL string) (BrokerHealth, time.Duration, error) {
+	start := time.Now()
+	resp, err := get(ctx, brokerURL + "/api/health")
+	latency := time.Since(start)
+	if err != nil {
+		return BrokerHealth{}, latency, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return BrokerHealth{}, latency, newBrokerError(resp)
+	}
+
+	var health BrokerHealth
+	err = json.NewDecoder(resp.Body).Decode(&health)
+	if err != nil {
+		return BrokerHealth{}, latency, errors.New("the broker's health check response could not be parsed: " + err.Error())
+	}
+
+	return health, latency, nil
+}
+
+/*
+brokers which predate capability negotiation have no capabilities endpoint,
+and only accept version 1 of the contract schema
+*/
+func GetCapabilities(ctx context.Context, brokerURL string) (Capabilities, error) {
+	capabilitiesMu.Lock()
+	capabilities, ok := capabilitiesCache[brokerURL]
+	capabilitiesMu.Unlock()
+	if ok {
+		return capabilities, nil
+	}
+
+	resp, err := get(ctx, brokerURL + "/api/capabilities")
+	if err != nil {
+		return Capabilities{}, err
+	}
+	defer resp.Body.Close()
+
+	capabilities = Capabilities{ContractSchemaVersions: []int{1}}
+	if resp.StatusCode == 200 {
+		err = json.NewDecoder(resp.Body).Decode(&capabilities)
+		if err != nil {
+			return Capabilities{}, err
+		}
+	} else if resp.StatusCode != 404 {
+		return Capabilities{}, fmt.Errorf("failed to look up the broker's capabilities: %s", resp.Status)
+	}
+
+	capabilitiesMu.Lock()
+	capabilitiesCache[brokerURL] = capabilities
+	capabilitiesMu.Unlock()
+	return capabilities, nil
+}
+
+// selects the highest contract schema version supported by both the CLI and the broker
+func NegotiateSchemaVersion(ctx context.Context, brokerURL string, supported []int) (int, error) {
+	capabilities, err := GetCapabilities(ctx, brokerURL)
+	if err != nil {
+		return 0, err
+	}
+
+	negotiated := 0
+	for _, cliVersion := range supported {
+		for _, brokerVersion := range capabilities.ContractSchemaVersions {
+			if cliVersion == brokerVersion && cliVersion > negotiated {
+				negotiated = cliVersion
+			}
+		}
+	}
+
+	if negotiated == 0 {
+		return 0, fmt.Errorf("the broker supports contract schema versions %v, but this version of signet only supports %v", capabilities.ContractSchemaVersions, supported)
+	}
+
+	return negotiated, nil
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	client "github.com/signet-framework/signet-cli/client"
+	utils "github.com/signet-framework/signet-cli/utils"
+)
+
+var assumeYes bool
+
+var contractsCmd = &cobra.Command{
+	Use:   "contracts",
+	Short: "inspect the contracts and specs published to the broker",
+	Long: `inspect the contracts and specs published to the Signet broker
+
+	subcommands:
+
+	list                list the contract versions a service has published
+
+	delete              delete a contract or spec version from the broker
+	`,
+}
+
+var contractsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the contract versions a service has published",
+	Long: `list the contract and spec versions a service has published to the Signet broker, with the branch and format of each, to confirm that a publish landed
+
+	flags:
+
+	-n --name           the name of the service whose contracts are listed
+
+	-o --output         output format, either 'text' for a table, or 'json' (optional, defaults to 'text')
+
+	-u --broker-url     the scheme, domain, and port where the Signet Broker is being hosted
+
+	-i --ignore-config  ingore .signetrc.yaml file if it exists (optional)
+	`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		brokerURL = resolveBrokerURL(cmd)
+		name = viper.GetString("contracts.name")
+		output = viper.GetString("contracts.output")
+
+		if len(brokerURL) == 0 {
+			return usageError(errors.New("No --broker-url was provided. This is a required flag."))
+		}
+
+		if len(name) == 0 {
+			return usageError(errors.New("No --name was provided. This is a required flag."))
+		}
+
+		if output != "text" && output != "json" {
+			return usageError(errors.New("--output must be either \"text\" or \"json\", --output was " + output))
+		}
+
+		contracts, err := client.ListContracts(cmd.Context(), brokerURL, name)
+		if err != nil {
+			return err
+		}
+
+		if output == "json" {
+			if contracts == nil {
+				contracts = []client.ContractSummary{}
+			}
+			return json.NewEncoder(cmd.OutOrStdout()).Encode(contracts)
+		}
+
+		if len(contracts) == 0 {
+			cmd.Println("No contracts have been published for " + name)
+			return nil
+		}
+
+		printContractsTable(cmd, contracts)
+		return nil
+	},
+}
+
+var contractsDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "delete a contract or spec version from the broker",
+	Long: `delete the contract or spec that a version of a service published to the Signet broker, so that a bad version no longer gates deploys
+
+	flags:
+
+	-n --name           the name of the service whose contract is deleted
+
+	-v --version        the version of the service whose contract is deleted
+
+	-t --type           the type of contract, either 'consumer' or 'provider'
+
+	-y --yes            delete without asking for confirmation (optional)
+
+	-u --broker-url     the scheme, domain, and port where the Signet Broker is being hosted
+
+	-i --ignore-config  ingore .signetrc.yaml file if it exists (optional)
+	`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		brokerURL = resolveBrokerURL(cmd)
+		name = viper.GetString("contracts.delete.name")
+		version = viper.GetString("contracts.delete.version")
+		serviceType = viper.GetString("contracts.delete.type")
+		assumeYes = viper.GetBool("contracts.delete.yes")
+
+		if len(brokerURL) == 0 {
+			return usageError(errors.New("No --broker-url was provided. This is a required flag."))
+		}
+
+		if len(name) == 0 {
+			return usageError(errors.New("No --name was provided. This is a required flag."))
+		}
+
+		if len(version) == 0 {
+			return usageError(errors.New("No --version was provided. This is a required flag."))
+		}
+
+		err := utils.ValidType(serviceType)
+		if err != nil {
+			return usageError(err)
+		}
+
+		description := "the " + serviceType + " contract of " + name + " at version " + version
+		if serviceType == "provider" {
+			description = "the API spec of " + name + " at version " + version
+		}
+
+		if !assumeYes {
+			confirmed, err := confirm(cmd, "Delete "+description+" from the Signet broker?")
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				cmd.Println("Cancelled - nothing was deleted")
+				return nil
+			}
+		}
+
+		err = client.DeleteContract(cmd.Context(), brokerURL, name, version, serviceType)
+		var brokerErr *client.BrokerError
+		if errors.As(err, &brokerErr) && brokerErr.StatusCode == http.StatusNotFound {
+			cmd.Println("Not found - the Signet broker has no " + strings.TrimPrefix(description, "the ") + ", so there was nothing to delete")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		cmd.Println(colorGreen + "Deleted" + colorReset + " - " + description + " was deleted from the Signet broker")
+		return nil
+	},
+}
+
+// asks a yes or no question on stdin, anything but y or yes is a no
+func confirm(cmd *cobra.Command, question string) (bool, error) {
+	cannotAsk := usageError(errors.New("the question \"" + question + "\" needs an answer on stdin, but stdin is not interactive. Pass --yes to skip the question."))
+	if file, ok := cmd.InOrStdin().(*os.File); ok && !isTerminal(file) {
+		return false, cannotAsk
+	}
+
+	cmd.Print(question + " [y/N] ")
+	answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err == io.EOF && len(strings.TrimSpace(answer)) == 0 {
+		cmd.Println()
+		return false, cannotAsk
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// prints one row per published contract, in the order the broker listed them
+func printContractsTable(cmd *cobra.Command, contracts []client.ContractSummary) {
+	table := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "VERSION\tBRANCH\tTYPE\tFORMAT\tPUBLISHED")
+	for _, contract := range contracts {
+		branch := contract.ParticipantBranch
+		if len(branch) == 0 {
+			branch = "-"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", contract.ParticipantVersion, branch, contract.ContractType, contract.ContractFormat, contract.PublishedAt)
+	}
+	table.Flush()
+}
+
+func init() {
+	RootCmd.AddCommand(contractsCmd)
+	contractsCmd.AddCommand(contractsListCmd)
+	contractsCmd.AddCommand(contractsDeleteCmd)
+
+	contractsListCmd.Flags().StringVarP(&name, "name", "n", "", "The name of the service whose contracts are listed")
+	contractsListCmd.Flags().StringVarP(&output, "output", "o", "text", "Output format, either \"text\" or \"json\"")
+
+	viper.BindPFlag("contracts.name", contractsListCmd.Flags().Lookup("name"))
+	viper.BindPFlag("contracts.output", contractsListCmd.Flags().Lookup("output"))
+
+	contractsDeleteCmd.Flags().StringVarP(&name, "name", "n", "", "The name of the service whose contract is deleted")
+	contractsDeleteCmd.Flags().StringVarP(&version, "version", "v", "", "The version of the service whose contract is deleted")
+	contractsDeleteCmd.Flags().StringVarP(&serviceType, "type", "t", "", "Type of the contract (\"consumer\" or \"provider\")")
+	contractsDeleteCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Delete without asking for confirmation")
+
+	viper.BindPFlag("contracts.delete.name", contractsDeleteCmd.Flags().Lookup("name"))
+	viper.BindPFlag("contracts.delete.version", contractsDeleteCmd.Flags().Lookup("version"))
+	viper.BindPFlag("contracts.delete.type", contractsDeleteCmd.Flags().Lookup("type"))
+	viper.BindPFlag("contracts.delete.yes", contractsDeleteCmd.Flags().Lookup("yes"))
+}
@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	client "github.com/signet-framework/signet-cli/client"
+)
+
+/* ------------- helpers ------------- */
+
+func callContractsList(argsAndFlags []string) actualOut {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs(append([]string{"contracts", "list"}, argsAndFlags...))
+	RootCmd.Execute()
+	return actualOut{actual.String()}
+}
+
+func mockServerForListContracts(t *testing.T, contracts []client.ContractSummary) (*httptest.Server, *http.Request) {
+	var req http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = *r
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		err := json.NewEncoder(w).Encode(contracts)
+		if err != nil {
+			t.Error("Failed to write mock response body")
+		}
+	}))
+
+	return server, &req
+}
+
+var publishedContracts = []client.ContractSummary{
+	{ParticipantName: "user_service", ParticipantVersion: "a1b2c3d", ParticipantBranch: "main", ContractType: "provider", ContractFormat: "yaml", PublishedAt: "2024-05-02T10:00:00Z"},
+	{ParticipantName: "user_service", ParticipantVersion: "9f8e7d6", ContractType: "consumer", ContractFormat: "json", PublishedAt: "2024-05-01T09:30:00Z"},
+}
+
+/* ------------- tests ------------- */
+
+func TestContractsListNoName(t *testing.T) {
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+	}
+	actual := callContractsList(flags)
+	expected := "Error: No --name was provided. This is a required flag."
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestContractsListInvalidOutput(t *testing.T) {
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+		"--name", "user_service",
+		"--output", "github",
+	}
+	actual := callContractsList(flags)
+	expected := "Error: --output must be either \"text\" or \"json\", --output was github"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestContractsList(t *testing.T) {
+	server, req := mockServerForListContracts(t, publishedContracts)
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+	}
+	actual := callContractsList(flags)
+
+	t.Run("requests the contracts of the participant", func(t *testing.T) {
+		if req.URL.Path != "/api/contracts/versions" || req.URL.Query().Get("participant") != "user_service" {
+			t.Error(req.URL.String())
+		}
+	})
+
+	t.Run("prints a table of the published contracts", func(t *testing.T) {
+		expected := "VERSION  BRANCH  TYPE      FORMAT  PUBLISHED\n" +
+			"a1b2c3d  main    provider  yaml    2024-05-02T10:00:00Z\n" +
+			"9f8e7d6  -       consumer  json    2024-05-01T09:30:00Z\n"
+		if actual.actual != expected {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestContractsListNoneFound(t *testing.T) {
+	server, _ := mockServerForListContracts(t, []client.ContractSummary{})
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+	}
+	actual := callContractsList(flags)
+	expected := "No contracts have been published for user_service"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestContractsListJSONOutput(t *testing.T) {
+	server, _ := mockServerForListContracts(t, publishedContracts)
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--output", "json",
+	}
+	actual := callContractsList(flags)
+
+	var listed []client.ContractSummary
+	err := json.Unmarshal([]byte(actual.actual), &listed)
+
+	t.Run("prints only JSON", func(t *testing.T) {
+		if err != nil || strings.Contains(actual.actual, "VERSION") {
+			t.Error(actual.actual)
+		}
+	})
+
+	t.Run("includes every contract", func(t *testing.T) {
+		if len(listed) != 2 || listed[0] != publishedContracts[0] || listed[1] != publishedContracts[1] {
+			t.Error(listed)
+		}
+	})
+	teardown()
+}
+
+func TestContractsListBrokerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "Participant not found"}`))
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+	}
+	actual := callContractsList(flags)
+	expected := "Error: Status code: 404 Not Found - Participant not found"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func callContractsDelete(argsAndFlags []string, stdin string) actualOut {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetIn(strings.NewReader(stdin))
+	RootCmd.SetArgs(append([]string{"contracts", "delete"}, argsAndFlags...))
+	RootCmd.Execute()
+	return actualOut{actual.String()}
+}
+
+func mockServerForDeleteContract(t *testing.T, status int) (*httptest.Server, *[]*http.Request) {
+	requests := []*http.Request{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		w.WriteHeader(status)
+		if status == http.StatusNotFound {
+			w.Write([]byte(`{"error": "contract not found"}`))
+		}
+	}))
+
+	return server, &requests
+}
+
+func TestContractsDeleteNoVersion(t *testing.T) {
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+		"--name", "service_1",
+		"--type", "consumer",
+	}
+	actual := callContractsDelete(flags, "")
+	expected := "Error: No --version was provided. This is a required flag."
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestContractsDeleteInvalidType(t *testing.T) {
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+		"--name", "service_1",
+		"--version", "1.0.0",
+		"--type", "spec",
+	}
+	actual := callContractsDelete(flags, "")
+	expected := "Error: --type required to be \"consumer\" or \"provider\", --type was spec"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestContractsDelete(t *testing.T) {
+	server, requests := mockServerForDeleteContract(t, http.StatusNoContent)
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "service_1",
+		"--version", "1.0.0",
+		"--type", "consumer",
+	}
+
+	t.Run("deletes after the user confirms", func(t *testing.T) {
+		actual := callContractsDelete(flags, "y\n")
+		expected := "Delete the consumer contract of service_1 at version 1.0.0 from the Signet broker? [y/N] " + colorGreen + "Deleted" + colorReset
+
+		actual.startsWith(expected, t)
+		if len(*requests) != 1 {
+			t.Fatal(len(*requests))
+		}
+
+		req := (*requests)[0]
+		query := req.URL.Query()
+		if req.Method != http.MethodDelete || req.URL.Path != "/api/contracts/versions" || query.Get("participant") != "service_1" || query.Get("version") != "1.0.0" || query.Get("type") != "consumer" {
+			t.Error(req.Method, req.URL)
+		}
+		teardown()
+	})
+
+	t.Run("deletes nothing when the user does not confirm", func(t *testing.T) {
+		*requests = nil
+		actual := callContractsDelete(flags, "\n")
+
+		if len(*requests) != 0 || !strings.Contains(actual.actual, "Cancelled - nothing was deleted") {
+			t.Error(actual.actual)
+		}
+		teardown()
+	})
+
+	t.Run("fails with a usage error when stdin has no answer", func(t *testing.T) {
+		*requests = nil
+		actual := callContractsDelete(flags, "")
+
+		if len(*requests) != 0 || !strings.Contains(actual.actual, "Pass --yes to skip the question.") {
+			t.Error(actual.actual)
+		}
+		teardown()
+
+		RootCmd.SetIn(strings.NewReader(""))
+		code := exitCodeOf(append([]string{"contracts", "delete"}, flags...))
+		teardown()
+		if code != exitUsage {
+			t.Errorf("expected exit code %d, got %d", exitUsage, code)
+		}
+	})
+
+	t.Run("does not ask with --yes", func(t *testing.T) {
+		*requests = nil
+		actual := callContractsDelete(append(flags, "--yes"), "")
+		expected := colorGreen + "Deleted" + colorReset + " - the consumer contract of service_1 at version 1.0.0 was deleted from the Signet broker"
+
+		actual.startsWith(expected, t)
+		if len(*requests) != 1 {
+			t.Error(len(*requests))
+		}
+		teardown()
+	})
+}
+
+func TestContractsDeleteNotFound(t *testing.T) {
+	server, _ := mockServerForDeleteContract(t, http.StatusNotFound)
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--version", "a1b2c3d",
+		"--type", "provider",
+		"--yes",
+	}
+	actual := callContractsDelete(flags, "")
+	expected := "Not found - the Signet broker has no API spec of user_service at version a1b2c3d, so there was nothing to delete"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
@@ -1,80 +1,409 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	client "github.com/signet-framework/signet-cli/client"
-	utils "github.com/signet-framework/signet-cli/utils"
+	signet "github.com/signet-framework/signet-cli/signet"
 )
 
+var output string
+var environmentTags []string
+var failOnUnverified bool
+var concurrency int
+var includePending bool
+var wait time.Duration
+var pollInterval time.Duration
+
+const defaultPollInterval = 10 * time.Second
+
 var deployGuardCmd = &cobra.Command{
-	Use:   "deploy-guard",
-	Short: "check if it is safe to deploy a service version to an environment",
+	Use:     "deploy-guard",
+	Aliases: []string{"can-i-deploy"},
+	Short:   "check if it is safe to deploy a service version to an environment",
 	Long: `check if it is safe to deploy a service version to an environment without breaking any consumers or being broken by an incompatible provider
 	
 	flags:
 
-	-n --name 					the name of the service
+	-n --name 					the name of the service (defaults to SIGNET_NAME)
+	
+	-v --version        the version of the service (if not passed, defaults to SIGNET_VERSION, and then to the git SHA of HEAD)
+	
+	-e --environment		the name of the environment that the service is deployed to, or a comma separated list of environments that must all be safe (ex. staging,production, defaults to SIGNET_ENVIRONMENT)
+	
+	--tag               check the contracts of the service that were published with any of these tags, instead of with --version (optional, repeatable)
+	
+	--environment-tag   check every registered environment with this key=value tag instead of a single --environment (optional, repeatable)
+	
+	--concurrency       how many environments are checked in parallel when more than one is checked (optional, defaults to 1)
 	
-	-v --version        the version of the service (defaults to git SHA of HEAD if no value is provided)
+	--fail-on-unverified  treat a contract that its provider has never verified as unsafe, instead of ignoring it (optional)
 	
-	-e --environment		the name of the environment that the service is deployed to (ex. production)
+	--include-pending   also check the version against contracts that have not been verified yet, ex. a consumer contract that was just published (optional)
+	
+	--wait              how long to keep checking while contracts are waiting to be verified, ex. 120s (optional, defaults to not waiting)
+	
+	--poll-interval     how long to wait between checks when --wait is passed (optional, defaults to 10s)
+	
+	-o --output         output format, either 'text', 'github' for GitHub Actions annotations, or 'json' (optional, defaults to 'text')
 	
 	-u --broker-url     the scheme, domain, and port where the Signet Broker is being hosted
 	
 	-i --ignore-config  ingore .signetrc.yaml file if it exists (optional)
 	`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		name = viper.GetString("deploy-guard.name")
+		brokerURL = resolveBrokerURL(cmd)
+		name = valueOrEnv(viper.GetString("deploy-guard.name"), nameEnvVar)
+		// checked before SIGNET_ENVIRONMENT is applied, which --environment-tag takes the place of
+		if len(splitEnvironments(environment)) != 0 && len(environmentTags) != 0 {
+			return usageError(errors.New("--environment and --environment-tag cannot be used together, --environment-tag selects the environments that are checked"))
+		}
+		environment = valueOrEnv(environment, environmentEnvVar)
+		output = viper.GetString("deploy-guard.output")
+		tags = viper.GetStringSlice("deploy-guard.tag")
+		wait = viper.GetDuration("deploy-guard.wait")
+		pollInterval = viper.GetDuration("deploy-guard.poll-interval")
 
 		if len(brokerURL) == 0 {
-			return errors.New("No --broker-url was provided. This is a required flag.")
+			return usageError(errors.New("No --broker-url was provided. This is a required flag."))
+		}
+
+		var err error
+		brokerURL, err = validateBrokerURL(brokerURL)
+		if err != nil {
+			return usageError(err)
 		}
 
 		if len(name) == 0 {
-			return errors.New("No --name was provided. This is a required flag.")
+			return usageError(errors.New("No --name was provided. This is a required flag."))
+		}
+
+		version, err = resolveVersion(cmd, version)
+		if err != nil {
+			return err
+		}
+
+		if len(splitEnvironments(environment)) == 0 && len(environmentTags) == 0 {
+			return usageError(errors.New("No --environment was provided. This is a required flag."))
+		}
+
+		if concurrency < 1 {
+			return usageError(errors.New("--concurrency must be at least 1, --concurrency was " + strconv.Itoa(concurrency)))
+		}
+
+		if output != "text" && output != "github" && output != "json" {
+			return usageError(errors.New("--output must be either \"text\", \"github\", or \"json\", --output was " + output))
+		}
+
+		if wait < 0 {
+			return usageError(errors.New("--wait must not be negative, --wait was " + wait.String()))
 		}
 
-		if version == "" || version == "auto" {
-			var err error
-			version, err = utils.SetVersionToGitSha(version)
+		if pollInterval <= 0 {
+			return usageError(errors.New("--poll-interval must be greater than 0, --poll-interval was " + pollInterval.String()))
+		}
+
+		environments := splitEnvironments(environment)
+		if len(environmentTags) != 0 {
+			environments, err = environmentsMatchingTags(cmd.Context(), brokerURL, environmentTags)
 			if err != nil {
 				return err
 			}
 		}
 
-		if len(environment) == 0 {
-			return errors.New("No --environment was provided. This is a required flag.")
-		}
-
-		ok, err := client.CheckDeployGuard(brokerURL, name, version, environment)
+		results, err := checkEnvironments(cmd.Context(), brokerURL, environments, concurrency)
 		if err != nil {
 			return err
 		}
 
-		if ok {
-			cmd.Println(colorGreen + "Safe To Deploy" + colorReset + " - version " + version + " of " + name + " is compatible with all other services in " + environment + " environment")
-		} else {
-			fmt.Fprintf(os.Stderr, colorRed+"Unsafe to Deploy"+colorReset+" - version "+version+" of "+name+" is incompatible with one or more services in "+environment+" environment\n")
-			os.Exit(1)
+		if wait > 0 {
+			results, err = waitForVerifications(cmd, brokerURL, environments, results)
+			if err != nil {
+				return err
+			}
+		}
+
+		safe := true
+		unsafeEnvironments := []string{}
+		for i, result := range results {
+			safe = safe && result.Status
+			if !result.Status {
+				unsafeEnvironments = append(unsafeEnvironments, environments[i])
+			}
+		}
+
+		for i, result := range results {
+			if output == "github" {
+				printGithubAnnotations(cmd, environments[i], result)
+			} else if output == "json" {
+				printDeployGuardJSON(cmd, environments[i], result)
+			} else if result.Status && safe {
+				cmd.Println(colorGreen + "Safe To Deploy" + colorReset + " - version " + version + " of " + name + " is compatible with all other services in " + environments[i] + " environment")
+			} else if result.Status {
+				// only part of a failed multi-environment check, so it is not reported as safe to deploy
+				cmd.PrintErrln("Compatible - version "+version+" of "+name+" is compatible with all other services in "+environments[i]+" environment")
+			} else {
+				cmd.PrintErrln(colorRed+"Unsafe to Deploy"+colorReset+" - version "+version+" of "+name+" is incompatible with one or more services in "+environments[i]+" environment")
+				if len(environments) == 1 && failOnUnverified {
+					for _, contract := range result.Unverified {
+						cmd.PrintErrf("    - the contract between consumer %s and provider %s has not been verified\n", contract.ConsumerName, contract.ProviderName)
+					}
+				}
+
+				// incompatibilities with pending contracts are marked by their title
+				for _, guardErr := range result.Errors {
+					cmd.PrintErrf("    - %s: %s\n", guardErrorTitle(guardErr), guardErr.Details)
+				}
+			}
+		}
+
+		if !safe {
+			return verificationFailed(cmd, "unsafe to deploy - version "+version+" of "+name+" is incompatible with one or more services in "+strings.Join(unsafeEnvironments, ", "))
 		}
 
 		return nil
 	},
 }
 
+// splits a comma separated --environment into the environments that are checked
+func splitEnvironments(environment string) []string {
+	environments := []string{}
+	for _, env := range strings.Split(environment, ",") {
+		if env = strings.TrimSpace(env); len(env) != 0 {
+			environments = append(environments, env)
+		}
+	}
+
+	return environments
+}
+
+/*
+runs deploy-guard against each environment, with up to concurrency checks
+in flight at once. Results are returned in the order of the environments,
+and the error for the earliest environment that failed is returned.
+*/
+func checkEnvironments(ctx context.Context, brokerURL string, environments []string, concurrency int) ([]client.DeployGuardResponse, error) {
+	results := make([]client.DeployGuardResponse, len(environments))
+	errs := make([]error, len(environments))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	for i, env := range environments {
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func(i int, env string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			results[i], errs[i] = signet.CheckDeployGuard(ctx, signet.DeployGuardOptions{
+				BrokerURL:        brokerURL,
+				Name:             name,
+				Version:          version,
+				Environment:      env,
+				Tags:             tags,
+				IncludePending:   includePending,
+				FailOnUnverified: failOnUnverified,
+			})
+		}(i, env)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+/*
+checks the environments again every --poll-interval while any of them has a
+contract that is waiting to be verified, until --wait has passed. The last
+results are returned, whether or not the verifications completed in time.
+*/
+func waitForVerifications(cmd *cobra.Command, brokerURL string, environments []string, results []client.DeployGuardResponse) ([]client.DeployGuardResponse, error) {
+	deadline := time.Now().Add(wait)
+
+	for awaitingVerification(results) {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			cmd.Println("Warning - contracts were still waiting to be verified after " + wait.String() + ", the result only reflects the verifications that have completed")
+			return results, nil
+		}
+
+		interval := pollInterval
+		if remaining < interval {
+			interval = remaining
+		}
+		cmd.Println("Waiting - contracts with version " + version + " of " + name + " have not been verified yet, checking again in " + interval.String())
+
+		select {
+		case <-cmd.Context().Done():
+			return nil, cmd.Context().Err()
+		case <-time.After(interval):
+		}
+
+		var err error
+		results, err = checkEnvironments(cmd.Context(), brokerURL, environments, concurrency)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// whether any of the results has a contract that its provider has not verified yet
+func awaitingVerification(results []client.DeployGuardResponse) bool {
+	for _, result := range results {
+		if len(result.Unverified) != 0 {
+			return true
+		}
+
+		for _, guardErr := range result.Errors {
+			if guardErr.Pending {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// the title of an incompatibility, marked when it is with a pending contract
+func guardErrorTitle(guardErr client.DeployGuardError) string {
+	if guardErr.Pending {
+		return guardErr.Title + " (pending)"
+	}
+	return guardErr.Title
+}
+
+/*
+resolves --environment-tag key=value filters to the names of the registered
+environments which carry every one of the tags
+*/
+func environmentsMatchingTags(ctx context.Context, brokerURL string, tagFilters []string) ([]string, error) {
+	wanted := map[string]string{}
+	for _, filter := range tagFilters {
+		key, value, found := strings.Cut(filter, "=")
+		if !found || len(key) == 0 {
+			return nil, usageError(errors.New("--environment-tag must be in the form key=value, --environment-tag was " + filter))
+		}
+		wanted[key] = value
+	}
+
+	registered, err := client.ListEnvironments(ctx, brokerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	environments := []string{}
+	for _, env := range registered {
+		matches := true
+		for key, value := range wanted {
+			if env.Tags[key] != value {
+				matches = false
+			}
+		}
+
+		if matches {
+			environments = append(environments, env.EnvironmentName)
+		}
+	}
+
+	if len(environments) == 0 {
+		return nil, errors.New("no registered environments have all of the tags " + strings.Join(tagFilters, ", "))
+	}
+
+	return environments, nil
+}
+
+/*
+prints the deploy-guard result as GitHub Actions workflow commands, which
+GitHub turns into check annotations when they are written to stdout
+*/
+func printGithubAnnotations(cmd *cobra.Command, environment string, result client.DeployGuardResponse) {
+	out := cmd.OutOrStdout()
+	subject := "version " + version + " of " + name + " in " + environment + " environment"
+
+	if result.Status {
+		fmt.Fprintln(out, "::notice title="+escapeGithubProperty("Safe To Deploy")+"::"+escapeGithubData(subject+" is compatible with all other services"))
+		return
+	}
+
+	for _, guardErr := range result.Errors {
+		fmt.Fprintln(out, "::error title="+escapeGithubProperty(guardErrorTitle(guardErr))+"::"+escapeGithubData(subject+": "+guardErr.Details))
+	}
+
+	if len(result.Errors) == 0 {
+		fmt.Fprintln(out, "::error title="+escapeGithubProperty("Unsafe to Deploy")+"::"+escapeGithubData(subject+" is incompatible with one or more services"))
+	}
+}
+
+// a deploy-guard result with the participant and environment that were checked
+type deployGuardResult struct {
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Environment string `json:"environment"`
+	client.DeployGuardResponse
+}
+
+/*
+prints the deploy-guard result for an environment as a JSON object on its
+own line of stdout, so one line is printed for each environment checked
+*/
+func printDeployGuardJSON(cmd *cobra.Command, environment string, result client.DeployGuardResponse) {
+	if result.Errors == nil {
+		result.Errors = []client.DeployGuardError{}
+	}
+
+	json.NewEncoder(cmd.OutOrStdout()).Encode(deployGuardResult{
+		Name:                name,
+		Version:             version,
+		Environment:         environment,
+		DeployGuardResponse: result,
+	})
+}
+
+func escapeGithubData(value string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(value)
+}
+
+func escapeGithubProperty(value string) string {
+	return strings.NewReplacer(":", "%3A", ",", "%2C").Replace(escapeGithubData(value))
+}
+
 func init() {
 	RootCmd.AddCommand(deployGuardCmd)
 
 	deployGuardCmd.Flags().StringVarP(&name, "name", "n", "", "The name of the service which was deployed")
 	deployGuardCmd.Flags().StringVarP(&version, "version", "v", "auto", "The version of the service which was deployed")
-	deployGuardCmd.Flags().StringVarP(&environment, "environment", "e", "", "The environment which the service was deployed to")
+	deployGuardCmd.Flags().StringVarP(&environment, "environment", "e", "", "The environment which the service was deployed to, or a comma separated list of environments")
+	deployGuardCmd.Flags().StringArrayVar(&tags, "tag", []string{}, "Check the contracts of the service that were published with any of these tags, instead of with --version (repeatable)")
+	deployGuardCmd.Flags().StringArrayVar(&environmentTags, "environment-tag", []string{}, "Check every registered environment with this key=value tag (repeatable)")
+	deployGuardCmd.Flags().IntVar(&concurrency, "concurrency", 1, "How many environments are checked in parallel when more than one is checked")
+	deployGuardCmd.Flags().BoolVar(&failOnUnverified, "fail-on-unverified", false, "Treat a contract that its provider has never verified as unsafe")
+	deployGuardCmd.Flags().BoolVar(&includePending, "include-pending", false, "Also check the version against contracts that have not been verified yet")
+	deployGuardCmd.Flags().DurationVar(&wait, "wait", 0, "How long to keep checking while contracts are waiting to be verified")
+	deployGuardCmd.Flags().DurationVar(&pollInterval, "poll-interval", defaultPollInterval, "How long to wait between checks when --wait is passed")
+	deployGuardCmd.Flags().StringVarP(&output, "output", "o", "text", "Output format, either \"text\", \"github\", or \"json\"")
 	deployGuardCmd.Flags().Lookup("version").NoOptDefVal = "auto"
 
 	viper.BindPFlag("deploy-guard.name", deployGuardCmd.Flags().Lookup("name"))
+	viper.BindPFlag("deploy-guard.output", deployGuardCmd.Flags().Lookup("output"))
+	viper.BindPFlag("deploy-guard.tag", deployGuardCmd.Flags().Lookup("tag"))
+	viper.BindPFlag("deploy-guard.wait", deployGuardCmd.Flags().Lookup("wait"))
+	viper.BindPFlag("deploy-guard.poll-interval", deployGuardCmd.Flags().Lookup("poll-interval"))
 }
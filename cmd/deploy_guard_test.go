@@ -2,12 +2,16 @@ package cmd
 
 import (
 	"bytes"
-	"io/ioutil"
-	"os"
-	"os/exec"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	client "github.com/signet-framework/signet-cli/client"
+	signet "github.com/signet-framework/signet-cli/signet"
 )
 
 /* ------------- helpers ------------- */
@@ -62,6 +66,49 @@ func TestDeployGuardNoEnvironment(t *testing.T) {
 	teardown()
 }
 
+func TestDeployGuardExitCodes(t *testing.T) {
+	t.Run("exits with 2 when a flag is missing", func(t *testing.T) {
+		exitCode := exitCodeOf([]string{"deploy-guard", "--broker-url=http://localhost:3000", "--environment", "production", "--version=version1"})
+		if exitCode != exitUsage {
+			t.Error(exitCode)
+		}
+		teardown()
+	})
+
+	t.Run("exits with 2 when a flag is invalid", func(t *testing.T) {
+		exitCode := exitCodeOf([]string{"deploy-guard", "--broker-url=http://localhost:3000", "--name", "user_service", "--environment", "production", "--version=version1", "--output", "xml"})
+		if exitCode != exitUsage {
+			t.Error(exitCode)
+		}
+		teardown()
+	})
+
+	t.Run("exits with 3 when the broker cannot be reached", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.Close()
+
+		exitCode := exitCodeOf([]string{"deploy-guard", "--broker-url", server.URL, "--name", "user_service", "--environment", "production", "--version=version1"})
+		if exitCode != exitBroker {
+			t.Error(exitCode)
+		}
+		teardown()
+	})
+
+	t.Run("exits with 3 when the broker responds with an error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": "database unavailable"}`))
+		}))
+		defer server.Close()
+
+		exitCode := exitCodeOf([]string{"deploy-guard", "--broker-url", server.URL, "--name", "user_service", "--environment", "production", "--version=version1"})
+		if exitCode != exitBroker {
+			t.Error(exitCode)
+		}
+		teardown()
+	})
+}
+
 func TestDeployGuardRequest(t *testing.T) {
 	respBody := client.DeployGuardResponse{
 		Status: true,
@@ -145,13 +192,7 @@ func TestDeployGuardRequestNoVersion(t *testing.T) {
 	teardown()
 }
 
-/*
-deploy-guard should exit with a exit code of 1 when it is unsafe to deploy
-
-in order to test this, the test executes itself in another process wherein it actually
-executes 'signet deploy-guard'. This should cause the test to fail becuase of the exit
-code. The test in this process asserts that the other process fails correctly.
-*/
+// deploy-guard should exit with a exit code of 1 when it is unsafe to deploy
 func TestDeployGuardRequestWhenUnsafe(t *testing.T) {
 	respBody := client.DeployGuardResponse{
 		Status: false,
@@ -160,6 +201,11 @@ func TestDeployGuardRequestWhenUnsafe(t *testing.T) {
 				Title:   "incompatible consumer",
 				Details: "service_1 is incompatible with this service as its provider",
 			},
+			client.DeployGuardError{
+				Title:   "incompatible consumer",
+				Details: "service_2 expects DELETE /users/{id}",
+				Pending: true,
+			},
 		},
 	}
 
@@ -172,33 +218,714 @@ func TestDeployGuardRequestWhenUnsafe(t *testing.T) {
 		"--version=version1",
 		"--environment", "production",
 	}
+	actual := callDeployGuard(flags)
+
+	t.Run("prints 'Unsafe To Deploy' to stderr", func(t *testing.T) {
+		expected := colorRed + "Unsafe to Deploy"
+		actual.startsWith(expected, t)
+	})
+
+	t.Run("prints every incompatibility, marking the pending ones", func(t *testing.T) {
+		expected := colorRed + "Unsafe to Deploy" + colorReset + " - version version1 of user_service is incompatible with one or more services in production environment\n" +
+			"    - incompatible consumer: service_1 is incompatible with this service as its provider\n" +
+			"    - incompatible consumer (pending): service_2 expects DELETE /users/{id}\n"
+		actual.startsWith(expected, t)
+	})
+
+	t.Run("fails with an error naming the environment", func(t *testing.T) {
+		expected := "Error: unsafe to deploy - version version1 of user_service is incompatible with one or more services in production"
+		if !strings.Contains(actual.actual, expected) {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+
+	t.Run("exits with exit code 1", func(t *testing.T) {
+		exitCode := exitCodeOf(append([]string{"deploy-guard"}, flags...))
+		if exitCode != exitFailed {
+			t.Error(exitCode)
+		}
+	})
+	teardown()
+}
+
+func TestDeployGuardInvalidOutput(t *testing.T) {
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+		"--name", "user_service",
+		"--version=version1",
+		"--environment", "production",
+		"--output", "xml",
+	}
+	actual := callDeployGuard(flags)
+	expected := "Error: --output must be either \"text\", \"github\", or \"json\", --output was xml"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestDeployGuardGithubOutput(t *testing.T) {
+	respBody := client.DeployGuardResponse{
+		Status: true,
+		Errors: []client.DeployGuardError{},
+	}
 
-	if os.Getenv("OKAY_TO_EXIT_1") == "true" {
-		_ = callDeployGuard(flags)
+	server, _ := mockServerForDeployGuardReq200OK(t, respBody)
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--version=version1",
+		"--environment", "production",
+		"--output", "github",
 	}
+	actual := callDeployGuard(flags)
+
+	t.Run("prints a GitHub notice workflow command", func(t *testing.T) {
+		expected := "::notice title=Safe To Deploy::version version1 of user_service in production environment"
+		actual.startsWith(expected, t)
+	})
+	teardown()
+}
 
-	cmd := exec.Command(os.Args[0], "-test.run=TestDeployGuardRequestWhenUnsafe")
-	cmd.Env = append(os.Environ(), "OKAY_TO_EXIT_1=true")
-	stdout, _ := cmd.StderrPipe()
-	if err := cmd.Start(); err != nil {
-		t.Error(err)
+func TestDeployGuardGithubAnnotationsWhenUnsafe(t *testing.T) {
+	result := client.DeployGuardResponse{
+		Status: false,
+		Errors: []client.DeployGuardError{
+			client.DeployGuardError{
+				Title:   "incompatible consumer: service_1",
+				Details: "service_1 is incompatible with this service as its provider",
+			},
+		},
 	}
 
-	outBytes, _ := ioutil.ReadAll(stdout)
-	actual := actualOut{actual: string(outBytes)}
+	actualBuf := new(bytes.Buffer)
+	deployGuardCmd.SetOut(actualBuf)
+	defer deployGuardCmd.SetOut(nil)
 
-	t.Run("prints 'Unsafe To Deploy' to stdout", func(t *testing.T) {
-		expected := colorRed + "Unsafe to Deploy"
+	name, version = "user_service", "version1"
+	printGithubAnnotations(deployGuardCmd, "production", result)
+	actual := actualOut{actualBuf.String()}
+
+	t.Run("prints an escaped error workflow command per error", func(t *testing.T) {
+		expected := "::error title=incompatible consumer%3A service_1::version version1 of user_service in production environment: service_1 is incompatible"
 		actual.startsWith(expected, t)
 	})
+	teardown()
+}
+
+func TestDeployGuardJSONOutput(t *testing.T) {
+	respBody := client.DeployGuardResponse{
+		Status: true,
+	}
+
+	server, _ := mockServerForDeployGuardReq200OK(t, respBody)
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--version=version1",
+		"--environment", "production",
+		"--output", "json",
+	}
+	actual := callDeployGuard(flags)
+
+	t.Run("prints the result with the participant and environment that were checked", func(t *testing.T) {
+		expected := `{"name":"user_service","version":"version1","environment":"production","status":true,"errors":[]}` + "\n"
+		if actual.actual != expected {
+			t.Errorf("expected %q, got %q", expected, actual.actual)
+		}
+	})
+	teardown()
+}
 
-	err := cmd.Wait()
-	t.Run("exits with exit code 1", func(t *testing.T) {
-		e, ok := err.(*exec.ExitError)
-		if !ok || e.Success() {
+func TestDeployGuardJSONOutputWhenUnsafe(t *testing.T) {
+	result := client.DeployGuardResponse{
+		Status: false,
+		Errors: []client.DeployGuardError{
+			client.DeployGuardError{
+				Title:   "incompatible consumer",
+				Details: "service_1 is incompatible with this service as its provider",
+			},
+		},
+	}
+
+	out := new(bytes.Buffer)
+	deployGuardCmd.SetOut(out)
+	name = "user_service"
+	version = "version1"
+	printDeployGuardJSON(deployGuardCmd, "production", result)
+	deployGuardCmd.SetOut(nil)
+
+	var printed deployGuardResult
+	err := json.Unmarshal(out.Bytes(), &printed)
+
+	t.Run("prints valid JSON without color codes", func(t *testing.T) {
+		if err != nil || strings.Contains(out.String(), "\033[") {
+			t.Error(out.String())
+		}
+	})
+
+	t.Run("includes the errors reported by the broker", func(t *testing.T) {
+		if printed.Status || len(printed.Errors) != 1 || printed.Errors[0].Title != "incompatible consumer" || printed.Environment != "production" {
+			t.Errorf("printed %+v", printed)
+		}
+	})
+	teardown()
+}
+
+func TestDeployGuardInvalidEnvironmentTag(t *testing.T) {
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+		"--name", "user_service",
+		"--version=version1",
+		"--environment-tag", "region",
+	}
+	actual := callDeployGuard(flags)
+	expected := "Error: --environment-tag must be in the form key=value"
+
+	actual.startsWith(expected, t)
+	teardown()
+
+	t.Run("exits with 2", func(t *testing.T) {
+		exitCode := exitCodeOf(append([]string{"deploy-guard"}, flags...))
+		if exitCode != exitUsage {
+			t.Error(exitCode)
+		}
+	})
+	teardown()
+}
+
+func TestDeployGuardEnvironmentWithEnvironmentTag(t *testing.T) {
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+		"--name", "user_service",
+		"--version=version1",
+		"--environment", "production",
+		"--environment-tag", "region=eu",
+	}
+	actual := callDeployGuard(flags)
+	expected := "Error: --environment and --environment-tag cannot be used together"
+
+	actual.startsWith(expected, t)
+	teardown()
+
+	t.Run("exits with 2", func(t *testing.T) {
+		exitCode := exitCodeOf(append([]string{"deploy-guard"}, flags...))
+		if exitCode != exitUsage {
+			t.Error(exitCode)
+		}
+	})
+	teardown()
+}
+
+func TestDeployGuardEnvironmentTags(t *testing.T) {
+	environments := []client.Environment{
+		{EnvironmentName: "eu-production", Tags: map[string]string{"region": "eu", "tier": "prod"}},
+		{EnvironmentName: "eu-staging", Tags: map[string]string{"region": "eu", "tier": "staging"}},
+		{EnvironmentName: "us-production", Tags: map[string]string{"region": "us", "tier": "prod"}},
+		{EnvironmentName: "eu-production-2", Tags: map[string]string{"region": "eu", "tier": "prod"}},
+	}
+	respBody := client.DeployGuardResponse{
+		Status: true,
+		Errors: []client.DeployGuardError{},
+	}
+
+	server, checked := mockServerForEnvironmentsAndDeployGuard(t, environments, respBody)
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--version=version1",
+		"--environment-tag", "region=eu",
+		"--environment-tag", "tier=prod",
+	}
+	actual := callDeployGuard(flags)
+
+	t.Run("checks every environment with all of the tags", func(t *testing.T) {
+		if len(*checked) != 2 || (*checked)[0] != "eu-production" || (*checked)[1] != "eu-production-2" {
+			t.Errorf("checked environments %v", *checked)
+		}
+	})
+
+	t.Run("prints 'Safe To Deploy' for each environment", func(t *testing.T) {
+		expected := colorGreen + "Safe To Deploy" + colorReset + " - version version1 of user_service is compatible with all other services in eu-production environment\n" +
+			colorGreen + "Safe To Deploy" + colorReset + " - version version1 of user_service is compatible with all other services in eu-production-2 environment"
+		actual.startsWith(expected, t)
+	})
+	teardown()
+}
+
+func TestDeployGuardEnvironmentTagsNoMatch(t *testing.T) {
+	environments := []client.Environment{
+		{EnvironmentName: "us-production", Tags: map[string]string{"region": "us", "tier": "prod"}},
+	}
+
+	server, _ := mockServerForEnvironmentsAndDeployGuard(t, environments, client.DeployGuardResponse{})
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--version=version1",
+		"--environment-tag", "region=eu",
+	}
+	actual := callDeployGuard(flags)
+	expected := "Error: no registered environments have all of the tags region=eu"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestDeployGuardFailUnverifiedContracts(t *testing.T) {
+	result := client.DeployGuardResponse{
+		Status: true,
+		Errors: []client.DeployGuardError{},
+		Unverified: []client.UnverifiedContract{
+			client.UnverifiedContract{ConsumerName: "service_1", ProviderName: "user_service"},
+		},
+	}
+
+	actual := signet.FailUnverifiedContracts(result)
+
+	t.Run("marks the result as unsafe", func(t *testing.T) {
+		if actual.Status {
 			t.Error()
 		}
 	})
 
+	t.Run("reports the unverified consumer/provider pair", func(t *testing.T) {
+		if len(actual.Errors) != 1 || actual.Errors[0].Title != "unverified contract: service_1 -> user_service" {
+			t.Errorf("errors were %v", actual.Errors)
+		}
+	})
+
+	t.Run("leaves a result without unverified contracts safe", func(t *testing.T) {
+		if !signet.FailUnverifiedContracts(client.DeployGuardResponse{Status: true}).Status {
+			t.Error()
+		}
+	})
+}
+
+func TestDeployGuardIncludePending(t *testing.T) {
+	respBody := client.DeployGuardResponse{
+		Status: true,
+		Errors: []client.DeployGuardError{},
+	}
+
+	server, req := mockServerForDeployGuardReq200OK(t, respBody)
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--version=version1",
+		"--environment", "production",
+	}
+
+	callDeployGuard(flags)
+	t.Run("leaves pending contracts out by default", func(t *testing.T) {
+		if req.URL.Query().Has("includePending") {
+			t.Error(req.URL.String())
+		}
+	})
+	teardown()
+
+	actualBuf := new(bytes.Buffer)
+	RootCmd.SetOut(actualBuf)
+	RootCmd.SetErr(actualBuf)
+	RootCmd.SetArgs(append([]string{"can-i-deploy", "--include-pending"}, flags...))
+	RootCmd.Execute()
+
+	t.Run("can-i-deploy asks the broker to include pending contracts", func(t *testing.T) {
+		if req.URL.Path != "/api/deploy" || req.URL.Query().Get("includePending") != "true" {
+			t.Error(req.URL.String())
+		}
+	})
+
+	t.Run("can-i-deploy prints the deploy-guard result", func(t *testing.T) {
+		actualOut{actualBuf.String()}.startsWith(colorGreen+"Safe To Deploy", t)
+	})
+	teardown()
+}
+
+func TestDeployGuardTags(t *testing.T) {
+	server, req := mockServerForDeployGuardReq200OK(t, client.DeployGuardResponse{Status: true, Errors: []client.DeployGuardError{}})
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--version=version1",
+		"--environment", "production",
+	}
+
+	callDeployGuard(flags)
+	t.Run("sends no tags by default", func(t *testing.T) {
+		if req.URL.Query().Has("tag") {
+			t.Error(req.URL.String())
+		}
+	})
+	teardown()
+
+	callDeployGuard(append(flags, "--tag", "main", "--tag", "release candidate"))
+	t.Run("asks the broker to match contracts with any of the tags", func(t *testing.T) {
+		sent := req.URL.Query()["tag"]
+		if len(sent) != 2 || sent[0] != "main" || sent[1] != "release candidate" {
+			t.Error(req.URL.String())
+		}
+	})
+	teardown()
+}
+
+func TestDeployGuardPendingIncompatibilities(t *testing.T) {
+	result := client.DeployGuardResponse{
+		Status: false,
+		Errors: []client.DeployGuardError{
+			client.DeployGuardError{
+				Title:   "incompatible consumer: service_1",
+				Details: "service_1 is incompatible with this service as its provider",
+				Pending: true,
+			},
+		},
+	}
+
+	actualBuf := new(bytes.Buffer)
+	deployGuardCmd.SetOut(actualBuf)
+	defer deployGuardCmd.SetOut(nil)
+
+	name, version = "user_service", "version1"
+	printGithubAnnotations(deployGuardCmd, "production", result)
+	printDeployGuardJSON(deployGuardCmd, "production", result)
+	lines := strings.Split(strings.TrimSpace(actualBuf.String()), "\n")
+
+	t.Run("marks pending incompatibilities in annotations", func(t *testing.T) {
+		expected := "::error title=incompatible consumer%3A service_1 (pending)::"
+		actualOut{lines[0]}.startsWith(expected, t)
+	})
+
+	t.Run("marks pending incompatibilities in JSON", func(t *testing.T) {
+		if !strings.Contains(lines[1], `"pending":true`) {
+			t.Error(lines[1])
+		}
+	})
+	teardown()
+}
+
+func TestDeployGuardConcurrency(t *testing.T) {
+	environments := []client.Environment{
+		{EnvironmentName: "eu-1", Tags: map[string]string{"region": "eu"}},
+		{EnvironmentName: "eu-2", Tags: map[string]string{"region": "eu"}},
+		{EnvironmentName: "eu-3", Tags: map[string]string{"region": "eu"}},
+	}
+
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/environments" {
+			json.NewEncoder(w).Encode(environments)
+			return
+		}
+
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+
+		// later environments finish first
+		delays := map[string]time.Duration{"eu-1": 60 * time.Millisecond, "eu-2": 30 * time.Millisecond}
+		time.Sleep(delays[r.URL.Query().Get("environmentName")])
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		json.NewEncoder(w).Encode(client.DeployGuardResponse{Status: true})
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--version=version1",
+		"--environment-tag", "region=eu",
+		"--concurrency", "3",
+	}
+	actual := callDeployGuard(flags)
+
+	t.Run("checks environments in parallel", func(t *testing.T) {
+		if maxInFlight < 2 {
+			t.Errorf("at most %d checks were in flight", maxInFlight)
+		}
+	})
+
+	t.Run("prints results in environment order", func(t *testing.T) {
+		first := strings.Index(actual.actual, "eu-1 environment")
+		second := strings.Index(actual.actual, "eu-2 environment")
+		third := strings.Index(actual.actual, "eu-3 environment")
+		if first == -1 || !(first < second && second < third) {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestDeployGuardEnvironmentList(t *testing.T) {
+	respBody := client.DeployGuardResponse{
+		Status: true,
+		Errors: []client.DeployGuardError{},
+	}
+
+	server, checked := mockServerForEnvironmentsAndDeployGuard(t, nil, respBody)
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--version=version1",
+		"--environment", "staging, production",
+	}
+	actual := callDeployGuard(flags)
+
+	t.Run("checks every environment in the list", func(t *testing.T) {
+		if len(*checked) != 2 || (*checked)[0] != "staging" || (*checked)[1] != "production" {
+			t.Errorf("checked environments %v", *checked)
+		}
+	})
+
+	t.Run("prints 'Safe To Deploy' for each environment", func(t *testing.T) {
+		expected := colorGreen + "Safe To Deploy" + colorReset + " - version version1 of user_service is compatible with all other services in staging environment\n" +
+			colorGreen + "Safe To Deploy" + colorReset + " - version version1 of user_service is compatible with all other services in production environment"
+		actual.startsWith(expected, t)
+	})
+	teardown()
+}
+
+func TestDeployGuardEnvironmentListWhenOneIsUnsafe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respBody := client.DeployGuardResponse{Status: true}
+		if r.URL.Query().Get("environmentName") == "production" {
+			respBody = client.DeployGuardResponse{
+				Status: false,
+				Errors: []client.DeployGuardError{
+					{Title: "incompatible consumer", Details: "service_1 expects GET /users/{id}"},
+				},
+			}
+		}
+		json.NewEncoder(w).Encode(respBody)
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--version=version1",
+		"--environment", "staging,production",
+	}
+
+	actual := callDeployGuard(flags)
+
+	t.Run("prints a breakdown for each environment", func(t *testing.T) {
+		expected := "Compatible - version version1 of user_service is compatible with all other services in staging environment\n" +
+			colorRed + "Unsafe to Deploy" + colorReset + " - version version1 of user_service is incompatible with one or more services in production environment\n" +
+			"    - incompatible consumer: service_1 expects GET /users/{id}\n"
+		actual.startsWith(expected, t)
+	})
+
+	t.Run("does not print 'Safe To Deploy'", func(t *testing.T) {
+		if strings.Contains(actual.actual, "Safe To Deploy") {
+			t.Error(actual.actual)
+		}
+	})
+
+	t.Run("fails with an error naming the unsafe environment", func(t *testing.T) {
+		if !strings.Contains(actual.actual, "Error: unsafe to deploy - version version1 of user_service is incompatible with one or more services in production\n") {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+
+	t.Run("exits with exit code 1", func(t *testing.T) {
+		exitCode := exitCodeOf(append([]string{"deploy-guard"}, flags...))
+		if exitCode != exitFailed {
+			t.Error(exitCode)
+		}
+	})
+	teardown()
+}
+
+func TestDeployGuardInvalidConcurrency(t *testing.T) {
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+		"--name", "user_service",
+		"--version=version1",
+		"--environment", "production",
+		"--concurrency", "0",
+	}
+	actual := callDeployGuard(flags)
+	expected := "Error: --concurrency must be at least 1"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+// responds to each deploy-guard request with the next response, repeating the last one
+func mockServerForDeployGuardSequence(t *testing.T, responses []client.DeployGuardResponse) (*httptest.Server, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respBody := responses[len(responses)-1]
+		if requests < len(responses) {
+			respBody = responses[requests]
+		}
+		requests++
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		err := json.NewEncoder(w).Encode(respBody)
+		if err != nil {
+			t.Error("Failed to write mock response body")
+		}
+	}))
+
+	return server, &requests
+}
+
+func TestDeployGuardWait(t *testing.T) {
+	pending := client.DeployGuardResponse{
+		Status: false,
+		Errors: []client.DeployGuardError{
+			client.DeployGuardError{
+				Title:   "incompatible consumer: service_1",
+				Details: "the contract with service_1 has not been verified",
+				Pending: true,
+			},
+		},
+	}
+	unverified := client.DeployGuardResponse{
+		Status:     true,
+		Errors:     []client.DeployGuardError{},
+		Unverified: []client.UnverifiedContract{{ConsumerName: "service_1", ProviderName: "user_service"}},
+	}
+	verified := client.DeployGuardResponse{
+		Status: true,
+		Errors: []client.DeployGuardError{},
+	}
+
+	flags := []string{
+		"--name", "user_service",
+		"--version=version1",
+		"--environment", "production",
+		"--include-pending",
+		"--wait", "5s",
+		"--poll-interval", "10ms",
+	}
+
+	t.Run("checks again until the contracts are verified", func(t *testing.T) {
+		server, requests := mockServerForDeployGuardSequence(t, []client.DeployGuardResponse{pending, unverified, verified})
+		defer server.Close()
+
+		actual := callDeployGuard(append(flags, "--broker-url", server.URL))
+		waiting := "Waiting - contracts with version version1 of user_service have not been verified yet, checking again in 10ms\n"
+		expected := waiting + waiting + colorGreen + "Safe To Deploy"
+
+		actual.startsWith(expected, t)
+		if *requests != 3 {
+			t.Error(*requests)
+		}
+		teardown()
+	})
+
+	t.Run("does not check again when nothing is waiting to be verified", func(t *testing.T) {
+		server, requests := mockServerForDeployGuardSequence(t, []client.DeployGuardResponse{verified})
+		defer server.Close()
+
+		actual := callDeployGuard(append(flags, "--broker-url", server.URL))
+		actual.startsWith(colorGreen+"Safe To Deploy", t)
+		if *requests != 1 {
+			t.Error(*requests)
+		}
+		teardown()
+	})
+
+	t.Run("stops checking once --wait has passed", func(t *testing.T) {
+		server, requests := mockServerForDeployGuardSequence(t, []client.DeployGuardResponse{unverified})
+		defer server.Close()
+
+		waitFlags := append([]string{}, flags[:len(flags)-4]...)
+		waitFlags = append(waitFlags, "--wait", "50ms", "--poll-interval", "20ms", "--broker-url", server.URL)
+		actual := callDeployGuard(waitFlags)
+
+		if !strings.Contains(actual.actual, "Warning - contracts were still waiting to be verified after 50ms") {
+			t.Error(actual.actual)
+		}
+		if !strings.Contains(actual.actual, colorGreen+"Safe To Deploy") {
+			t.Error(actual.actual)
+		}
+		if *requests < 2 {
+			t.Error(*requests)
+		}
+		teardown()
+	})
+}
+
+func TestDeployGuardInvalidWait(t *testing.T) {
+	t.Run("--wait must not be negative", func(t *testing.T) {
+		flags := []string{
+			"--broker-url=http://localhost:3000",
+			"--name", "user_service",
+			"--version=version1",
+			"--environment", "production",
+			"--wait", "-1s",
+		}
+		actual := callDeployGuard(flags)
+		expected := "Error: --wait must not be negative, --wait was -1s"
+
+		actual.startsWith(expected, t)
+		teardown()
+	})
+
+	t.Run("--poll-interval must be greater than 0", func(t *testing.T) {
+		flags := []string{
+			"--broker-url=http://localhost:3000",
+			"--name", "user_service",
+			"--version=version1",
+			"--environment", "production",
+			"--wait", "10s",
+			"--poll-interval", "0s",
+		}
+		actual := callDeployGuard(flags)
+		expected := "Error: --poll-interval must be greater than 0, --poll-interval was 0s"
+
+		actual.startsWith(expected, t)
+		teardown()
+	})
+}
+
+func TestDeployGuardFromEnvironmentVariables(t *testing.T) {
+	respBody := client.DeployGuardResponse{
+		Status: true,
+		Errors: []client.DeployGuardError{},
+	}
+	server, req := mockServerForDeployGuardReq200OK(t, respBody)
+	defer server.Close()
+
+	t.Setenv("SIGNET_NAME", "user_service")
+	t.Setenv("SIGNET_VERSION", "1.2.0")
+	t.Setenv("SIGNET_ENVIRONMENT", "staging")
+
+	actual := callDeployGuard([]string{"--broker-url", server.URL})
+	expected := colorGreen + "Safe To Deploy" + colorReset + " - version 1.2.0 of user_service is compatible with all other services in staging environment"
+
+	actual.startsWith(expected, t)
+	query := req.URL.Query()
+	if query.Get("participantName") != "user_service" || query.Get("environmentName") != "staging" {
+		t.Error(req.URL.String())
+	}
 	teardown()
 }
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	client "github.com/signet-framework/signet-cli/client"
+)
+
+var deploymentsCmd = &cobra.Command{
+	Use:   "deployments",
+	Short: "inspect which service versions the broker records as deployed",
+	Long: `inspect which service versions the Signet broker records as deployed to each environment
+
+	subcommands:
+
+	list                list the service versions deployed to an environment
+	`,
+}
+
+var deploymentsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the service versions deployed to an environment",
+	Long: `list the service versions that the Signet broker records as deployed to an environment, which are the versions deploy-guard checks against
+
+	flags:
+
+	-e --environment    the name of the deployment environment (ex. production)
+
+	-o --output         output format, either 'text' for a table, or 'json' (optional, defaults to 'text')
+
+	-u --broker-url     the scheme, domain, and port where the Signet Broker is being hosted
+
+	-i --ignore-config  ingore .signetrc.yaml file if it exists (optional)
+	`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		brokerURL = resolveBrokerURL(cmd)
+		environment = viper.GetString("deployments.environment")
+		output = viper.GetString("deployments.output")
+
+		if len(brokerURL) == 0 {
+			return usageError(errors.New("No --broker-url was provided. This is a required flag."))
+		}
+
+		if len(environment) == 0 {
+			return usageError(errors.New("No --environment was provided. This is a required flag."))
+		}
+
+		if output != "text" && output != "json" {
+			return usageError(errors.New("--output must be either \"text\" or \"json\", --output was " + output))
+		}
+
+		deployments, err := client.GetDeployments(cmd.Context(), brokerURL, environment)
+		if err != nil {
+			return err
+		}
+
+		if output == "json" {
+			if deployments == nil {
+				deployments = []client.Deployment{}
+			}
+			return json.NewEncoder(cmd.OutOrStdout()).Encode(deployments)
+		}
+
+		if len(deployments) == 0 {
+			cmd.Println("No service versions are deployed to " + environment + " environment")
+			return nil
+		}
+
+		table := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(table, "SERVICE\tVERSION")
+		for _, deployment := range deployments {
+			fmt.Fprintf(table, "%s\t%s\n", deployment.ParticipantName, deployment.ParticipantVersion)
+		}
+		return table.Flush()
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(deploymentsCmd)
+	deploymentsCmd.AddCommand(deploymentsListCmd)
+
+	deploymentsListCmd.Flags().StringVarP(&environment, "environment", "e", "", "The name of the deployment environment (ex. production)")
+	deploymentsListCmd.Flags().StringVarP(&output, "output", "o", "text", "Output format, either \"text\" or \"json\"")
+
+	viper.BindPFlag("deployments.environment", deploymentsListCmd.Flags().Lookup("environment"))
+	viper.BindPFlag("deployments.output", deploymentsListCmd.Flags().Lookup("output"))
+}
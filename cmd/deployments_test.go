@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	client "github.com/signet-framework/signet-cli/client"
+)
+
+/* ------------- helpers ------------- */
+
+func callDeploymentsList(argsAndFlags []string) actualOut {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs(append([]string{"deployments", "list"}, argsAndFlags...))
+	RootCmd.Execute()
+	return actualOut{actual.String()}
+}
+
+func mockServerForGetDeployments(t *testing.T, deployments []client.Deployment) (*httptest.Server, *http.Request) {
+	var req http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = *r
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		err := json.NewEncoder(w).Encode(deployments)
+		if err != nil {
+			t.Error("Failed to write mock response body")
+		}
+	}))
+
+	return server, &req
+}
+
+var productionDeployments = []client.Deployment{
+	{ParticipantName: "user_service", ParticipantVersion: "a1b2c3d", EnvironmentName: "production"},
+	{ParticipantName: "orders_service", ParticipantVersion: "1.12.0", EnvironmentName: "production"},
+}
+
+/* ------------- tests ------------- */
+
+func TestDeploymentsListNoEnvironment(t *testing.T) {
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+	}
+	actual := callDeploymentsList(flags)
+	expected := "Error: No --environment was provided. This is a required flag."
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestDeploymentsList(t *testing.T) {
+	server, req := mockServerForGetDeployments(t, productionDeployments)
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--environment", "production",
+	}
+	actual := callDeploymentsList(flags)
+
+	t.Run("requests the deployments of the environment", func(t *testing.T) {
+		if req.Method != "GET" || req.URL.Path != "/api/participants" || req.URL.Query().Get("environmentName") != "production" {
+			t.Error(req.URL.String())
+		}
+	})
+
+	t.Run("prints a table of the deployed versions", func(t *testing.T) {
+		expected := "SERVICE         VERSION\n" +
+			"user_service    a1b2c3d\n" +
+			"orders_service  1.12.0\n"
+		if actual.actual != expected {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestDeploymentsListNoneDeployed(t *testing.T) {
+	server, _ := mockServerForGetDeployments(t, []client.Deployment{})
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--environment", "staging",
+	}
+	actual := callDeploymentsList(flags)
+	expected := "No service versions are deployed to staging environment"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestDeploymentsListJSONOutput(t *testing.T) {
+	server, _ := mockServerForGetDeployments(t, productionDeployments)
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--environment", "production",
+		"--output", "json",
+	}
+	actual := callDeploymentsList(flags)
+
+	var listed []client.Deployment
+	err := json.Unmarshal([]byte(actual.actual), &listed)
+	if err != nil || len(listed) != 2 || listed[0] != productionDeployments[0] || listed[1] != productionDeployments[1] {
+		t.Error(actual.actual)
+	}
+	teardown()
+}
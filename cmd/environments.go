@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	client "github.com/signet-framework/signet-cli/client"
+)
+
+var environmentsCmd = &cobra.Command{
+	Use:   "environments",
+	Short: "inspect the deployment environments registered with the broker",
+	Long: `inspect the deployment environments registered with the Signet broker
+
+	subcommands:
+
+	list                list the registered deployment environments
+	`,
+}
+
+var environmentsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the registered deployment environments",
+	Long: `list the names of the deployment environments registered with the Signet broker, one per line
+
+	flags:
+
+	-o --output         output format, either 'text' for one name per line, or 'json' (optional, defaults to 'text')
+
+	-u --broker-url     the scheme, domain, and port where the Signet Broker is being hosted
+
+	-i --ignore-config  ingore .signetrc.yaml file if it exists (optional)
+	`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		brokerURL = resolveBrokerURL(cmd)
+		output = viper.GetString("environments.output")
+
+		if len(brokerURL) == 0 {
+			return usageError(errors.New("No --broker-url was provided. This is a required flag."))
+		}
+
+		if output != "text" && output != "json" {
+			return usageError(errors.New("--output must be either \"text\" or \"json\", --output was " + output))
+		}
+
+		environments, err := client.ListEnvironments(cmd.Context(), brokerURL)
+		if err != nil {
+			return err
+		}
+
+		if output == "json" {
+			if environments == nil {
+				environments = []client.Environment{}
+			}
+			return json.NewEncoder(cmd.OutOrStdout()).Encode(environments)
+		}
+
+		if len(environments) == 0 {
+			cmd.Println("No environments are registered with the Signet broker")
+			return nil
+		}
+
+		for _, env := range environments {
+			fmt.Fprintln(cmd.OutOrStdout(), env.EnvironmentName)
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(environmentsCmd)
+	environmentsCmd.AddCommand(environmentsListCmd)
+
+	environmentsListCmd.Flags().StringVarP(&output, "output", "o", "text", "Output format, either \"text\" or \"json\"")
+
+	viper.BindPFlag("environments.output", environmentsListCmd.Flags().Lookup("output"))
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	client "github.com/signet-framework/signet-cli/client"
+)
+
+/* ------------- helpers ------------- */
+
+func callEnvironmentsList(argsAndFlags []string) actualOut {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs(append([]string{"environments", "list"}, argsAndFlags...))
+	RootCmd.Execute()
+	return actualOut{actual.String()}
+}
+
+/*
+serves the registered environments, and records the name of each environment
+that is registered
+*/
+func mockServerForEnvironments(t *testing.T, environments []client.Environment) (*httptest.Server, *[]string) {
+	registered := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var env client.Environment
+			err := json.NewDecoder(r.Body).Decode(&env)
+			if err != nil {
+				t.Error(err)
+			}
+			registered = append(registered, env.EnvironmentName)
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		err := json.NewEncoder(w).Encode(environments)
+		if err != nil {
+			t.Error("Failed to write mock response body")
+		}
+	}))
+
+	return server, &registered
+}
+
+var registeredEnvironments = []client.Environment{
+	{EnvironmentName: "production", Tags: map[string]string{"region": "us-east-1"}},
+	{EnvironmentName: "staging"},
+}
+
+/* ------------- tests ------------- */
+
+func TestEnvironmentsListNoBrokerURL(t *testing.T) {
+	code := exitCodeOf([]string{"environments", "list"})
+	if code != exitUsage {
+		t.Errorf("expected exit code %d, got %d", exitUsage, code)
+	}
+	teardown()
+}
+
+func TestEnvironmentsList(t *testing.T) {
+	server, _ := mockServerForEnvironments(t, registeredEnvironments)
+	defer server.Close()
+
+	actual := callEnvironmentsList([]string{"--broker-url", server.URL})
+	expected := "production\nstaging\n"
+	if actual.actual != expected {
+		t.Error(actual.actual)
+	}
+	teardown()
+}
+
+func TestEnvironmentsListNoneRegistered(t *testing.T) {
+	server, _ := mockServerForEnvironments(t, []client.Environment{})
+	defer server.Close()
+
+	actual := callEnvironmentsList([]string{"--broker-url", server.URL})
+	expected := "No environments are registered with the Signet broker"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestEnvironmentsListJSONOutput(t *testing.T) {
+	server, _ := mockServerForEnvironments(t, registeredEnvironments)
+	defer server.Close()
+
+	actual := callEnvironmentsList([]string{"--broker-url", server.URL, "--output", "json"})
+
+	var listed []client.Environment
+	err := json.Unmarshal([]byte(actual.actual), &listed)
+	if err != nil || len(listed) != 2 || listed[0].EnvironmentName != "production" || listed[0].Tags["region"] != "us-east-1" || listed[1].EnvironmentName != "staging" {
+		t.Error(actual.actual)
+	}
+	teardown()
+}
+
+func TestEnvironmentsListInvalidOutput(t *testing.T) {
+	actual := callEnvironmentsList([]string{"--broker-url=http://localhost:3000", "--output", "yaml"})
+	expected := "Error: --output must be either \"text\" or \"json\", --output was yaml"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+
+	"github.com/spf13/cobra"
+
+	client "github.com/signet-framework/signet-cli/client"
+)
+
+// the exit codes of signet, so scripts can tell why a command failed
+const (
+	exitSuccess = 0
+	// deploy-guard found the version unsafe to deploy, or test failed verification
+	exitFailed = 1
+	// a flag was missing or invalid
+	exitUsage = 2
+	// the broker responded with an error, or could not be reached
+	exitBroker = 3
+	// signet was interrupted (ex. Ctrl+C) while waiting on the broker, the shell convention for SIGINT
+	exitInterrupted = 130
+)
+
+/*
+a command failure as it is reported with --error-format json. The exit code
+is not part of the JSON object, it is the code that signet exits with.
+*/
+type cliError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   string `json:"details,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+	ExitCode  int    `json:"-"`
+}
+
+func (e *cliError) Error() string {
+	return e.Message
+}
+
+func usageError(err error) error {
+	return &cliError{Code: "usage_error", Message: err.Error(), ExitCode: exitUsage}
+}
+
+// a check that ran to completion and failed, rather than a command that could not run
+func failedError(message string) error {
+	return &cliError{Code: "verification_failed", Message: message, ExitCode: exitFailed}
+}
+
+// maps any error returned by a command to a cliError
+func toCLIError(err error) *cliError {
+	var cliErr *cliError
+	if errors.As(err, &cliErr) {
+		return cliErr
+	}
+
+	var brokerErr *client.BrokerError
+	if errors.As(err, &brokerErr) {
+		return &cliError{
+			Code:      "broker_error",
+			Message:   brokerErr.Message,
+			Details:   brokerErr.Status,
+			RequestID: brokerErr.RequestID,
+			ExitCode:  exitBroker,
+		}
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return &cliError{Code: "interrupted", Message: err.Error(), ExitCode: exitInterrupted}
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return &cliError{Code: "network_error", Message: urlErr.Error(), ExitCode: exitBroker}
+	}
+
+	return &cliError{Code: "error", Message: err.Error(), ExitCode: exitFailed}
+}
+
+/*
+reports a failed command in the --error-format, and returns the exit code.
+In the default text format, cobra has already printed the error.
+*/
+func handleError(cmd *cobra.Command, err error) int {
+	cliErr := toCLIError(err)
+
+	if errorFormat == "json" {
+		json.NewEncoder(cmd.ErrOrStderr()).Encode(cliErr)
+	}
+
+	return cliErr.ExitCode
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+)
+
+const configFileName = ".signetrc.yaml"
+
+var force bool
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "write a .signetrc.yaml for a service",
+	Long: `write a commented .signetrc.yaml to the current directory, with the keys that proxy, publish, test, deploy-guard, and update-deployment read. Any of --broker-url, --name, and --environment that is not passed is asked for on stdin.
+
+	flags:
+
+	-n --name           the name of the service
+
+	-e --environment    the environment the service is deployed to (optional)
+
+	--force             overwrite an existing .signetrc.yaml (optional)
+
+	-u --broker-url     the scheme, domain, and port where the Signet Broker is being hosted
+	`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		brokerURL = valueOrEnv(viper.GetString("broker-url"), brokerURLEnvVar)
+		name = viper.GetString("init.name")
+		environment = viper.GetString("init.environment")
+		force = viper.GetBool("init.force")
+
+		if _, err := os.Stat(configFileName); err == nil && !force {
+			return errors.New(configFileName + " already exists. Pass --force to overwrite it.")
+		}
+
+		answers := bufio.NewReader(cmd.InOrStdin())
+		if len(brokerURL) == 0 {
+			brokerURL = ask(cmd, answers, "Signet broker URL (ex. http://localhost:3000): ")
+		}
+
+		if len(name) == 0 {
+			name = ask(cmd, answers, "Service name: ")
+		}
+
+		if len(environment) == 0 {
+			environment = ask(cmd, answers, "Default deployment environment (optional, ex. production): ")
+		}
+
+		if len(brokerURL) == 0 {
+			return errors.New("No --broker-url was provided. This is a required flag.")
+		}
+
+		if len(name) == 0 {
+			return errors.New("No --name was provided. This is a required flag.")
+		}
+
+		err := os.WriteFile(configFileName, []byte(signetrc(brokerURL, name, environment)), 0644)
+		if err != nil {
+			return errors.New("failed to write " + configFileName + ": " + err.Error())
+		}
+
+		cmd.Println(colorGreen + "Created" + colorReset + " - " + configFileName + " was written for " + name + ". Edit it to set the remaining keys.")
+		return nil
+	},
+}
+
+// prints a question and reads the answer from stdin, an empty answer when stdin has ended
+func ask(cmd *cobra.Command, answers *bufio.Reader, question string) string {
+	cmd.Print(question)
+	answer, _ := answers.ReadString('\n')
+	return strings.TrimSpace(answer)
+}
+
+// the contents of a .signetrc.yaml for a service, keys that are not known yet are commented out
+func signetrc(brokerURL, name, environment string) string {
+	contractPath := "./contracts/" + name + ".json"
+
+	environmentKey := "environment: " + yamlString(environment)
+	if len(environment) == 0 {
+		environmentKey = "# environment: production"
+	}
+
+	return fmt.Sprintf(`# settings for signet, flags passed on the command line take precedence over them
+
+# the scheme, domain, and port where the Signet broker is being hosted
+broker-url: %[1]s
+
+# signet proxy records the requests this service makes to a provider stub as a consumer contract
+proxy:
+  name: %[2]s
+  # the file the consumer contract is written to
+  path: %[3]s
+  # the URL of the running provider stub or mock, and the name of the provider it represents
+  # target: http://localhost:3002
+  # provider-name: user_service
+
+# signet publish publishes the consumer contract written by signet proxy
+publish:
+  type: consumer
+  path: %[3]s
+
+# signet test verifies this service against its API spec, when it is a provider
+test:
+  name: %[2]s
+  # provider-url: http://localhost:3002
+
+# signet deploy-guard checks that this service is safe to deploy
+deploy-guard:
+  name: %[2]s
+
+# signet update-deployment records the environment this service was deployed to
+update-deployment:
+  name: %[2]s
+  %[4]s
+`, yamlString(brokerURL), yamlString(name), yamlString(contractPath), environmentKey)
+}
+
+// a string as a YAML scalar, quoted only when it needs to be
+func yamlString(value string) string {
+	valueBytes, err := yaml.Marshal(value)
+	if err != nil {
+		return value
+	}
+	return strings.TrimSuffix(string(valueBytes), "\n")
+}
+
+func init() {
+	RootCmd.AddCommand(initCmd)
+
+	initCmd.Flags().StringVarP(&name, "name", "n", "", "The name of the service")
+	initCmd.Flags().StringVarP(&environment, "environment", "e", "", "The environment the service is deployed to")
+	initCmd.Flags().BoolVar(&force, "force", false, "Overwrite an existing .signetrc.yaml")
+
+	viper.BindPFlag("init.name", initCmd.Flags().Lookup("name"))
+	viper.BindPFlag("init.environment", initCmd.Flags().Lookup("environment"))
+	viper.BindPFlag("init.force", initCmd.Flags().Lookup("force"))
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+/* ------------- helpers ------------- */
+
+func callInit(argsAndFlags []string, stdin string) actualOut {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetIn(strings.NewReader(stdin))
+	RootCmd.SetArgs(append([]string{"init"}, argsAndFlags...))
+	RootCmd.Execute()
+	return actualOut{actual.String()}
+}
+
+func readSignetrc(t *testing.T) map[string]interface{} {
+	configBytes, err := os.ReadFile(configFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := map[string]interface{}{}
+	err = yaml.Unmarshal(configBytes, &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+/* ------------- tests ------------- */
+
+func TestInitFlags(t *testing.T) {
+	chdirOutsideGitRepo(t)
+
+	flags := []string{
+		"--broker-url", "http://localhost:3000",
+		"--name", "service_1",
+		"--environment", "production",
+	}
+	actual := callInit(flags, "")
+	expected := colorGreen + "Created" + colorReset + " - .signetrc.yaml was written for service_1."
+
+	actual.startsWith(expected, t)
+
+	config := readSignetrc(t)
+	if config["broker-url"] != "http://localhost:3000" {
+		t.Error(config["broker-url"])
+	}
+
+	proxy := config["proxy"].(map[interface{}]interface{})
+	if proxy["name"] != "service_1" || proxy["path"] != "./contracts/service_1.json" {
+		t.Error(proxy)
+	}
+
+	publish := config["publish"].(map[interface{}]interface{})
+	if publish["type"] != "consumer" || publish["path"] != "./contracts/service_1.json" {
+		t.Error(publish)
+	}
+
+	updateDeployment := config["update-deployment"].(map[interface{}]interface{})
+	if updateDeployment["name"] != "service_1" || updateDeployment["environment"] != "production" {
+		t.Error(updateDeployment)
+	}
+	teardown()
+}
+
+func TestInitPrompts(t *testing.T) {
+	chdirOutsideGitRepo(t)
+
+	actual := callInit([]string{}, "http://localhost:3000\nservice_1\n\n")
+	expected := "Signet broker URL (ex. http://localhost:3000): Service name: Default deployment environment (optional, ex. production): " + colorGreen + "Created" + colorReset
+
+	actual.startsWith(expected, t)
+
+	config := readSignetrc(t)
+	if config["broker-url"] != "http://localhost:3000" {
+		t.Error(config["broker-url"])
+	}
+
+	updateDeployment := config["update-deployment"].(map[interface{}]interface{})
+	if _, ok := updateDeployment["environment"]; ok {
+		t.Error(updateDeployment)
+	}
+	teardown()
+}
+
+func TestInitNoName(t *testing.T) {
+	chdirOutsideGitRepo(t)
+
+	actual := callInit([]string{"--broker-url", "http://localhost:3000"}, "")
+	expected := "Service name: Default deployment environment (optional, ex. production): Error: No --name was provided. This is a required flag."
+
+	actual.startsWith(expected, t)
+	if _, err := os.Stat(configFileName); err == nil {
+		t.Error(".signetrc.yaml was written without a name")
+	}
+	teardown()
+}
+
+func TestInitQuotesValues(t *testing.T) {
+	chdirOutsideGitRepo(t)
+
+	flags := []string{
+		"--broker-url", "http://localhost:3000",
+		"--name", "service: 1",
+		"--environment", "yes",
+	}
+	callInit(flags, "")
+
+	config := readSignetrc(t)
+	updateDeployment := config["update-deployment"].(map[interface{}]interface{})
+	if updateDeployment["name"] != "service: 1" || updateDeployment["environment"] != "yes" {
+		t.Error(updateDeployment)
+	}
+	teardown()
+}
+
+func TestInitExistingFile(t *testing.T) {
+	chdirOutsideGitRepo(t)
+	os.WriteFile(configFileName, []byte("broker-url: http://localhost:3001\n"), 0644)
+
+	flags := []string{
+		"--broker-url", "http://localhost:3000",
+		"--name", "service_1",
+		"--environment", "production",
+	}
+
+	t.Run("refuses to overwrite it", func(t *testing.T) {
+		actual := callInit(flags, "")
+		expected := "Error: .signetrc.yaml already exists. Pass --force to overwrite it."
+
+		actual.startsWith(expected, t)
+
+		config := readSignetrc(t)
+		if config["broker-url"] != "http://localhost:3001" {
+			t.Error(config["broker-url"])
+		}
+		teardown()
+	})
+
+	t.Run("overwrites it with --force", func(t *testing.T) {
+		actual := callInit(append(flags, "--force"), "")
+		expected := colorGreen + "Created" + colorReset
+
+		actual.startsWith(expected, t)
+
+		config := readSignetrc(t)
+		if config["broker-url"] != "http://localhost:3000" {
+			t.Error(config["broker-url"])
+		}
+		teardown()
+	})
+}
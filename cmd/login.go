@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	client "github.com/signet-framework/signet-cli/client"
+)
+
+var token string
+
+var loginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "store a token for a Signet broker that requires authentication",
+	Long: `store a token for a Signet broker that requires authentication. The token is written to ~/.signet/credentials for the broker URL, and every later request to that broker is sent with it as a bearer token. The SIGNET_TOKEN environment variable takes precedence over stored tokens.
+
+	flags:
+
+	--token             the bearer token issued for the broker
+
+	-u --broker-url     the scheme, domain, and port where the Signet Broker is being hosted
+
+	-i --ignore-config  ingore .signetrc.yaml file if it exists (optional)
+	`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		brokerURL = resolveBrokerURL(cmd)
+		token = viper.GetString("login.token")
+
+		if len(brokerURL) == 0 {
+			return errors.New("No --broker-url was provided. This is a required flag.")
+		}
+
+		if len(token) == 0 {
+			return errors.New("No --token was provided. This is a required flag.")
+		}
+
+		credentialsPath, err := client.SaveToken(brokerURL, token)
+		if err != nil {
+			return errors.New("failed to store the token: " + err.Error())
+		}
+
+		cmd.Println(colorGreen + "Logged in" + colorReset + " - the token for " + brokerURL + " was stored in " + credentialsPath)
+
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(loginCmd)
+
+	loginCmd.Flags().StringVar(&token, "token", "", "The bearer token issued for the broker")
+
+	viper.BindPFlag("login.token", loginCmd.Flags().Lookup("token"))
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+/* ------------- helpers ------------- */
+
+func callLogin(argsAndFlags []string) actualOut {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs(append([]string{"login"}, argsAndFlags...))
+	RootCmd.Execute()
+	return actualOut{actual.String()}
+}
+
+/*
+returns a mock broker which responds 401 unless the request has the bearer
+token, and a pointer to the Authorization header of the last request
+*/
+func mockServerRequiringToken(token string) (*httptest.Server, *string) {
+	var authorization string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		if authorization != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "Unauthorized"}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	return server, &authorization
+}
+
+/* ------------- tests ------------- */
+
+func TestLoginNoToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+	}
+	actual := callLogin(flags)
+	expected := "Error: No --token was provided. This is a required flag."
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestLoginStoresTokenPerBroker(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	callLogin([]string{"--broker-url=http://localhost:3000/", "--token", "token1"})
+	teardown()
+	actual := callLogin([]string{"--broker-url=https://broker.example.com", "--token", "token2"})
+	credentialsPath := filepath.Join(home, ".signet", "credentials")
+
+	t.Run("prints where the token was stored", func(t *testing.T) {
+		if !strings.Contains(actual.actual, "the token for https://broker.example.com was stored in "+credentialsPath) {
+			t.Error(actual.actual)
+		}
+	})
+
+	t.Run("stores a token for each broker URL", func(t *testing.T) {
+		credentialsBytes, _ := os.ReadFile(credentialsPath)
+		var credentials map[string]string
+		json.Unmarshal(credentialsBytes, &credentials)
+
+		if credentials["http://localhost:3000"] != "token1" || credentials["https://broker.example.com"] != "token2" {
+			t.Error(credentials)
+		}
+	})
+
+	t.Run("is only readable by the user", func(t *testing.T) {
+		info, err := os.Stat(credentialsPath)
+		if err != nil || info.Mode().Perm() != 0600 {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestLoginTokenIsSentToBroker(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server, authorization := mockServerRequiringToken("token1")
+	defer server.Close()
+
+	callLogin([]string{"--broker-url", server.URL, "--token", "token1"})
+	teardown()
+	actual := callRegisterEnv([]string{"--broker-url", server.URL, "--environment=production"})
+
+	t.Run("sends the stored token as a bearer token", func(t *testing.T) {
+		if *authorization != "Bearer token1" {
+			t.Error(*authorization)
+		}
+	})
+
+	t.Run("succeeds", func(t *testing.T) {
+		if actual.actual != "" {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestLoginEnvTokenOverridesStoredToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server, authorization := mockServerRequiringToken("token2")
+	defer server.Close()
+
+	callLogin([]string{"--broker-url", server.URL, "--token", "token1"})
+	teardown()
+	t.Setenv("SIGNET_TOKEN", "token2")
+	callRegisterEnv([]string{"--broker-url", server.URL, "--environment=production"})
+
+	if *authorization != "Bearer token2" {
+		t.Error(*authorization)
+	}
+	teardown()
+}
+
+func TestLoginUnauthorizedWithoutToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server, _ := mockServerRequiringToken("token1")
+	defer server.Close()
+
+	actual := callRegisterEnv([]string{"--broker-url", server.URL, "--environment=production"})
+	expected := "Error: Status code: 401 Unauthorized - Unauthorized\n\nThe broker requires a token. Run signet login --broker-url <url> --token <token>, or set SIGNET_TOKEN."
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestLoginUnauthorizedWithRejectedToken(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	server, _ := mockServerRequiringToken("token1")
+	defer server.Close()
+
+	t.Setenv("SIGNET_TOKEN", "expired")
+	actual := callRegisterEnv([]string{"--broker-url", server.URL, "--environment=production"})
+	expected := "Error: Status code: 401 Unauthorized - Unauthorized\n\nThe broker rejected the token that was sent. Run signet login again with a valid --token, or set SIGNET_TOKEN."
+
+	actual.startsWith(expected, t)
+	teardown()
+}
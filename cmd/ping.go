@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	client "github.com/signet-framework/signet-cli/client"
+)
+
+var pingCmd = &cobra.Command{
+	Use:   "ping",
+	Short: "check that the broker is reachable, and which version it is running",
+	Long: `check that the Signet broker is reachable before running other commands, and print the version it is running and how long it took to respond. Exits with 3 when the broker cannot be reached or is unhealthy.
+
+	flags:
+
+	-u --broker-url     the scheme, domain, and port where the Signet Broker is being hosted
+
+	-i --ignore-config  ingore .signetrc.yaml file if it exists (optional)
+	`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		brokerURL = resolveBrokerURL(cmd)
+
+		if len(brokerURL) == 0 {
+			return usageError(errors.New("No --broker-url was provided. This is a required flag."))
+		}
+
+		health, latency, err := client.Ping(cmd.Context(), brokerURL)
+		if err != nil {
+			return err
+		}
+
+		brokerVersion := health.Version
+		if len(brokerVersion) == 0 {
+			brokerVersion = "unknown"
+		}
+
+		cmd.Println(colorGreen + "Reachable" + colorReset + " - Signet broker at " + brokerURL + " is running version " + brokerVersion + ", and responded in " + strconv.FormatInt(latency.Milliseconds(), 10) + "ms")
+
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(pingCmd)
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+/* ------------- helpers ------------- */
+
+func callPing(argsAndFlags []string) actualOut {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs(append([]string{"ping"}, argsAndFlags...))
+	RootCmd.Execute()
+	return actualOut{actual.String()}
+}
+
+/* ------------- tests ------------- */
+
+func TestPingNoBrokerURL(t *testing.T) {
+	actual := callPing([]string{})
+	expected := "Error: No --broker-url was provided. This is a required flag."
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestPing(t *testing.T) {
+	var req http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = *r
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status": "ok", "version": "1.4.0"}`))
+	}))
+	defer server.Close()
+
+	actual := callPing([]string{"--broker-url", server.URL})
+
+	t.Run("requests the health endpoint", func(t *testing.T) {
+		if req.URL.Path != "/api/health" {
+			t.Error(req.URL.Path)
+		}
+	})
+
+	t.Run("prints the broker version and latency", func(t *testing.T) {
+		expected := colorGreen + "Reachable" + colorReset + " - Signet broker at " + server.URL + " is running version 1.4.0, and responded in "
+		actual.startsWith(expected, t)
+	})
+	teardown()
+}
+
+func TestPingExitCodes(t *testing.T) {
+	t.Run("exits with 3 when the broker cannot be reached", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.Close()
+
+		exitCode := exitCodeOf([]string{"ping", "--broker-url", server.URL})
+		if exitCode != exitBroker {
+			t.Error(exitCode)
+		}
+	})
+
+	t.Run("exits with 3 when the broker is unhealthy", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": "database unavailable"}`))
+		}))
+		defer server.Close()
+
+		exitCode := exitCodeOf([]string{"ping", "--broker-url", server.URL})
+		if exitCode != exitBroker {
+			t.Error(exitCode)
+		}
+	})
+	teardown()
+}
+
+func TestPingInterrupted(t *testing.T) {
+	server, requests := mockServerForStatuses(http.StatusServiceUnavailable)
+	defer server.Close()
+
+	// cobra only passes the root command's context to a subcommand the first time it runs
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	pingCmd.SetContext(ctx)
+	defer pingCmd.SetContext(context.Background())
+
+	start := time.Now()
+	exitCode := exitCodeOf([]string{"ping", "--broker-url", server.URL, "--retry", "5"})
+
+	t.Run("stops waiting to retry once interrupted", func(t *testing.T) {
+		if *requests != 1 || time.Since(start) >= 500*time.Millisecond {
+			t.Error(*requests, time.Since(start))
+		}
+	})
+
+	t.Run("exits with 130", func(t *testing.T) {
+		if exitCode != exitInterrupted {
+			t.Error(exitCode)
+		}
+	})
+	teardown()
+}
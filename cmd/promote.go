@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	client "github.com/signet-framework/signet-cli/client"
+	signet "github.com/signet-framework/signet-cli/signet"
+	utils "github.com/signet-framework/signet-cli/utils"
+)
+
+var fromEnvironment string
+var toEnvironment string
+
+var promoteCmd = &cobra.Command{
+	Use:   "promote",
+	Short: "promote a service version from one environment to another",
+	Long: `promote a service version from one environment to another, by checking that it is safe to deploy to the target environment with deploy-guard, and then notifying the broker that it has been deployed there
+
+	flags:
+
+	-n --name               the name of the service
+
+	-v --version            the version of the service (if not passed, defaults to SIGNET_VERSION, and then to the git SHA of HEAD)
+
+	--from-environment      the environment that the version is being promoted from (ex. staging)
+
+	--to-environment        the environment that the version is being promoted to (ex. production)
+
+	-u --broker-url         the scheme, domain, and port where the Signet Broker is being hosted
+
+	-i --ignore-config      ingore .signetrc.yaml file if it exists (optional)
+	`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		brokerURL = resolveBrokerURL(cmd)
+		name = viper.GetString("promote.name")
+		fromEnvironment = viper.GetString("promote.from-environment")
+		toEnvironment = viper.GetString("promote.to-environment")
+
+		if len(brokerURL) == 0 {
+			return errors.New("No --broker-url was provided. This is a required flag.")
+		}
+
+		if len(name) == 0 {
+			return errors.New("No --name was provided. This is a required flag.")
+		}
+
+		if len(fromEnvironment) == 0 {
+			return errors.New("No --from-environment was provided. This is a required flag.")
+		}
+
+		if len(toEnvironment) == 0 {
+			return errors.New("No --to-environment was provided. This is a required flag.")
+		}
+
+		if fromEnvironment == toEnvironment {
+			return errors.New("--from-environment and --to-environment must be different environments, both were " + toEnvironment)
+		}
+
+		var err error
+		version, err = resolveVersion(cmd, version)
+		if err != nil {
+			return err
+		}
+
+		result, err := signet.CheckDeployGuard(cmd.Context(), signet.DeployGuardOptions{BrokerURL: brokerURL, Name: name, Version: version, Environment: toEnvironment})
+		if err != nil {
+			return err
+		}
+
+		if !result.Status {
+			fmt.Fprintf(os.Stderr, colorRed+"Unsafe to Promote"+colorReset+" - version "+version+" of "+name+" is incompatible with one or more services in "+toEnvironment+" environment\n")
+			for _, guardErr := range result.Errors {
+				fmt.Fprintf(os.Stderr, "    - %s: %s\n", guardErr.Title, guardErr.Details)
+			}
+			return errors.New("version " + version + " of " + name + " was not promoted from " + fromEnvironment + " to " + toEnvironment)
+		}
+
+		requestBody := utils.DeploymentBody{
+			EnvironmentName:    toEnvironment,
+			ParticipantName:    name,
+			ParticipantVersion: version,
+			Deployed:           true,
+		}
+
+		jsonData, err := json.Marshal(requestBody)
+		if err != nil {
+			return err
+		}
+
+		idempotencyKey, err := utils.NewDeploymentIdempotencyKey(requestBody)
+		if err != nil {
+			return err
+		}
+
+		_, err = client.UpdateDeploymentWithBroker(cmd.Context(), brokerURL, jsonData, idempotencyKey)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(colorGreen + "Promoted" + colorReset + " - version " + version + " of " + name + " is compatible with all other services in " + toEnvironment + " environment, and Signet broker was notified that it was promoted from " + fromEnvironment)
+
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(promoteCmd)
+
+	promoteCmd.Flags().StringVarP(&name, "name", "n", "", "The name of the service which is being promoted")
+	promoteCmd.Flags().StringVarP(&version, "version", "v", "", "The version of the service which is being promoted")
+	promoteCmd.Flags().StringVar(&fromEnvironment, "from-environment", "", "The environment which the version is being promoted from")
+	promoteCmd.Flags().StringVar(&toEnvironment, "to-environment", "", "The environment which the version is being promoted to")
+	promoteCmd.Flags().Lookup("version").NoOptDefVal = "auto"
+
+	viper.BindPFlag("promote.name", promoteCmd.Flags().Lookup("name"))
+	viper.BindPFlag("promote.from-environment", promoteCmd.Flags().Lookup("from-environment"))
+	viper.BindPFlag("promote.to-environment", promoteCmd.Flags().Lookup("to-environment"))
+}
@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	client "github.com/signet-framework/signet-cli/client"
+	utils "github.com/signet-framework/signet-cli/utils"
+)
+
+/* ------------- helpers ------------- */
+
+func callPromote(argsAndFlags []string) actualOut {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs(append([]string{"promote"}, argsAndFlags...))
+	RootCmd.Execute()
+	return actualOut{actual.String()}
+}
+
+/*
+returns a mock broker which responds to deploy-guard checks with guardResp,
+and a pointer to the deployment that is recorded, if any
+*/
+func mockServerForPromote(t *testing.T, guardResp client.DeployGuardResponse) (*httptest.Server, *http.Request, *utils.DeploymentBody) {
+	var guardReq http.Request
+	var deployment utils.DeploymentBody
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/participants" {
+			json.NewDecoder(r.Body).Decode(&deployment)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		guardReq = *r
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(guardResp)
+	}))
+
+	return server, &guardReq, &deployment
+}
+
+/* ------------- tests ------------- */
+
+func TestPromoteNoToEnvironment(t *testing.T) {
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+		"--name", "user_service",
+		"--version=version1",
+		"--from-environment", "staging",
+	}
+	actual := callPromote(flags)
+	expected := "Error: No --to-environment was provided. This is a required flag."
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestPromoteSameEnvironment(t *testing.T) {
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+		"--name", "user_service",
+		"--version=version1",
+		"--from-environment", "staging",
+		"--to-environment", "staging",
+	}
+	actual := callPromote(flags)
+	expected := "Error: --from-environment and --to-environment must be different environments, both were staging"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestPromoteSafe(t *testing.T) {
+	server, guardReq, deployment := mockServerForPromote(t, client.DeployGuardResponse{Status: true})
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--version=version1",
+		"--from-environment", "staging",
+		"--to-environment", "production",
+	}
+	callPromote(flags)
+
+	t.Run("checks deploy-guard against the target environment", func(t *testing.T) {
+		query := guardReq.URL.Query()
+		if query.Get("participantName") != "user_service" || query.Get("participantVersion") != "version1" || query.Get("environmentName") != "production" {
+			t.Error(query)
+		}
+	})
+
+	t.Run("records the deployment to the target environment", func(t *testing.T) {
+		expected := utils.DeploymentBody{EnvironmentName: "production", ParticipantName: "user_service", ParticipantVersion: "version1", Deployed: true}
+		if *deployment != expected {
+			t.Error(*deployment)
+		}
+	})
+	teardown()
+}
+
+func TestPromoteUnsafe(t *testing.T) {
+	guardResp := client.DeployGuardResponse{
+		Status: false,
+		Errors: []client.DeployGuardError{{Title: "incompatible consumer", Details: "service_1 expects GET /users/{id}"}},
+	}
+	server, _, deployment := mockServerForPromote(t, guardResp)
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--version=version1",
+		"--from-environment", "staging",
+		"--to-environment", "production",
+	}
+	actual := callPromote(flags)
+
+	t.Run("fails without recording a deployment", func(t *testing.T) {
+		expected := "Error: version version1 of user_service was not promoted from staging to production"
+		actual.startsWith(expected, t)
+
+		if len(deployment.EnvironmentName) != 0 {
+			t.Error(*deployment)
+		}
+	})
+	teardown()
+}
@@ -1,23 +1,57 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	signet "github.com/signet-framework/signet-cli/signet"
 	utils "github.com/signet-framework/signet-cli/utils"
 )
 
+const defaultMaxBodySize = 1 << 20
+
+// how often recorded requests are appended to the --dump-requests file
+const requestLogInterval = 500 * time.Millisecond
+
+// how long mountebank is given to start listening on the proxy port, and how often the port is checked until it does
+const mbStartTimeout = 30 * time.Second
+const mbStartPollInterval = 100 * time.Millisecond
+
 var port string
-var target string
-var providerName string
+var targets []string
+var providerNames []string
+var contractEncoding string
+var recordSpec string
+var normalizeNumbers bool
+var recordTrailers bool
+var maxBodySize int
+var dumpRequests string
+var rotateDump bool
+var fixtureDir string
+var publishContract bool
+var includePaths []string
+var excludePaths []string
+var scrubHeaders []string
+var caCert string
+var insecure bool
+var providerState string
+var recordStatuses []string
 
 var proxyCmd = &cobra.Command{
 	Use:   "proxy",
@@ -26,30 +60,176 @@ var proxyCmd = &cobra.Command{
 
 	flags:
 
-	-o --port           the port that signet proxy should run on
+	-o --port           the port that signet proxy should run on (optional, a free port is picked when it is not set or is 0)
 
-	-t --target         the URL of the running provider stub or mock
+	-t --target         the http or https URL of the running provider stub or mock, repeatable with a path prefix for each provider (ex. /users=http://localhost:3002)
+
+	--ca-cert           PEM bundle of the CA certificates that https targets are verified against, in addition to the system CAs (optional)
+
+	--insecure          proxy to https targets without verifying their certificates, for local development only (optional)
 
 	-p --path           the relative path and filename that the consumer contract will be written to
 
+	--path-relative-to  what --path is relative to, either 'cwd' or 'git-root' (optional, defaults to 'cwd')
+
 	-n -—name           the canonical name of the consumer service
 
-	-m --provider-name  the canonical name of the provider service that the mock or stub represents
+	-m --provider-name  the canonical name of the provider service that the mock or stub represents, repeated in the same order as --target
+
+	--contract-encoding charset that recorded bodies are decoded from before the contract is written (optional, defaults to the Content-Type charset, or UTF-8)
+
+	--record-spec       file listing the "METHOD /path/pattern" interactions to record, all others are left out of the contract (optional)
+
+	--record-status     status code or class (ex. 2xx) of the responses to record, comma separated or repeatable, interactions with other statuses are proxied but left out of the contract (optional)
+
+	--fixture-dir       directory of JSON fixtures whose request and response bodies replace the recorded bodies of matching interactions (optional)
+
+	--scrub-header      header left out of the recorded requests, responses, and trailers, repeatable, added to the defaults (Authorization, Proxy-Authorization, Cookie, Set-Cookie, Date, X-Request-Id, X-Api-Key) (optional)
+
+	--normalize-numbers rewrite numbers in recorded JSON bodies to a canonical form, ex. 1.0 as 1 (optional)
+
+	--record-trailers   add HTTP response trailers (ex. gRPC-Web grpc-status) to the contract, requires mountebank to record trailers (optional)
+
+	--provider-state    the provider state of interactions recorded without an X-Signet-Provider-State request header (optional)
+
+	--max-body-size     the most bytes of a streamed (chunked or server-sent events) response body that are written to the contract (optional, defaults to 1048576)
+
+	--dump-requests     JSONL file that every proxied request/response pair is appended to as it is recorded, including pairs left out of the contract (optional)
+
+	--rotate-dump       move an existing --dump-requests file to <path>.1 instead of appending to it (optional)
+
+	--publish           publish the consumer contract to the broker as soon as it is written (optional)
+
+	-v --version        the consumer version the contract is published as (optional, only with --publish, if not passed, defaults to SIGNET_VERSION, and then to the git SHA of HEAD)
+
+	-b --branch         git branch the contract is published with (optional, only with --publish, defaults to git branch of HEAD if no value is provided)
+
+	-u --broker-url     the scheme, domain, and port where the Signet broker is being hosted (only with --publish)
 
 	-i --ignore-config  ingore .signetrc.yaml file if it exists (optional)
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		path = viper.GetString("proxy.path")
 		port = viper.GetString("proxy.port")
-		target = viper.GetString("proxy.target")
+		targets = viper.GetStringSlice("proxy.target")
 		name = viper.GetString("proxy.name")
-		providerName = viper.GetString("proxy.provider-name")
+		providerNames = viper.GetStringSlice("proxy.provider-name")
+		contractEncoding = viper.GetString("proxy.contract-encoding")
+		recordSpec = viper.GetString("proxy.record-spec")
+		normalizeNumbers = viper.GetBool("proxy.normalize-numbers")
+		recordTrailers = viper.GetBool("proxy.record-trailers")
+		maxBodySize = viper.GetInt("proxy.max-body-size")
+		dumpRequests = viper.GetString("proxy.dump-requests")
+		rotateDump = viper.GetBool("proxy.rotate-dump")
+		fixtureDir = viper.GetString("proxy.fixture-dir")
+		pathRelativeTo = viper.GetString("proxy.path-relative-to")
+		publishContract = viper.GetBool("proxy.publish")
+		includePaths = viper.GetStringSlice("proxy.include-path")
+		excludePaths = viper.GetStringSlice("proxy.exclude-path")
+		scrubHeaders = viper.GetStringSlice("proxy.scrub-headers")
+		providerState = viper.GetString("proxy.provider-state")
+		recordStatuses = viper.GetStringSlice("proxy.record-status")
+		caCert = viper.GetString("proxy.ca-cert")
+		insecure = viper.GetBool("proxy.insecure")
+		brokerURL = resolveBrokerURL(cmd)
+
+		err := validateProxyFlags(path, targets, name, providerNames)
+		if err != nil {
+			return err
+		}
 
-		err := validateProxyFlags(path, port, target, name, providerName)
+		path, err = resolveContractPath(path, pathRelativeTo)
 		if err != nil {
 			return err
 		}
 
+		proxyTargets, err := parseProxyTargets(targets, providerNames, path)
+		if err != nil {
+			return err
+		}
+
+		port, err = resolveProxyPort(port)
+		if err != nil {
+			return err
+		}
+
+		mbEnv, err := mbTLSEnv(caCert, insecure)
+		if err != nil {
+			return err
+		}
+
+		if insecure {
+			cmd.Println("Warning - the certificates of https targets are not verified, --insecure is for local development only")
+		}
+
+		err = validatePathGlobs("--include-path", includePaths)
+		if err != nil {
+			return err
+		}
+
+		err = validatePathGlobs("--exclude-path", excludePaths)
+		if err != nil {
+			return err
+		}
+
+		err = utils.ValidateRecordStatuses(recordStatuses)
+		if err != nil {
+			return err
+		}
+
+		if publishContract {
+			if len(brokerURL) == 0 {
+				return errors.New("No --broker-url was provided. This flag is required with --publish.")
+			}
+
+			// the version is resolved up front, so that a missing git repository is reported before recording starts
+			if branch == "auto" || (branch == "" && (version == "auto" || version == "")) {
+				branch, err = utils.SetBranchToCurrentGit(branch)
+				if err != nil {
+					return err
+				}
+			}
+
+			version, err = resolveVersion(cmd, version)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = utils.ValidEncoding(contractEncoding)
+		if err != nil {
+			return err
+		}
+		pactOptions := utils.PactOptions{
+			Encoding:         contractEncoding,
+			NormalizeNumbers: normalizeNumbers,
+			RecordTrailers:   recordTrailers,
+			MaxBodySize:      maxBodySize,
+			IncludePaths:     includePaths,
+			ExcludePaths:     excludePaths,
+			ScrubHeaders:     append(append([]string{}, utils.DefaultScrubHeaders...), scrubHeaders...),
+			ProviderState:    providerState,
+			RecordStatuses:   recordStatuses,
+		}
+
+		if maxBodySize < 1 {
+			return errors.New("--max-body-size must be at least 1 byte, --max-body-size was " + strconv.Itoa(maxBodySize))
+		}
+
+		if len(recordSpec) != 0 {
+			pactOptions.RecordSpec, err = utils.LoadRecordSpec(recordSpec)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(fixtureDir) != 0 {
+			pactOptions.Fixtures, err = utils.LoadFixtures(fixtureDir)
+			if err != nil {
+				return err
+			}
+		}
+
 		signetRoot, err := getNpmPkgRoot()
 		if err != nil {
 			return err
@@ -59,40 +239,135 @@ var proxyCmd = &cobra.Command{
 		dataDir := signetRoot + "/mbdata"
 		stubsDir := dataDir + "/" + port + "/stubs"
 
-		err = setupMbConfig(port, target, configPath)
+		err = setupMbConfig(port, proxyTargets, includePaths, excludePaths, configPath)
 		if err != nil {
 			return err
 		}
 
+		var requestLog *os.File
+		var logTicks <-chan time.Time
+		if len(dumpRequests) != 0 {
+			requestLog, err = utils.OpenRequestLog(dumpRequests, rotateDump)
+			if err != nil {
+				return errors.New("failed to open --dump-requests file: " + err.Error())
+			}
+			defer requestLog.Close()
+
+			logTicks = time.NewTicker(requestLogInterval).C
+		}
+		logged := map[string]bool{}
+
 		mbCmd := exec.Command("npx", mbPath, "--configfile", configPath, "--datadir", dataDir, "--debug", "--nologfile")
+		mbCmd.Env = append(os.Environ(), mbEnv...)
+		setProcessGroup(mbCmd)
 		err = mbCmd.Start()
 		if err != nil {
 			return errors.New("failed to start mountebank: " + err.Error())
 		}
 
-		cmd.Println(colorGreen + "Listening" + colorReset + " - Signet proxy is listening on port " + port + " and will proxy messages for " + target)
-		cmd.Println("\nHit Ctl + C to stop")
+		var mbErr error
+		mbExited := make(chan struct{})
+		go func() {
+			mbErr = mbCmd.Wait()
+			close(mbExited)
+		}()
 
 		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		interrupted := make(chan struct{})
+		generated := make(chan struct{})
+		var publishErr error
 		go func() {
-			for range c {
+			for {
+				select {
+				case <-logTicks:
+					utils.LogNewMatches(stubsDir, logged, requestLog)
+					continue
+				case <-c:
+				}
+
+				if requestLog != nil {
+					utils.LogNewMatches(stubsDir, logged, requestLog)
+				}
+
+				close(interrupted)
+				defer close(generated)
+
+				// mountebank runs in its own process group, so it has to be stopped whether signet got SIGINT or SIGTERM
+				err := stopProcessGroup(mbCmd.Process)
+				if err != nil {
+					cmd.Println("Warning - failed to stop mountebank: " + err.Error())
+				}
 				cmd.Println("\n\ngenerating consumer contract...")
 
-				err, ok := utils.CreatePact(stubsDir, path, name, providerName)
+				summaries, err := utils.CreatePacts(stubsDir, name, proxyTargets, pactOptions)
 				if err != nil {
 					log.Fatal(err)
 				}
 
-				if ok {
-					cmd.Println("\n" + colorGreen + "Success" + colorReset + " - Signet proxy wrote the consumer contract to " + path)
-				} else {
-					cmd.Println("\nInfo - No contract was generated because Signet proxy did not record any interactions")
+				for _, summary := range summaries {
+					// with more than one provider, each message says which contract it is about
+					forProvider := ""
+					if len(summaries) > 1 {
+						forProvider = " for " + summary.ProviderName
+					}
+
+					if summary.Duplicates > 0 {
+						cmd.Printf("\nInfo - %d repeated interactions%s were left out of the contract, each is recorded once\n", summary.Duplicates, forProvider)
+					}
+
+					if len(recordSpec) != 0 {
+						cmd.Printf("\nInfo - %d of %d recorded interactions%s matched the --record-spec, %d were dropped\n", summary.Recorded-summary.Dropped, summary.Recorded, forProvider, summary.Dropped)
+					}
+
+					if len(recordStatuses) != 0 {
+						cmd.Printf("\nInfo - %d of %d recorded interactions%s had a response status matching --record-status %s, %d were left out\n", summary.Recorded-summary.Dropped-summary.StatusDropped, summary.Recorded-summary.Dropped, forProvider, strings.Join(recordStatuses, ","), summary.StatusDropped)
+					}
+
+					if len(fixtureDir) != 0 {
+						cmd.Printf("\nInfo - %d of %d interactions%s use bodies from --fixture-dir, the rest use the recorded bodies\n", summary.Substituted, summary.Recorded-summary.Dropped-summary.StatusDropped, forProvider)
+					}
+
+					if summary.Written {
+						cmd.Println("\n" + colorGreen + "Success" + colorReset + " - Signet proxy wrote the consumer contract" + forProvider + " to " + summary.Path)
+					} else if summary.Recorded > summary.Dropped {
+						cmd.Println("\nInfo - No contract was generated" + forProvider + " because none of the recorded interactions had a response status matching --record-status")
+					} else if summary.Recorded > 0 {
+						cmd.Println("\nInfo - No contract was generated" + forProvider + " because none of the recorded interactions matched the --record-spec")
+					} else {
+						cmd.Println("\nInfo - No contract was generated" + forProvider + " because Signet proxy did not record any interactions")
+					}
+
+					if publishContract {
+						publishErr = errors.Join(publishErr, publishRecordedContract(cmd, summary))
+					}
 				}
+				return
 			}
 		}()
 
-		err = mbCmd.Wait()
+		spin := startSpinner(cmd.ErrOrStderr(), "Starting mountebank on port "+port)
+		listening, timedOut := waitForProxyPort(port, mbStartTimeout, mbExited, interrupted)
+		spin.stop()
+		if listening {
+			cmd.Println(colorGreen + "Listening" + colorReset + " - Signet proxy is listening on port " + port + " and will proxy messages for " + describeProxyTargets(proxyTargets))
+			cmd.Println("\nHit Ctl + C to stop")
+		} else if timedOut {
+			cmd.Println("Warning - mountebank is still running, but is not accepting connections on port " + port + " after " + mbStartTimeout.String() + ". Requests to the proxy may fail until it does.")
+			cmd.Println("\nHit Ctl + C to stop")
+		}
+
+		<-mbExited
+		err = mbErr
+
+		// mountebank is stopped once signet is interrupted or terminated, so wait for the contract to be written and published
+		select {
+		case <-interrupted:
+			<-generated
+			return publishErr
+		default:
+		}
+
 		if err != nil {
 			return errors.New("mountebank exited early: " + err.Error())
 		}
@@ -101,16 +376,56 @@ var proxyCmd = &cobra.Command{
 	},
 }
 
-func validateProxyFlags(path, port, target, name, providerName string) error {
-	if len(path) == 0 {
-		return errors.New("No --path was provided. This is a required flag.")
+func publishRecordedContract(cmd *cobra.Command, summary utils.PactSummary) error {
+	if !summary.Written {
+		cmd.Println("Info - Nothing was published to the Signet broker because no contract was generated")
+		return nil
 	}
 
-	if len(port) == 0 {
-		return errors.New("No --port was provided. This is a required flag.")
+	// the contract is published after signet proxy is interrupted, so the interrupt does not cancel it
+	err := signet.PublishConsumer(context.Background(), signet.ConsumerContract{BrokerURL: brokerURL, Path: summary.Path, Version: version, Branch: branch})
+	if err != nil {
+		return errors.New("the consumer contract was written to " + summary.Path + ", but could not be published: " + err.Error())
 	}
 
-	if len(target) == 0 {
+	cmd.Println(colorGreen + "Published" + colorReset + " - version " + version + " of the consumer contract published to Signet broker")
+	return nil
+}
+
+/*
+waits until mountebank accepts connections on the proxy port, and reports
+whether it is listening, or whether the timeout passed first. Waiting stops
+early when mountebank exits or signet proxy is interrupted. The port is
+checked on 127.0.0.1, since localhost can resolve to ::1 first, where an
+IPv4-only mountebank never accepts connections.
+*/
+func waitForProxyPort(port string, timeout time.Duration, exited, interrupted <-chan struct{}) (bool, bool) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		conn, err := net.DialTimeout("tcp", "127.0.0.1:"+port, mbStartPollInterval)
+		if err == nil {
+			conn.Close()
+			return true, false
+		}
+
+		select {
+		case <-exited:
+			return false, false
+		case <-interrupted:
+			return false, false
+		case <-time.After(mbStartPollInterval):
+		}
+	}
+
+	return false, true
+}
+
+func validateProxyFlags(path string, targets []string, name string, providerNames []string) error {
+	if len(path) == 0 {
+		return errors.New("No --path was provided. This is a required flag.")
+	}
+
+	if len(targets) == 0 {
 		return errors.New("No --target was provided. This is a required flag.")
 	}
 
@@ -118,35 +433,193 @@ func validateProxyFlags(path, port, target, name, providerName string) error {
 		return errors.New("No --name was provided. This is a required flag.")
 	}
 
-	if len(providerName) == 0 {
+	if len(providerNames) == 0 {
 		return errors.New("No --provider-name was provided. This is a required flag.")
 	}
 
 	return nil
 }
 
-func setupMbConfig(port, target, configPath string) error {
+/*
+picks a free ephemeral port when no --port, or --port 0, is given, so that
+proxies run in parallel do not collide. The port is released before
+mountebank binds to it.
+*/
+func resolveProxyPort(port string) (string, error) {
+	if len(port) != 0 && port != "0" {
+		return port, nil
+	}
+
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return "", errors.New("failed to find a free port for signet proxy: " + err.Error())
+	}
+	defer listener.Close()
+
+	return strconv.Itoa(listener.Addr().(*net.TCPAddr).Port), nil
+}
+
+/*
+pairs each --target with the --provider-name in the same position. A target
+can start with the path prefix of the requests that are proxied to it (ex.
+/users=http://localhost:3002), which every target needs when there is more
+than one. Each provider's contract is then written next to --path, with the
+provider name added to the file name.
+*/
+func parseProxyTargets(targets, providerNames []string, path string) ([]utils.ProxyTarget, error) {
+	if len(targets) != len(providerNames) {
+		return nil, fmt.Errorf("--target and --provider-name must be passed the same number of times, there were %d --target and %d --provider-name", len(targets), len(providerNames))
+	}
+
+	proxyTargets := []utils.ProxyTarget{}
+	prefixes := map[string]bool{}
+	for i, target := range targets {
+		proxyTarget := utils.ProxyTarget{URL: target, ProviderName: providerNames[i], ContractPath: path}
+
+		if strings.HasPrefix(target, "/") {
+			prefix, url, found := strings.Cut(target, "=")
+			if !found || len(url) == 0 {
+				return nil, errors.New("--target must be a URL, or a path prefix and URL in the form '/prefix=URL', --target was " + target)
+			}
+			proxyTarget.PathPrefix, proxyTarget.URL = prefix, url
+		} else if len(targets) > 1 {
+			return nil, errors.New("--target " + target + " has no path prefix. When there is more than one --target, each needs the path prefix of the requests that are proxied to it (ex. /users=" + target + ")")
+		}
+
+		if !strings.HasPrefix(proxyTarget.URL, "http://") && !strings.HasPrefix(proxyTarget.URL, "https://") {
+			return nil, errors.New("--target must be an http or https URL, --target was " + target)
+		}
+
+		if prefixes[proxyTarget.PathPrefix] {
+			return nil, errors.New("more than one --target has the path prefix " + proxyTarget.PathPrefix)
+		}
+		prefixes[proxyTarget.PathPrefix] = true
+
+		if len(targets) > 1 {
+			proxyTarget.ContractPath = providerContractPath(path, proxyTarget.ProviderName)
+		}
+
+		proxyTargets = append(proxyTargets, proxyTarget)
+	}
+
+	return proxyTargets, nil
+}
+
+/*
+the environment variables that make mountebank, which runs on Node, trust
+--ca-cert when proxying to https targets, or skip verification for
+--insecure
+*/
+func mbTLSEnv(caCert string, insecure bool) ([]string, error) {
+	if len(caCert) != 0 && insecure {
+		return nil, errors.New("--ca-cert and --insecure cannot be used together, --insecure skips the verification that --ca-cert is used for")
+	}
+
+	if insecure {
+		return []string{"NODE_TLS_REJECT_UNAUTHORIZED=0"}, nil
+	}
+
+	if len(caCert) == 0 {
+		return nil, nil
+	}
+
+	err := utils.ValidCACertBundle(caCert)
+	if err != nil {
+		return nil, err
+	}
+
+	caCertPath, err := filepath.Abs(caCert)
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{"NODE_EXTRA_CA_CERTS=" + caCertPath}, nil
+}
+
+// adds the provider name to a contract file name, ex. contracts/service_1.json to contracts/service_1-user_service.json
+func providerContractPath(path, providerName string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + providerName + ext
+}
+
+func describeProxyTargets(proxyTargets []utils.ProxyTarget) string {
+	descriptions := []string{}
+	for _, proxyTarget := range proxyTargets {
+		if len(proxyTarget.PathPrefix) == 0 {
+			descriptions = append(descriptions, proxyTarget.URL)
+		} else {
+			descriptions = append(descriptions, proxyTarget.PathPrefix+" to "+proxyTarget.URL)
+		}
+	}
+
+	return strings.Join(descriptions, ", ")
+}
+
+/*
+configures a mountebank imposter with a proxy stub for each target. Stubs
+with longer path prefixes come first, so that mountebank routes each request
+to the same target that CreatePacts records it for.
+*/
+func setupMbConfig(port string, proxyTargets []utils.ProxyTarget, includePaths, excludePaths []string, configPath string) error {
 	portInt, err := strconv.Atoi(port)
 	if err != nil {
 		return err
 	}
 
-	proxyConfig := utils.ProxyConfig{
-		Port:     portInt,
-		Name:     "signet-proxy",
-		Protocol: "http",
-		Stubs: []utils.MbStub{
-			utils.MbStub{
+	ordered := append([]utils.ProxyTarget{}, proxyTargets...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return len(ordered[i].PathPrefix) > len(ordered[j].PathPrefix)
+	})
+
+	stubs := []utils.MbStub{}
+	for _, proxyTarget := range ordered {
+		if notRecorded := notRecordedPredicate(includePaths, excludePaths); notRecorded != nil {
+			passthrough := utils.MbStub{
+				Predicates: []utils.MbPredicate{*notRecorded},
 				Responses: []utils.MbResponse{
 					utils.MbResponse{
 						Proxy: utils.MbProxy{
-							To:   target,
-							Mode: "proxyOnce",
+							To:   proxyTarget.URL,
+							Mode: "proxyTransparent",
 						},
 					},
 				},
+			}
+
+			if len(proxyTarget.PathPrefix) != 0 {
+				passthrough.Predicates = append([]utils.MbPredicate{
+					{Matches: map[string]string{"path": utils.PathPrefixPattern(proxyTarget.PathPrefix)}},
+				}, passthrough.Predicates...)
+			}
+
+			stubs = append(stubs, passthrough)
+		}
+
+		stub := utils.MbStub{
+			Responses: []utils.MbResponse{
+				utils.MbResponse{
+					Proxy: utils.MbProxy{
+						To:   proxyTarget.URL,
+						Mode: "proxyOnce",
+					},
+				},
 			},
-		},
+		}
+
+		if len(proxyTarget.PathPrefix) != 0 {
+			stub.Predicates = []utils.MbPredicate{
+				{Matches: map[string]string{"path": utils.PathPrefixPattern(proxyTarget.PathPrefix)}},
+			}
+		}
+
+		stubs = append(stubs, stub)
+	}
+
+	proxyConfig := utils.ProxyConfig{
+		Port:     portInt,
+		Name:     "signet-proxy",
+		Protocol: "http",
+		Stubs:    stubs,
 	}
 
 	jsonBytes, err := json.Marshal(proxyConfig)
@@ -162,18 +635,92 @@ func setupMbConfig(port, target, configPath string) error {
 	return nil
 }
 
+/*
+matches the requests that are proxied without being recorded: those matching
+an --exclude-path, or no --include-path when there are any
+*/
+func notRecordedPredicate(includePaths, excludePaths []string) *utils.MbPredicate {
+	var predicates []utils.MbPredicate
+	if len(excludePaths) != 0 {
+		predicates = append(predicates, utils.MbPredicate{
+			Matches: map[string]string{"path": utils.PathGlobsRegexp(excludePaths)},
+		})
+	}
+
+	if len(includePaths) != 0 {
+		predicates = append(predicates, utils.MbPredicate{
+			Not: &utils.MbPredicate{Matches: map[string]string{"path": utils.PathGlobsRegexp(includePaths)}},
+		})
+	}
+
+	if len(predicates) == 0 {
+		return nil
+	} else if len(predicates) == 1 {
+		return &predicates[0]
+	}
+	return &utils.MbPredicate{Or: predicates}
+}
+
+// path globs use the same syntax as --record-spec, and must start with /
+func validatePathGlobs(flag string, globs []string) error {
+	for _, glob := range globs {
+		if !strings.HasPrefix(glob, "/") {
+			return errors.New(flag + " must be a path pattern starting with / (ex. /health/**), " + flag + " was " + glob)
+		}
+	}
+	return nil
+}
+
 func init() {
 	RootCmd.AddCommand(proxyCmd)
 
 	proxyCmd.Flags().StringVarP(&path, "path", "p", "", "the relative path and filename that the consumer contract will be written to")
-	proxyCmd.Flags().StringVarP(&port, "port", "o", "", "the port that signet proxy should run on")
-	proxyCmd.Flags().StringVarP(&target, "target", "t", "", "the URL of the running provider stub or mock")
+	proxyCmd.Flags().StringVar(&pathRelativeTo, "path-relative-to", "cwd", "what --path is relative to, either \"cwd\" or \"git-root\"")
+	proxyCmd.Flags().StringVarP(&port, "port", "o", "", "the port that signet proxy should run on, a free port is picked when it is not set or is 0")
+	proxyCmd.Flags().StringSliceVarP(&targets, "target", "t", []string{}, "the http or https URL of the running provider stub or mock, repeatable with a path prefix for each provider (ex. /users=http://localhost:3002)")
+	proxyCmd.Flags().StringVar(&caCert, "ca-cert", "", "PEM bundle of the CA certificates that https targets are verified against, in addition to the system CAs")
+	proxyCmd.Flags().BoolVar(&insecure, "insecure", false, "proxy to https targets without verifying their certificates, for local development only")
 	proxyCmd.Flags().StringVarP(&name, "name", "n", "", "the canonical name of the consumer service")
-	proxyCmd.Flags().StringVarP(&providerName, "provider-name", "m", "", "the canonical name of the provider service that the mock or stub represents")
+	proxyCmd.Flags().StringSliceVarP(&providerNames, "provider-name", "m", []string{}, "the canonical name of the provider service that the mock or stub represents, repeated in the same order as --target")
+	proxyCmd.Flags().StringVar(&contractEncoding, "contract-encoding", "", "charset that recorded bodies are decoded from before the contract is written (defaults to the Content-Type charset, or UTF-8)")
+	proxyCmd.Flags().StringVar(&recordSpec, "record-spec", "", "file listing the \"METHOD /path/pattern\" interactions to record, all others are left out of the contract")
+	proxyCmd.Flags().StringSliceVar(&includePaths, "include-path", []string{}, "path glob of the requests to record, repeatable, all other requests are proxied without being recorded")
+	proxyCmd.Flags().StringSliceVar(&excludePaths, "exclude-path", []string{}, "path glob of requests that are proxied without being recorded, repeatable (ex. /health)")
+	proxyCmd.Flags().StringSliceVar(&recordStatuses, "record-status", []string{}, "status code or class (ex. 2xx) of the responses to record, repeatable, interactions with other statuses are left out of the contract")
+	proxyCmd.Flags().StringSliceVar(&scrubHeaders, "scrub-header", []string{}, "header left out of the recorded requests, responses, and trailers, repeatable, added to the default denylist")
+	proxyCmd.Flags().BoolVar(&normalizeNumbers, "normalize-numbers", false, "rewrite numbers in recorded JSON bodies to a canonical form, ex. 1.0 as 1")
+	proxyCmd.Flags().BoolVar(&recordTrailers, "record-trailers", false, "add HTTP response trailers (ex. gRPC-Web grpc-status) to the contract, requires mountebank to record trailers")
+	proxyCmd.Flags().StringVar(&providerState, "provider-state", "", "the provider state of interactions recorded without an X-Signet-Provider-State request header")
+	proxyCmd.Flags().IntVar(&maxBodySize, "max-body-size", defaultMaxBodySize, "the most bytes of a streamed (chunked or server-sent events) response body that are written to the contract")
+	proxyCmd.Flags().StringVar(&fixtureDir, "fixture-dir", "", "directory of JSON fixtures whose request and response bodies replace the recorded bodies of matching interactions")
+	proxyCmd.Flags().StringVar(&dumpRequests, "dump-requests", "", "JSONL file that every proxied request/response pair is appended to as it is recorded")
+	proxyCmd.Flags().BoolVar(&rotateDump, "rotate-dump", false, "move an existing --dump-requests file to <path>.1 instead of appending to it")
+	proxyCmd.Flags().BoolVar(&publishContract, "publish", false, "publish the consumer contract to the broker as soon as it is written")
+	proxyCmd.Flags().StringVarP(&version, "version", "v", "", "the consumer version the contract is published as (only with --publish, if not passed, defaults to SIGNET_VERSION, and then to the git SHA of HEAD)")
+	proxyCmd.Flags().StringVarP(&branch, "branch", "b", "", "git branch the contract is published with (only with --publish, defaults to git branch of HEAD)")
+	proxyCmd.Flags().Lookup("version").NoOptDefVal = "auto"
+	proxyCmd.Flags().Lookup("branch").NoOptDefVal = "auto"
 
 	viper.BindPFlag("proxy.path", proxyCmd.Flags().Lookup("path"))
+	viper.BindPFlag("proxy.path-relative-to", proxyCmd.Flags().Lookup("path-relative-to"))
 	viper.BindPFlag("proxy.port", proxyCmd.Flags().Lookup("port"))
 	viper.BindPFlag("proxy.target", proxyCmd.Flags().Lookup("target"))
+	viper.BindPFlag("proxy.ca-cert", proxyCmd.Flags().Lookup("ca-cert"))
+	viper.BindPFlag("proxy.insecure", proxyCmd.Flags().Lookup("insecure"))
 	viper.BindPFlag("proxy.name", proxyCmd.Flags().Lookup("name"))
 	viper.BindPFlag("proxy.provider-name", proxyCmd.Flags().Lookup("provider-name"))
+	viper.BindPFlag("proxy.contract-encoding", proxyCmd.Flags().Lookup("contract-encoding"))
+	viper.BindPFlag("proxy.record-spec", proxyCmd.Flags().Lookup("record-spec"))
+	viper.BindPFlag("proxy.include-path", proxyCmd.Flags().Lookup("include-path"))
+	viper.BindPFlag("proxy.exclude-path", proxyCmd.Flags().Lookup("exclude-path"))
+	viper.BindPFlag("proxy.record-status", proxyCmd.Flags().Lookup("record-status"))
+	viper.BindPFlag("proxy.scrub-headers", proxyCmd.Flags().Lookup("scrub-header"))
+	viper.BindPFlag("proxy.normalize-numbers", proxyCmd.Flags().Lookup("normalize-numbers"))
+	viper.BindPFlag("proxy.record-trailers", proxyCmd.Flags().Lookup("record-trailers"))
+	viper.BindPFlag("proxy.provider-state", proxyCmd.Flags().Lookup("provider-state"))
+	viper.BindPFlag("proxy.max-body-size", proxyCmd.Flags().Lookup("max-body-size"))
+	viper.BindPFlag("proxy.fixture-dir", proxyCmd.Flags().Lookup("fixture-dir"))
+	viper.BindPFlag("proxy.dump-requests", proxyCmd.Flags().Lookup("dump-requests"))
+	viper.BindPFlag("proxy.rotate-dump", proxyCmd.Flags().Lookup("rotate-dump"))
+	viper.BindPFlag("proxy.publish", proxyCmd.Flags().Lookup("publish"))
 }
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+/*
+starts mountebank in its own process group, so that stopping it also stops
+the node process that npx starts for it
+*/
+func setProcessGroup(mbCmd *exec.Cmd) {
+	mbCmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// stops a process started with setProcessGroup, along with all of its children
+func stopProcessGroup(process *os.Process) error {
+	return syscall.Kill(-process.Pid, syscall.SIGTERM)
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// windows has no process groups to set up, the process tree is stopped instead
+func setProcessGroup(mbCmd *exec.Cmd) {}
+
+// stops a process along with all of its children
+func stopProcessGroup(process *os.Process) error {
+	return exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(process.Pid)).Run()
+}
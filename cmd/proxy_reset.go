@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	utils "github.com/signet-framework/signet-cli/utils"
+)
+
+// the admin API of the mountebank process started by 'signet proxy'
+var mbAdminURL = "http://localhost:2525"
+
+var proxyResetCmd = &cobra.Command{
+	Use:   "reset",
+	Short: "clear the interactions recorded by a running signet proxy",
+	Long: `clear the interactions recorded by a running signet proxy, so that the consumer contract is generated only from requests made after the reset
+	
+	flags:
+
+	-o --port           the port that signet proxy is running on
+	
+	-i --ignore-config  ingore .signetrc.yaml file if it exists (optional)
+	`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !cmd.Flags().Changed("port") {
+			port = viper.GetString("proxy.port")
+		}
+
+		if len(port) == 0 {
+			return errors.New("No --port was provided. This is a required flag.")
+		}
+
+		cleared, err := utils.ResetImposterStubs(mbAdminURL, port)
+		if err != nil {
+			return err
+		}
+
+		cmd.Println(colorGreen + "Reset" + colorReset + " - cleared " + strconv.Itoa(cleared) + " recorded interactions from the signet proxy on port " + port)
+		return nil
+	},
+}
+
+func init() {
+	proxyCmd.AddCommand(proxyResetCmd)
+
+	proxyResetCmd.Flags().StringVarP(&port, "port", "o", "", "the port that signet proxy is running on")
+}
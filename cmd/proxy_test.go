@@ -0,0 +1,1070 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+
+	utils "github.com/signet-framework/signet-cli/utils"
+)
+
+/* ------------- tests ------------- */
+
+func TestProxyInvalidContractEncoding(t *testing.T) {
+	err := utils.ValidEncoding("not-a-charset")
+	if err == nil {
+		t.Error()
+	}
+}
+
+func TestCreatePactDecodesContractEncoding(t *testing.T) {
+	latin1Body := base64.StdEncoding.EncodeToString([]byte{'c', 'a', 'f', 0xe9})
+	match := mbMatch("GET", "/menu", 200, map[string]interface{}{"Content-Type": "text/plain; charset=ISO-8859-1"}, latin1Body)
+	match["response"].(map[string]interface{})["_mode"] = "binary"
+	stubsDir := writeMbMatches(t, match)
+	pactPath := t.TempDir() + "/cons-prov.json"
+
+	summary, err := utils.CreatePact(stubsDir, pactPath, "service_1", "user_service", utils.PactOptions{})
+	if err != nil || !summary.Written {
+		t.Fatal(err)
+	}
+
+	pact := loadPactMap(t, pactPath)
+	response := pact["interactions"].([]interface{})[0].(map[string]interface{})["response"].(map[string]interface{})
+
+	t.Run("decodes the body to UTF-8", func(t *testing.T) {
+		if response["body"] != "café" {
+			t.Error(response["body"])
+		}
+	})
+
+	t.Run("keeps the original charset in the Content-Type header", func(t *testing.T) {
+		if response["headers"].(map[string]interface{})["Content-Type"] != "text/plain; charset=ISO-8859-1" {
+			t.Error()
+		}
+	})
+
+	t.Run("adds a matching rule for the original charset", func(t *testing.T) {
+		if response["matchingRules"] == nil {
+			t.Error()
+		}
+	})
+}
+
+func TestCreatePactWithRecordSpec(t *testing.T) {
+	specPath := t.TempDir() + "/record-spec.txt"
+	spec := "# only user lookups are part of the contract\n\nGET /users/*\n* /orders/**\n"
+	err := os.WriteFile(specPath, []byte(spec), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := utils.LoadRecordSpec(specPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsonHeaders := map[string]interface{}{"Content-Type": "application/json"}
+	stubsDir := writeMbMatches(t,
+		mbMatch("GET", "/users/1", 200, jsonHeaders, `{"userId": 1}`),
+		mbMatch("DELETE", "/users/1", 204, jsonHeaders, ""),
+		mbMatch("POST", "/orders/1/items", 201, jsonHeaders, `{}`),
+		mbMatch("GET", "/health", 200, jsonHeaders, "ok"),
+	)
+	pactPath := t.TempDir() + "/cons-prov.json"
+
+	summary, err := utils.CreatePact(stubsDir, pactPath, "service_1", "user_service", utils.PactOptions{RecordSpec: rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("reports the recorded and dropped counts", func(t *testing.T) {
+		if summary.Recorded != 4 || summary.Dropped != 2 {
+			t.Error(summary)
+		}
+	})
+
+	t.Run("only writes the matching interactions", func(t *testing.T) {
+		interactions := loadPactMap(t, pactPath)["interactions"].([]interface{})
+		if len(interactions) != 2 {
+			t.Error(len(interactions))
+		}
+	})
+}
+
+func TestCreatePactWithRecordStatuses(t *testing.T) {
+	jsonHeaders := map[string]interface{}{"Content-Type": "application/json"}
+	stubsDir := writeMbMatches(t,
+		mbMatch("GET", "/users/1", 200, jsonHeaders, `{"userId": 1}`),
+		mbMatch("POST", "/users", 201, jsonHeaders, `{"userId": 2}`),
+		mbMatch("GET", "/users/3", 404, jsonHeaders, `{"error": "not found"}`),
+		mbMatch("GET", "/users/4", 503, jsonHeaders, `{"error": "unavailable"}`),
+	)
+	pactPath := t.TempDir() + "/cons-prov.json"
+
+	summary, err := utils.CreatePact(stubsDir, pactPath, "service_1", "user_service", utils.PactOptions{RecordStatuses: []string{"2xx", "404"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("reports how many interactions were left out", func(t *testing.T) {
+		if summary.Recorded != 4 || summary.StatusDropped != 1 {
+			t.Error(summary)
+		}
+	})
+
+	t.Run("only writes the interactions with a matching status", func(t *testing.T) {
+		interactions := loadPactMap(t, pactPath)["interactions"].([]interface{})
+		for _, interaction := range interactions {
+			if interaction.(map[string]interface{})["response"].(map[string]interface{})["status"] == float64(503) {
+				t.Error(interaction)
+			}
+		}
+		if len(interactions) != 3 {
+			t.Error(len(interactions))
+		}
+	})
+}
+
+func TestStatusIsRecorded(t *testing.T) {
+	cases := []struct {
+		status   int
+		statuses []string
+		expected bool
+	}{
+		{200, []string{"2xx"}, true},
+		{204, []string{"2XX"}, true},
+		{301, []string{"2xx"}, false},
+		{404, []string{"2xx", "404"}, true},
+		{500, []string{"200", "404"}, false},
+	}
+
+	for _, c := range cases {
+		if actual := utils.StatusIsRecorded(c.status, c.statuses); actual != c.expected {
+			t.Errorf("%d %v: expected %t", c.status, c.statuses, c.expected)
+		}
+	}
+}
+
+func TestCreatePactDedupesRepeatedInteractions(t *testing.T) {
+	// the consumer looked up the same user three times, which mountebank saved as three matches
+	stubsDir := "../data_test/stubs/repeated-interactions"
+	pactPath := t.TempDir() + "/cons-prov.json"
+
+	summary, err := utils.CreatePact(stubsDir, pactPath, "service_1", "user_service", utils.PactOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("reports how many repeated interactions were left out", func(t *testing.T) {
+		if summary.Recorded != 3 || summary.Duplicates != 2 {
+			t.Error(summary)
+		}
+	})
+
+	t.Run("writes each distinct interaction once", func(t *testing.T) {
+		actual := loadPactMap(t, pactPath)
+		expected := loadPactMap(t, "../data_test/cons-prov-deduped.json")
+		if !reflect.DeepEqual(actual, expected) {
+			t.Error(actual)
+		}
+	})
+}
+
+func TestCreatePactRecordsProviderStates(t *testing.T) {
+	jsonHeaders := map[string]interface{}{"Content-Type": "application/json"}
+	withState := mbMatch("GET", "/users/1", 200, jsonHeaders, `{"userId": 1}`)
+	withState["request"].(map[string]interface{})["headers"] = map[string]interface{}{"Accept": "application/json", "x-signet-provider-state": "user 1 exists"}
+	stubsDir := writeMbMatches(t, withState, mbMatch("GET", "/users/2", 404, jsonHeaders, `{}`))
+	pactPath := t.TempDir() + "/cons-prov.json"
+
+	providerStates := func(t *testing.T, options utils.PactOptions) []interface{} {
+		_, err := utils.CreatePact(stubsDir, pactPath, "service_1", "user_service", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		states := []interface{}{}
+		for _, interaction := range loadPactMap(t, pactPath)["interactions"].([]interface{}) {
+			states = append(states, interaction.(map[string]interface{})["providerStates"])
+		}
+		return states
+	}
+
+	t.Run("takes the state from the request header", func(t *testing.T) {
+		states := providerStates(t, utils.PactOptions{})
+		if !reflect.DeepEqual(states, []interface{}{[]interface{}{map[string]interface{}{"name": "user 1 exists"}}, nil}) {
+			t.Error(states)
+		}
+	})
+
+	t.Run("uses --provider-state for requests without the header", func(t *testing.T) {
+		states := providerStates(t, utils.PactOptions{ProviderState: "no users exist"})
+		expected := []interface{}{
+			[]interface{}{map[string]interface{}{"name": "user 1 exists"}},
+			[]interface{}{map[string]interface{}{"name": "no users exist"}},
+		}
+		if !reflect.DeepEqual(states, expected) {
+			t.Error(states)
+		}
+	})
+}
+
+func TestCreatePactNormalizesNumbers(t *testing.T) {
+	jsonHeaders := map[string]interface{}{"Content-Type": "application/json"}
+	match := mbMatch("POST", "/orders", 201, jsonHeaders, `{"total": 10.0, "items": [{"price": 2.50, "qty": 4e0}]}`)
+	match["request"].(map[string]interface{})["body"] = `{"qty": 1.0}`
+	stubsDir := writeMbMatches(t, match, mbMatch("GET", "/health", 200, jsonHeaders, "ok 1.0"))
+	pactPath := t.TempDir() + "/cons-prov.json"
+
+	_, err := utils.CreatePact(stubsDir, pactPath, "service_1", "user_service", utils.PactOptions{NormalizeNumbers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interactions := loadPactMap(t, pactPath)["interactions"].([]interface{})
+	bodyOf := func(i int, message string) interface{} {
+		return interactions[i].(map[string]interface{})[message].(map[string]interface{})["body"]
+	}
+
+	t.Run("normalizes numbers in the request body", func(t *testing.T) {
+		if bodyOf(0, "request") != `{"qty":1}` {
+			t.Error(bodyOf(0, "request"))
+		}
+	})
+
+	t.Run("normalizes numbers in the response body", func(t *testing.T) {
+		if bodyOf(0, "response") != `{"items":[{"price":2.5,"qty":4}],"total":10}` {
+			t.Error(bodyOf(0, "response"))
+		}
+	})
+
+	t.Run("leaves non-JSON bodies unchanged", func(t *testing.T) {
+		if bodyOf(1, "response") != "ok 1.0" {
+			t.Error(bodyOf(1, "response"))
+		}
+	})
+}
+
+func TestCreatePactNormalizesNumbersKeepsLargeIntegers(t *testing.T) {
+	jsonHeaders := map[string]interface{}{"Content-Type": "application/json"}
+	stubsDir := writeMbMatches(t,
+		mbMatch("GET", "/orders/1", 200, jsonHeaders, `{"id": 9007199254740993, "amount": 9007199254740993.0, "ratio": 1.10e-1}`),
+		mbMatch("GET", "/orders/2", 200, jsonHeaders, `{"id": 9007199254740992, "amount": 9007199254740992.0, "ratio": 0.11}`),
+	)
+	pactPath := t.TempDir() + "/cons-prov.json"
+
+	_, err := utils.CreatePact(stubsDir, pactPath, "service_1", "user_service", utils.PactOptions{NormalizeNumbers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interactions := loadPactMap(t, pactPath)["interactions"].([]interface{})
+	bodyOf := func(i int) interface{} {
+		return interactions[i].(map[string]interface{})["response"].(map[string]interface{})["body"]
+	}
+
+	t.Run("keeps integers above 2^53 exactly", func(t *testing.T) {
+		if bodyOf(0) != `{"amount":9007199254740993,"id":9007199254740993,"ratio":0.11}` {
+			t.Error(bodyOf(0))
+		}
+	})
+
+	t.Run("does not make different integers equal", func(t *testing.T) {
+		if bodyOf(1) != `{"amount":9007199254740992,"id":9007199254740992,"ratio":0.11}` {
+			t.Error(bodyOf(1))
+		}
+	})
+}
+
+func TestCreatePactRecordsTrailers(t *testing.T) {
+	match := mbMatch("POST", "/users.UserService/GetUser", 200, map[string]interface{}{"Content-Type": "application/grpc-web+json"}, `{}`)
+	match["response"].(map[string]interface{})["trailers"] = map[string]interface{}{"grpc-status": "0", "grpc-message": "OK"}
+	stubsDir := writeMbMatches(t, match)
+
+	responseFor := func(options utils.PactOptions) map[string]interface{} {
+		pactPath := t.TempDir() + "/cons-prov.json"
+		_, err := utils.CreatePact(stubsDir, pactPath, "service_1", "user_service", options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return loadPactMap(t, pactPath)["interactions"].([]interface{})[0].(map[string]interface{})["response"].(map[string]interface{})
+	}
+
+	t.Run("leaves trailers out by default", func(t *testing.T) {
+		if responseFor(utils.PactOptions{})["trailers"] != nil {
+			t.Error()
+		}
+	})
+
+	response := responseFor(utils.PactOptions{RecordTrailers: true})
+
+	t.Run("adds the trailers to the response", func(t *testing.T) {
+		trailers, _ := response["trailers"].(map[string]interface{})
+		if trailers["grpc-status"] != "0" || trailers["grpc-message"] != "OK" {
+			t.Error(response["trailers"])
+		}
+	})
+
+	t.Run("adds matching rules for the trailers", func(t *testing.T) {
+		rules, _ := response["matchingRules"].(map[string]interface{})
+		trailerRules, _ := rules["trailers"].(map[string]interface{})
+		if trailerRules["grpc-status"] == nil || trailerRules["grpc-message"] == nil {
+			t.Error(response["matchingRules"])
+		}
+	})
+}
+
+func TestCreatePactScrubsHeaders(t *testing.T) {
+	match := mbMatch("POST", "/users.UserService/GetUser", 200, map[string]interface{}{"Content-Type": "application/grpc-web+json"}, `{}`)
+	match["response"].(map[string]interface{})["trailers"] = map[string]interface{}{"grpc-status": "0", "set-cookie": "session=abc123", "x-trace-id": "7f3a"}
+	stubsDir := writeMbMatches(t, match)
+	pactPath := t.TempDir() + "/cons-prov.json"
+
+	scrubbed := append(append([]string{}, utils.DefaultScrubHeaders...), "accept", "X-Trace-Id")
+	_, err := utils.CreatePact(stubsDir, pactPath, "service_1", "user_service", utils.PactOptions{RecordTrailers: true, ScrubHeaders: scrubbed})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interaction := loadPactMap(t, pactPath)["interactions"].([]interface{})[0].(map[string]interface{})
+	request := interaction["request"].(map[string]interface{})
+	response := interaction["response"].(map[string]interface{})
+
+	t.Run("removes denied headers whatever their case", func(t *testing.T) {
+		headers, _ := request["headers"].(map[string]interface{})
+		if headers["Accept"] != nil {
+			t.Error(request["headers"])
+		}
+	})
+
+	t.Run("keeps headers that are not denied", func(t *testing.T) {
+		headers, _ := response["headers"].(map[string]interface{})
+		if headers["Content-Type"] != "application/grpc-web+json" {
+			t.Error(response["headers"])
+		}
+	})
+
+	t.Run("removes denied trailers and their matching rules", func(t *testing.T) {
+		trailers, _ := response["trailers"].(map[string]interface{})
+		rules, _ := response["matchingRules"].(map[string]interface{})
+		trailerRules, _ := rules["trailers"].(map[string]interface{})
+		if len(trailers) != 1 || trailers["grpc-status"] != "0" || len(trailerRules) != 1 {
+			t.Error(response)
+		}
+	})
+}
+
+func TestCreatePactRecordsStreamedResponses(t *testing.T) {
+	events := "data: {\"tick\": 1}\n\ndata: {\"tick\": 2}\n\ndata: {\"tick\": 3}\n\n"
+	sse := mbMatch("GET", "/ticks", 200, map[string]interface{}{"Content-Type": "text/event-stream", "Transfer-Encoding": "chunked"}, base64.StdEncoding.EncodeToString([]byte(events)))
+	sse["response"].(map[string]interface{})["_mode"] = "binary"
+	plain := mbMatch("GET", "/users/1", 200, map[string]interface{}{"Content-Type": "application/json"}, `{"userId": 1}`)
+	stubsDir := writeMbMatches(t, sse, plain)
+	pactPath := t.TempDir() + "/cons-prov.json"
+
+	_, err := utils.CreatePact(stubsDir, pactPath, "service_1", "user_service", utils.PactOptions{MaxBodySize: 40})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interactions := loadPactMap(t, pactPath)["interactions"].([]interface{})
+	streamed := interactions[0].(map[string]interface{})
+	streaming, _ := streamed["streaming"].(map[string]interface{})
+
+	t.Run("decodes the streamed body and caps it after the last complete event", func(t *testing.T) {
+		body := streamed["response"].(map[string]interface{})["body"]
+		if body != "data: {\"tick\": 1}\n\ndata: {\"tick\": 2}\n\n" {
+			t.Errorf("%q", body)
+		}
+	})
+
+	t.Run("marks the interaction as streamed and truncated", func(t *testing.T) {
+		if streaming["transferEncoding"] != "chunked" || streaming["truncated"] != true || streaming["size"] != float64(len(events)) {
+			t.Error(streaming)
+		}
+	})
+
+	t.Run("leaves other interactions unmarked", func(t *testing.T) {
+		if interactions[1].(map[string]interface{})["streaming"] != nil {
+			t.Error()
+		}
+	})
+}
+
+func TestLogNewMatches(t *testing.T) {
+	jsonHeaders := map[string]interface{}{"Content-Type": "application/json"}
+	match := mbMatch("GET", "/users/1", 200, jsonHeaders, `{"userId": 1}`)
+	match["timestamp"] = "2026-10-17T09:00:00.000Z"
+	match["processingTime"] = 12
+	stubsDir := writeMbMatches(t, match, mbMatch("DELETE", "/users/1", 204, jsonHeaders, ""))
+
+	logPath := t.TempDir() + "/requests.jsonl"
+	os.WriteFile(logPath, []byte("{}\n"), 0644)
+
+	requestLog, err := utils.OpenRequestLog(logPath, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logged := map[string]bool{}
+	first, _ := utils.LogNewMatches(stubsDir, logged, requestLog)
+	second, _ := utils.LogNewMatches(stubsDir, logged, requestLog)
+	requestLog.Close()
+
+	t.Run("logs each recorded pair once", func(t *testing.T) {
+		if first != 2 || second != 0 {
+			t.Error(first, second)
+		}
+	})
+
+	t.Run("writes a JSON line per pair", func(t *testing.T) {
+		logBytes, _ := os.ReadFile(logPath)
+		lines := strings.Split(strings.TrimSpace(string(logBytes)), "\n")
+		expected := `{"timestamp":"2026-10-17T09:00:00.000Z","method":"GET","path":"/users/1","status":200,"processingTimeMs":12}`
+		if len(lines) != 2 || lines[0] != expected {
+			t.Error(string(logBytes))
+		}
+	})
+
+	t.Run("rotates the existing file", func(t *testing.T) {
+		rotated, _ := os.ReadFile(logPath + ".1")
+		if string(rotated) != "{}\n" {
+			t.Error(string(rotated))
+		}
+	})
+}
+
+func TestLoadRecordSpecInvalidLine(t *testing.T) {
+	specPath := t.TempDir() + "/record-spec.txt"
+	err := os.WriteFile(specPath, []byte("GET\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = utils.LoadRecordSpec(specPath)
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Error(err)
+	}
+}
+
+func TestResolveProxyPort(t *testing.T) {
+	t.Run("keeps the given port", func(t *testing.T) {
+		resolved, err := resolveProxyPort("3004")
+		if err != nil || resolved != "3004" {
+			t.Error(resolved, err)
+		}
+	})
+
+	for _, port := range []string{"", "0"} {
+		t.Run("picks a free port for "+strconv.Quote(port), func(t *testing.T) {
+			resolved, err := resolveProxyPort(port)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			portInt, err := strconv.Atoi(resolved)
+			if err != nil || portInt == 0 {
+				t.Error(resolved)
+			}
+		})
+	}
+}
+
+func TestWaitForProxyPort(t *testing.T) {
+	t.Run("reports mountebank started once the port accepts connections", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+
+		port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
+		listening, timedOut := waitForProxyPort(port, 5*time.Second, make(chan struct{}), make(chan struct{}))
+		if !listening || timedOut {
+			t.Error(listening, timedOut)
+		}
+	})
+
+	t.Run("stops waiting when mountebank exits", func(t *testing.T) {
+		port, err := resolveProxyPort("")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		exited := make(chan struct{})
+		close(exited)
+		listening, timedOut := waitForProxyPort(port, 5*time.Second, exited, make(chan struct{}))
+		if listening || timedOut {
+			t.Error(listening, timedOut)
+		}
+	})
+
+	t.Run("reports a timeout when the port never accepts connections", func(t *testing.T) {
+		port, err := resolveProxyPort("")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listening, timedOut := waitForProxyPort(port, 300*time.Millisecond, make(chan struct{}), make(chan struct{}))
+		if listening || !timedOut {
+			t.Error(listening, timedOut)
+		}
+	})
+}
+
+func TestProxyResetNoPort(t *testing.T) {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs([]string{"proxy", "reset"})
+	RootCmd.Execute()
+
+	actualOut{actual.String()}.startsWith("Error: No --port was provided.", t)
+	teardown()
+}
+
+func TestProxyReset(t *testing.T) {
+	proxyStub := map[string]interface{}{
+		"responses": []interface{}{map[string]interface{}{"proxy": map[string]interface{}{"to": "http://localhost:3001", "mode": "proxyOnce"}}},
+		"matches":   []interface{}{map[string]interface{}{}},
+	}
+	recordedStub := map[string]interface{}{
+		"responses": []interface{}{map[string]interface{}{"is": map[string]interface{}{"statusCode": 200}}},
+		"matches":   []interface{}{map[string]interface{}{}, map[string]interface{}{}},
+	}
+
+	var putBody map[string][]map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut && r.URL.Path == "/imposters/3002/stubs" {
+			json.NewDecoder(r.Body).Decode(&putBody)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"stubs": []interface{}{recordedStub, proxyStub},
+		})
+	}))
+	defer server.Close()
+
+	mbAdminURL = server.URL
+	defer func() { mbAdminURL = "http://localhost:2525" }()
+
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs([]string{"proxy", "reset", "--port", "3002"})
+	RootCmd.Execute()
+
+	t.Run("reports how many interactions were cleared", func(t *testing.T) {
+		expected := colorGreen + "Reset" + colorReset + " - cleared 3 recorded interactions"
+		actualOut{actual.String()}.startsWith(expected, t)
+	})
+
+	t.Run("keeps only the proxy stub, without its matches", func(t *testing.T) {
+		stubs := putBody["stubs"]
+		if len(stubs) != 1 || stubs[0]["responses"] == nil || stubs[0]["matches"] != nil {
+			t.Errorf("stubs were %v", stubs)
+		}
+	})
+	teardown()
+}
+
+func TestCreatePactWithFixtures(t *testing.T) {
+	fixtureDir := t.TempDir()
+	fixture := `{"method": "get", "path": "/users/*", "response": {"body": {"userId": 1, "username": "example"}}}`
+	err := os.WriteFile(fixtureDir+"/users.json", []byte(fixture), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fixtures, err := utils.LoadFixtures(fixtureDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsonHeaders := map[string]interface{}{"Content-Type": "application/json"}
+	stubsDir := writeMbMatches(t,
+		mbMatch("GET", "/users/1", 200, jsonHeaders, `{"userId": 1, "username": "recorded-8f3a"}`),
+		mbMatch("GET", "/orders/1", 200, jsonHeaders, `{"orderId": 1}`),
+	)
+	pactPath := t.TempDir() + "/cons-prov.json"
+
+	summary, err := utils.CreatePact(stubsDir, pactPath, "service_1", "user_service", utils.PactOptions{Fixtures: fixtures})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("reports how many interactions were substituted", func(t *testing.T) {
+		if summary.Substituted != 1 {
+			t.Error(summary)
+		}
+	})
+
+	interactions := loadPactMap(t, pactPath)["interactions"].([]interface{})
+	for _, rawInteraction := range interactions {
+		interaction := rawInteraction.(map[string]interface{})
+		request := interaction["request"].(map[string]interface{})
+		body, _ := json.Marshal(interaction["response"].(map[string]interface{})["body"])
+
+		if request["path"] == "/users/1" {
+			t.Run("uses the fixture body for a matching interaction", func(t *testing.T) {
+				if string(body) != `{"userId":1,"username":"example"}` {
+					t.Error(string(body))
+				}
+			})
+		} else {
+			t.Run("keeps the recorded body for an unmatched interaction", func(t *testing.T) {
+				if string(body) != `"{\"orderId\": 1}"` {
+					t.Error(string(body))
+				}
+			})
+		}
+	}
+}
+
+func TestLoadFixturesWithoutPath(t *testing.T) {
+	fixtureDir := t.TempDir()
+	err := os.WriteFile(fixtureDir+"/users.json", []byte(`{"method": "GET"}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = utils.LoadFixtures(fixtureDir)
+	if err == nil || !strings.Contains(err.Error(), "must have a method and a path pattern") {
+		t.Error(err)
+	}
+}
+
+func TestCreatePactsSplitsInteractionsByTarget(t *testing.T) {
+	jsonHeaders := map[string]interface{}{"Content-Type": "application/json"}
+	stubsDir := writeMbMatches(t,
+		mbMatch("GET", "/users/1", 200, jsonHeaders, `{"userId": 1}`),
+		mbMatch("GET", "/orders/1", 200, jsonHeaders, `{"orderId": 1}`),
+		mbMatch("GET", "/orders/1/items", 200, jsonHeaders, `[]`),
+		mbMatch("GET", "/health", 200, jsonHeaders, `{}`),
+	)
+	contractsDir := t.TempDir()
+	targets := []utils.ProxyTarget{
+		{PathPrefix: "/users", ProviderName: "user_service", ContractPath: contractsDir + "/service_1-user_service.json"},
+		{PathPrefix: "/orders", ProviderName: "order_service", ContractPath: contractsDir + "/service_1-order_service.json"},
+		{PathPrefix: "/payments", ProviderName: "payment_service", ContractPath: contractsDir + "/service_1-payment_service.json"},
+	}
+
+	summaries, err := utils.CreatePacts(stubsDir, "service_1", targets, utils.PactOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("writes a contract for each provider with its own interactions", func(t *testing.T) {
+		userPact := loadPactMap(t, targets[0].ContractPath)
+		orderPact := loadPactMap(t, targets[1].ContractPath)
+
+		if len(userPact["interactions"].([]interface{})) != 1 || len(orderPact["interactions"].([]interface{})) != 2 {
+			t.Error(userPact["interactions"], orderPact["interactions"])
+		}
+
+		if orderPact["provider"].(map[string]interface{})["name"] != "order_service" {
+			t.Error(orderPact["provider"])
+		}
+	})
+
+	t.Run("does not write a contract for a provider with no interactions", func(t *testing.T) {
+		if summaries[2].Written || summaries[2].Recorded != 0 {
+			t.Error(summaries[2])
+		}
+		if _, err := os.Stat(targets[2].ContractPath); err == nil {
+			t.Error("contract was written")
+		}
+	})
+
+	t.Run("leaves out requests that match no target", func(t *testing.T) {
+		if summaries[0].Recorded+summaries[1].Recorded+summaries[2].Recorded != 3 {
+			t.Error(summaries)
+		}
+	})
+}
+
+func TestMatchProxyTargetPrefersLongestPrefix(t *testing.T) {
+	targets := []utils.ProxyTarget{
+		{PathPrefix: ""},
+		{PathPrefix: "/api"},
+		{PathPrefix: "/api/users"},
+	}
+
+	cases := map[string]int{
+		"/api/users/1":   2,
+		"/api/users":     2,
+		"/api/users-old": 1,
+		"/api/orders":    1,
+		"/api":           1,
+		"/api-docs":      0,
+		"/health":        0,
+	}
+
+	for requestPath, expected := range cases {
+		if actual := utils.MatchProxyTarget(targets, requestPath); actual != expected {
+			t.Errorf("%s: expected target %d, got %d", requestPath, expected, actual)
+		}
+	}
+}
+
+func TestParseProxyTargets(t *testing.T) {
+	t.Run("keeps a single target without a prefix as before", func(t *testing.T) {
+		proxyTargets, err := parseProxyTargets([]string{"http://localhost:3002"}, []string{"user_service"}, "contracts/service_1.json")
+		if err != nil || len(proxyTargets) != 1 || proxyTargets[0].PathPrefix != "" || proxyTargets[0].ContractPath != "contracts/service_1.json" {
+			t.Error(proxyTargets, err)
+		}
+	})
+
+	t.Run("writes a contract per provider next to --path", func(t *testing.T) {
+		proxyTargets, err := parseProxyTargets(
+			[]string{"/users=http://localhost:3002", "/orders=http://localhost:3003"},
+			[]string{"user_service", "order_service"},
+			"contracts/service_1.json",
+		)
+		if err != nil || proxyTargets[1].PathPrefix != "/orders" || proxyTargets[1].URL != "http://localhost:3003" || proxyTargets[1].ContractPath != "contracts/service_1-order_service.json" {
+			t.Error(proxyTargets, err)
+		}
+	})
+
+	errorCases := []struct {
+		targets       []string
+		providerNames []string
+		expected      string
+	}{
+		{[]string{"/users=http://localhost:3002"}, []string{"user_service", "order_service"}, "--target and --provider-name must be passed the same number of times"},
+		{[]string{"/users=http://localhost:3002", "http://localhost:3003"}, []string{"user_service", "order_service"}, "--target http://localhost:3003 has no path prefix"},
+		{[]string{"/users=http://localhost:3002", "/users=http://localhost:3003"}, []string{"user_service", "order_service"}, "more than one --target has the path prefix /users"},
+		{[]string{"/users"}, []string{"user_service"}, "--target must be a URL, or a path prefix and URL"},
+		{[]string{"localhost:3002"}, []string{"user_service"}, "--target must be an http or https URL, --target was localhost:3002"},
+		{[]string{"/users=ftp://localhost:3002"}, []string{"user_service"}, "--target must be an http or https URL"},
+	}
+
+	for _, c := range errorCases {
+		_, err := parseProxyTargets(c.targets, c.providerNames, "contracts/service_1.json")
+		if err == nil || !strings.HasPrefix(err.Error(), c.expected) {
+			t.Errorf("%v: expected error %q, got %v", c.targets, c.expected, err)
+		}
+	}
+}
+
+func TestParseProxyTargetsHTTPS(t *testing.T) {
+	proxyTargets, err := parseProxyTargets([]string{"https://stubs.internal:8443"}, []string{"user_service"}, "contracts/service_1.json")
+	if err != nil || proxyTargets[0].URL != "https://stubs.internal:8443" {
+		t.Error(proxyTargets, err)
+	}
+}
+
+// writes a PEM bundle with a self-signed CA certificate
+func writeCACert(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Internal CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caCertPath := filepath.Join(t.TempDir(), "ca.pem")
+	err = os.WriteFile(caCertPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes}), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return caCertPath
+}
+
+func TestMbTLSEnv(t *testing.T) {
+	t.Run("has no variables by default", func(t *testing.T) {
+		env, err := mbTLSEnv("", false)
+		if err != nil || len(env) != 0 {
+			t.Error(env, err)
+		}
+	})
+
+	t.Run("trusts --ca-cert", func(t *testing.T) {
+		caCertPath := writeCACert(t)
+		env, err := mbTLSEnv(caCertPath, false)
+		if err != nil || len(env) != 1 || env[0] != "NODE_EXTRA_CA_CERTS="+caCertPath {
+			t.Error(env, err)
+		}
+	})
+
+	t.Run("skips verification with --insecure", func(t *testing.T) {
+		env, err := mbTLSEnv("", true)
+		if err != nil || len(env) != 1 || env[0] != "NODE_TLS_REJECT_UNAUTHORIZED=0" {
+			t.Error(env, err)
+		}
+	})
+
+	errorCases := []struct {
+		caCert   string
+		insecure bool
+		expected string
+	}{
+		{"../data_test/api-spec.json", false, "--ca-cert ../data_test/api-spec.json does not contain any PEM encoded certificates"},
+		{"../data_test/missing.pem", false, "could not read --ca-cert"},
+		{"../data_test/api-spec.json", true, "--ca-cert and --insecure cannot be used together"},
+	}
+
+	for _, c := range errorCases {
+		_, err := mbTLSEnv(c.caCert, c.insecure)
+		if err == nil || !strings.HasPrefix(err.Error(), c.expected) {
+			t.Errorf("%v: expected error %q, got %v", c.caCert, c.expected, err)
+		}
+	}
+}
+
+func TestSetupMbConfigRoutesByPathPrefix(t *testing.T) {
+	configPath := t.TempDir() + "/config.ejs"
+	proxyTargets := []utils.ProxyTarget{
+		{PathPrefix: "/api", URL: "http://localhost:3002"},
+		{PathPrefix: "/api/orders", URL: "http://localhost:3003"},
+	}
+
+	err := setupMbConfig("3004", proxyTargets, nil, nil, configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configBytes, _ := os.ReadFile(configPath)
+	var config utils.ProxyConfig
+	json.Unmarshal(configBytes, &config)
+
+	t.Run("adds a stub per target, longest prefix first", func(t *testing.T) {
+		if len(config.Stubs) != 2 || config.Stubs[0].Responses[0].Proxy.To != "http://localhost:3003" {
+			t.Error(string(configBytes))
+		}
+	})
+
+	t.Run("matches requests by whole path segments of the prefix", func(t *testing.T) {
+		if config.Stubs[1].Predicates[0].Matches["path"] != "^/api(/|$)" {
+			t.Error(string(configBytes))
+		}
+	})
+}
+
+func TestSetupMbConfigPassesThroughUnrecordedPaths(t *testing.T) {
+	configPath := t.TempDir() + "/config.ejs"
+	proxyTargets := []utils.ProxyTarget{
+		{PathPrefix: "/api", URL: "http://localhost:3002"},
+	}
+
+	err := setupMbConfig("3004", proxyTargets, []string{"/api/users/**"}, []string{"/api/users/*/avatar"}, configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configBytes, _ := os.ReadFile(configPath)
+	var config utils.ProxyConfig
+	json.Unmarshal(configBytes, &config)
+
+	t.Run("proxies unrecorded paths transparently before the recording stub", func(t *testing.T) {
+		if len(config.Stubs) != 2 || config.Stubs[0].Responses[0].Proxy.Mode != "proxyTransparent" || config.Stubs[1].Responses[0].Proxy.Mode != "proxyOnce" {
+			t.Error(string(configBytes))
+		}
+	})
+
+	t.Run("keeps the path prefix of the target", func(t *testing.T) {
+		if config.Stubs[0].Predicates[0].Matches["path"] != "^/api(/|$)" || config.Stubs[0].Responses[0].Proxy.To != "http://localhost:3002" {
+			t.Error(string(configBytes))
+		}
+	})
+
+	t.Run("matches excluded paths, or paths that are not included", func(t *testing.T) {
+		notRecorded := config.Stubs[0].Predicates[1]
+		if len(notRecorded.Or) != 2 ||
+			notRecorded.Or[0].Matches["path"] != "^/api/users/[^/]*/avatar$" ||
+			notRecorded.Or[1].Not.Matches["path"] != "^/api/users/.*$" {
+			t.Error(string(configBytes))
+		}
+	})
+}
+
+func TestCreatePactsLeavesOutUnrecordedPaths(t *testing.T) {
+	jsonHeaders := map[string]interface{}{"Content-Type": "application/json"}
+	stubsDir := writeMbMatches(t,
+		mbMatch("GET", "/users/1", 200, jsonHeaders, `{"userId": 1}`),
+		mbMatch("GET", "/users/1/avatar", 200, jsonHeaders, `{}`),
+		mbMatch("GET", "/health", 200, jsonHeaders, `{}`),
+		mbMatch("GET", "/static/app.js", 200, jsonHeaders, `{}`),
+	)
+	targets := []utils.ProxyTarget{
+		{ProviderName: "user_service", ContractPath: t.TempDir() + "/service_1-user_service.json"},
+	}
+
+	options := utils.PactOptions{
+		ExcludePaths: []string{"/health", "/static/**", "/users/*/avatar"},
+	}
+	summaries, err := utils.CreatePacts(stubsDir, "service_1", targets, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pact := loadPactMap(t, targets[0].ContractPath)
+	interactions := pact["interactions"].([]interface{})
+	if len(interactions) != 1 || summaries[0].Recorded != 1 || summaries[0].Dropped != 0 {
+		t.Error(interactions, summaries)
+	}
+}
+
+func TestProxyRotateDumpConfig(t *testing.T) {
+	viper.Set("proxy.rotate-dump", true)
+	defer viper.Set("proxy.rotate-dump", false)
+
+	exitCodeOf([]string{"proxy", "--dump-requests", t.TempDir() + "/requests.jsonl"})
+
+	if !rotateDump {
+		t.Error("rotate-dump was not read from the config")
+	}
+	teardown()
+}
+
+func TestProxyInvalidExcludePath(t *testing.T) {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs([]string{"proxy", "--path=./cons-prov.json", "--port", "3002", "--target", "http://localhost:3001", "--name", "service_1", "--provider-name", "user_service", "--exclude-path", "health"})
+	RootCmd.Execute()
+
+	expected := "Error: --exclude-path must be a path pattern starting with / (ex. /health/**), --exclude-path was health"
+	actualOut{actual.String()}.startsWith(expected, t)
+	teardown()
+}
+
+func TestProxyInvalidRecordStatus(t *testing.T) {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs([]string{"proxy", "--path=./cons-prov.json", "--port", "3002", "--target", "http://localhost:3001", "--name", "service_1", "--provider-name", "user_service", "--record-status", "2xx,success"})
+	RootCmd.Execute()
+
+	expected := "Error: --record-status must be a status code (ex. 404) or a class of status codes (ex. 2xx), --record-status was success"
+	actualOut{actual.String()}.startsWith(expected, t)
+	teardown()
+}
+
+func TestProxyPublishNoBrokerURL(t *testing.T) {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs([]string{"proxy", "--path=./cons-prov.json", "--port", "3002", "--target", "http://localhost:3001", "--name", "service_1", "--provider-name", "user_service", "--publish"})
+	RootCmd.Execute()
+
+	expected := "Error: No --broker-url was provided. This flag is required with --publish."
+	actualOut{actual.String()}.startsWith(expected, t)
+	teardown()
+}
+
+func TestPublishRecordedContract(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+
+	brokerURL = server.URL
+	version = "version1"
+	branch = "main"
+	err := publishRecordedContract(proxyCmd, utils.PactSummary{Path: "../data_test/cons-prov.json", Recorded: 1, Written: true})
+
+	t.Run("publishes the written contract with the resolved version and branch", func(t *testing.T) {
+		if err != nil || reqBody.ConsumerVersion != "version1" || reqBody.ConsumerBranch != "main" {
+			t.Error(err, reqBody)
+		}
+	})
+
+	t.Run("reports the publish outcome", func(t *testing.T) {
+		expected := colorGreen + "Published" + colorReset + " - version version1 of the consumer contract published to Signet broker"
+		actualOut{actual.String()}.startsWith(expected, t)
+	})
+	teardown()
+}
+
+func TestPublishRecordedContractNotWritten(t *testing.T) {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+
+	err := publishRecordedContract(proxyCmd, utils.PactSummary{})
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := "Info - Nothing was published to the Signet broker because no contract was generated"
+	actualOut{actual.String()}.startsWith(expected, t)
+	teardown()
+}
+
+func TestStopProcessGroupStopsChildren(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+
+	// like npx, sh starts the long running process as a child
+	childPidFile := t.TempDir() + "/child.pid"
+	parent := exec.Command("sh", "-c", "sleep 30 & echo $! > "+childPidFile+"; wait")
+	setProcessGroup(parent)
+	if err := parent.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	var childPid int
+	for i := 0; i < 50 && childPid == 0; i++ {
+		time.Sleep(20 * time.Millisecond)
+		pidBytes, _ := os.ReadFile(childPidFile)
+		childPid, _ = strconv.Atoi(strings.TrimSpace(string(pidBytes)))
+	}
+
+	err := stopProcessGroup(parent.Process)
+	parent.Wait()
+
+	t.Run("stops the process without error", func(t *testing.T) {
+		if err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("stops the child process", func(t *testing.T) {
+		time.Sleep(50 * time.Millisecond)
+		// a stopped child can be left as a zombie when nothing reaps orphans
+		state, _ := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(childPid)).Output()
+		if childPid == 0 || (len(state) != 0 && state[0] != 'Z') {
+			t.Errorf("child process %d is still running", childPid)
+		}
+	})
+}
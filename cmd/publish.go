@@ -1,18 +1,46 @@
 package cmd
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	client "github.com/signet-framework/signet-cli/client"
+	signet "github.com/signet-framework/signet-cli/signet"
 	utils "github.com/signet-framework/signet-cli/utils"
 )
 
+const defaultMaxSpecSize = 10 << 20
+
 var serviceType string
 var contractFormat string
 var contract []byte
+var tags []string
+var tagsFromEnv []string
+var schemaVersion int
+var onlyBranches []string
+var ttl string
+var changedSince string
+var sourcePaths []string
+var maxSpecSize int
+var onConflict string
+var contractType string
+var skipValidation bool
+var webhookURL string
+var showDiff bool
 
 var publishCmd = &cobra.Command{
 	Use:   "publish",
@@ -21,24 +49,75 @@ var publishCmd = &cobra.Command{
 
 	flags:
 
-	-p --path           the relative path to the contract or API spec
+	-p --path           the relative path to the contract or API spec, or '-' to read it from stdin. For --type 'consumer', a directory or glob (ex. 'pacts/*.json') publishes every contract in it
+
+	--path-relative-to  what --path is relative to, either 'cwd' or 'git-root' (optional, defaults to 'cwd')
 
 	-t -—type           the type of service contract (either 'consumer' or 'provider')
 
 	-n -—name           canonical name of the provider service (only for —-type 'provider')
 
-	-v -—version        service version (only for --type 'consumer', defaults to the git SHA of HEAD if no value is provided)
+	-v -—version        service version (only for --type 'consumer', if not passed, defaults to SIGNET_VERSION, and then to the git SHA of HEAD)
 
 	-b -—branch         git branch name (optional, only for --type 'consumer', defaults to git branch of HEAD if no value is provided)
 
+	--version-output    file that the resolved version is written to, or '-' for stdout (optional, only for --type 'consumer')
+
+	--tag               a tag for the consumer contract, ex. stable, sent in addition to the branch (optional, repeatable, only for --type 'consumer')
+
+	--tags-from-env     comma separated names of environment variables whose values are added as contract tags (optional, only for --type 'consumer')
+
+	--only-branches     comma separated branch names or glob patterns, publishing is skipped when the branch matches none of them (optional)
+
+	--changed-since     git ref, publishing is skipped when the contract or --source-path has not changed since it (optional)
+
+	--source-path       comma separated paths checked by --changed-since instead of the contract or spec (optional)
+
+	--ttl               how long the broker keeps the contract before expiring it, ex. 72h or 14d (optional, only for --type 'consumer')
+
+	--on-conflict       what to do when the consumer version is already published: 'fail', 'skip', or 'retry-with-suffix' (optional, only for --type 'consumer', defaults to 'fail')
+
+	--contract-type     the type of provider spec, either 'openapi', 'graphql', or 'asyncapi' (optional, only for --type 'provider', defaults to 'graphql' for .graphql and .gql files, 'asyncapi' for documents with an asyncapi key, and 'openapi' otherwise)
+
+	--skip-validation   publish the spec without checking that it is a valid OpenAPI document, or the contract without checking that it is a valid pact (optional)
+
+	--max-spec-size     the largest spec in bytes that is sent to the broker (optional, only for --type 'provider', defaults to 10485760)
+
+	--schema-version    contract schema version to publish with, instead of the highest version supported by both signet and the broker (optional, only for --type 'consumer')
+
+	--webhook           URL that a JSON notification is posted to once the contract or spec is published (optional)
+
+	--diff              print how the interactions changed since the contract was last published, and ask for confirmation before publishing (optional, only for --type 'consumer')
+
+	-y --yes            publish without asking for confirmation after --diff (optional)
+
+	--dry-run           print the request that would be sent to the broker, without sending it (optional)
+
 	-u --broker-url     the scheme, domain, and port where the Signet broker is being hosted
 
 	-i --ignore-config  ingore .signetrc.yaml file if it exists (optional)
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		brokerURL = resolveBrokerURL(cmd)
 		path = viper.GetString("publish.path")
 		serviceType = viper.GetString("publish.type")
 		name = viper.GetString("publish.name")
+		tags = viper.GetStringSlice("publish.tag")
+		tagsFromEnv = viper.GetStringSlice("publish.tags-from-env")
+		versionOutput = viper.GetString("publish.version-output")
+		schemaVersion = viper.GetInt("publish.schema-version")
+		onlyBranches = viper.GetStringSlice("publish.only-branches")
+		ttl = viper.GetString("publish.ttl")
+		changedSince = viper.GetString("publish.changed-since")
+		sourcePaths = viper.GetStringSlice("publish.source-path")
+		maxSpecSize = viper.GetInt("publish.max-spec-size")
+		onConflict = viper.GetString("publish.on-conflict")
+		pathRelativeTo = viper.GetString("publish.path-relative-to")
+		contractType = viper.GetString("publish.contract-type")
+		skipValidation = viper.GetBool("publish.skip-validation")
+		webhookURL = viper.GetString("publish.webhook-url")
+		showDiff = viper.GetBool("publish.diff")
+		assumeYes = viper.GetBool("publish.yes")
 
 		if len(path) == 0 {
 			return errors.New("No --path to a contract/spec was provided. This is a required flag.")
@@ -48,41 +127,582 @@ var publishCmd = &cobra.Command{
 			return errors.New("No --broker-url was provided. This is a required flag.")
 		}
 
-		err := utils.ValidType(serviceType)
+		var err error
+		brokerURL, err = validateBrokerURL(brokerURL)
+		if err != nil {
+			return err
+		}
+
+		err = utils.ValidType(serviceType)
+		if err != nil {
+			return err
+		}
+
+		if path == utils.StdinPath {
+			if len(changedSince) != 0 && len(sourcePaths) == 0 {
+				return errors.New("--changed-since needs a --source-path when the contract is read from stdin")
+			}
+
+			utils.StdinContents, err = io.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return errors.New("could not read the contract from stdin: " + err.Error())
+			}
+
+			if len(bytes.TrimSpace(utils.StdinContents)) == 0 {
+				return errors.New("--path is -, but nothing was read from stdin")
+			}
+		} else {
+			path, err = resolveContractPath(path, pathRelativeTo)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(contractType) != 0 && contractType != "openapi" && contractType != "graphql" && contractType != "asyncapi" {
+			return errors.New("--contract-type must be either \"openapi\", \"graphql\", or \"asyncapi\", --contract-type was " + contractType)
+		}
+
+		if len(contractType) != 0 && serviceType == "consumer" {
+			return errors.New("--contract-type is only for --type 'provider'")
+		}
+
+		contractPaths, multiple, err := contractPathsIn(path)
 		if err != nil {
 			return err
 		}
 
+		if multiple && serviceType != "consumer" {
+			return errors.New("--path can only be a directory or glob for --type 'consumer', --path was " + path)
+		}
+
+		if showDiff && serviceType != "consumer" {
+			return errors.New("--diff is only for --type 'consumer'")
+		}
+
+		if showDiff && path == utils.StdinPath && !assumeYes && !dryRun {
+			return errors.New("--diff needs --yes when the contract is read from stdin, since stdin cannot also answer the confirmation")
+		}
+
+		if len(webhookURL) != 0 && !strings.HasPrefix(webhookURL, "http://") && !strings.HasPrefix(webhookURL, "https://") {
+			return errors.New("--webhook must be an http or https URL, --webhook was " + webhookURL)
+		}
+
+		if onConflict != "fail" && onConflict != "skip" && onConflict != "retry-with-suffix" {
+			return errors.New("--on-conflict must be \"fail\", \"skip\", or \"retry-with-suffix\", --on-conflict was " + onConflict)
+		}
+
+		if len(onlyBranches) != 0 {
+			publishBranch, allowed, err := branchIsAllowed(branch, onlyBranches)
+			if err != nil {
+				return err
+			}
+
+			if !allowed {
+				cmd.Println("Skipped - branch " + publishBranch + " does not match --only-branches " + strings.Join(onlyBranches, ","))
+				return nil
+			}
+		}
+
+		if len(changedSince) != 0 {
+			checkedPaths := sourcePaths
+			if len(checkedPaths) == 0 {
+				checkedPaths = contractPaths
+			}
+
+			changed, err := utils.ChangedSince(changedSince, checkedPaths)
+			if err != nil {
+				return err
+			}
+
+			if !changed {
+				cmd.Println("Skipped - " + strings.Join(checkedPaths, ",") + " has not changed since " + changedSince)
+				return nil
+			}
+		}
+
 		if serviceType == "consumer" {
-			err = utils.PublishConsumer(path, brokerURL, version, branch)
+			contractTTL, err := parseTTL(ttl)
 			if err != nil {
 				return err
 			}
-			fmt.Println(colorGreen + "Published" + colorReset + " - consumer contract published to Signet broker")
+
+			if branch == "auto" || (branch == "" && (version == "auto" || version == "")) {
+				branch, err = utils.SetBranchToCurrentGit(branch)
+				if err != nil {
+					return err
+				}
+			}
+
+			version, err = resolveVersion(cmd, version)
+			if err != nil {
+				return err
+			}
+
+			publishOptions := utils.PublishOptions{
+				Tags:           contractTags(),
+				SchemaVersion:  schemaVersion,
+				TTL:            contractTTL,
+				SkipValidation: skipValidation,
+			}
+
+			if multiple {
+				return publishConsumerContracts(cmd, contractPaths, publishOptions)
+			}
+
+			err = publishConsumerContract(cmd, publishOptions)
+			var pactErr *utils.PactValidationError
+			if errors.As(err, &pactErr) {
+				return errors.New(err.Error() + "\n\nFix the contract, or pass --skip-validation to publish it anyway.")
+			}
+			return err
 		} else {
-			err = utils.PublishProvider(path, brokerURL, name, "", "")
+			if maxSpecSize < 1 {
+				return errors.New("--max-spec-size must be at least 1 byte, --max-spec-size was " + strconv.Itoa(maxSpecSize))
+			}
+
+			specSize, err := utils.SpecSize(path, contractType)
+			if err != nil {
+				return err
+			}
+
+			if specSize > maxSpecSize {
+				specSource := "at " + path
+				if path == utils.StdinPath {
+					specSource = "read from stdin"
+				}
+				return fmt.Errorf("the API spec %s is %d bytes, which is larger than the --max-spec-size of %d bytes", specSource, specSize, maxSpecSize)
+			}
+
+			requestBody, err := utils.PrepareProviderRequest(path, contractType, name, "", "", "")
+			if err != nil {
+				return err
+			}
+
+			if !skipValidation {
+				err = utils.ValidateSpecFile(path, contractType)
+				if err != nil {
+					return errors.New(err.Error() + "\n\nFix the spec, or pass --skip-validation to publish it anyway.")
+				}
+			}
+
+			if dryRun {
+				return printDryRun(cmd, "POST", brokerURL+"/api/specs", requestBody)
+			}
+
+			err = client.PublishToBroker(cmd.Context(), brokerURL+"/api/specs", requestBody)
 			if err != nil {
 				return err
 			}
 			fmt.Println(colorGreen + "Published" + colorReset + " - provider API spec published to Signet broker")
+			notifyPublishWebhook(cmd, "", "")
 		}
 
 		return nil
 	},
 }
 
+// publishes the consumer contract at path
+func publishConsumerContract(cmd *cobra.Command, publishOptions utils.PublishOptions) error {
+	if showDiff {
+		changed, err := printContractDiff(cmd)
+		if err != nil {
+			return err
+		}
+
+		if changed && !dryRun && !assumeYes {
+			confirmed, err := confirm(cmd, "Publish this contract to the Signet broker?")
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				cmd.Println("Cancelled - nothing was published")
+				return nil
+			}
+		}
+	}
+
+	if dryRun {
+		requestBody, err := signet.PrepareConsumer(cmd.Context(), consumerContract(publishOptions))
+		if err != nil {
+			return err
+		}
+		return printDryRun(cmd, "POST", brokerURL+"/api/contracts", requestBody)
+	}
+
+	err := signet.PublishConsumer(cmd.Context(), consumerContract(publishOptions))
+	var brokerErr *client.BrokerError
+	if errors.As(err, &brokerErr) && brokerErr.StatusCode == http.StatusConflict && onConflict != "fail" {
+		return resolvePublishConflict(cmd, publishOptions)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Println(colorGreen + "Published" + colorReset + " - consumer contract published to Signet broker")
+	notifyPublishWebhook(cmd, version, branch)
+	return nil
+}
+
+// the --tag values, then the values of --tags-from-env, without duplicates
+func contractTags() []string {
+	contractTags := []string{}
+	seen := map[string]bool{}
+	for _, tag := range append(tags, utils.TagsFromEnv(tagsFromEnv)...) {
+		tag = strings.TrimSpace(tag)
+		if len(tag) != 0 && !seen[tag] {
+			seen[tag] = true
+			contractTags = append(contractTags, tag)
+		}
+	}
+	return contractTags
+}
+
+// the consumer contract at --path, published as --version and --branch
+func consumerContract(publishOptions utils.PublishOptions) signet.ConsumerContract {
+	return signet.ConsumerContract{
+		BrokerURL:      brokerURL,
+		Path:           path,
+		Version:        version,
+		Branch:         branch,
+		Tags:           publishOptions.Tags,
+		SchemaVersion:  publishOptions.SchemaVersion,
+		TTL:            publishOptions.TTL,
+		SkipValidation: publishOptions.SkipValidation,
+		DryRun:         dryRun,
+	}
+}
+
+/*
+prints how the interactions of the contract at path changed since the
+consumer last published a contract with the same provider, and reports
+whether anything changed
+*/
+func printContractDiff(cmd *cobra.Command) (bool, error) {
+	pact, err := utils.LoadContract(path)
+	if err != nil {
+		return false, err
+	}
+	providerName := utils.ProviderName(pact)
+
+	contracts, err := client.ListContracts(cmd.Context(), brokerURL, pact.Consumer.Name)
+	if err != nil {
+		return false, err
+	}
+
+	// versions are listed newest first, and not every version has a contract with this provider
+	var priorContract []byte
+	priorVersion := ""
+	for _, summary := range contracts {
+		if summary.ContractType != "consumer" || summary.ParticipantVersion == version {
+			continue
+		}
+
+		priorContract, err = client.GetContract(cmd.Context(), brokerURL, pact.Consumer.Name, providerName, summary.ParticipantVersion)
+		var brokerErr *client.BrokerError
+		if errors.As(err, &brokerErr) && brokerErr.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		priorVersion = summary.ParticipantVersion
+		break
+	}
+
+	if len(priorVersion) == 0 {
+		cmd.Println("Diff - " + pact.Consumer.Name + " has not published a contract with " + providerName + " before, so every interaction is new")
+		return true, nil
+	}
+
+	var prior utils.Pact
+	err = json.Unmarshal(priorContract, &prior)
+	if err != nil {
+		return false, errors.New("could not parse the contract for consumer version " + priorVersion + ": " + err.Error())
+	}
+
+	changes, err := utils.DiffInteractions(pact, prior)
+	if err != nil {
+		return false, err
+	}
+
+	if len(changes) == 0 {
+		cmd.Println("Diff - no interactions with " + providerName + " changed since version " + priorVersion)
+		return false, nil
+	}
+
+	cmd.Println("Diff - interactions with " + providerName + " that changed since version " + priorVersion + ":")
+	for _, change := range changes {
+		switch change.Kind {
+		case "added":
+			cmd.Println("  " + colorGreen + "+" + colorReset + " " + change.Description)
+		case "removed":
+			cmd.Println("  " + colorRed + "-" + colorReset + " " + change.Description)
+		default:
+			cmd.Println("  " + colorBlue + "~" + colorReset + " " + change.Description)
+			for _, field := range change.Fields {
+				cmd.Println("      " + field)
+			}
+		}
+	}
+
+	return true, nil
+}
+
+/*
+publishes each consumer contract with the same version and branch, and
+prints whether each one was published. A contract that fails to publish
+does not stop the others.
+*/
+func publishConsumerContracts(cmd *cobra.Command, contractPaths []string, publishOptions utils.PublishOptions) error {
+	resolvedVersion := version
+	failures := map[string]error{}
+	for _, contractPath := range contractPaths {
+		path = contractPath
+		version = resolvedVersion
+		cmd.Println("Publishing " + contractPath)
+
+		err := publishConsumerContract(cmd, publishOptions)
+		if err != nil {
+			cmd.Println(colorRed + "Failed" + colorReset + " - " + err.Error())
+			failures[contractPath] = err
+		}
+	}
+
+	cmd.Println()
+	cmd.Println("Summary of the " + strconv.Itoa(len(contractPaths)) + " contracts:")
+	for _, contractPath := range contractPaths {
+		if err, failed := failures[contractPath]; failed {
+			cmd.Println("  " + colorRed + "FAIL" + colorReset + " " + contractPath + ": " + err.Error())
+		} else {
+			cmd.Println("  " + colorGreen + "OK" + colorReset + "   " + contractPath)
+		}
+	}
+
+	if len(failures) != 0 {
+		cmd.Root().SilenceUsage = true
+		return failedError(strconv.Itoa(len(failures)) + " of the " + strconv.Itoa(len(contractPaths)) + " contracts failed to publish")
+	}
+
+	return nil
+}
+
+/*
+lists the contracts that --path names. A directory names every .json, .yaml,
+and .yml file directly in it, and a path with *, ?, or [ is matched as a
+glob. The bool reports whether --path named a directory or glob rather than
+a single file.
+*/
+func contractPathsIn(path string) ([]string, bool, error) {
+	if path == utils.StdinPath {
+		return []string{path}, false, nil
+	}
+
+	var matches []string
+	if strings.ContainsAny(path, "*?[") {
+		var err error
+		matches, err = filepath.Glob(path)
+		if err != nil {
+			return nil, false, errors.New("--path is not a valid glob: " + err.Error())
+		}
+	} else if info, err := os.Stat(path); err == nil && info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".json", ".yaml", ".yml":
+				matches = append(matches, filepath.Join(path, entry.Name()))
+			}
+		}
+	} else {
+		return []string{path}, false, nil
+	}
+
+	contractPaths := []string{}
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			contractPaths = append(contractPaths, match)
+		}
+	}
+
+	if len(contractPaths) == 0 {
+		return nil, false, errors.New("no contracts were found at --path " + path)
+	}
+
+	return contractPaths, true, nil
+}
+
+/*
+handles a consumer version that was already published, for example by a
+parallel pipeline, by either skipping the publish or republishing with a
+uniquely suffixed version
+*/
+func resolvePublishConflict(cmd *cobra.Command, publishOptions utils.PublishOptions) error {
+	if onConflict == "skip" {
+		cmd.Println("Skipped - version " + version + " of the consumer is already published to the Signet broker, --on-conflict is skip")
+		return nil
+	}
+
+	suffix := make([]byte, 4)
+	_, err := rand.Read(suffix)
+	if err != nil {
+		return err
+	}
+
+	conflictingVersion := version
+	version = version + "-" + hex.EncodeToString(suffix)
+
+	err = signet.PublishConsumer(cmd.Context(), consumerContract(publishOptions))
+	if err != nil {
+		return err
+	}
+
+	err = writeVersionOutput(cmd, version)
+	if err != nil {
+		return err
+	}
+
+	cmd.Println("Retried - version " + conflictingVersion + " of the consumer is already published, the contract was published as version " + version)
+	fmt.Println(colorGreen + "Published" + colorReset + " - consumer contract published to Signet broker")
+	notifyPublishWebhook(cmd, version, branch)
+	return nil
+}
+
+/*
+posts the participant, version, branch, and contract type that was published
+to --webhook, so that downstream pipelines can be triggered. The contract is
+already stored by the broker, so a webhook that cannot be reached is only a
+warning.
+*/
+func notifyPublishWebhook(cmd *cobra.Command, publishedVersion, publishedBranch string) {
+	if len(webhookURL) == 0 {
+		return
+	}
+
+	participantName := name
+	if serviceType == "consumer" {
+		contract, err := utils.LoadContract(path)
+		if err != nil {
+			cmd.Println("Warning - the --webhook was not notified: " + err.Error())
+			return
+		}
+		participantName = contract.Consumer.Name
+	}
+
+	requestBody, err := json.Marshal(utils.PublishWebhookBody{
+		ParticipantName:    participantName,
+		ParticipantVersion: publishedVersion,
+		ParticipantBranch:  publishedBranch,
+		ContractType:       serviceType,
+	})
+	if err == nil {
+		err = client.NotifyWebhook(webhookURL, requestBody)
+	}
+
+	if err != nil {
+		cmd.Println("Warning - the contract was published, but the --webhook could not be notified: " + err.Error())
+		return
+	}
+
+	cmd.Println("Notified - posted the publish to " + webhookURL)
+}
+
+/*
+parses a --ttl duration, which can also be given in days (ex. 14d). TTLs
+shorter than a minute or longer than a year are rejected.
+*/
+func parseTTL(value string) (time.Duration, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
+
+	var duration time.Duration
+	days, isDays := strings.CutSuffix(value, "d")
+	if n, err := strconv.Atoi(days); isDays && err == nil {
+		duration = time.Duration(n) * 24 * time.Hour
+	} else {
+		duration, err = time.ParseDuration(value)
+		if err != nil {
+			return 0, errors.New("--ttl must be a duration such as 72h or 14d, --ttl was " + value)
+		}
+	}
+
+	if duration < time.Minute || duration > 365*24*time.Hour {
+		return 0, errors.New("--ttl must be between 1m and 365d, --ttl was " + value)
+	}
+
+	return duration, nil
+}
+
+/*
+checks --branch, or the git branch of HEAD when --branch is not set, against
+the --only-branches allowlist of branch names and glob patterns
+*/
+func branchIsAllowed(branch string, allowlist []string) (string, bool, error) {
+	if branch == "" || branch == "auto" {
+		var err error
+		branch, err = utils.SetBranchToCurrentGit(branch)
+		if err != nil {
+			return "", false, err
+		}
+	}
+
+	for _, pattern := range allowlist {
+		if utils.MatchGlob(pattern, branch) {
+			return branch, true, nil
+		}
+	}
+
+	return branch, false, nil
+}
+
 func init() {
 	RootCmd.AddCommand(publishCmd)
 
-	publishCmd.Flags().StringVarP(&path, "path", "p", "", "Relative path from the root directory to the contract or spec file")
+	publishCmd.Flags().StringVarP(&path, "path", "p", "", "Relative path from the root directory to the contract or spec file, or '-' to read it from stdin")
+	publishCmd.Flags().StringVar(&pathRelativeTo, "path-relative-to", "cwd", "what --path is relative to, either \"cwd\" or \"git-root\"")
 	publishCmd.Flags().StringVarP(&serviceType, "type", "t", "", "Type of the participant (\"consumer\" or \"provider\")")
 	publishCmd.Flags().StringVarP(&branch, "branch", "b", "", "git branch name (optional, only for --type 'consumer', defaults to git branch of HEAD)")
 	publishCmd.Flags().StringVarP(&name, "name", "n", "", "canonical name of the provider service (only for —-type 'provider')")
-	publishCmd.Flags().StringVarP(&version, "version", "v", "", "service version (only for --type 'consumer', if flag not passed or passed without value, defaults to the git SHA of HEAD)")
+	publishCmd.Flags().StringVarP(&version, "version", "v", "", "service version (only for --type 'consumer', if not passed, defaults to SIGNET_VERSION, and then to the git SHA of HEAD)")
+	publishCmd.Flags().StringVar(&versionOutput, "version-output", "", "File that the resolved version is written to, or '-' for stdout (only for --type 'consumer')")
+	publishCmd.Flags().StringArrayVar(&tags, "tag", []string{}, "a tag for the consumer contract, ex. stable (repeatable, only for --type 'consumer')")
+	publishCmd.Flags().StringSliceVar(&tagsFromEnv, "tags-from-env", []string{}, "comma separated names of environment variables whose values are added as contract tags (only for --type 'consumer')")
+	publishCmd.Flags().StringSliceVar(&onlyBranches, "only-branches", []string{}, "comma separated branch names or glob patterns, publishing is skipped when the branch matches none of them")
+	publishCmd.Flags().StringVar(&changedSince, "changed-since", "", "git ref, publishing is skipped when the contract or --source-path has not changed since it")
+	publishCmd.Flags().StringSliceVar(&sourcePaths, "source-path", []string{}, "comma separated paths checked by --changed-since instead of the contract or spec")
+	publishCmd.Flags().StringVar(&ttl, "ttl", "", "how long the broker keeps the contract before expiring it, ex. 72h or 14d (only for --type 'consumer')")
+	publishCmd.Flags().StringVar(&onConflict, "on-conflict", "fail", "what to do when the consumer version is already published, either \"fail\", \"skip\", or \"retry-with-suffix\" (only for --type 'consumer')")
+	publishCmd.Flags().StringVar(&contractType, "contract-type", "", "the type of provider spec, either \"openapi\", \"graphql\", or \"asyncapi\" (only for --type 'provider', defaults to \"graphql\" for .graphql and .gql files, \"asyncapi\" for documents with an asyncapi key, and \"openapi\" otherwise)")
+	publishCmd.Flags().BoolVar(&skipValidation, "skip-validation", false, "Publish the spec without checking that it is a valid OpenAPI document, or the contract without checking that it is a valid pact")
+	publishCmd.Flags().IntVar(&maxSpecSize, "max-spec-size", defaultMaxSpecSize, "the largest spec in bytes that is sent to the broker (only for --type 'provider')")
+	publishCmd.Flags().IntVar(&schemaVersion, "schema-version", 0, "contract schema version to publish with (only for --type 'consumer', defaults to the highest version supported by both signet and the broker)")
+	publishCmd.Flags().StringVar(&webhookURL, "webhook", "", "URL that a JSON notification is posted to once the contract or spec is published")
+	publishCmd.Flags().BoolVar(&showDiff, "diff", false, "Print how the interactions changed since the contract was last published, and ask for confirmation before publishing (only for --type 'consumer')")
+	publishCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Publish without asking for confirmation after --diff")
+	publishCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the request that would be sent to the broker, without sending it")
 	publishCmd.Flags().Lookup("version").NoOptDefVal = "auto"
 	publishCmd.Flags().Lookup("branch").NoOptDefVal = "auto"
 
 	viper.BindPFlag("publish.path", publishCmd.Flags().Lookup("path"))
+	viper.BindPFlag("publish.path-relative-to", publishCmd.Flags().Lookup("path-relative-to"))
 	viper.BindPFlag("publish.type", publishCmd.Flags().Lookup("type"))
 	viper.BindPFlag("publish.name", publishCmd.Flags().Lookup("name"))
+	viper.BindPFlag("publish.version-output", publishCmd.Flags().Lookup("version-output"))
+	viper.BindPFlag("publish.tag", publishCmd.Flags().Lookup("tag"))
+	viper.BindPFlag("publish.tags-from-env", publishCmd.Flags().Lookup("tags-from-env"))
+	viper.BindPFlag("publish.schema-version", publishCmd.Flags().Lookup("schema-version"))
+	viper.BindPFlag("publish.changed-since", publishCmd.Flags().Lookup("changed-since"))
+	viper.BindPFlag("publish.source-path", publishCmd.Flags().Lookup("source-path"))
+	viper.BindPFlag("publish.ttl", publishCmd.Flags().Lookup("ttl"))
+	viper.BindPFlag("publish.only-branches", publishCmd.Flags().Lookup("only-branches"))
+	viper.BindPFlag("publish.on-conflict", publishCmd.Flags().Lookup("on-conflict"))
+	viper.BindPFlag("publish.max-spec-size", publishCmd.Flags().Lookup("max-spec-size"))
+	viper.BindPFlag("publish.contract-type", publishCmd.Flags().Lookup("contract-type"))
+	viper.BindPFlag("publish.skip-validation", publishCmd.Flags().Lookup("skip-validation"))
+	viper.BindPFlag("publish.webhook-url", publishCmd.Flags().Lookup("webhook"))
+	viper.BindPFlag("publish.diff", publishCmd.Flags().Lookup("diff"))
+	viper.BindPFlag("publish.yes", publishCmd.Flags().Lookup("yes"))
 }
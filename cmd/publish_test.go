@@ -2,8 +2,23 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 
+	client "github.com/signet-framework/signet-cli/client"
+	signet "github.com/signet-framework/signet-cli/signet"
 	utils "github.com/signet-framework/signet-cli/utils"
 )
 
@@ -154,138 +169,1884 @@ func TestPublishConsumerWithVersion(t *testing.T) {
 	teardown()
 }
 
+func TestPublishConsumerNotifiesWebhook(t *testing.T) {
+	server, _ := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+	webhook, webhookBody := mockServerForJSONReq201Created[utils.PublishWebhookBody](t)
+	defer webhook.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--branch=main",
+		"--webhook", webhook.URL,
+	}
+	actual := callPublish(flags)
+
+	t.Run("posts the published participant, version, branch, and contract type", func(t *testing.T) {
+		expected := utils.PublishWebhookBody{ParticipantName: "service_1", ParticipantVersion: "version1", ParticipantBranch: "main", ContractType: "consumer"}
+		if *webhookBody != expected {
+			t.Errorf("%+v", *webhookBody)
+		}
+	})
+
+	t.Run("reports the notification", func(t *testing.T) {
+		actual.startsWith("Notified - posted the publish to "+webhook.URL, t)
+	})
+	teardown()
+}
+
+func TestPublishProviderWebhookUnreachable(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.ProviderBody](t)
+	defer server.Close()
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer webhook.Close()
+
+	flags := []string{
+		"--path=../data_test/api-spec.json",
+		"--broker-url", server.URL,
+		"--type", "provider",
+		"--name", "user_service",
+		"--webhook", webhook.URL,
+	}
+	actual := callPublish(flags)
+
+	t.Run("still publishes the spec", func(t *testing.T) {
+		if reqBody.ProviderName != "user_service" {
+			t.Error(reqBody)
+		}
+	})
+
+	t.Run("warns instead of failing", func(t *testing.T) {
+		expected := "Warning - the contract was published, but the --webhook could not be notified: the webhook responded 502 Bad Gateway"
+		actual.startsWith(expected, t)
+	})
+	teardown()
+}
+
+func TestPublishInvalidWebhook(t *testing.T) {
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url=http://localhost:3000",
+		"--type", "consumer",
+		"--webhook", "hooks.example.com/signet",
+	}
+	actual := callPublish(flags)
+	expected := "Error: --webhook must be an http or https URL, --webhook was hooks.example.com/signet"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestPublishConsumerYAMLContract(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.yaml",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+	}
+	callPublish(flags)
+
+	t.Run("has correct consumerName", func(t *testing.T) {
+		if reqBody.ConsumerName != "service_1" {
+			t.Error()
+		}
+	})
+
+	t.Run("has the interactions of the contract", func(t *testing.T) {
+		interactions, ok := reqBody.Contract.Interactions.([]interface{})
+		if !ok || len(interactions) != 1 {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestPublishConsumerFromStdin(t *testing.T) {
+	contractBytes, err := os.ReadFile("../data_test/cons-prov.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path", "-",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--branch=main",
+	}
+	RootCmd.SetIn(bytes.NewReader(contractBytes))
+	callPublish(flags)
+
+	t.Run("publishes the contract read from stdin", func(t *testing.T) {
+		if reqBody.ConsumerName != "service_1" || reqBody.ConsumerVersion != "version1" {
+			t.Error(reqBody)
+		}
+	})
+
+	t.Run("parses a YAML contract", func(t *testing.T) {
+		interactions, ok := reqBody.Contract.Interactions.([]interface{})
+		if !ok || len(interactions) != 1 {
+			t.Error(reqBody.Contract)
+		}
+	})
+	teardown()
+}
+
+func TestPublishProviderFromStdin(t *testing.T) {
+	specBytes, err := os.ReadFile("../data_test/api-spec.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, reqBody := mockServerForJSONReq201Created[utils.ProviderBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path", "-",
+		"--broker-url", server.URL,
+		"--type", "provider",
+		"--name", "user_service",
+	}
+	RootCmd.SetIn(bytes.NewReader(specBytes))
+	callPublish(flags)
+
+	t.Run("sniffs the spec as json", func(t *testing.T) {
+		if reqBody.ProviderName != "user_service" || reqBody.SpecFormat != "json" {
+			t.Error(reqBody)
+		}
+	})
+	teardown()
+}
+
+func TestPublishEmptyStdin(t *testing.T) {
+	flags := []string{
+		"--path", "-",
+		"--broker-url", "http://localhost:3000",
+		"--type", "consumer",
+	}
+	RootCmd.SetIn(strings.NewReader("\n"))
+	actual := callPublish(flags)
+	expected := "Error: --path is -, but nothing was read from stdin"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestPublishProviderSpecWithoutExtension(t *testing.T) {
+	specBytes, err := os.ReadFile("../data_test/api-spec.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	specPath := t.TempDir() + "/api-spec"
+	err = os.WriteFile(specPath, specBytes, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, reqBody := mockServerForJSONReq201Created[utils.ProviderBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path", specPath,
+		"--broker-url", server.URL,
+		"--type", "provider",
+		"--name", "user_service",
+	}
+	callPublish(flags)
+
+	t.Run("sniffs the spec as yaml", func(t *testing.T) {
+		if reqBody.SpecFormat != "yaml" {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+// writes a copy of the consumer contract fixture for each provider to a new directory
+func writeContractsForProviders(t *testing.T, providerNames ...string) string {
+	contractBytes, err := os.ReadFile("../data_test/cons-prov.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	for _, providerName := range providerNames {
+		contract := strings.ReplaceAll(string(contractBytes), "user_service", providerName)
+		err = os.WriteFile(filepath.Join(dir, providerName+".json"), []byte(contract), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = os.WriteFile(filepath.Join(dir, "README.md"), []byte("not a contract"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func TestPublishMultipleConsumerContracts(t *testing.T) {
+	dir := writeContractsForProviders(t, "orders_service", "user_service")
+
+	var published []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/capabilities" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var reqBody utils.ConsumerBody
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		published = append(published, reqBody.ConsumerVersion+" "+reqBody.ConsumerBranch)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	for _, contractPath := range []string{dir, filepath.Join(dir, "*.json")} {
+		published = nil
+		flags := []string{
+			"--path", contractPath,
+			"--broker-url", server.URL,
+			"--type", "consumer",
+			"--version=1.0.0",
+			"--branch=main",
+		}
+		actual := callPublish(flags)
+
+		t.Run("publishes every contract in "+contractPath+" with the same version and branch", func(t *testing.T) {
+			if len(published) != 2 || published[0] != "1.0.0 main" || published[1] != "1.0.0 main" {
+				t.Error(published)
+			}
+		})
+
+		t.Run("prints a summary of "+contractPath, func(t *testing.T) {
+			if !strings.Contains(actual.actual, "Summary of the 2 contracts:") || !strings.Contains(actual.actual, filepath.Join(dir, "orders_service.json")) {
+				t.Error(actual.actual)
+			}
+		})
+		teardown()
+	}
+}
+
+func TestPublishMultipleConsumerContractsWithFailure(t *testing.T) {
+	dir := writeContractsForProviders(t, "orders_service", "user_service")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/capabilities" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		bodyBytes, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(bodyBytes), "orders_service") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid contract"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	args := []string{
+		"publish",
+		"--path", dir,
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=1.0.0",
+	}
+	actual := callPublish(args[1:])
+	teardown()
+
+	t.Run("reports which contract failed", func(t *testing.T) {
+		expected := "FAIL" + colorReset + " " + filepath.Join(dir, "orders_service.json")
+		if !strings.Contains(actual.actual, expected) || !strings.Contains(actual.actual, "1 of the 2 contracts failed to publish") {
+			t.Error(actual.actual)
+		}
+	})
+
+	t.Run("exits with 1", func(t *testing.T) {
+		if exitCode := exitCodeOf(args); exitCode != exitFailed {
+			t.Error(exitCode)
+		}
+	})
+	teardown()
+}
+
+func TestPublishProviderDirectory(t *testing.T) {
+	flags := []string{
+		"--path", "../data_test",
+		"--broker-url", "http://localhost:3000",
+		"--type", "provider",
+		"--name", "user_service",
+	}
+	actual := callPublish(flags)
+	expected := "Error: --path can only be a directory or glob for --type 'consumer', --path was ../data_test"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestPublishGlobWithNoMatches(t *testing.T) {
+	flags := []string{
+		"--path", "../data_test/*.pact",
+		"--broker-url", "http://localhost:3000",
+		"--type", "consumer",
+	}
+	actual := callPublish(flags)
+	expected := "Error: no contracts were found at --path ../data_test/*.pact"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+/*
+serves a prior version of the consumer contract, in which the user was named
+jimmy and there was a second interaction, and records each publish
+*/
+func mockBrokerWithPriorContract(t *testing.T) (*httptest.Server, *int) {
+	prior := loadPactMap(t, "../data_test/cons-prov.json")
+	interaction := prior["interactions"].([]interface{})[0].(map[string]interface{})
+	interaction["response"].(map[string]interface{})["body"].(map[string]interface{})["username"] = "jimmy"
+	prior["interactions"] = append(prior["interactions"].([]interface{}), map[string]interface{}{
+		"description": "a request to delete the user",
+		"request":     map[string]interface{}{"method": "DELETE", "path": "/users/1"},
+		"response":    map[string]interface{}{"status": 204},
+	})
+	priorBytes, err := json.Marshal(prior)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	published := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/contracts/versions":
+			w.Write([]byte(`[{"participantName": "service_1", "participantVersion": "0.9.0", "contractType": "consumer"}]`))
+		case r.URL.Path == "/api/contracts" && r.Method == http.MethodGet:
+			if r.URL.Query().Get("consumerVersion") != "0.9.0" || r.URL.Query().Get("provider") != "user_service" {
+				t.Error(r.URL.RawQuery)
+			}
+			w.Write(priorBytes)
+		case r.URL.Path == "/api/contracts":
+			published++
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return server, &published
+}
+
+func TestPublishDiff(t *testing.T) {
+	server, published := mockBrokerWithPriorContract(t)
+	defer server.Close()
+
+	flags := []string{
+		"--path", "../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=1.0.0",
+		"--diff",
+	}
+
+	t.Run("prints the interactions that changed", func(t *testing.T) {
+		RootCmd.SetIn(strings.NewReader("n\n"))
+		actual := callPublish(flags)
+		expected := "Diff - interactions with user_service that changed since version 0.9.0:\n" +
+			"  " + colorBlue + "~" + colorReset + " a request for the user with a userId of 1\n" +
+			"      response.body.username: \"jimmy\" -> \"mimmy\"\n" +
+			"  " + colorRed + "-" + colorReset + " a request to delete the user\n"
+
+		actual.startsWith(expected, t)
+		teardown()
+	})
+
+	t.Run("does not publish unless confirmed", func(t *testing.T) {
+		*published = 0
+		RootCmd.SetIn(strings.NewReader("n\n"))
+		actual := callPublish(flags)
+
+		if *published != 0 || !strings.Contains(actual.actual, "Publish this contract to the Signet broker? [y/N] Cancelled - nothing was published") {
+			t.Error(actual.actual)
+		}
+		teardown()
+	})
+
+	t.Run("publishes once confirmed", func(t *testing.T) {
+		*published = 0
+		RootCmd.SetIn(strings.NewReader("y\n"))
+		callPublish(flags)
+
+		if *published != 1 {
+			t.Error(*published)
+		}
+		teardown()
+	})
+
+	t.Run("does not ask with --yes", func(t *testing.T) {
+		*published = 0
+		actual := callPublish(append(flags, "--yes"))
+
+		if *published != 1 || strings.Contains(actual.actual, "[y/N]") {
+			t.Error(actual.actual)
+		}
+		teardown()
+	})
+}
+
+func TestPublishDiffProvider(t *testing.T) {
+	flags := []string{
+		"--path", "../data_test/api-spec.json",
+		"--broker-url", "http://localhost:3000",
+		"--type", "provider",
+		"--name", "user_service",
+		"--diff",
+	}
+	actual := callPublish(flags)
+	expected := "Error: --diff is only for --type 'consumer'"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestDiffInteractionsUnchanged(t *testing.T) {
+	pact, err := utils.LoadContract("../data_test/cons-prov.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := utils.DiffInteractions(pact, pact)
+	if err != nil || len(changes) != 0 {
+		t.Error(changes, err)
+	}
+}
+
+/*
+changes into an empty directory outside any git repository for the rest of
+the test, and returns the absolute path of the consumer contract fixture
+*/
+func chdirOutsideGitRepo(t *testing.T) string {
+	contractPath, err := filepath.Abs("../data_test/cons-prov.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	t.Setenv("GIT_CEILING_DIRECTORIES", filepath.Dir(dir))
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	return contractPath
+}
+
+/*
+changes into a new git repository whose HEAD is detached from any branch,
+as CI systems check it out, and returns the absolute path of the consumer
+contract fixture
+*/
+func chdirDetachedGitRepo(t *testing.T) string {
+	contractPath := chdirOutsideGitRepo(t)
+
+	gitCommands := [][]string{
+		{"init", "--quiet"},
+		{"-c", "user.name=signet", "-c", "user.email=signet@example.com", "commit", "--quiet", "--allow-empty", "-m", "initial"},
+		{"checkout", "--quiet", "--detach"},
+	}
+	for _, args := range gitCommands {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			t.Fatal(string(out))
+		}
+	}
+
+	return contractPath
+}
+
+func TestPublishConsumerDetachedHeadUsesCIBranch(t *testing.T) {
+	contractPath := chdirDetachedGitRepo(t)
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path", contractPath,
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--branch",
+	}
+
+	t.Run("reads CI_COMMIT_BRANCH", func(t *testing.T) {
+		t.Setenv("CI_COMMIT_BRANCH", "feature/gitlab")
+		t.Setenv("GITHUB_HEAD_REF", "")
+		t.Setenv("GITHUB_REF_NAME", "main")
+		callPublish(append([]string{}, flags...))
+
+		if reqBody.ConsumerBranch != "feature/gitlab" {
+			t.Error(reqBody.ConsumerBranch)
+		}
+		teardown()
+	})
+
+	t.Run("reads GITHUB_REF_NAME", func(t *testing.T) {
+		t.Setenv("CI_COMMIT_BRANCH", "")
+		t.Setenv("GITHUB_HEAD_REF", "")
+		t.Setenv("GITHUB_REF_NAME", "main")
+		callPublish(append([]string{}, flags...))
+
+		if reqBody.ConsumerBranch != "main" {
+			t.Error(reqBody.ConsumerBranch)
+		}
+		teardown()
+	})
+}
+
+func TestPublishConsumerUsesEnvVersion(t *testing.T) {
+	t.Setenv("SIGNET_VERSION", "1.4.0")
+
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+	}
+
+	t.Run("prefers SIGNET_VERSION to the git SHA of HEAD", func(t *testing.T) {
+		callPublish(flags)
+		if reqBody.ConsumerVersion != "1.4.0" {
+			t.Error(reqBody.ConsumerVersion)
+		}
+		teardown()
+	})
+
+	t.Run("prefers --version to SIGNET_VERSION", func(t *testing.T) {
+		callPublish(append([]string{"--version=version1"}, flags...))
+		if reqBody.ConsumerVersion != "version1" {
+			t.Error(reqBody.ConsumerVersion)
+		}
+		teardown()
+	})
+}
+
+func TestPublishConsumerOutsideGitRepoUsesEnvVersion(t *testing.T) {
+	contractPath := chdirOutsideGitRepo(t)
+	t.Setenv("SIGNET_VERSION", "1.4.0")
+	t.Setenv("SIGNET_BRANCH", "release")
+
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path", contractPath,
+		"--broker-url", server.URL,
+		"--type", "consumer",
+	}
+	callPublish(flags)
+
+	if reqBody.ConsumerVersion != "1.4.0" || reqBody.ConsumerBranch != "release" {
+		t.Error(reqBody.ConsumerVersion, reqBody.ConsumerBranch)
+	}
+	teardown()
+}
+
+func TestPublishConsumerOutsideGitRepoUsesTimestampVersion(t *testing.T) {
+	contractPath := chdirOutsideGitRepo(t)
+	t.Setenv("SIGNET_VERSION", "")
+	t.Setenv("SIGNET_BRANCH", "")
+
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path", contractPath,
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version-output", "-",
+	}
+	actual := callPublish(flags)
+
+	t.Run("publishes a timestamp version instead of failing", func(t *testing.T) {
+		if !regexp.MustCompile(`^build-\d{8}T\d{6}Z$`).MatchString(reqBody.ConsumerVersion) || reqBody.ConsumerBranch != "" {
+			t.Error(reqBody.ConsumerVersion, reqBody.ConsumerBranch)
+		}
+	})
+
+	t.Run("keeps the warning out of the version output", func(t *testing.T) {
+		if actual.actual != reqBody.ConsumerVersion+"\n" {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestPublishProviderGraphQLSchema(t *testing.T) {
+	schemaBytes, err := os.ReadFile("../data_test/user-service.graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, reqBody := mockServerForJSONReq201Created[utils.ProviderBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path", "../data_test/user-service.graphql",
+		"--broker-url", server.URL,
+		"--type", "provider",
+		"--name", "user_service",
+	}
+	callPublish(flags)
+
+	t.Run("detects the schema as graphql", func(t *testing.T) {
+		if reqBody.SpecFormat != "graphql" {
+			t.Error(reqBody.SpecFormat)
+		}
+	})
+
+	t.Run("sends the schema text", func(t *testing.T) {
+		if reqBody.Spec != string(schemaBytes) {
+			t.Error(reqBody.Spec)
+		}
+	})
+	teardown()
+}
+
+func TestPublishProviderContractTypeGraphQL(t *testing.T) {
+	schemaBytes, err := os.ReadFile("../data_test/user-service.graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemaPath := t.TempDir() + "/schema.txt"
+	err = os.WriteFile(schemaPath, schemaBytes, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, reqBody := mockServerForJSONReq201Created[utils.ProviderBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path", schemaPath,
+		"--broker-url", server.URL,
+		"--type", "provider",
+		"--name", "user_service",
+		"--contract-type", "graphql",
+	}
+	callPublish(flags)
+
+	if reqBody.SpecFormat != "graphql" || reqBody.Spec != string(schemaBytes) {
+		t.Error(reqBody)
+	}
+	teardown()
+}
+
+func TestPublishProviderAsyncAPISpec(t *testing.T) {
+	specBytes, err := os.ReadFile("../data_test/user-events.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, reqBody := mockServerForJSONReq201Created[utils.ProviderBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path", "../data_test/user-events.yaml",
+		"--broker-url", server.URL,
+		"--type", "provider",
+		"--name", "user_service",
+	}
+	callPublish(flags)
+
+	t.Run("detects the spec as asyncapi", func(t *testing.T) {
+		if reqBody.SpecFormat != "asyncapi" {
+			t.Error(reqBody.SpecFormat)
+		}
+	})
+
+	t.Run("sends the document", func(t *testing.T) {
+		if reqBody.Spec != string(specBytes) {
+			t.Error(reqBody.Spec)
+		}
+	})
+	teardown()
+}
+
+func TestPublishProviderContractTypeAsyncAPI(t *testing.T) {
+	t.Run("publishes the spec as asyncapi", func(t *testing.T) {
+		server, reqBody := mockServerForJSONReq201Created[utils.ProviderBody](t)
+		defer server.Close()
+
+		flags := []string{
+			"--path", "../data_test/user-events.yaml",
+			"--broker-url", server.URL,
+			"--type", "provider",
+			"--name", "user_service",
+			"--contract-type", "asyncapi",
+		}
+		callPublish(flags)
+
+		if reqBody.SpecFormat != "asyncapi" {
+			t.Error(reqBody.SpecFormat)
+		}
+		teardown()
+	})
+
+	t.Run("rejects a document without an asyncapi key", func(t *testing.T) {
+		flags := []string{
+			"--path", "../data_test/api-spec.json",
+			"--broker-url=http://localhost:3000",
+			"--type", "provider",
+			"--name", "user_service",
+			"--contract-type", "asyncapi",
+		}
+		actual := callPublish(flags)
+		expected := "Error: the API spec is not a valid AsyncAPI document - asyncapi is required at /asyncapi"
+
+		actual.startsWith(expected, t)
+		teardown()
+	})
+}
+
+func TestPublishInvalidContractType(t *testing.T) {
+	flags := []string{
+		"--path", "../data_test/user-service.graphql",
+		"--broker-url=http://localhost:3000",
+		"--type", "provider",
+		"--name", "user_service",
+		"--contract-type", "soap",
+	}
+	actual := callPublish(flags)
+	expected := "Error: --contract-type must be either \"openapi\", \"graphql\", or \"asyncapi\", --contract-type was soap"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestPublishConsumerContractType(t *testing.T) {
+	flags := []string{
+		"--path", "../data_test/cons-prov.json",
+		"--broker-url=http://localhost:3000",
+		"--type", "consumer",
+		"--contract-type", "graphql",
+	}
+	actual := callPublish(flags)
+	expected := "Error: --contract-type is only for --type 'provider'"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestPublishProviderInvalidSpec(t *testing.T) {
+	specPath := t.TempDir() + "/api-spec.yaml"
+	spec := `openapi: 3.0.2
+info:
+  title: user_service_api
+  version: "1"
+paths:
+  /users/{id}:
+    get:
+      parameters:
+        - name: id
+          in: path
+          required: true
+      responses:
+        "200":
+          descripton: Successful request
+`
+	err := os.WriteFile(specPath, []byte(spec), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--path", specPath,
+		"--broker-url", server.URL,
+		"--type", "provider",
+		"--name", "user_service",
+	}
+
+	t.Run("refuses to publish, naming the invalid node", func(t *testing.T) {
+		actual := callPublish(flags)
+		expected := "Error: the API spec is not a valid OpenAPI document - description is required at /paths/~1users~1{id}/get/responses/200"
+
+		actual.startsWith(expected, t)
+		if requests != 0 {
+			t.Error(requests)
+		}
+		teardown()
+	})
+
+	t.Run("publishes with --skip-validation", func(t *testing.T) {
+		callPublish(append(flags, "--skip-validation"))
+		if requests != 1 {
+			t.Error(requests)
+		}
+		teardown()
+	})
+}
+
+func TestLibraryPublish(t *testing.T) {
+	t.Run("publishes a provider spec", func(t *testing.T) {
+		server, reqBody := mockServerForJSONReq201Created[utils.ProviderBody](t)
+		defer server.Close()
+
+		err := signet.PublishProvider(context.Background(), signet.ProviderSpec{BrokerURL: server.URL, Name: "user_service", Path: "../data_test/api-spec.yaml"})
+		if err != nil || reqBody.ProviderName != "user_service" || reqBody.SpecFormat != "yaml" {
+			t.Error(err, reqBody)
+		}
+	})
+
+	t.Run("publishes a consumer contract", func(t *testing.T) {
+		server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+		defer server.Close()
+
+		err := signet.PublishConsumer(context.Background(), signet.ConsumerContract{BrokerURL: server.URL, Path: "../data_test/cons-prov.json", Version: "version1", Branch: "main", SchemaVersion: 1})
+		if err != nil || reqBody.ConsumerName != "service_1" || reqBody.ConsumerVersion != "version1" {
+			t.Error(err, reqBody)
+		}
+	})
+
+	t.Run("returns a typed error for an invalid pact", func(t *testing.T) {
+		contractPath := t.TempDir() + "/cons-prov.json"
+		os.WriteFile(contractPath, []byte(`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}}`), 0644)
+
+		err := signet.PublishConsumer(context.Background(), signet.ConsumerContract{BrokerURL: "http://localhost:3000", Path: contractPath, Version: "version1", Branch: "main", SchemaVersion: 1})
+		var pactErr *utils.PactValidationError
+		if !errors.As(err, &pactErr) || pactErr.Field != "interactions" {
+			t.Error(err)
+		}
+	})
+
+	t.Run("requires a broker URL", func(t *testing.T) {
+		err := signet.PublishConsumer(context.Background(), signet.ConsumerContract{Path: "../data_test/cons-prov.json"})
+		if err == nil || err.Error() != "no BrokerURL was provided, it is required" {
+			t.Error(err)
+		}
+	})
+}
+
+func TestValidateOpenAPI(t *testing.T) {
+	cases := []struct {
+		spec     string
+		expected string
+	}{
+		{`{"openapi": "3.0.2", "info": {"title": "api", "version": "1"}, "paths": {}}`, ""},
+		{`{"swagger": "2.0", "info": {"title": "api", "version": "1"}, "paths": {"/users": {"post": {"parameters": [{"name": "user", "in": "body"}], "responses": {"201": {"description": "created"}}}}}}`, ""},
+		{`{"info": {"title": "api", "version": "1"}, "paths": {}}`, "openapi is required at /"},
+		{`{"openapi": "3.0.2", "info": {"title": "api"}, "paths": {}}`, "version is required at /info"},
+		{`{"openapi": "3.0.2", "info": {"title": "api", "version": "1"}}`, "paths is required at /"},
+		{`{"openapi": "3.0.2", "info": {"title": "api", "version": "1"}, "paths": {"users": {}}}`, "path users must begin with / at /paths/users"},
+		{`{"openapi": "3.0.2", "info": {"title": "api", "version": "1"}, "paths": {"/users": {"gett": {}}}}`, "unknown field gett in a path item at /paths/~1users/gett"},
+		{`{"openapi": "3.0.2", "info": {"title": "api", "version": "1"}, "paths": {"/users": {"get": {}}}}`, "responses is required at /paths/~1users/get"},
+		{`{"openapi": "3.0.2", "info": {"title": "api", "version": "1"}, "paths": {"/users": {"get": {"responses": {"ok": {"description": "ok"}}}}}}`, "response ok must be an HTTP status code, a range such as 4XX, or default at /paths/~1users/get/responses/ok"},
+		{`{"openapi": "3.0.2", "info": {"title": "api", "version": "1"}, "paths": {"/users/{id}": {"parameters": [{"name": "id", "in": "path"}], "get": {"responses": {"200": {"description": "ok"}}}}}}`, "path parameter id must be required at /paths/~1users~1{id}/parameters/0/required"},
+		{`{"openapi": "3.0.2", "info": {"title": "api", "version": "1"}, "paths": {"/users": {"get": {"responses": {"200": {"$ref": "#/components/responses/User"}}}}}}`, "$ref #/components/responses/User does not resolve at /paths/~1users/get/responses/200/$ref"},
+		{`{"openapi": "3.0.2", "info": {"title": "api", "version": "1"}, "paths": {"/users": {"x-internal": true, "get": {"responses": {"200": {"$ref": "#/components/responses/User"}}}}}, "components": {"responses": {"User": {"description": "ok"}}}}`, ""},
+		{"openapi: 3.0\ninfo:\n  title: api\n  version: 1.0\npaths: {}\n", ""},
+		{"openapi: 3.1\ninfo:\n  title: api\n  version: 2\n", ""},
+		{"swagger: 2.0\ninfo:\n  title: api\n  version: 1.0\npaths: {}\n", ""},
+		{"openapi: 2.0\ninfo:\n  title: api\n  version: 1.0\npaths: {}\n", "openapi must be a 3.x version string, openapi was 2 at /openapi"},
+		{`{"openapi": "3.0.2", "info": {"title": "api", "version": true}, "paths": {}}`, "version must be a string at /info/version"},
+	}
+
+	for _, c := range cases {
+		err := utils.ValidateOpenAPI([]byte(c.spec))
+		if len(c.expected) == 0 && err != nil {
+			t.Error(c.spec, err)
+		}
+		if len(c.expected) != 0 && (err == nil || !strings.HasSuffix(err.Error(), c.expected)) {
+			t.Error(c.spec, err)
+		}
+	}
+
+	for _, path := range []string{"../data_test/api-spec.json", "../data_test/api-spec.yaml", "../data_test/api-spec.yml"} {
+		err := utils.ValidateSpecFile(path, "")
+		if err != nil {
+			t.Error(path, err)
+		}
+	}
+}
+
+func TestPublishConsumerInvalidPact(t *testing.T) {
+	contractPath := t.TempDir() + "/cons-prov.json"
+	contract := `{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [{"description": "a request for a user", "request": {"path": "/users/1"}, "response": {"status": 200}}]}`
+	err := os.WriteFile(contractPath, []byte(contract), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--path", contractPath,
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--branch=main",
+		"--schema-version", "1",
+	}
+
+	t.Run("refuses to publish, naming the missing field", func(t *testing.T) {
+		actual := callPublish(flags)
+		expected := "Error: consumer contract is not a valid pact - interactions[0].request.method is required\n\nFix the contract, or pass --skip-validation to publish it anyway."
+
+		actual.startsWith(expected, t)
+		if requests != 0 {
+			t.Error(requests)
+		}
+		teardown()
+	})
+
+	t.Run("publishes with --skip-validation", func(t *testing.T) {
+		callPublish(append(flags, "--skip-validation"))
+		if requests != 1 {
+			t.Error(requests)
+		}
+		teardown()
+	})
+}
+
+func TestPublishConsumerRecordsPactSpecification(t *testing.T) {
+	contractPath := t.TempDir() + "/cons-prov.json"
+	contract := `{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [{"type": "Asynchronous/Messages", "description": "a user created event", "contents": {"content": {"userId": 1}}}], "metadata": {"pactSpecification": {"version": "4.0"}}}`
+	err := os.WriteFile(contractPath, []byte(contract), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	t.Run("records the version of a version 4 pact", func(t *testing.T) {
+		callPublish([]string{"--path", contractPath, "--broker-url", server.URL, "--type", "consumer", "--version=version1", "--branch=main", "--schema-version", "1"})
+		if reqBody.PactSpecification != "4.0" {
+			t.Error(reqBody.PactSpecification)
+		}
+		teardown()
+	})
+
+	t.Run("records the version of the fixture", func(t *testing.T) {
+		callPublish([]string{"--path", "../data_test/cons-prov.json", "--broker-url", server.URL, "--type", "consumer", "--version=version1", "--branch=main", "--schema-version", "1"})
+		if reqBody.PactSpecification != "3.0.0" {
+			t.Error(reqBody.PactSpecification)
+		}
+		teardown()
+	})
+}
+
+func TestValidatePact(t *testing.T) {
+	cases := []struct {
+		contract string
+		expected string
+	}{
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": []}`, ""},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [], "metadata": {"pact-specification": {"version": "2.0.0"}}}`, ""},
+		{`{"provider": {"name": "user_service"}, "interactions": []}`, "consumer.name is required"},
+		{`{"consumer": {"name": "service_1"}, "interactions": []}`, "provider.name is required"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": 1}, "interactions": []}`, "provider.name must be a string"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}}`, "interactions is required"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": {}}`, "interactions must be an array"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [{"request": {"method": "GET", "path": "/users"}, "response": {"status": 200}}]}`, "interactions[0].description is required"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [{"description": "a request for users", "response": {"status": 200}}]}`, "interactions[0].request is required"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [{"description": "a request for users", "request": {"method": "GET"}, "response": {"status": 200}}]}`, "interactions[0].request.path is required"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [{"description": "a request for users", "request": {"method": "GET", "path": "/users"}, "response": {}}]}`, "interactions[0].response.status is required"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [{"description": "a request for users", "request": {"method": "GET", "path": "/users"}, "response": {"status": "200"}}]}`, "interactions[0].response.status must be an HTTP status code"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [], "metadata": {"pactSpecification": {"version": "5.0"}}}`, "metadata.pactSpecification.version must be version 2, 3, or 4 of the pact specification, it was 5.0"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [], "metadata": {"pact-specification": {"version": "1.1.0"}}}`, "metadata.pact-specification.version must be version 2, 3, or 4 of the pact specification, it was 1.1.0"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "messages": [{"description": "a user created event", "contents": {"userId": 1}}], "metadata": {"pactSpecification": {"version": "3.0.0"}}}`, ""},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "messages": [{"contents": {"userId": 1}}], "metadata": {"pactSpecification": {"version": "3.0.0"}}}`, "messages[0].description is required"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [{"type": "Synchronous/HTTP", "description": "a request for users", "request": {"method": "GET", "path": "/users"}, "response": {"status": 200}}, {"type": "Asynchronous/Messages", "description": "a user created event", "contents": {"content": {"userId": 1}}}], "metadata": {"pactSpecification": {"version": "4.0"}}}`, ""},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [{"description": "a request for users", "request": {"method": "GET", "path": "/users"}, "response": {"status": 200}}], "metadata": {"pactSpecification": {"version": "4.0"}}}`, "interactions[0].type is required"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [{"type": "Synchronous/HTTP", "description": "a request for users", "request": {"path": "/users"}, "response": {"status": 200}}], "metadata": {"pactSpecification": {"version": "4.0"}}}`, "interactions[0].request.method is required"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [{"type": "Synchronous/Messages", "description": "a user lookup"}], "metadata": {"pactSpecification": {"version": "4.0"}}}`, "interactions[0].request is required"},
+		{`{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [{"type": "Asynchronous/Events", "description": "a user created event"}], "metadata": {"pactSpecification": {"version": "4.0"}}}`, "interactions[0].type must be Synchronous/HTTP, Asynchronous/Messages, or Synchronous/Messages, it was Asynchronous/Events"},
+	}
+
+	for _, c := range cases {
+		var contract utils.Pact
+		err := json.Unmarshal([]byte(c.contract), &contract)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = utils.ValidatePact(contract)
+		if len(c.expected) == 0 && err != nil {
+			t.Error(c.contract, err)
+		}
+		if len(c.expected) != 0 && (err == nil || err.Error() != "consumer contract is not a valid pact - "+c.expected) {
+			t.Error(c.contract, err)
+		}
+	}
+
+	for _, path := range []string{"../data_test/cons-prov.json", "../data_test/cons-prov.yaml"} {
+		contract, err := utils.LoadContract(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = utils.ValidatePact(contract)
+		if err != nil {
+			t.Error(path, err)
+		}
+	}
+}
+
+func TestPublishConsumerDryRun(t *testing.T) {
+	paths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--branch=main",
+		"--schema-version", "1",
+		"--dry-run",
+	}
+	actual := callPublish(flags)
+
+	t.Run("does not send a request", func(t *testing.T) {
+		if len(paths) != 0 {
+			t.Error(paths)
+		}
+	})
+
+	t.Run("prints the request body", func(t *testing.T) {
+		if !strings.Contains(actual.actual, "POST "+server.URL+"/api/contracts") ||
+			!strings.Contains(actual.actual, `"consumerName": "service_1"`) ||
+			!strings.Contains(actual.actual, `"consumerVersion": "version1"`) {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestPublishConsumerDryRunWithoutBroker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	unreachableURL := server.URL
+	server.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", unreachableURL,
+		"--type", "consumer",
+		"--version=version1",
+		"--branch=main",
+		"--ttl", "72h",
+		"--dry-run",
+	}
+	actual := callPublish(flags)
+
+	t.Run("prints the request body with the highest supported schema version", func(t *testing.T) {
+		expected := `"schemaVersion": ` + strconv.Itoa(utils.SupportedSchemaVersions[len(utils.SupportedSchemaVersions)-1])
+		if strings.Contains(actual.actual, "Error") ||
+			!strings.Contains(actual.actual, "POST "+unreachableURL+"/api/contracts") ||
+			!strings.Contains(actual.actual, expected) {
+			t.Error(actual.actual)
+		}
+	})
+
+	t.Run("keeps the TTL", func(t *testing.T) {
+		if !strings.Contains(actual.actual, `"ttl": 259200`) {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestPublishProviderDryRun(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/api-spec.yaml",
+		"--broker-url", server.URL,
+		"--type", "provider",
+		"--name", "user_service",
+		"--dry-run",
+	}
+	actual := callPublish(flags)
+
+	t.Run("does not send a request", func(t *testing.T) {
+		if requests != 0 {
+			t.Error()
+		}
+	})
+
+	t.Run("prints the request body", func(t *testing.T) {
+		if !strings.Contains(actual.actual, "POST "+server.URL+"/api/specs") ||
+			!strings.Contains(actual.actual, `"providerName": "user_service"`) ||
+			!strings.Contains(actual.actual, `"specFormat": "yaml"`) {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
 func TestPublishProviderWithoutVersion(t *testing.T) {
 	server, reqBody := mockServerForJSONReq201Created[utils.ProviderBody](t)
 	defer server.Close()
 
 	flags := []string{
-		"--path=../data_test/api-spec.json",
+		"--path=../data_test/api-spec.json",
+		"--broker-url", server.URL,
+		"--type", "provider",
+		"--name", "user_service",
+	}
+	actual := callPublish(flags)
+
+	t.Run("prints nothing to stdout", func(t *testing.T) {
+		if actual.actual != "" {
+			t.Error()
+		}
+	})
+
+	t.Run("has correct providerName", func(t *testing.T) {
+		if reqBody.ProviderName != "user_service" {
+			t.Error()
+		}
+	})
+
+	t.Run("does not have providerVersion", func(t *testing.T) {
+		if len(reqBody.ProviderVersion) != 0 {
+			t.Error()
+		}
+	})
+
+	t.Run("does not have providerBranch", func(t *testing.T) {
+		if len(reqBody.ProviderBranch) != 0 {
+			t.Error()
+		}
+	})
+
+	t.Run("has correct specFormat", func(t *testing.T) {
+		if reqBody.SpecFormat != "json" {
+			t.Error()
+		}
+	})
+
+	t.Run("has non-nil spec", func(t *testing.T) {
+		if reqBody.Spec == nil {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestPublishProviderWithVersionAndBranch(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.ProviderBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/api-spec.json",
+		"--broker-url", server.URL,
+		"--type", "provider",
+		"--name", "user_service",
+		"--version=version1",
+		"--branch=main",
+	}
+	actual := callPublish(flags)
+
+	t.Run("prints nothing to stdout", func(t *testing.T) {
+		if actual.actual != "" {
+			t.Error()
+		}
+	})
+
+	t.Run("does not have providerVersion", func(t *testing.T) {
+		if len(reqBody.ProviderVersion) != 0 {
+			t.Error()
+		}
+	})
+
+	t.Run("does not have providerBranch", func(t *testing.T) {
+		if len(reqBody.ProviderBranch) != 0 {
+			t.Error()
+		}
+	})
+
+	teardown()
+}
+
+func TestPublishProviderYAMLSpec(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.ProviderBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/api-spec.yaml",
+		"--broker-url", server.URL,
+		"--type", "provider",
+		"--name", "user_service",
+	}
+	actual := callPublish(flags)
+
+	t.Run("prints nothing to stdout", func(t *testing.T) {
+		if actual.actual != "" {
+			t.Error()
+		}
+	})
+
+	t.Run("has correct providerName", func(t *testing.T) {
+		if reqBody.ProviderName != "user_service" {
+			t.Error()
+		}
+	})
+
+	t.Run("does not have providerVersion", func(t *testing.T) {
+		if len(reqBody.ProviderVersion) != 0 {
+			t.Error()
+		}
+	})
+
+	t.Run("does not have providerBranch", func(t *testing.T) {
+		if len(reqBody.ProviderBranch) != 0 {
+			t.Error()
+		}
+	})
+
+	t.Run("has correct specFormat", func(t *testing.T) {
+		if reqBody.SpecFormat != "yaml" {
+			t.Error()
+		}
+	})
+
+	t.Run("has non-nil spec", func(t *testing.T) {
+		if reqBody.Spec == nil {
+			t.Error()
+		}
+	})
+
+	teardown()
+}
+
+func TestPublishConsumerWithTagsFromEnv(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	t.Setenv("SIGNET_TEST_PIPELINE", "pipeline-42")
+	t.Setenv("SIGNET_TEST_EMPTY", "")
+	t.Setenv("SIGNET_TEST_CHANNEL", "stable")
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--branch=main",
+		"--tags-from-env", "SIGNET_TEST_PIPELINE,SIGNET_TEST_EMPTY,SIGNET_TEST_UNSET,SIGNET_TEST_CHANNEL",
+	}
+	callPublish(flags)
+
+	t.Run("has the values of the set env vars as tags", func(t *testing.T) {
+		if len(reqBody.Tags) != 2 || reqBody.Tags[0] != "pipeline-42" || reqBody.Tags[1] != "stable" {
+			t.Error(reqBody.Tags)
+		}
+	})
+
+	t.Run("still has the consumerBranch", func(t *testing.T) {
+		if reqBody.ConsumerBranch != "main" {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestPublishConsumerWithTags(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	t.Setenv("SIGNET_TEST_CHANNEL", "stable")
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--branch=main",
+		"--tag", "main",
+		"--tag", "stable",
+		"--tags-from-env", "SIGNET_TEST_CHANNEL",
+	}
+	callPublish(flags)
+
+	t.Run("has each --tag once, then the tags from env vars", func(t *testing.T) {
+		if len(reqBody.Tags) != 2 || reqBody.Tags[0] != "main" || reqBody.Tags[1] != "stable" {
+			t.Error(reqBody.Tags)
+		}
+	})
+
+	t.Run("still has the consumerBranch", func(t *testing.T) {
+		if reqBody.ConsumerBranch != "main" {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestPublishConsumerVersionOutputToStdout(t *testing.T) {
+	server, _ := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--version-output", "-",
+	}
+	actual := callPublish(flags)
+
+	t.Run("prints the resolved version on its own line", func(t *testing.T) {
+		if actual.actual != "version1\n" {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestPublishConsumerNegotiatesSchemaVersion(t *testing.T) {
+	var reqBody utils.ConsumerBodyV2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/capabilities" {
+			json.NewEncoder(w).Encode(client.Capabilities{ContractSchemaVersions: []int{1, 2, 3}})
+			return
+		}
+
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--branch=main",
+	}
+	callPublish(flags)
+
+	t.Run("publishes with the highest mutually supported version", func(t *testing.T) {
+		if reqBody.SchemaVersion != 2 {
+			t.Errorf("schemaVersion was %d", reqBody.SchemaVersion)
+		}
+	})
+
+	t.Run("groups the consumer's details", func(t *testing.T) {
+		if reqBody.Consumer.Version != "version1" || reqBody.Consumer.Branch != "main" || len(reqBody.Consumer.Name) == 0 {
+			t.Errorf("consumer was %v", reqBody.Consumer)
+		}
+	})
+	teardown()
+}
+
+func TestPublishConsumerSchemaVersionOverride(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
 		"--broker-url", server.URL,
-		"--type", "provider",
-		"--name", "user_service",
+		"--type", "consumer",
+		"--version=version1",
+		"--schema-version", "1",
 	}
-	actual := callPublish(flags)
+	callPublish(flags)
 
-	t.Run("prints nothing to stdout", func(t *testing.T) {
-		if actual.actual != "" {
+	t.Run("publishes with the version 1 body", func(t *testing.T) {
+		if reqBody.ConsumerVersion != "version1" {
 			t.Error()
 		}
 	})
+	teardown()
+}
 
-	t.Run("has correct providerName", func(t *testing.T) {
-		if reqBody.ProviderName != "user_service" {
-			t.Error()
-		}
+func TestPublishConsumerUnsupportedSchemaVersion(t *testing.T) {
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url=http://localhost:3000",
+		"--type", "consumer",
+		"--version=version1",
+		"--branch=main",
+		"--schema-version", "9",
+	}
+	actual := callPublish(flags)
+	expected := "Error: contract schema version 9 is not supported"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestPublishOnlyBranchesSkipsOtherBranches(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--branch=feature/login",
+		"--only-branches", "main,release/*",
+	}
+	actual := callPublish(flags)
+
+	t.Run("prints a notice that publishing was skipped", func(t *testing.T) {
+		expected := "Skipped - branch feature/login does not match --only-branches main,release/*"
+		actual.startsWith(expected, t)
 	})
 
-	t.Run("does not have providerVersion", func(t *testing.T) {
-		if len(reqBody.ProviderVersion) != 0 {
+	t.Run("does not publish to the broker", func(t *testing.T) {
+		if requests != 0 {
 			t.Error()
 		}
 	})
+	teardown()
+}
 
-	t.Run("does not have providerBranch", func(t *testing.T) {
-		if len(reqBody.ProviderBranch) != 0 {
+func TestPublishOnlyBranchesMatchesGlob(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--branch=release/1.2",
+		"--only-branches", "main,release/*",
+	}
+	callPublish(flags)
+
+	t.Run("publishes from a branch matching the allowlist", func(t *testing.T) {
+		if reqBody.ConsumerBranch != "release/1.2" {
 			t.Error()
 		}
 	})
+	teardown()
+}
 
-	t.Run("has correct specFormat", func(t *testing.T) {
-		if reqBody.SpecFormat != "json" {
-			t.Error()
+func TestPublishConsumerInvalidTTL(t *testing.T) {
+	for _, value := range []string{"soon", "30s", "400d"} {
+		flags := []string{
+			"--path=../data_test/cons-prov.json",
+			"--broker-url=http://localhost:3000",
+			"--type", "consumer",
+			"--ttl", value,
+		}
+		actual := callPublish(flags)
+		expected := "Error: --ttl must be"
+
+		actual.startsWith(expected, t)
+		teardown()
+	}
+}
+
+func TestPublishConsumerWithTTL(t *testing.T) {
+	var reqBody utils.ConsumerBody
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/capabilities" {
+			json.NewEncoder(w).Encode(client.Capabilities{ContractSchemaVersions: []int{1}, ContractTTL: true})
+			return
+		}
+
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--branch=feature/login",
+		"--ttl", "14d",
+	}
+	callPublish(flags)
+
+	t.Run("sends the TTL in seconds", func(t *testing.T) {
+		if reqBody.TTL != 14*24*60*60 {
+			t.Errorf("ttl was %d", reqBody.TTL)
 		}
 	})
+	teardown()
+}
 
-	t.Run("has non-nil spec", func(t *testing.T) {
-		if reqBody.Spec == nil {
+func TestPublishConsumerTTLUnsupportedByBroker(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--branch=feature/login",
+		"--ttl", "72h",
+	}
+	callPublish(flags)
+
+	t.Run("publishes without a TTL", func(t *testing.T) {
+		if reqBody.ConsumerVersion != "version1" || reqBody.TTL != 0 {
 			t.Error()
 		}
 	})
 	teardown()
 }
 
-func TestPublishProviderWithVersionAndBranch(t *testing.T) {
-	server, reqBody := mockServerForJSONReq201Created[utils.ProviderBody](t)
+func TestPublishChangedSinceSkipsUnchangedContract(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusCreated)
+	}))
 	defer server.Close()
 
 	flags := []string{
-		"--path=../data_test/api-spec.json",
+		"--path=../data_test/cons-prov.json",
 		"--broker-url", server.URL,
-		"--type", "provider",
-		"--name", "user_service",
+		"--type", "consumer",
 		"--version=version1",
-		"--branch=main",
+		"--changed-since", "HEAD",
 	}
 	actual := callPublish(flags)
 
-	t.Run("prints nothing to stdout", func(t *testing.T) {
-		if actual.actual != "" {
-			t.Error()
-		}
+	t.Run("prints a notice that publishing was skipped", func(t *testing.T) {
+		expected := "Skipped - ../data_test/cons-prov.json has not changed since HEAD"
+		actual.startsWith(expected, t)
 	})
 
-	t.Run("does not have providerVersion", func(t *testing.T) {
-		if len(reqBody.ProviderVersion) != 0 {
+	t.Run("does not publish to the broker", func(t *testing.T) {
+		if requests != 0 {
 			t.Error()
 		}
 	})
+	teardown()
+}
 
-	t.Run("does not have providerBranch", func(t *testing.T) {
-		if len(reqBody.ProviderBranch) != 0 {
+func TestPublishChangedSinceUntrackedSourcePath(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	sourceDir, err := os.MkdirTemp(".", "untracked-source-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(sourceDir)
+	os.WriteFile(sourceDir+"/client.go", []byte("package client\n"), 0644)
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--changed-since", "HEAD",
+		"--source-path", sourceDir,
+	}
+	callPublish(flags)
+
+	t.Run("publishes when a source path changed", func(t *testing.T) {
+		if reqBody.ConsumerVersion != "version1" {
 			t.Error()
 		}
 	})
+	teardown()
+}
+
+func TestPublishChangedSinceInvalidRef(t *testing.T) {
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url=http://localhost:3000",
+		"--type", "consumer",
+		"--changed-since", "no-such-ref",
+	}
+	actual := callPublish(flags)
+	expected := "Error: could not diff against --changed-since no-such-ref"
 
+	actual.startsWith(expected, t)
 	teardown()
 }
 
-func TestPublishProviderYAMLSpec(t *testing.T) {
-	server, reqBody := mockServerForJSONReq201Created[utils.ProviderBody](t)
+func TestPublishProviderLargerThanMaxSpecSize(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusCreated)
+	}))
 	defer server.Close()
 
+	specSize, err := utils.SpecSize("../data_test/api-spec.json", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	flags := []string{
-		"--path=../data_test/api-spec.yaml",
+		"--path=../data_test/api-spec.json",
 		"--broker-url", server.URL,
 		"--type", "provider",
 		"--name", "user_service",
+		"--max-spec-size", strconv.Itoa(specSize - 1),
 	}
 	actual := callPublish(flags)
 
-	t.Run("prints nothing to stdout", func(t *testing.T) {
-		if actual.actual != "" {
+	t.Run("names the actual size of the spec", func(t *testing.T) {
+		expected := fmt.Sprintf("Error: the API spec at ../data_test/api-spec.json is %d bytes, which is larger than the --max-spec-size of %d bytes", specSize, specSize-1)
+		actual.startsWith(expected, t)
+	})
+
+	t.Run("does not send the spec to the broker", func(t *testing.T) {
+		if requests != 0 {
 			t.Error()
 		}
 	})
+	teardown()
+}
 
-	t.Run("has correct providerName", func(t *testing.T) {
-		if reqBody.ProviderName != "user_service" {
-			t.Error()
+/*
+returns a mock broker which responds 409 Conflict to the first contract that
+is published, and records the consumer version of each published contract
+*/
+func mockServerForConflictingPublish(t *testing.T) (*httptest.Server, *[]string) {
+	publishedVersions := []string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/capabilities" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		var reqBody utils.ConsumerBody
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		publishedVersions = append(publishedVersions, reqBody.ConsumerVersion)
+
+		if len(publishedVersions) == 1 {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error": "Participant version already exists"}`))
+			return
 		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	return server, &publishedVersions
+}
+
+func TestPublishOnConflictSkip(t *testing.T) {
+	server, publishedVersions := mockServerForConflictingPublish(t)
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--on-conflict", "skip",
+	}
+	actual := callPublish(flags)
+
+	t.Run("reports that the publish was skipped", func(t *testing.T) {
+		expected := "Skipped - version version1 of the consumer is already published to the Signet broker, --on-conflict is skip"
+		actual.startsWith(expected, t)
 	})
 
-	t.Run("does not have providerVersion", func(t *testing.T) {
-		if len(reqBody.ProviderVersion) != 0 {
-			t.Error()
+	t.Run("does not republish", func(t *testing.T) {
+		if len(*publishedVersions) != 1 {
+			t.Error(*publishedVersions)
 		}
 	})
+	teardown()
+}
 
-	t.Run("does not have providerBranch", func(t *testing.T) {
-		if len(reqBody.ProviderBranch) != 0 {
-			t.Error()
+func TestPublishOnConflictRetryWithSuffix(t *testing.T) {
+	server, publishedVersions := mockServerForConflictingPublish(t)
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+		"--on-conflict", "retry-with-suffix",
+	}
+	actual := callPublish(flags)
+
+	t.Run("republishes with a suffixed version", func(t *testing.T) {
+		if len(*publishedVersions) != 2 || !strings.HasPrefix((*publishedVersions)[1], "version1-") || len((*publishedVersions)[1]) != len("version1-")+8 {
+			t.Error(*publishedVersions)
 		}
 	})
 
-	t.Run("has correct specFormat", func(t *testing.T) {
-		if reqBody.SpecFormat != "yaml" {
-			t.Error()
+	t.Run("reports the version that was published", func(t *testing.T) {
+		expected := "Retried - version version1 of the consumer is already published, the contract was published as version version1-"
+		actual.startsWith(expected, t)
+	})
+	teardown()
+}
+
+func TestPublishOnConflictFailByDefault(t *testing.T) {
+	server, publishedVersions := mockServerForConflictingPublish(t)
+	defer server.Close()
+
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+	}
+	actual := callPublish(flags)
+
+	t.Run("fails with the broker's error", func(t *testing.T) {
+		expected := "Error: Status code: 409 Conflict - Participant version already exists"
+		actual.startsWith(expected, t)
+	})
+
+	t.Run("does not republish", func(t *testing.T) {
+		if len(*publishedVersions) != 1 {
+			t.Error(*publishedVersions)
 		}
 	})
+	teardown()
+}
 
-	t.Run("has non-nil spec", func(t *testing.T) {
-		if reqBody.Spec == nil {
+func TestPublishInvalidOnConflict(t *testing.T) {
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url=http://localhost:3000",
+		"--type", "consumer",
+		"--on-conflict", "overwrite",
+	}
+	actual := callPublish(flags)
+	expected := "Error: --on-conflict must be \"fail\", \"skip\", or \"retry-with-suffix\", --on-conflict was overwrite"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestPublishPathRelativeToGitRoot(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.ConsumerBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--path=data_test/cons-prov.json",
+		"--path-relative-to", "git-root",
+		"--broker-url", server.URL,
+		"--type", "consumer",
+		"--version=version1",
+	}
+	callPublish(flags)
+
+	t.Run("publishes the contract found from the git root", func(t *testing.T) {
+		if reqBody.ConsumerName != "service_1" {
 			t.Error()
 		}
 	})
+	teardown()
+}
+
+func TestPublishInvalidPathRelativeTo(t *testing.T) {
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--path-relative-to", "home",
+		"--broker-url=http://localhost:3000",
+		"--type", "consumer",
+	}
+	actual := callPublish(flags)
+	expected := "Error: --path-relative-to must be either \"cwd\" or \"git-root\", --path-relative-to was home"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestPublishShortSHAVersion(t *testing.T) {
+	cases := []struct {
+		flag   string
+		length int
+	}{
+		{"", defaultSHALength},
+		{"--short-sha", shortSHALength},
+		{"--short-sha=12", 12},
+	}
 
+	for _, c := range cases {
+		expected, err := exec.Command("git", "rev-parse", "--short="+strconv.Itoa(c.length), "HEAD").Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		flags := []string{
+			"--path=../data_test/cons-prov.json",
+			"--broker-url=http://localhost:3000",
+			"--type", "consumer",
+			"--branch=main",
+			"--schema-version", "1",
+			"--version-output", "-",
+			"--dry-run",
+		}
+		if len(c.flag) != 0 {
+			flags = append(flags, c.flag)
+		}
+		actual := callPublish(flags)
+
+		actual.startsWith(string(expected), t)
+		teardown()
+	}
+}
+
+func TestPublishInvalidShortSHA(t *testing.T) {
+	flags := []string{
+		"--path=../data_test/cons-prov.json",
+		"--broker-url=http://localhost:3000",
+		"--type", "consumer",
+		"--short-sha=2",
+	}
+	actual := callPublish(flags)
+	expected := "Error: --short-sha must be between 4 and 40, --short-sha was 2"
+
+	actual.startsWith(expected, t)
 	teardown()
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 
@@ -11,6 +12,8 @@ import (
 	utils "github.com/signet-framework/signet-cli/utils"
 )
 
+var ifNotExists bool
+
 var registerEnvCmd = &cobra.Command{
 	Use:   "register-env",
 	Short: "register a new deployment environment",
@@ -20,17 +23,29 @@ var registerEnvCmd = &cobra.Command{
 
 	-e --environment    the name of the deployment environment being registered (ex. production)
 
+	--if-not-exists     succeed without registering the environment when the broker already has it (optional)
+
+	--dry-run           print the request that would be sent to the broker, without sending it (optional)
+
 	-u --broker-url     the scheme, domain, and port where the Signet Broker is being hosted
 
 	-i --ignore-config  ingore .signetrc.yaml file if it exists (optional)
 	`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		brokerURL = resolveBrokerURL(cmd)
 		environment = viper.GetString("register-env.environment")
+		ifNotExists = viper.GetBool("register-env.if-not-exists")
 
 		if len(brokerURL) == 0 {
 			return errors.New("No --broker-url was provided. This is a required flag.")
 		}
 
+		var err error
+		brokerURL, err = validateBrokerURL(brokerURL)
+		if err != nil {
+			return err
+		}
+
 		if len(environment) == 0 {
 			return errors.New("No --environment was provided. A value for this flag is required.")
 		}
@@ -42,7 +57,22 @@ var registerEnvCmd = &cobra.Command{
 			return err
 		}
 
-		err = client.RegisterEnvWithBroker(brokerURL, jsonData)
+		if dryRun {
+			return printDryRun(cmd, "POST", brokerURL+"/api/environments", jsonData)
+		}
+
+		if ifNotExists {
+			registered, err := environmentIsRegistered(cmd.Context(), brokerURL, environment)
+			if err != nil {
+				return err
+			}
+			if registered {
+				cmd.Println(environment + " environment is already registered with the Signet broker")
+				return nil
+			}
+		}
+
+		err = client.RegisterEnvWithBroker(cmd.Context(), brokerURL, jsonData)
 		if err != nil {
 			return err
 		}
@@ -51,10 +81,28 @@ var registerEnvCmd = &cobra.Command{
 	},
 }
 
+// whether the broker already has an environment with this name
+func environmentIsRegistered(ctx context.Context, brokerURL, environment string) (bool, error) {
+	environments, err := client.ListEnvironments(ctx, brokerURL)
+	if err != nil {
+		return false, err
+	}
+
+	for _, env := range environments {
+		if env.EnvironmentName == environment {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func init() {
 	RootCmd.AddCommand(registerEnvCmd)
 
 	registerEnvCmd.Flags().StringVarP(&environment, "environment", "e", "", "The name of the deployment environment being registered")
+	registerEnvCmd.Flags().BoolVar(&ifNotExists, "if-not-exists", false, "Succeed without registering the environment when the broker already has it")
+	registerEnvCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the request that would be sent to the broker, without sending it")
 
 	viper.BindPFlag("register-env.environment", registerEnvCmd.Flags().Lookup("environment"))
+	viper.BindPFlag("register-env.if-not-exists", registerEnvCmd.Flags().Lookup("if-not-exists"))
 }
@@ -2,7 +2,14 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/spf13/viper"
 
 	utils "github.com/signet-framework/signet-cli/utils"
 )
@@ -65,3 +72,251 @@ func TestRegisterEnvRequest(t *testing.T) {
 	})
 	teardown()
 }
+
+func TestRegisterEnvCommandBrokerURLConfig(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.EnvBody](t)
+	defer server.Close()
+
+	viper.Set("register-env.broker-url", server.URL)
+	defer viper.Set("register-env.broker-url", "")
+
+	flags := []string{
+		"--environment=production",
+	}
+	callRegisterEnv(flags)
+
+	t.Run("sends the request to the command's broker-url", func(t *testing.T) {
+		if reqBody.EnvironmentName != "production" {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestRegisterEnvBrokerURLFlagOverridesCommandConfig(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.EnvBody](t)
+	defer server.Close()
+
+	viper.Set("register-env.broker-url", "http://localhost:1")
+	defer viper.Set("register-env.broker-url", "")
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--environment=production",
+	}
+	callRegisterEnv(flags)
+
+	t.Run("sends the request to the --broker-url flag", func(t *testing.T) {
+		if reqBody.EnvironmentName != "production" {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestRegisterEnvBrokerURLFromEnvironmentVariable(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.EnvBody](t)
+	defer server.Close()
+
+	t.Setenv("SIGNET_BROKER_URL", server.URL)
+
+	t.Run("falls back to SIGNET_BROKER_URL", func(t *testing.T) {
+		callRegisterEnv([]string{"--environment=production"})
+		if reqBody.EnvironmentName != "production" {
+			t.Error()
+		}
+		teardown()
+	})
+
+	t.Run("prefers the command's broker-url config", func(t *testing.T) {
+		configServer, configReqBody := mockServerForJSONReq201Created[utils.EnvBody](t)
+		defer configServer.Close()
+
+		viper.Set("register-env.broker-url", configServer.URL)
+		defer viper.Set("register-env.broker-url", "")
+
+		callRegisterEnv([]string{"--environment=staging"})
+		if configReqBody.EnvironmentName != "staging" || reqBody.EnvironmentName != "production" {
+			t.Error()
+		}
+		teardown()
+	})
+
+	t.Run("prefers the --broker-url flag", func(t *testing.T) {
+		flagServer, flagReqBody := mockServerForJSONReq201Created[utils.EnvBody](t)
+		defer flagServer.Close()
+
+		callRegisterEnv([]string{"--broker-url", flagServer.URL, "--environment=qa"})
+		if flagReqBody.EnvironmentName != "qa" || reqBody.EnvironmentName != "production" {
+			t.Error()
+		}
+		teardown()
+	})
+}
+
+func TestRegisterEnvDryRun(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--environment=production",
+		"--dry-run",
+	}
+	actual := callRegisterEnv(flags)
+
+	t.Run("does not send a request", func(t *testing.T) {
+		if requests != 0 {
+			t.Error()
+		}
+	})
+
+	t.Run("prints the request body", func(t *testing.T) {
+		if !strings.Contains(actual.actual, "POST "+server.URL+"/api/environments") || !strings.Contains(actual.actual, `"environmentName": "production"`) {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+/*
+returns a mock broker which responds with each of the statuses in turn, and
+a pointer to the number of requests it has received
+*/
+func mockServerForStatuses(statuses ...int) (*httptest.Server, *int) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[len(statuses)-1]
+		if requests < len(statuses) {
+			status = statuses[requests]
+		}
+		requests++
+
+		w.WriteHeader(status)
+		w.Write([]byte(`{"error": "broker is warming up"}`))
+	}))
+
+	return server, &requests
+}
+
+func TestRegisterEnvRetriesServerErrors(t *testing.T) {
+	server, requests := mockServerForStatuses(http.StatusServiceUnavailable, http.StatusCreated)
+	defer server.Close()
+
+	flags := []string{
+		"--environment=production",
+		"--broker-url", server.URL,
+		"--retry", "2",
+	}
+	actual := callRegisterEnv(flags)
+
+	t.Run("retries until the broker responds", func(t *testing.T) {
+		if *requests != 2 {
+			t.Error(*requests)
+		}
+	})
+
+	t.Run("prints the attempts remaining", func(t *testing.T) {
+		expected := "Retrying - the broker responded 503 Service Unavailable, 2 attempts remaining, next attempt in 500ms"
+		actual.startsWith(expected, t)
+	})
+	teardown()
+}
+
+func TestRegisterEnvDoesNotRetryClientErrors(t *testing.T) {
+	server, requests := mockServerForStatuses(http.StatusBadRequest, http.StatusCreated)
+	defer server.Close()
+
+	flags := []string{
+		"--environment=production",
+		"--broker-url", server.URL,
+		"--retry", "2",
+	}
+	callRegisterEnv(flags)
+
+	if *requests != 1 {
+		t.Error(*requests)
+	}
+	teardown()
+}
+
+func TestRegisterEnvRetryTimeout(t *testing.T) {
+	server, requests := mockServerForStatuses(http.StatusServiceUnavailable)
+	defer server.Close()
+
+	flags := []string{
+		"--environment=production",
+		"--broker-url", server.URL,
+		"--retry", "5",
+		"--retry-timeout", "100ms",
+	}
+	actual := callRegisterEnv(flags)
+
+	t.Run("does not start a retry that would end after the timeout", func(t *testing.T) {
+		if *requests != 1 {
+			t.Error(*requests)
+		}
+	})
+
+	t.Run("fails with the broker's error", func(t *testing.T) {
+		expected := "Error: Status code: 503 Service Unavailable - broker is warming up"
+		actual.startsWith(expected, t)
+	})
+	teardown()
+}
+
+func TestRegisterEnvInterrupted(t *testing.T) {
+	server, requests := mockServerForStatuses(http.StatusServiceUnavailable)
+	defer server.Close()
+
+	// cobra only passes the root command's context to a subcommand the first time it runs
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	registerEnvCmd.SetContext(ctx)
+	defer registerEnvCmd.SetContext(context.Background())
+
+	start := time.Now()
+	exitCode := exitCodeOf([]string{"register-env", "--environment=production", "--broker-url", server.URL, "--retry", "5"})
+
+	t.Run("stops waiting to retry once interrupted", func(t *testing.T) {
+		if *requests != 1 || time.Since(start) >= 500*time.Millisecond {
+			t.Error(*requests, time.Since(start))
+		}
+	})
+
+	t.Run("exits with 130", func(t *testing.T) {
+		if exitCode != exitInterrupted {
+			t.Error(exitCode)
+		}
+	})
+	teardown()
+}
+
+func TestRegisterEnvIfNotExists(t *testing.T) {
+	t.Run("does not register an environment the broker already has", func(t *testing.T) {
+		server, registered := mockServerForEnvironments(t, registeredEnvironments)
+		defer server.Close()
+
+		actual := callRegisterEnv([]string{"--broker-url", server.URL, "--environment", "staging", "--if-not-exists"})
+		if len(*registered) != 0 {
+			t.Error(*registered)
+		}
+		actual.startsWith("staging environment is already registered with the Signet broker", t)
+		teardown()
+	})
+
+	t.Run("registers an environment the broker does not have", func(t *testing.T) {
+		server, registered := mockServerForEnvironments(t, registeredEnvironments)
+		defer server.Close()
+
+		callRegisterEnv([]string{"--broker-url", server.URL, "--environment", "qa", "--if-not-exists"})
+		if len(*registered) != 1 || (*registered)[0] != "qa" {
+			t.Error(*registered)
+		}
+		teardown()
+	})
+}
@@ -1,60 +1,539 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	client "github.com/signet-framework/signet-cli/client"
+	utils "github.com/signet-framework/signet-cli/utils"
 )
 
-const colorGreen = "\033[32m"
-const colorRed = "\033[31m"
-const colorBlue = "\033[34m"
-const colorReset = "\033[0m"
+const ansiGreen = "\033[32m"
+const ansiRed = "\033[31m"
+const ansiBlue = "\033[34m"
+const ansiReset = "\033[0m"
 const stackName = "signetbroker"
+const defaultRetryTimeout = 30 * time.Second
+const defaultSHALength = 10
+const shortSHALength = 7
+
+// environment variables that update-deployment and deploy-guard fall back to when a flag is not passed or configured
+const nameEnvVar = "SIGNET_NAME"
+const environmentEnvVar = "SIGNET_ENVIRONMENT"
+
+// environment variable that every command falls back to when no broker URL is passed or configured
+const brokerURLEnvVar = "SIGNET_BROKER_URL"
 
 var IgnoreConfig bool
+var configFile string
 var brokerURL string
 var path string
 var name string
 var version string
 var branch string
 var environment string
+var versionOutput string
+var errorFormat string
+var versionTransform string
+var dryRun bool
+var pathRelativeTo string
+var retries int
+var retryTimeout time.Duration
+var shaLength int
+var verbose bool
+var brokerHeaders []string
+var noColor bool
+
+// the escape codes that output is colored with, which are empty when color is disabled
+var colorGreen = ansiGreen
+var colorRed = ansiRed
+var colorBlue = ansiBlue
+var colorReset = ansiReset
+
+// abstracted to enable mocking during testing
+var stdoutIsTerminal = func() bool {
+	return isTerminal(os.Stdout)
+}
+
+// abstracted to enable mocking during testing
+var stderrIsTerminal = func() bool {
+	return isTerminal(os.Stderr)
+}
 
 var RootCmd = &cobra.Command{
 	Use:   "signet",
 	Short: "The command line interface for the Signet contract testing framework",
 	Long:  `The command line interface for the Signet contract testing framework`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if IgnoreConfig && len(configFile) != 0 {
+			return usageError(errors.New("--config and --ignore-config cannot be used together, --config reads a config file that --ignore-config ignores"))
+		}
+
+		errorFormat = viper.GetString("error-format")
+
+		noColor = viper.GetBool("no-color")
+		setColor(!noColor && os.Getenv("NO_COLOR") == "" && stdoutIsTerminal())
+
+		if errorFormat != "text" && errorFormat != "json" {
+			return usageError(errors.New("--error-format must be either \"text\" or \"json\", --error-format was " + errorFormat))
+		}
+
+		// the error is printed by handleError instead of by cobra
+		if errorFormat == "json" {
+			cmd.Root().SilenceErrors = true
+			cmd.Root().SilenceUsage = true
+		}
+
+		retries = viper.GetInt("retry")
+		retryTimeout = viper.GetDuration("retry-timeout")
+		if retries < 0 {
+			return usageError(errors.New("--retry must be at least 0, --retry was " + strconv.Itoa(retries)))
+		}
+
+		shaLength = viper.GetInt("short-sha")
+		if shaLength < 4 || shaLength > 40 {
+			return usageError(errors.New("--short-sha must be between 4 and 40, --short-sha was " + strconv.Itoa(shaLength)))
+		}
+		utils.SHALength = shaLength
+
+		verbose = viper.GetBool("verbose")
+		if verbose {
+			client.VerboseOutput = cmd.ErrOrStderr()
+		}
+
+		brokerHeaders = viper.GetStringSlice("broker.headers")
+		for headerName, value := range viper.GetStringMapString("broker.headers") {
+			brokerHeaders = append(brokerHeaders, headerName+": "+value)
+		}
+		headers, err := parseHeaders(brokerHeaders)
+		if err != nil {
+			return usageError(err)
+		}
+		client.Headers = headers
+
+		client.Retries = retries
+		client.RetryTimeout = retryTimeout
+		client.RetryOutput = cmd.ErrOrStderr()
+
+		return nil
+	},
 }
 
 func Execute() {
-	readConfigFile()
-	brokerURL = viper.GetString("broker-url")
-
-	err := RootCmd.Execute()
+	// the config file is read before cobra parses flags, so --ignore-config and --config are looked for directly
+	IgnoreConfig = ignoreConfigRequested(os.Args[1:])
+	configFile = configFileRequested(os.Args[1:])
+	err := readConfigFile()
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitUsage)
 	}
+
+	// Ctrl+C cancels the requests in flight to the broker, and a second Ctrl+C exits immediately
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	RootCmd.SetContext(ctx)
+	cmd, err := RootCmd.ExecuteC()
+	if err != nil {
+		printError(os.Stdout, err)
+		os.Exit(handleError(cmd, err))
+	}
+}
+
+/*
+prints the error a command failed with, unless handleError prints it as JSON.
+With --output json stdout only holds the JSON report, and the error is left
+on stderr, where cobra has already printed it.
+*/
+func printError(stdout io.Writer, err error) {
+	if errorFormat == "json" || output == "json" {
+		return
+	}
+
+	fmt.Fprintf(stdout, "Error: %v\n", err)
 }
 
 func init() {
 	RootCmd.PersistentFlags().BoolVarP(&IgnoreConfig, "ignore-config", "i", false, "ignore config file if present")
-	RootCmd.PersistentFlags().StringVarP(&brokerURL, "broker-url", "u", "", "Scheme, domain, and port where the Signet Broker is being hosted (ex. http://localhost:3000)")
+	RootCmd.PersistentFlags().StringVar(&configFile, "config", "", "Path to a config file that is read instead of .signetrc.yaml")
+	RootCmd.PersistentFlags().StringVarP(&brokerURL, "broker-url", "u", "", "Scheme, domain, and port where the Signet Broker is being hosted (ex. http://localhost:3000, defaults to SIGNET_BROKER_URL)")
+	RootCmd.PersistentFlags().StringVar(&errorFormat, "error-format", "text", "Format of the error printed when a command fails, either 'text' or 'json'")
 
 	viper.BindPFlag("broker-url", RootCmd.PersistentFlags().Lookup("broker-url"))
+	RootCmd.PersistentFlags().StringVar(&versionTransform, "version-transform", "", "'regex=replacement' applied to the resolved version before it is sent to the broker")
+
+	viper.BindPFlag("version-transform", RootCmd.PersistentFlags().Lookup("version-transform"))
+	RootCmd.PersistentFlags().IntVar(&retries, "retry", 0, "How many times a request to the broker is retried after a connection error or 5xx response")
+	RootCmd.PersistentFlags().DurationVar(&retryTimeout, "retry-timeout", defaultRetryTimeout, "The longest time that requests to the broker are retried for")
+
+	RootCmd.PersistentFlags().IntVar(&shaLength, "short-sha", defaultSHALength, "Length of the git SHA that versions default to, --short-sha alone uses 7 characters to match short SHA tags")
+	RootCmd.PersistentFlags().Lookup("short-sha").NoOptDefVal = strconv.Itoa(shortSHALength)
+
+	viper.BindPFlag("short-sha", RootCmd.PersistentFlags().Lookup("short-sha"))
+	RootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Print plain text without ANSI color codes, which is the default when NO_COLOR is set or stdout is not a terminal")
+
+	viper.BindPFlag("no-color", RootCmd.PersistentFlags().Lookup("no-color"))
+	RootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Log every request to the broker and its response to stderr, with the Authorization header redacted")
+
+	viper.BindPFlag("verbose", RootCmd.PersistentFlags().Lookup("verbose"))
+	RootCmd.PersistentFlags().StringArrayVar(&brokerHeaders, "header", []string{}, "'Key: Value' header sent with every request to the broker, can be repeated")
+
+	viper.BindPFlag("broker.headers", RootCmd.PersistentFlags().Lookup("header"))
+	viper.BindPFlag("retry", RootCmd.PersistentFlags().Lookup("retry"))
+	viper.BindPFlag("retry-timeout", RootCmd.PersistentFlags().Lookup("retry-timeout"))
+	viper.BindPFlag("error-format", RootCmd.PersistentFlags().Lookup("error-format"))
+
+	RootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		if viper.GetString("error-format") == "json" {
+			errorFormat = "json"
+			cmd.Root().SilenceErrors = true
+			cmd.Root().SilenceUsage = true
+		}
+		return usageError(err)
+	})
+}
+
+// colors all output, or leaves every color code empty so that output is plain text
+func setColor(enabled bool) {
+	if enabled {
+		colorGreen, colorRed, colorBlue, colorReset = ansiGreen, ansiRed, ansiBlue, ansiReset
+	} else {
+		colorGreen, colorRed, colorBlue, colorReset = "", "", "", ""
+	}
+}
+
+// a file is a terminal when it is a character device, rather than a pipe or a regular file
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
 }
 
-func readConfigFile() {
+func readConfigFile() error {
 	if IgnoreConfig == false {
-		viper.AddConfigPath(".")
-		viper.SetConfigName(".signetrc.yaml")
-		viper.SetConfigType("yaml")
-		if err := viper.ReadInConfig(); err != nil {
-			if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil
+		}
+
+		configPath := configFile
+		if len(configPath) != 0 {
+			if _, err := os.Stat(configPath); err != nil {
+				return errors.New("could not read --config " + configPath + ": " + err.Error())
+			}
+		} else {
+			configPath = findConfigFile(cwd)
+		}
+
+		if len(configPath) != 0 {
+			viper.SetConfigFile(configPath)
+			viper.SetConfigType("yaml")
+			if err := viper.ReadInConfig(); err != nil {
 				panic(err)
 			}
 		}
+
+		// settings under the "signet" key of package.json take precedence over .signetrc.yaml
+		if packageConfig := findPackageJSONConfig(cwd); len(packageConfig) != 0 {
+			viper.MergeConfigMap(packageConfig)
+		}
+	}
+
+	return nil
+}
+
+// reports whether --ignore-config (or -i) was passed, before flags are parsed
+func ignoreConfigRequested(args []string) bool {
+	for _, arg := range args {
+		if arg == "--" {
+			return false
+		}
+
+		if arg == "--ignore-config" || arg == "-i" || arg == "--ignore-config=true" || arg == "-i=true" {
+			return true
+		}
+	}
+	return false
+}
+
+// the value of --config, before flags are parsed
+func configFileRequested(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			return ""
+		}
+
+		if value, found := strings.CutPrefix(arg, "--config="); found {
+			return value
+		}
+
+		if arg == "--config" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+/*
+searches upward from dir for the nearest .signetrc.yaml, so that signet can
+be run from any subdirectory of a monorepo with one config file at its root.
+The search stops at the root of the git repository, and returns "" when no
+config file is found.
+*/
+func findConfigFile(dir string) string {
+	for {
+		configPath := filepath.Join(dir, configFileName)
+		if _, err := os.Stat(configPath); err == nil {
+			return configPath
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+/*
+searches upward from dir for the nearest package.json, and returns the
+settings under its "signet" key with their camelCase keys converted to the
+kebab-case used by flags (ex. brokerUrl to broker-url)
+*/
+func findPackageJSONConfig(dir string) map[string]interface{} {
+	for {
+		packageBytes, err := os.ReadFile(filepath.Join(dir, "package.json"))
+		if err == nil {
+			var packageJSON struct {
+				Signet map[string]interface{} `json:"signet"`
+			}
+			if json.Unmarshal(packageBytes, &packageJSON) != nil {
+				return nil
+			}
+			return kebabCaseKeys(packageJSON.Signet)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+var camelCaseBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+func kebabCaseKeys(settings map[string]interface{}) map[string]interface{} {
+	converted := map[string]interface{}{}
+	for key, value := range settings {
+		if nested, ok := value.(map[string]interface{}); ok {
+			value = kebabCaseKeys(nested)
+		}
+		converted[strings.ToLower(camelCaseBoundary.ReplaceAllString(key, "$1-$2"))] = value
+	}
+	return converted
+}
+
+/*
+resolves the broker URL for a command, in order of precedence:
+the --broker-url flag, the <command>.broker-url config key, the global
+broker-url config key, and then the SIGNET_BROKER_URL environment variable
+*/
+func resolveBrokerURL(cmd *cobra.Command) string {
+	if flag := cmd.Flag("broker-url"); flag != nil && flag.Changed {
+		return brokerURL
+	}
+
+	if commandURL := viper.GetString(cmd.Name() + ".broker-url"); len(commandURL) != 0 {
+		return commandURL
+	}
+
+	return valueOrEnv(viper.GetString("broker-url"), brokerURLEnvVar)
+}
+
+/*
+checks that a broker URL has an http or https scheme and a host, which the
+client would otherwise fail on with an unclear error, and strips trailing
+slashes so that API paths can be appended to it
+*/
+func validateBrokerURL(brokerURL string) (string, error) {
+	parsed, err := url.Parse(brokerURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || len(parsed.Host) == 0 {
+		return "", errors.New("--broker-url must be in the form http(s)://host[:port] (ex. http://localhost:3000), --broker-url was " + brokerURL)
+	}
+
+	return strings.TrimRight(brokerURL, "/"), nil
+}
+
+/*
+parses 'Key: Value' headers from --header or broker.headers, which are sent
+with every request to the broker. Repeated keys are sent with every value
+*/
+func parseHeaders(headerLines []string) (http.Header, error) {
+	headers := http.Header{}
+	for _, headerLine := range headerLines {
+		headerName, value, found := strings.Cut(headerLine, ":")
+		headerName = strings.TrimSpace(headerName)
+		if !found || len(headerName) == 0 || strings.ContainsAny(headerName, " \t") {
+			return nil, errors.New("--header must be in the form 'Key: Value' (ex. 'X-Api-Key: abc123'), --header was " + headerLine)
+		}
+		headers.Add(headerName, strings.TrimSpace(value))
+	}
+
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	return headers, nil
+}
+
+/*
+falls back to an environment variable for a flag value which is empty because
+the flag was neither passed nor set in the config file, so a pipeline can set
+the value once for every command
+*/
+func valueOrEnv(value string, envVar string) string {
+	if len(value) == 0 {
+		return strings.TrimSpace(os.Getenv(envVar))
+	}
+	return value
+}
+
+/*
+resolves a --version flag value to the version that is sent to the broker,
+with the same precedence in every command: a passed --version, then
+SIGNET_VERSION, then the git SHA of HEAD. --version-transform is then
+applied. When
+--version-output is set, the resolved version is written to that file, or to
+stdout on its own line for "-"
+*/
+func resolveVersion(cmd *cobra.Command, version string) (string, error) {
+	version, err := utils.ResolveVersion(version, cmd.Flags().Changed("version"))
+	if err != nil {
+		return "", err
+	}
+
+	versionTransform = viper.GetString("version-transform")
+	if len(versionTransform) != 0 {
+		version, err = transformVersion(version, versionTransform)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	err = writeVersionOutput(cmd, version)
+	if err != nil {
+		return "", err
+	}
+
+	return version, nil
+}
+
+// writes the resolved version to the --version-output file, or stdout for '-'
+func writeVersionOutput(cmd *cobra.Command, version string) error {
+	if versionOutput == "-" {
+		fmt.Fprintln(cmd.OutOrStdout(), version)
+	} else if len(versionOutput) != 0 {
+		err := osWriteFile(versionOutput, []byte(version+"\n"), rwPermissions)
+		if err != nil {
+			return errors.New("failed to write --version-output file: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+/*
+resolves a contract or spec path against --path-relative-to: either the
+working directory ("cwd"), or the root of the git repository ("git-root") so
+that paths in committed config work from any subdirectory
+*/
+func resolveContractPath(path, relativeTo string) (string, error) {
+	if relativeTo != "cwd" && relativeTo != "git-root" {
+		return "", errors.New("--path-relative-to must be either \"cwd\" or \"git-root\", --path-relative-to was " + relativeTo)
+	}
+
+	if relativeTo == "cwd" || filepath.IsAbs(path) {
+		return path, nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
 	}
-}
\ No newline at end of file
+
+	gitRoot, err := utils.FindGitRoot(cwd)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(gitRoot, path), nil
+}
+
+/*
+applies a 'regex=replacement' transform to a version, ex.
+'^refs/tags/v(.*)$=$1' turns refs/tags/v1.2.3 into 1.2.3. The replacement can
+refer to capture groups as $1 or ${name}
+*/
+func transformVersion(version, transform string) (string, error) {
+	pattern, replacement, found := strings.Cut(transform, "=")
+	if !found || len(pattern) == 0 {
+		return "", errors.New("--version-transform must be in the form 'regex=replacement', --version-transform was " + transform)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", errors.New("--version-transform regex does not compile: " + err.Error())
+	}
+
+	transformed := re.ReplaceAllString(version, replacement)
+	if len(transformed) == 0 {
+		return "", errors.New("--version-transform turned version " + version + " into an empty version")
+	}
+
+	return transformed, nil
+}
+
+/*
+prints the request a command would send to the broker with --dry-run: a
+notice with the method and URL, followed by the indented JSON request body
+on stdout
+*/
+func printDryRun(cmd *cobra.Command, method, url string, jsonData []byte) error {
+	var indented bytes.Buffer
+	err := json.Indent(&indented, jsonData, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	cmd.Println(colorBlue + "Dry run" + colorReset + " - no request was sent. " + method + " " + url + " would be sent with this body:")
+	fmt.Fprintln(cmd.OutOrStdout(), indented.String())
+	return nil
+}
@@ -3,6 +3,14 @@ package cmd
 import (
 	"testing"
 	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	utils "github.com/signet-framework/signet-cli/utils"
 )
 
 func TestCLIBaseCommand(t *testing.T) {
@@ -18,4 +26,472 @@ func TestCLIBaseCommand(t *testing.T) {
 	if actualOutput[:len(expected)] != expected {
 		t.Error()
 	}
-}
\ No newline at end of file
+}
+func TestErrorFormatJSONForBrokerError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-123")
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error": "database unavailable"}`))
+	}))
+	defer server.Close()
+
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs([]string{"register-env", "--broker-url", server.URL, "--environment", "production", "--error-format", "json"})
+	cmd, err := RootCmd.ExecuteC()
+	exitCode := handleError(cmd, err)
+
+	var reported map[string]string
+	json.Unmarshal(actual.Bytes(), &reported)
+
+	t.Run("prints only a JSON error object", func(t *testing.T) {
+		if reported["code"] != "broker_error" || reported["message"] != "database unavailable" || reported["details"] != "500 Internal Server Error" {
+			t.Error(actual.String())
+		}
+	})
+
+	t.Run("includes the broker request ID", func(t *testing.T) {
+		if reported["requestId"] != "req-123" {
+			t.Error()
+		}
+	})
+
+	t.Run("exits with the broker error code", func(t *testing.T) {
+		if exitCode != 3 {
+			t.Error(exitCode)
+		}
+	})
+	teardown()
+}
+
+func TestErrorFormatJSONForUsageError(t *testing.T) {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs([]string{"register-env", "--error-format", "json", "--no-such-flag"})
+	cmd, err := RootCmd.ExecuteC()
+	exitCode := handleError(cmd, err)
+
+	var reported map[string]string
+	json.Unmarshal(actual.Bytes(), &reported)
+
+	if reported["code"] != "usage_error" || exitCode != 2 {
+		t.Error(actual.String())
+	}
+	teardown()
+}
+
+func TestErrorFormatInvalid(t *testing.T) {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs([]string{"register-env", "--error-format", "xml"})
+	RootCmd.Execute()
+
+	actualOut{actual.String()}.startsWith("Error: --error-format must be either \"text\" or \"json\"", t)
+	teardown()
+}
+
+func TestTransformVersion(t *testing.T) {
+	cases := []struct {
+		version   string
+		transform string
+		expected  string
+	}{
+		{"refs/tags/v1.2.3", "^refs/tags/v(.*)$=$1", "1.2.3"},
+		{"release-2024.05.1+build.77", `\+build\.\d+$=`, "release-2024.05.1"},
+		{"feature/LOGIN-42", "[^a-zA-Z0-9.]+=-", "feature-LOGIN-42"},
+		{"1.2.3", "^(?P<major>\\d+)\\.(?P<minor>\\d+).*=${major}.${minor}", "1.2"},
+		{"1.2.3", "^v=", "1.2.3"},
+	}
+
+	for _, c := range cases {
+		actual, err := transformVersion(c.version, c.transform)
+		if err != nil || actual != c.expected {
+			t.Errorf("%s with %s: expected %s, got %s (%v)", c.version, c.transform, c.expected, actual, err)
+		}
+	}
+}
+
+func TestTransformVersionInvalid(t *testing.T) {
+	for _, transform := range []string{"no-separator", "=1.0", "([=$1", "^.*$="} {
+		_, err := transformVersion("1.2.3", transform)
+		if err == nil {
+			t.Errorf("expected %s to be rejected", transform)
+		}
+	}
+}
+
+func TestFindPackageJSONConfig(t *testing.T) {
+	projectDir := t.TempDir()
+	packageJSON := `{"name": "service_1", "signet": {"brokerUrl": "http://localhost:3000", "publish": {"type": "consumer", "versionOutput": "-"}}}`
+	os.WriteFile(filepath.Join(projectDir, "package.json"), []byte(packageJSON), 0644)
+
+	nestedDir := filepath.Join(projectDir, "src", "contracts")
+	os.MkdirAll(nestedDir, os.ModePerm)
+
+	config := findPackageJSONConfig(nestedDir)
+
+	t.Run("finds the nearest package.json above the directory", func(t *testing.T) {
+		if config["broker-url"] != "http://localhost:3000" {
+			t.Error(config)
+		}
+	})
+
+	t.Run("converts nested keys to kebab-case", func(t *testing.T) {
+		publish, _ := config["publish"].(map[string]interface{})
+		if publish["type"] != "consumer" || publish["version-output"] != "-" {
+			t.Error(config)
+		}
+	})
+
+	t.Run("returns nothing without a signet key", func(t *testing.T) {
+		os.WriteFile(filepath.Join(nestedDir, "package.json"), []byte(`{"name": "contracts"}`), 0644)
+		if len(findPackageJSONConfig(nestedDir)) != 0 {
+			t.Error()
+		}
+	})
+}
+
+func TestFindConfigFile(t *testing.T) {
+	repoDir := t.TempDir()
+	os.Mkdir(filepath.Join(repoDir, ".git"), os.ModePerm)
+	serviceDir := filepath.Join(repoDir, "services", "user_service")
+	os.MkdirAll(serviceDir, os.ModePerm)
+
+	t.Run("returns nothing when there is no config file up to the git root", func(t *testing.T) {
+		os.WriteFile(filepath.Join(filepath.Dir(repoDir), ".signetrc.yaml"), []byte("broker-url: http://outside:3000"), 0644)
+		defer os.Remove(filepath.Join(filepath.Dir(repoDir), ".signetrc.yaml"))
+
+		if configPath := findConfigFile(serviceDir); configPath != "" {
+			t.Error(configPath)
+		}
+	})
+
+	t.Run("finds the config file at the git root", func(t *testing.T) {
+		os.WriteFile(filepath.Join(repoDir, ".signetrc.yaml"), []byte("broker-url: http://localhost:3000"), 0644)
+		if configPath := findConfigFile(serviceDir); configPath != filepath.Join(repoDir, ".signetrc.yaml") {
+			t.Error(configPath)
+		}
+	})
+
+	t.Run("prefers the nearest config file", func(t *testing.T) {
+		os.WriteFile(filepath.Join(serviceDir, ".signetrc.yaml"), []byte("broker-url: http://localhost:3001"), 0644)
+		if configPath := findConfigFile(serviceDir); configPath != filepath.Join(serviceDir, ".signetrc.yaml") {
+			t.Error(configPath)
+		}
+	})
+}
+
+func TestIgnoreConfigRequested(t *testing.T) {
+	cases := []struct {
+		args     []string
+		expected bool
+	}{
+		{[]string{"publish", "--ignore-config"}, true},
+		{[]string{"-i", "deploy-guard"}, true},
+		{[]string{"publish", "--ignore-config=true"}, true},
+		{[]string{"publish", "--path", "contract.json"}, false},
+		{[]string{"test", "--", "-i"}, false},
+	}
+
+	for _, c := range cases {
+		if actual := ignoreConfigRequested(c.args); actual != c.expected {
+			t.Errorf("%v: expected %t, got %t", c.args, c.expected, actual)
+		}
+	}
+}
+
+func TestConfigFileRequested(t *testing.T) {
+	cases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"publish", "--config", ".signetrc.ci.yaml"}, ".signetrc.ci.yaml"},
+		{[]string{"--config=ci/signet.yaml", "deploy-guard"}, "ci/signet.yaml"},
+		{[]string{"publish", "--path", "contract.json"}, ""},
+		{[]string{"test", "--", "--config", "dredd.yml"}, ""},
+	}
+
+	for _, c := range cases {
+		if actual := configFileRequested(c.args); actual != c.expected {
+			t.Errorf("%v: expected %q, got %q", c.args, c.expected, actual)
+		}
+	}
+}
+
+func TestReadConfigFileMissingConfig(t *testing.T) {
+	configFile = filepath.Join(t.TempDir(), ".signetrc.ci.yaml")
+	err := readConfigFile()
+
+	if err == nil || !strings.HasPrefix(err.Error(), "could not read --config "+configFile) {
+		t.Error(err)
+	}
+	teardown()
+}
+
+func TestConfigWithIgnoreConfig(t *testing.T) {
+	actual := callRegisterEnv([]string{"--broker-url=http://localhost:3000", "--environment=production", "--config", ".signetrc.ci.yaml", "--ignore-config"})
+	expected := "Error: --config and --ignore-config cannot be used together"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestNoColor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status": "ok", "version": "1.4.0"}`))
+	}))
+	defer server.Close()
+
+	t.Run("colors output by default", func(t *testing.T) {
+		actual := callPing([]string{"--broker-url", server.URL})
+		if !strings.Contains(actual.actual, ansiGreen) {
+			t.Error(actual.actual)
+		}
+		teardown()
+	})
+
+	t.Run("prints plain text with --no-color", func(t *testing.T) {
+		actual := callPing([]string{"--broker-url", server.URL, "--no-color"})
+		if strings.Contains(actual.actual, "\033[") || !strings.HasPrefix(actual.actual, "Reachable - ") {
+			t.Error(actual.actual)
+		}
+		teardown()
+	})
+
+	t.Run("prints plain text when NO_COLOR is set", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "1")
+		actual := callPing([]string{"--broker-url", server.URL})
+		if strings.Contains(actual.actual, "\033[") {
+			t.Error(actual.actual)
+		}
+		teardown()
+	})
+
+	t.Run("prints plain text when stdout is not a terminal", func(t *testing.T) {
+		stdoutIsTerminal = func() bool { return false }
+		defer func() { stdoutIsTerminal = func() bool { return true } }()
+
+		actual := callPing([]string{"--broker-url", server.URL})
+		if strings.Contains(actual.actual, "\033[") {
+			t.Error(actual.actual)
+		}
+		teardown()
+	})
+}
+
+func TestIsTerminal(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "output.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if isTerminal(file) {
+		t.Error("a regular file is not a terminal")
+	}
+}
+
+func TestVerboseLogsBrokerTraffic(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("SIGNET_TOKEN", "secret-token")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"error": "Environment already exists"}`))
+	}))
+	defer server.Close()
+
+	actual := callRegisterEnv([]string{"--broker-url", server.URL, "--environment=production", "--verbose"})
+
+	t.Run("logs the request", func(t *testing.T) {
+		expected := "> POST " + server.URL + "/api/environments\n"
+		if !strings.Contains(actual.actual, expected) || !strings.Contains(actual.actual, "> {\"environmentName\":\"production\"}\n") {
+			t.Error(actual.actual)
+		}
+	})
+
+	t.Run("logs the response", func(t *testing.T) {
+		if !strings.Contains(actual.actual, "< 409 Conflict\n< {\"error\": \"Environment already exists\"}\n") {
+			t.Error(actual.actual)
+		}
+	})
+
+	t.Run("still reports the broker's error from the logged response", func(t *testing.T) {
+		if !strings.Contains(actual.actual, "Error: Status code: 409 Conflict - Environment already exists") {
+			t.Error(actual.actual)
+		}
+	})
+
+	t.Run("redacts the token", func(t *testing.T) {
+		if !strings.Contains(actual.actual, "> Authorization: [REDACTED]\n") || strings.Contains(actual.actual, "secret-token") {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestBrokerTrafficIsNotLoggedByDefault(t *testing.T) {
+	server, _ := mockServerForJSONReq201Created[utils.EnvBody](t)
+	defer server.Close()
+
+	actual := callRegisterEnv([]string{"--broker-url", server.URL, "--environment=production"})
+
+	if strings.Contains(actual.actual, "> POST") {
+		t.Error(actual.actual)
+	}
+	teardown()
+}
+
+func TestHeadersAreSentToBroker(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	actual := callRegisterEnv([]string{"--broker-url", server.URL, "--environment=production", "--header", "X-Api-Key: abc123", "--header=X-Tenant:team-a", "--verbose"})
+
+	t.Run("sends every header", func(t *testing.T) {
+		if received.Get("X-Api-Key") != "abc123" || received.Get("X-Tenant") != "team-a" {
+			t.Error(received)
+		}
+	})
+
+	t.Run("still sends the request's own headers", func(t *testing.T) {
+		if received.Get("Content-Type") != "application/json" {
+			t.Error(received)
+		}
+	})
+
+	t.Run("redacts the header values in the verbose log", func(t *testing.T) {
+		if !strings.Contains(actual.actual, "> X-Api-Key: [REDACTED]\n") || strings.Contains(actual.actual, "abc123") {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestHeaderWithoutValueSeparator(t *testing.T) {
+	actual := callRegisterEnv([]string{"--broker-url=http://localhost:3000", "--environment=production", "--header", "X-Api-Key=abc123"})
+	expected := "Error: --header must be in the form 'Key: Value' (ex. 'X-Api-Key: abc123'), --header was X-Api-Key=abc123"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestParseHeaders(t *testing.T) {
+	t.Run("sends every value of a repeated key", func(t *testing.T) {
+		headers, err := parseHeaders([]string{"X-Tenant: team-a", "x-tenant: team-b"})
+		if err != nil || strings.Join(headers.Values("X-Tenant"), ",") != "team-a,team-b" {
+			t.Error(headers, err)
+		}
+	})
+
+	t.Run("keeps colons in the value", func(t *testing.T) {
+		headers, err := parseHeaders([]string{"X-Forwarded-Host: broker.example.com:3000"})
+		if err != nil || headers.Get("X-Forwarded-Host") != "broker.example.com:3000" {
+			t.Error(headers, err)
+		}
+	})
+
+	for _, invalid := range []string{"X-Api-Key", ": abc123", "X Api Key: abc123"} {
+		t.Run("rejects "+invalid, func(t *testing.T) {
+			if _, err := parseHeaders([]string{invalid}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestValidateBrokerURL(t *testing.T) {
+	t.Run("strips trailing slashes", func(t *testing.T) {
+		actual, err := validateBrokerURL("https://broker.example.com:3000//")
+		if err != nil || actual != "https://broker.example.com:3000" {
+			t.Error(actual, err)
+		}
+	})
+
+	for _, invalid := range []string{"localhost:3000", "broker.example.com", "ftp://broker.example.com", "http://"} {
+		t.Run("rejects "+invalid, func(t *testing.T) {
+			_, err := validateBrokerURL(invalid)
+			if err == nil || err.Error() != "--broker-url must be in the form http(s)://host[:port] (ex. http://localhost:3000), --broker-url was "+invalid {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestBrokerURLIsValidated(t *testing.T) {
+	t.Run("before a request is sent", func(t *testing.T) {
+		actual := callRegisterEnv([]string{"--broker-url", "localhost:3000", "--environment=production"})
+		expected := "Error: --broker-url must be in the form http(s)://host[:port] (ex. http://localhost:3000), --broker-url was localhost:3000"
+
+		actual.startsWith(expected, t)
+		teardown()
+	})
+
+	t.Run("and sent without a trailing slash", func(t *testing.T) {
+		var path string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			w.WriteHeader(http.StatusCreated)
+		}))
+		defer server.Close()
+
+		callRegisterEnv([]string{"--broker-url", server.URL + "/", "--environment=production"})
+		if path != "/api/environments" {
+			t.Error(path)
+		}
+		teardown()
+	})
+
+	t.Run("exits with 2 from deploy-guard", func(t *testing.T) {
+		exitCode := exitCodeOf([]string{"deploy-guard", "--broker-url", "localhost:3000", "--name", "user_service", "--environment", "production", "--version=version1"})
+		if exitCode != exitUsage {
+			t.Error(exitCode)
+		}
+		teardown()
+	})
+}
+
+func TestSpinner(t *testing.T) {
+	defer func() { stderrIsTerminal = func() bool { return false } }()
+
+	t.Run("draws the message, and clears its line when stopped", func(t *testing.T) {
+		stderrIsTerminal = func() bool { return true }
+		out := new(bytes.Buffer)
+
+		spin := startSpinner(out, "Verifying the provider")
+		spin.stop()
+		spin.stop()
+
+		if !strings.HasPrefix(out.String(), clearLine+spinnerFrames[0]+" Verifying the provider") || !strings.HasSuffix(out.String(), clearLine) {
+			t.Errorf("%q", out.String())
+		}
+	})
+
+	t.Run("draws nothing when stderr is not a terminal", func(t *testing.T) {
+		stderrIsTerminal = func() bool { return false }
+		out := new(bytes.Buffer)
+
+		startSpinner(out, "Verifying the provider").stop()
+		if out.Len() != 0 {
+			t.Errorf("%q", out.String())
+		}
+	})
+
+	t.Run("draws nothing with --no-color", func(t *testing.T) {
+		stderrIsTerminal = func() bool { return true }
+		noColor = true
+		defer teardown()
+		out := new(bytes.Buffer)
+
+		startSpinner(out, "Verifying the provider").stop()
+		if out.Len() != 0 {
+			t.Errorf("%q", out.String())
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// how often the spinner moves to its next frame
+const spinnerInterval = 100 * time.Millisecond
+
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// clears the line that the spinner was drawn on, and returns the cursor to its start
+const clearLine = "\r\033[K"
+
+/*
+shows that a long-running step is still in progress, by redrawing a frame
+and a message in place on one line. It is only drawn when stderr is a
+terminal and color has not been turned off, so nothing is written to logs
+or pipes.
+*/
+type spinner struct {
+	out     io.Writer
+	message string
+	stopped chan struct{}
+	done    chan struct{}
+}
+
+// starts a spinner next to message, which is drawn until stop is called
+func startSpinner(out io.Writer, message string) *spinner {
+	s := &spinner{out: out, message: message, stopped: make(chan struct{}), done: make(chan struct{})}
+	if !spinnerEnabled() {
+		close(s.done)
+		return s
+	}
+
+	go s.spin()
+	return s
+}
+
+func (s *spinner) spin() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(spinnerInterval)
+	defer ticker.Stop()
+
+	for frame := 0; ; frame++ {
+		fmt.Fprint(s.out, clearLine+spinnerFrames[frame%len(spinnerFrames)]+" "+s.message)
+
+		select {
+		case <-ticker.C:
+		case <-s.stopped:
+			fmt.Fprint(s.out, clearLine)
+			return
+		}
+	}
+}
+
+// stops the spinner and clears its line, so that the next output starts on an empty line
+func (s *spinner) stop() {
+	select {
+	case <-s.stopped:
+	default:
+		close(s.stopped)
+	}
+	<-s.done
+}
+
+// the spinner redraws its line with escape codes, which only a terminal understands
+func spinnerEnabled() bool {
+	return !noColor && os.Getenv("NO_COLOR") == "" && stderrIsTerminal()
+}
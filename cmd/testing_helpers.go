@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -8,10 +9,21 @@ import (
 	"os"
 	"testing"
 
+	"github.com/spf13/cobra"
+
 	client "github.com/signet-framework/signet-cli/client"
 	utils "github.com/signet-framework/signet-cli/utils"
 )
 
+/*
+go test does not run with a terminal, but tests compare against the colored
+output. A spinner would be drawn into that output, so it is left off.
+*/
+func init() {
+	stdoutIsTerminal = func() bool { return true }
+	stderrIsTerminal = func() bool { return false }
+}
+
 func teardown() {
 	serviceType = ""
 	path = ""
@@ -24,6 +36,103 @@ func teardown() {
 	environment = ""
 	delete = false
 	providerURL = ""
+	pactFile = ""
+	compileOnly = false
+	teardownURL = ""
+	failOnTeardownError = false
+	noCache = false
+	providerDiscovery = ""
+	strict = false
+	onlyNewSince = ""
+	schemeFallback = false
+	summaryJSON = ""
+	providerURLScan = ""
+	verifySignature = false
+	signingKey = ""
+	dreddTimeout = defaultDreddTimeout
+	maxResponseTime = 0
+	client.SigningKey = nil
+	environmentTags = []string{}
+	failOnUnverified = false
+	includePending = false
+	concurrency = 1
+	wait = 0
+	pollInterval = defaultPollInterval
+	port = ""
+	targets = []string{}
+	providerNames = []string{}
+	includePaths = []string{}
+	excludePaths = []string{}
+	scrubHeaders = []string{}
+	providerState = ""
+	recordStatuses = []string{}
+	caCert = ""
+	insecure = false
+	normalizeNumbers = false
+	recordTrailers = false
+	maxBodySize = defaultMaxBodySize
+	dumpRequests = ""
+	rotateDump = false
+	fixtureDir = ""
+	publishContract = false
+	fromEnvironment = ""
+	toEnvironment = ""
+	token = ""
+	output = "text"
+	tags = []string{}
+	tagsFromEnv = []string{}
+	schemaVersion = 0
+	onlyBranches = []string{}
+	ttl = ""
+	changedSince = ""
+	sourcePaths = []string{}
+	maxSpecSize = defaultMaxSpecSize
+	contractType = ""
+	skipValidation = false
+	onConflict = "fail"
+	versionOutput = ""
+	versionTransform = ""
+	dryRun = false
+	guard = false
+	ifNotExists = false
+	pathRelativeTo = "cwd"
+	retries = 0
+	retryTimeout = defaultRetryTimeout
+	client.Retries = 0
+	client.RetryTimeout = defaultRetryTimeout
+	shaLength = defaultSHALength
+	utils.SHALength = defaultSHALength
+	utils.StdinContents = nil
+	webhookURL = ""
+	showDiff = false
+	force = false
+	allConsumers = false
+	providerVersion = ""
+	assumeYes = false
+	RootCmd.SetIn(nil)
+	verbose = false
+	IgnoreConfig = false
+	noColor = false
+	setColor(true)
+	configFile = ""
+	client.VerboseOutput = nil
+	brokerHeaders = []string{}
+	client.Headers = nil
+	errorFormat = "text"
+	RootCmd.SilenceErrors = false
+	RootCmd.SilenceUsage = false
+	RootCmd.PersistentFlags().Lookup("broker-url").Changed = false
+	resetVersionFlags(RootCmd)
+}
+
+// --version counts as passed once a test passes it, which would stop later tests from falling back to SIGNET_VERSION
+func resetVersionFlags(command *cobra.Command) {
+	if flag := command.Flags().Lookup("version"); flag != nil {
+		flag.Changed = false
+	}
+	for _, subcommand := range command.Commands() {
+		resetVersionFlags(subcommand)
+	}
 }
 
 type actualOut struct {
@@ -44,7 +153,7 @@ func (ao actualOut) startsWith(expected string, t *testing.T) {
 }
 
 type requestBody interface {
-	utils.ConsumerBody | utils.ProviderBody | utils.EnvBody | utils.DeploymentBody
+	utils.ConsumerBody | utils.ProviderBody | utils.EnvBody | utils.DeploymentBody | utils.PublishWebhookBody
 }
 
 /*
@@ -57,6 +166,11 @@ func mockServerForJSONReq201Created[T requestBody](t *testing.T) (*httptest.Serv
 	var reqBody T
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/capabilities" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
 		if r.Header.Get("Content-Type") != "application/json" {
 			t.Errorf("Expected Content-Type: application/json header, got: %s", r.Header.Get("Content-Type"))
 		}
@@ -77,6 +191,11 @@ func mockServerForJSONReq200OK[T requestBody](t *testing.T) (*httptest.Server, *
 	var reqBody T
 
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/capabilities" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
 		if r.Header.Get("Content-Type") != "application/json" {
 			t.Errorf("Expected Content-Type: application/json header, got: %s", r.Header.Get("Content-Type"))
 		}
@@ -135,3 +254,100 @@ func mockServerForDeployGuardReq200OK(t *testing.T, respBody client.DeployGuardR
 
 	return server, &req
 }
+
+/*
+serves the registered environments, and answers deploy-guard requests with
+respBody while recording the environment each request checked
+*/
+func mockServerForEnvironmentsAndDeployGuard(t *testing.T, environments []client.Environment, respBody client.DeployGuardResponse) (*httptest.Server, *[]string) {
+	checked := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body interface{} = respBody
+		if r.URL.Path == "/api/environments" {
+			body = environments
+		} else {
+			checked = append(checked, r.URL.Query().Get("environmentName"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+
+		err := json.NewEncoder(w).Encode(body)
+		if err != nil {
+			t.Error("Failed to write mock response body")
+		}
+	}))
+
+	return server, &checked
+}
+
+/*
+writes a recorded request/response pair to a stubs directory laid out like
+the one mountebank creates with --datadir, and returns the stubs directory
+*/
+func writeMbMatches(t *testing.T, matches ...map[string]interface{}) string {
+	stubsDir := t.TempDir()
+
+	for i, match := range matches {
+		matchesDir := fmt.Sprintf("%s/stub%d/matches", stubsDir, i)
+		err := os.MkdirAll(matchesDir, os.ModePerm)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		matchBytes, err := json.Marshal(match)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = os.WriteFile(matchesDir+"/match.json", matchBytes, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return stubsDir
+}
+
+func mbMatch(method, path string, statusCode int, responseHeaders map[string]interface{}, responseBody string) map[string]interface{} {
+	return map[string]interface{}{
+		"request": map[string]interface{}{
+			"method":  method,
+			"path":    path,
+			"query":   map[string]interface{}{},
+			"headers": map[string]interface{}{"Accept": "application/json"},
+		},
+		"response": map[string]interface{}{
+			"statusCode": statusCode,
+			"headers":    responseHeaders,
+			"body":       responseBody,
+		},
+	}
+}
+
+func loadPactMap(t *testing.T, pactPath string) map[string]interface{} {
+	pactBytes, err := os.ReadFile(pactPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pact := map[string]interface{}{}
+	err = json.Unmarshal(pactBytes, &pact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pact
+}
+
+// runs signet with args, and returns the code it would exit with
+func exitCodeOf(args []string) int {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs(args)
+	cmd, err := RootCmd.ExecuteC()
+	if err != nil {
+		return handleError(cmd, err)
+	}
+	return exitSuccess
+}
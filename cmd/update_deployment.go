@@ -9,10 +9,12 @@ import (
 	"github.com/spf13/viper"
 
 	client "github.com/signet-framework/signet-cli/client"
+	signet "github.com/signet-framework/signet-cli/signet"
 	utils "github.com/signet-framework/signet-cli/utils"
 )
 
 var delete bool
+var guard bool
 
 var updateDeploymentCmd = &cobra.Command{
 	Use:   "update-deployment",
@@ -21,42 +23,73 @@ var updateDeploymentCmd = &cobra.Command{
 	
 	flags:
 
-	-n --name           the name of the service
+	-n --name           the name of the service (defaults to SIGNET_NAME)
 	
-	-v --version        the version of the service (defaults to git SHA of HEAD if no value is provided)
+	-v --version        the version of the service (if not passed, defaults to SIGNET_VERSION, and then to the git SHA of HEAD)
 	
-	-e --environment    the name of the environment that the service is deployed to (ex. production)
+	-e --environment    the name of the environment that the service is deployed to (ex. production, defaults to SIGNET_ENVIRONMENT)
+	
+	--version-output    file that the resolved version is written to, or '-' for stdout (optional)
 	
 	-d --delete         the presence of this flag indicates that the service is no longer deployed to the environment (optional)
 	
+	--guard             check that the version is safe to deploy to the environment with deploy-guard first, and only record the deployment when it is (optional)
+	
+	--dry-run           print the request that would be sent to the broker, without sending it (optional)
+	
 	-u --broker-url     the scheme, domain, and port where the Signet Broker is being hosted
 	
 	-i --ignore-config  ingore .signetrc.yaml file if it exists (optional)
 	`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		name = viper.GetString("update-deployment.name")
-		environment = viper.GetString("update-deployment.environment")
+		brokerURL = resolveBrokerURL(cmd)
+		name = valueOrEnv(viper.GetString("update-deployment.name"), nameEnvVar)
+		environment = valueOrEnv(viper.GetString("update-deployment.environment"), environmentEnvVar)
+		versionOutput = viper.GetString("update-deployment.version-output")
+		guard = viper.GetBool("update-deployment.guard")
 
 		if len(brokerURL) == 0 {
 			return errors.New("No --broker-url was provided. This is a required flag.")
 		}
 
+		var err error
+		brokerURL, err = validateBrokerURL(brokerURL)
+		if err != nil {
+			return err
+		}
+
 		if len(name) == 0 {
 			return errors.New("No --name was provided. A value for this flag is required.")
 		}
 
-		if version == "" || version == "auto" {
-			var err error
-			version, err = utils.SetVersionToGitSha(version)
-			if err != nil {
-				return err
-			}
+		version, err = resolveVersion(cmd, version)
+		if err != nil {
+			return err
 		}
 
 		if len(environment) == 0 {
 			return errors.New("No --environment was provided. A value for this flag is required.")
 		}
 
+		if guard && delete {
+			return errors.New("--guard cannot be used with --delete, a service can always be undeployed")
+		}
+
+		if guard {
+			result, err := signet.CheckDeployGuard(cmd.Context(), signet.DeployGuardOptions{BrokerURL: brokerURL, Name: name, Version: version, Environment: environment})
+			if err != nil {
+				return err
+			}
+
+			if !result.Status {
+				cmd.Println(colorRed + "Unsafe to Deploy" + colorReset + " - version " + version + " of " + name + " is incompatible with one or more services in " + environment + " environment")
+				for _, guardErr := range result.Errors {
+					cmd.Printf("    - %s: %s\n", guardErr.Title, guardErr.Details)
+				}
+				return verificationFailed(cmd, "version "+version+" of "+name+" was not recorded as deployed to "+environment)
+			}
+		}
+
 		requestBody := utils.DeploymentBody{
 			EnvironmentName:    environment,
 			ParticipantName:    name,
@@ -69,13 +102,28 @@ var updateDeploymentCmd = &cobra.Command{
 			return err
 		}
 
-		err = client.UpdateDeploymentWithBroker(brokerURL, jsonData)
+		if dryRun {
+			return printDryRun(cmd, "PATCH", brokerURL+"/api/participants", jsonData)
+		}
+
+		idempotencyKey, err := utils.NewDeploymentIdempotencyKey(requestBody)
+		if err != nil {
+			return err
+		}
+
+		alreadyRecorded, err := client.UpdateDeploymentWithBroker(cmd.Context(), brokerURL, jsonData, idempotencyKey)
 		if err != nil {
 			return err
 		}
 
-		if delete {
+		if alreadyRecorded && delete {
+			fmt.Println(colorGreen + "Undeployed" + colorReset + " - Signet broker had already recorded that service version is no longer deployed to the environment")
+		} else if alreadyRecorded {
+			fmt.Println(colorGreen + "Deployed" + colorReset + " - Signet broker had already recorded that service version is deployed to the environment")
+		} else if delete {
 			fmt.Println(colorGreen + "Undeployed" + colorReset + " - Signet broker was notified that service version is no longer deployed to the environment")
+		} else if guard {
+			fmt.Println(colorGreen + "Deployed" + colorReset + " - version " + version + " of " + name + " is compatible with all other services in " + environment + " environment, and Signet broker was notified that it has been deployed there")
 		} else {
 			fmt.Println(colorGreen + "Deployed" + colorReset + " - Signet broker was notified that service version has been deployed to the environment")
 		}
@@ -90,9 +138,14 @@ func init() {
 	updateDeploymentCmd.Flags().StringVarP(&name, "name", "n", "", "The name of the service which was deployed")
 	updateDeploymentCmd.Flags().StringVarP(&version, "version", "v", "", "The version of the service which was deployed")
 	updateDeploymentCmd.Flags().StringVarP(&environment, "environment", "e", "", "The environment which the service was deployed to")
+	updateDeploymentCmd.Flags().StringVar(&versionOutput, "version-output", "", "File that the resolved version is written to, or '-' for stdout")
 	updateDeploymentCmd.Flags().BoolVarP(&delete, "delete", "d", false, "The service is no longer deployed to the environment")
+	updateDeploymentCmd.Flags().BoolVar(&guard, "guard", false, "Check that the version is safe to deploy with deploy-guard first, and only record the deployment when it is")
+	updateDeploymentCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the request that would be sent to the broker, without sending it")
 	updateDeploymentCmd.Flags().Lookup("version").NoOptDefVal = "auto"
 
 	viper.BindPFlag("update-deployment.name", updateDeploymentCmd.Flags().Lookup("name"))
 	viper.BindPFlag("update-deployment.environment", updateDeploymentCmd.Flags().Lookup("environment"))
+	viper.BindPFlag("update-deployment.version-output", updateDeploymentCmd.Flags().Lookup("version-output"))
+	viper.BindPFlag("update-deployment.guard", updateDeploymentCmd.Flags().Lookup("guard"))
 }
@@ -2,8 +2,14 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 
+	client "github.com/signet-framework/signet-cli/client"
 	utils "github.com/signet-framework/signet-cli/utils"
 )
 
@@ -154,3 +160,293 @@ func TestUpdateDeploymentRequestWithDelete(t *testing.T) {
 	})
 	teardown()
 }
+
+func TestUpdateDeploymentVersionOutput(t *testing.T) {
+	server, reqBody := mockServerForJSONReq200OK[utils.DeploymentBody](t)
+	defer server.Close()
+
+	versionPath := t.TempDir() + "/version.txt"
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--environment", "production",
+		"--version-output", versionPath,
+	}
+	callUpdateDeployment(flags)
+
+	t.Run("writes the resolved version to the --version-output file", func(t *testing.T) {
+		versionBytes, err := os.ReadFile(versionPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(reqBody.ParticipantVersion) == 0 || string(versionBytes) != reqBody.ParticipantVersion+"\n" {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestUpdateDeploymentVersionTransform(t *testing.T) {
+	server, reqBody := mockServerForJSONReq200OK[utils.DeploymentBody](t)
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--environment", "production",
+		"--version=refs/tags/v1.2.3",
+		"--version-transform", "^refs/tags/v(.*)$=$1",
+	}
+	callUpdateDeployment(flags)
+
+	t.Run("sends the transformed version", func(t *testing.T) {
+		if reqBody.ParticipantVersion != "1.2.3" {
+			t.Error(reqBody.ParticipantVersion)
+		}
+	})
+	teardown()
+}
+
+func TestUpdateDeploymentDryRun(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--environment", "production",
+		"--version=version1",
+		"--dry-run",
+	}
+	actual := callUpdateDeployment(flags)
+
+	t.Run("does not send a request", func(t *testing.T) {
+		if requests != 0 {
+			t.Error()
+		}
+	})
+
+	t.Run("prints the request body with the resolved version", func(t *testing.T) {
+		if !strings.Contains(actual.actual, `"participantVersion": "version1"`) || !strings.Contains(actual.actual, `"deployed": true`) {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestUpdateDeploymentDryRunValidatesFlags(t *testing.T) {
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+		"--name", "user_service",
+		"--version=version1",
+		"--dry-run",
+	}
+	actual := callUpdateDeployment(flags)
+	expected := "Error: No --environment was provided."
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestUpdateDeploymentFromEnvironmentVariables(t *testing.T) {
+	server, reqBody := mockServerForJSONReq200OK[utils.DeploymentBody](t)
+	defer server.Close()
+
+	t.Setenv("SIGNET_NAME", "user_service")
+	t.Setenv("SIGNET_VERSION", "1.2.0")
+	t.Setenv("SIGNET_ENVIRONMENT", "staging")
+
+	t.Run("falls back to SIGNET_NAME, SIGNET_VERSION, and SIGNET_ENVIRONMENT", func(t *testing.T) {
+		callUpdateDeployment([]string{"--broker-url", server.URL})
+		if reqBody.ParticipantName != "user_service" || reqBody.ParticipantVersion != "1.2.0" || reqBody.EnvironmentName != "staging" {
+			t.Error(reqBody)
+		}
+		teardown()
+	})
+
+	t.Run("prefers the flags", func(t *testing.T) {
+		flags := []string{
+			"--broker-url", server.URL,
+			"--name", "service_1",
+			"--version=version1",
+			"--environment", "production",
+		}
+		callUpdateDeployment(flags)
+		if reqBody.ParticipantName != "service_1" || reqBody.ParticipantVersion != "version1" || reqBody.EnvironmentName != "production" {
+			t.Error(reqBody)
+		}
+		teardown()
+	})
+
+	t.Run("uses the git SHA of HEAD for an explicit --version auto", func(t *testing.T) {
+		gitSHA, err := utils.SetVersionToGitSha("auto")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		callUpdateDeployment([]string{"--broker-url", server.URL, "--version=auto"})
+		if reqBody.ParticipantVersion != gitSHA {
+			t.Error(reqBody.ParticipantVersion)
+		}
+		teardown()
+	})
+}
+
+// answers deploy-guard checks with guardResult, and records the deployments that are sent
+func mockServerForGuardedDeployment(t *testing.T, guardResult client.DeployGuardResponse) (*httptest.Server, *[]utils.DeploymentBody) {
+	deployments := []utils.DeploymentBody{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/deploy" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(guardResult)
+			return
+		}
+
+		var deployment utils.DeploymentBody
+		err := json.NewDecoder(r.Body).Decode(&deployment)
+		if err != nil {
+			t.Error(err)
+		}
+		deployments = append(deployments, deployment)
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	return server, &deployments
+}
+
+func TestUpdateDeploymentGuard(t *testing.T) {
+	flags := []string{
+		"--name", "user_service",
+		"--environment", "production",
+		"--version=version1",
+		"--guard",
+	}
+
+	t.Run("records the deployment when it is safe", func(t *testing.T) {
+		server, deployments := mockServerForGuardedDeployment(t, client.DeployGuardResponse{Status: true})
+		defer server.Close()
+
+		callUpdateDeployment(append(flags, "--broker-url", server.URL))
+		if len(*deployments) != 1 || (*deployments)[0].ParticipantVersion != "version1" || !(*deployments)[0].Deployed {
+			t.Error(*deployments)
+		}
+		teardown()
+	})
+
+	t.Run("does not record the deployment when it is unsafe", func(t *testing.T) {
+		unsafe := client.DeployGuardResponse{
+			Status: false,
+			Errors: []client.DeployGuardError{{Title: "incompatible consumer", Details: "service_1 expects GET /users/{id}"}},
+		}
+		server, deployments := mockServerForGuardedDeployment(t, unsafe)
+		defer server.Close()
+
+		actual := callUpdateDeployment(append(flags, "--broker-url", server.URL))
+		expected := colorRed + "Unsafe to Deploy" + colorReset + " - version version1 of user_service is incompatible with one or more services in production environment\n" +
+			"    - incompatible consumer: service_1 expects GET /users/{id}\n" +
+			"Error: version version1 of user_service was not recorded as deployed to production"
+
+		actual.startsWith(expected, t)
+		if len(*deployments) != 0 {
+			t.Error(*deployments)
+		}
+		teardown()
+	})
+
+	t.Run("exits with 1 when it is unsafe", func(t *testing.T) {
+		server, _ := mockServerForGuardedDeployment(t, client.DeployGuardResponse{Status: false})
+		defer server.Close()
+
+		exitCode := exitCodeOf(append([]string{"update-deployment", "--broker-url", server.URL}, flags...))
+		if exitCode != exitFailed {
+			t.Error(exitCode)
+		}
+		teardown()
+	})
+
+	t.Run("cannot be used with --delete", func(t *testing.T) {
+		actual := callUpdateDeployment(append(flags, "--broker-url=http://localhost:3000", "--delete"))
+		expected := "Error: --guard cannot be used with --delete"
+
+		actual.startsWith(expected, t)
+		teardown()
+	})
+}
+
+func TestUpdateDeploymentIdempotencyKey(t *testing.T) {
+	keys := []string{}
+	statuses := []int{}
+	conflictBody := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(client.IdempotencyKeyHeader))
+		status := http.StatusOK
+		if len(statuses) != 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		w.WriteHeader(status)
+		if status == http.StatusConflict {
+			w.Write([]byte(conflictBody))
+		}
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"update-deployment",
+		"--broker-url", server.URL,
+		"--name", "user_service",
+		"--environment", "production",
+		"--version=version1",
+	}
+
+	t.Run("sends the same key when the request is retried", func(t *testing.T) {
+		keys = []string{}
+		statuses = []int{http.StatusServiceUnavailable}
+		exitCodeOf(append(flags, "--retry", "1", "--retry-timeout", "10s"))
+		teardown()
+
+		if len(keys) != 2 || len(keys[0]) == 0 || keys[0] != keys[1] {
+			t.Error(keys)
+		}
+	})
+
+	t.Run("sends a different key when the version is undeployed and redeployed", func(t *testing.T) {
+		keys = []string{}
+		exitCodeOf(flags)
+		teardown()
+		exitCodeOf(append(flags, "--delete"))
+		teardown()
+		exitCodeOf(flags)
+		teardown()
+
+		if len(keys) != 3 || keys[0] == keys[1] || keys[0] == keys[2] || keys[1] == keys[2] {
+			t.Error(keys)
+		}
+	})
+
+	t.Run("succeeds when the broker had already recorded the request", func(t *testing.T) {
+		statuses = []int{http.StatusConflict}
+		conflictBody = `{"error": "already recorded", "code": "` + client.IdempotencyKeyReusedCode + `"}`
+		code := exitCodeOf(flags)
+		teardown()
+
+		if code != exitSuccess {
+			t.Errorf("expected exit code %d, got %d", exitSuccess, code)
+		}
+	})
+
+	t.Run("fails on any other conflict", func(t *testing.T) {
+		statuses = []int{http.StatusConflict}
+		conflictBody = `{"error": "the environment is locked"}`
+		code := exitCodeOf(flags)
+		teardown()
+
+		if code != exitBroker {
+			t.Errorf("expected exit code %d, got %d", exitBroker, code)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var verifyPactCmd = &cobra.Command{
+	Use:   "verify-pact",
+	Short: "replay a local consumer pact against a running provider, without the Signet broker",
+	Long: `replay each interaction in a local consumer pact against a running provider service, and report the interactions whose responses do not match. The broker is not contacted, and the results are not published.
+
+	flags:
+
+	-p --path           the relative path to the consumer pact
+
+	-s --provider-url   the URL where the provider service is running (defaults to http:// if no scheme is given)
+
+	--provider-states-teardown-url   URL that a teardown request is POSTed to after each replayed interaction with a provider state (optional)
+
+	--fail-on-teardown-error         fail an interaction when its provider state teardown fails, instead of warning (optional)
+
+	--max-response-time warn about each interaction whose response is slower than this, ex. 500ms (optional)
+
+	--strict            fail when --provider-url has no scheme, instead of defaulting to http://, and fail interactions slower than --max-response-time instead of warning (optional)
+
+	--summary-json      file that a JSON summary of the result is written to, whether the pact passed or failed (optional)
+	`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path = viper.GetString("verify-pact.path")
+		providerURL = viper.GetString("verify-pact.provider-url")
+		teardownURL = viper.GetString("verify-pact.provider-states-teardown-url")
+		failOnTeardownError = viper.GetBool("verify-pact.fail-on-teardown-error")
+		summaryJSON = viper.GetString("verify-pact.summary-json")
+		maxResponseTime = viper.GetDuration("verify-pact.max-response-time")
+
+		if len(path) == 0 {
+			return usageError(errors.New("No --path to a consumer pact was provided. This is a required flag."))
+		}
+
+		if len(providerURL) == 0 {
+			return usageError(errors.New("No --provider-url was provided. This is a required flag."))
+		}
+
+		if maxResponseTime < 0 {
+			return usageError(errors.New("--max-response-time must not be negative, --max-response-time was " + maxResponseTime.String()))
+		}
+
+		var err error
+		providerURL, err = normalizeProviderURL(cmd, providerURL, strict)
+		if err != nil {
+			return usageError(err)
+		}
+
+		summary, err := replayPactFile(cmd, path, []string{providerURL})
+		if err != nil {
+			return err
+		}
+
+		err = writeTestSummary(summary)
+		if err != nil {
+			return err
+		}
+
+		if !summary.Passed {
+			return verificationFailed(cmd, "pact verification failed - "+strconv.Itoa(summary.Interactions.Failed)+" of the interactions in "+path+" failed against the provider service")
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(verifyPactCmd)
+
+	verifyPactCmd.Flags().StringVarP(&path, "path", "p", "", "The relative path to the consumer pact")
+	verifyPactCmd.Flags().StringVarP(&providerURL, "provider-url", "s", "", "The URL where the provider service is running")
+	verifyPactCmd.Flags().StringVar(&teardownURL, "provider-states-teardown-url", "", "URL that a teardown request is POSTed to after each replayed interaction with a provider state")
+	verifyPactCmd.Flags().BoolVar(&failOnTeardownError, "fail-on-teardown-error", false, "Fail an interaction when its provider state teardown fails, instead of warning")
+	verifyPactCmd.Flags().DurationVar(&maxResponseTime, "max-response-time", 0, "Warn about each interaction whose response is slower than this")
+	verifyPactCmd.Flags().BoolVar(&strict, "strict", false, "Fail when --provider-url has no scheme, instead of defaulting to http://, and fail interactions slower than --max-response-time instead of warning")
+	verifyPactCmd.Flags().StringVar(&summaryJSON, "summary-json", "", "File that a JSON summary of the result is written to, whether the pact passed or failed")
+
+	viper.BindPFlag("verify-pact.path", verifyPactCmd.Flags().Lookup("path"))
+	viper.BindPFlag("verify-pact.provider-url", verifyPactCmd.Flags().Lookup("provider-url"))
+	viper.BindPFlag("verify-pact.provider-states-teardown-url", verifyPactCmd.Flags().Lookup("provider-states-teardown-url"))
+	viper.BindPFlag("verify-pact.fail-on-teardown-error", verifyPactCmd.Flags().Lookup("fail-on-teardown-error"))
+	viper.BindPFlag("verify-pact.summary-json", verifyPactCmd.Flags().Lookup("summary-json"))
+	viper.BindPFlag("verify-pact.max-response-time", verifyPactCmd.Flags().Lookup("max-response-time"))
+}
@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+/* ------------- helpers ------------- */
+
+func callVerifyPact(argsAndFlags []string) actualOut {
+	actual := new(bytes.Buffer)
+	RootCmd.SetOut(actual)
+	RootCmd.SetErr(actual)
+	RootCmd.SetArgs(append([]string{"verify-pact"}, argsAndFlags...))
+	RootCmd.Execute()
+	return actualOut{actual.String()}
+}
+
+/* ------------- tests ------------- */
+
+func TestVerifyPactNoPath(t *testing.T) {
+	actual := callVerifyPact([]string{"--provider-url", "http://localhost:3002"})
+	expected := "Error: No --path to a consumer pact was provided. This is a required flag."
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestVerifyPactNoProviderURL(t *testing.T) {
+	actual := callVerifyPact([]string{"--path", "../data_test/cons-prov.json"})
+	expected := "Error: No --provider-url was provided. This is a required flag."
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestVerifyPact(t *testing.T) {
+	brokerRequested := false
+	broker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		brokerRequested = true
+	}))
+	defer broker.Close()
+
+	var req http.Request
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = *r
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"userId": 2, "username": "jimmy", "touchedBy": ["user_service"]}`))
+	}))
+	defer provider.Close()
+
+	summaryPath := t.TempDir() + "/summary.json"
+	flags := []string{
+		"--path", "../data_test/cons-prov.json",
+		"--provider-url", provider.URL,
+		"--broker-url", broker.URL,
+		"--summary-json", summaryPath,
+	}
+	actual := callVerifyPact(flags)
+
+	t.Run("replays the interaction request", func(t *testing.T) {
+		if req.Method != "GET" || req.URL.Path != "/users/1" {
+			t.Error(req.URL.String())
+		}
+	})
+
+	t.Run("prints a PASS for the interaction", func(t *testing.T) {
+		expected := colorGreen + "PASS" + colorReset + ": a request for the user with a userId of 1"
+		actual.startsWith(expected, t)
+	})
+
+	t.Run("does not contact the broker", func(t *testing.T) {
+		if brokerRequested {
+			t.Error()
+		}
+	})
+
+	t.Run("writes the summary", func(t *testing.T) {
+		summaryBytes, _ := os.ReadFile(summaryPath)
+		var summary testSummary
+		json.Unmarshal(summaryBytes, &summary)
+		if !summary.Passed || summary.Published || summary.Interactions != (interactionCounts{Total: 1, Passed: 1}) {
+			t.Error(string(summaryBytes))
+		}
+	})
+	teardown()
+}
+
+func TestVerifyPactMismatch(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "not found"}`))
+	}))
+	defer provider.Close()
+
+	flags := []string{
+		"--path", "../data_test/cons-prov.json",
+		"--provider-url", provider.URL,
+	}
+
+	t.Run("reports the mismatch", func(t *testing.T) {
+		actual := callVerifyPact(flags)
+		expected := colorRed + "FAIL" + colorReset + ": a request for the user with a userId of 1\n    - expected status 200 but got 404"
+
+		actual.startsWith(expected, t)
+		if !strings.Contains(actual.actual, "Error: pact verification failed - 1 of the interactions in ../data_test/cons-prov.json failed against the provider service") {
+			t.Error(actual.actual)
+		}
+		teardown()
+	})
+
+	t.Run("exits with 1", func(t *testing.T) {
+		exitCode := exitCodeOf(append([]string{"verify-pact"}, flags...))
+		if exitCode != exitFailed {
+			t.Error(exitCode)
+		}
+		teardown()
+	})
+}
+
+func TestVerifyPactSkipsMessageInteractions(t *testing.T) {
+	pactPath := t.TempDir() + "/cons-prov.json"
+	pact := `{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [{"type": "Asynchronous/Messages", "description": "a user created event", "contents": {"content": {"userId": 1}}}, {"type": "Synchronous/HTTP", "description": "a request for users", "request": {"method": "GET", "path": "/users"}, "response": {"status": 200}}], "metadata": {"pactSpecification": {"version": "4.0"}}}`
+	err := os.WriteFile(pactPath, []byte(pact), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := 0
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer provider.Close()
+
+	actual := callVerifyPact([]string{"--path", pactPath, "--provider-url", provider.URL})
+
+	t.Run("replays only the HTTP interactions", func(t *testing.T) {
+		if requests != 1 || !strings.Contains(actual.actual, "PASS"+colorReset+": a request for users") || strings.Contains(actual.actual, "a user created event") {
+			t.Error(requests, actual.actual)
+		}
+	})
+
+	t.Run("reports how many interactions were skipped", func(t *testing.T) {
+		expected := "Skipped - 1 message interactions in " + pactPath + " are not sent over HTTP, so they were not verified"
+		if !strings.Contains(actual.actual, expected) {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestVerifyPactOnlyMessageInteractions(t *testing.T) {
+	pactPath := t.TempDir() + "/cons-prov.json"
+	pact := `{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [{"type": "Asynchronous/Messages", "description": "a user created event", "contents": {"content": {"userId": 1}}}], "metadata": {"pactSpecification": {"version": "4.0"}}}`
+	err := os.WriteFile(pactPath, []byte(pact), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flags := []string{"--path", pactPath, "--provider-url", "http://localhost:3002"}
+
+	t.Run("reports that nothing was verified", func(t *testing.T) {
+		actual := callVerifyPact(flags)
+		expected := "Error: none of the 1 interactions in " + pactPath + " are sent over HTTP, so nothing was verified"
+
+		actual.startsWith(expected, t)
+		teardown()
+	})
+
+	t.Run("exits with 1", func(t *testing.T) {
+		exitCode := exitCodeOf(append([]string{"verify-pact"}, flags...))
+		if exitCode != exitFailed {
+			t.Error(exitCode)
+		}
+		teardown()
+	})
+}
+
+func TestVerifyPactV4HTTPInteraction(t *testing.T) {
+	pactPath := t.TempDir() + "/cons-prov.json"
+	pact := `{"consumer": {"name": "service_1"}, "provider": {"name": "user_service"}, "interactions": [` +
+		`{"type": "Synchronous/HTTP", "description": "a request to create a user", "request": {"method": "POST", "path": "/users", "headers": {"Content-Type": ["application/json"]}, "body": {"content": {"username": "jimmy"}, "contentType": "application/json", "encoded": false}}, "response": {"status": 201, "headers": {"Content-Type": ["application/json"], "Vary": ["Accept", "Origin"]}, "body": {"content": {"userId": 1, "username": "jimmy"}, "contentType": "application/json", "encoded": false}}},` +
+		`{"type": "Synchronous/HTTP", "description": "a request for the avatar of a user", "request": {"method": "GET", "path": "/users/1/avatar"}, "response": {"status": 200, "headers": {"Content-Type": ["text/plain"]}, "body": {"content": "YXZhdGFy", "contentType": "text/plain", "encoded": "base64"}}}` +
+		`], "metadata": {"pactSpecification": {"version": "4.0"}}}`
+	err := os.WriteFile(pactPath, []byte(pact), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var contentType, requestBody string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users/1/avatar" {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("avatar"))
+			return
+		}
+
+		contentType = r.Header.Get("Content-Type")
+		bodyBytes, _ := io.ReadAll(r.Body)
+		requestBody = string(bodyBytes)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Add("Vary", "Accept")
+		w.Header().Add("Vary", "Origin")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"userId": 1, "username": "jimmy"}`))
+	}))
+	defer provider.Close()
+
+	actual := callVerifyPact([]string{"--path", pactPath, "--provider-url", provider.URL})
+
+	t.Run("sends each header value as it is", func(t *testing.T) {
+		if contentType != "application/json" {
+			t.Error(contentType)
+		}
+	})
+
+	t.Run("sends the content of the body", func(t *testing.T) {
+		if requestBody != `{"username":"jimmy"}` {
+			t.Error(requestBody)
+		}
+	})
+
+	t.Run("passes both interactions", func(t *testing.T) {
+		if !strings.Contains(actual.actual, "PASS"+colorReset+": a request to create a user") ||
+			!strings.Contains(actual.actual, "PASS"+colorReset+": a request for the avatar of a user") ||
+			strings.Contains(actual.actual, "FAIL") {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestVerifyPactMaxResponseTime(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"userId": 2, "username": "jimmy", "touchedBy": ["user_service"]}`))
+	}))
+	defer provider.Close()
+
+	summaryPath := t.TempDir() + "/summary.json"
+	flags := []string{
+		"--path", "../data_test/cons-prov.json",
+		"--provider-url", provider.URL,
+		"--max-response-time", "10ms",
+		"--summary-json", summaryPath,
+	}
+
+	t.Run("warns about a slow response", func(t *testing.T) {
+		actual := callVerifyPact(flags)
+		expected := colorGreen + "PASS" + colorReset + ": a request for the user with a userId of 1\n    Warning - response took "
+		actual.startsWith(expected, t)
+		if !strings.Contains(actual.actual, ", longer than the maximum response time of 10ms") {
+			t.Error(actual.actual)
+		}
+		teardown()
+	})
+
+	t.Run("includes the timing of each interaction in the summary", func(t *testing.T) {
+		summaryBytes, _ := os.ReadFile(summaryPath)
+		var summary testSummary
+		json.Unmarshal(summaryBytes, &summary)
+		if len(summary.Timings) != 1 || summary.Timings[0].Consumer != "service_1" || summary.Timings[0].Interaction != "a request for the user with a userId of 1" || summary.Timings[0].DurationMs < 50 {
+			t.Error(string(summaryBytes))
+		}
+	})
+
+	t.Run("fails a slow response with --strict", func(t *testing.T) {
+		actual := callVerifyPact(append(flags, "--strict"))
+		expected := colorRed + "FAIL" + colorReset + ": a request for the user with a userId of 1\n    - response took "
+		actual.startsWith(expected, t)
+		teardown()
+
+		exitCode := exitCodeOf(append([]string{"verify-pact", "--strict"}, flags...))
+		if exitCode != exitFailed {
+			t.Error(exitCode)
+		}
+		teardown()
+	})
+
+	t.Run("rejects a negative --max-response-time", func(t *testing.T) {
+		actual := callVerifyPact([]string{"--path", "../data_test/cons-prov.json", "--provider-url", provider.URL, "--max-response-time", "-1s"})
+		expected := "Error: --max-response-time must not be negative, --max-response-time was -1s"
+		actual.startsWith(expected, t)
+		teardown()
+	})
+}
@@ -1,26 +1,109 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	client "github.com/signet-framework/signet-cli/client"
+	signet "github.com/signet-framework/signet-cli/signet"
 	utils "github.com/signet-framework/signet-cli/utils"
 )
 
 const rwPermissions = 0666
 
 var providerURL string
+var pactFile string
+var compileOnly bool
+var teardownURL string
+var failOnTeardownError bool
+var noCache bool
+var providerDiscovery string
+var strict bool
+var onlyNewSince string
+var schemeFallback bool
+var summaryJSON string
+var providerURLScan string
+var verifySignature bool
+var signingKey string
+var dreddTimeout time.Duration
+var allConsumers bool
+var providerVersion string
+var maxResponseTime time.Duration
+
+const defaultDreddTimeout = 60 * time.Second
+
+// dredd ends its output with a line such as "complete: 3 passing, 1 failing, 0 errors, 0 skipped, 4 total"
+var dreddComplete = regexp.MustCompile(`complete: (\d+) passing, (\d+) failing, (\d+) errors, (\d+) skipped, (\d+) total`)
+
+// dredd reports each transaction on a line such as "fail: GET (200) /users/1 duration: 5ms"
+var dreddTransaction = regexp.MustCompile(`^(pass|fail|skip|error): ([A-Z]+) \((\d+)\) (\S+)`)
+
+// the --summary-json result of a provider test, for dashboards and other tooling
+type testSummary struct {
+	Provider string `json:"provider"`
+	Version  string `json:"version,omitempty"`
+	// the provider build that was tested, when it is not the version that is published on success
+	ProviderVersion string            `json:"providerVersion,omitempty"`
+	Passed          bool              `json:"passed"`
+	Interactions    interactionCounts `json:"interactions"`
+	Published       bool              `json:"published"`
+	// how long the provider took to respond to each replayed interaction, with --pact-file or --all-consumers
+	Timings []interactionTiming `json:"timings,omitempty"`
+}
+
+type interactionTiming struct {
+	Consumer    string `json:"consumer"`
+	Interaction string `json:"interaction"`
+	ProviderURL string `json:"providerUrl"`
+	DurationMs  int64  `json:"durationMs"`
+}
+
+type interactionCounts struct {
+	Total  int `json:"total"`
+	Passed int `json:"passed"`
+	Failed int `json:"failed"`
+	// message interactions, which are not sent over HTTP and so are not replayed
+	Skipped int `json:"skipped,omitempty"`
+}
+
+// the --output json result of a provider test, with the outcome of each transaction dredd verified
+type testReport struct {
+	testSummary
+	Results []dreddResult `json:"results"`
+}
+
+type dreddResult struct {
+	ProviderURL string `json:"providerUrl"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Status      int    `json:"status"`
+	Result      string `json:"result"`
+	Reason      string `json:"reason,omitempty"`
+}
 
 // abstract pkg fn's to enable mocking during testing
 var getNpmPkgRoot = utils.GetNpmPkgRoot
 var osWriteFile = os.WriteFile
+var lookupSRV = net.LookupSRV
+var lookupHost = net.LookupHost
 
 var testCmd = &cobra.Command{
 	Use:   "test",
@@ -31,30 +114,258 @@ var testCmd = &cobra.Command{
 
 	-n --name           the name of the provider service
 	
-	-v --version        the version of the provider service (defaults to git SHA of HEAD if no value is provided)
+	-v --version        the version of the provider service that is recorded as verified on success (if not passed, defaults to SIGNET_VERSION, and then to the git SHA of HEAD)
+	
+	--provider-version  the version of the provider build that is tested, when it is not --version, or the git SHA of HEAD if '--provider-version' is passed with no value. It labels the output and reports only, and is not sent to the broker (optional, defaults to --version)
 	
 	-b --branch         git branch (optional, defaults to git branch of HEAD if '--branch' is passed with no value, or if '--version' defaulted to git SHA)
 	
-	-s --provider-url   the URL where the provider service is running
+	-e --environment    the environment the provider was verified in, which is published with the verification results (optional)
+	
+	-s --provider-url   the URL where the provider service is running (defaults to http:// if no scheme is given)
+	
+	--provider-discovery  'srv:<name>' to verify every provider instance resolved from DNS SRV records, instead of --provider-url (optional)
+	
+	--provider-url-scan 'host:startPort-endPort' range that is probed for a responding provider, the first port that responds is verified instead of --provider-url (optional)
+	
+	--verify-signature  fail unless the fetched API spec, or pact for --only-new-since, is signed by --signing-key (optional)
+	
+	--signing-key       PEM file with the broker's Ed25519 public key (required with --verify-signature)
+	
+	--no-cache          always download the latest API spec instead of reusing a cached copy the broker reports as unchanged (optional)
+	
+	--version-output    file that the resolved version is written to, or '-' for stdout (optional)
+	
+	--pact-file         replay a local consumer pact against --provider-url instead of fetching the spec from the broker (optional, results are not published)
+
+	--all-consumers     replay the latest contract of every consumer of the provider from the broker, instead of verifying the API spec (optional, results are not published)
+
+	--concurrency       how many consumer contracts are replayed in parallel with --all-consumers (optional, defaults to 1)
+	
+	--only-new-since    consumer version whose pact is fetched from the broker, only interactions added or changed since it are replayed (optional, only with --pact-file)
+	
+	--provider-states-teardown-url   URL that a teardown request is POSTed to after each replayed interaction with a provider state (optional, only with --pact-file)
+	
+	--fail-on-teardown-error         fail an interaction when its provider state teardown fails, instead of warning (optional)
+	
+	--max-response-time warn about each replayed interaction whose response is slower than this, ex. 500ms (optional, only with --pact-file or --all-consumers)
+	
+	--scheme-fallback   when the provider cannot be reached over the scheme of --provider-url, retry over the other of http and https (optional)
+	
+	--strict            fail when --provider-url has no scheme, instead of defaulting to http://, and fail interactions slower than --max-response-time instead of warning (optional)
+	
+	--summary-json      file that a JSON summary of the result is written to, whether the test passed or failed (optional)
+	
+	-o --output         'text' for the dredd breakdown, or 'json' for a summary of each endpoint and method that passed or failed on stdout (optional, defaults to 'text')
+	
+	--timeout           the longest time that dredd is given to verify each provider instance, ex. 90s or 5m (optional, defaults to 60s)
+	
+	--compile-only      only check that the latest API spec compiles under dredd, without contacting the provider (optional, results are not published)
+	
+	--skip-validation   run dredd without first checking that the latest API spec is a valid OpenAPI document (optional)
 	
 	-u --broker-url     the scheme, domain, and port where the Signet broker is being hosted
 	
 	-i --ignore-config  ingore .signetrc.yaml file if it exists (optional)
 	`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		brokerURL = resolveBrokerURL(cmd)
 		name = viper.GetString("test.name")
 		providerURL = viper.GetString("test.provider-url")
+		pactFile = viper.GetString("test.pact-file")
+		versionOutput = viper.GetString("test.version-output")
+		providerVersion = viper.GetString("test.provider-version")
+		teardownURL = viper.GetString("test.provider-states-teardown-url")
+		failOnTeardownError = viper.GetBool("test.fail-on-teardown-error")
+		providerDiscovery = viper.GetString("test.provider-discovery")
+		onlyNewSince = viper.GetString("test.only-new-since")
+		environment = viper.GetString("test.environment")
+		summaryJSON = viper.GetString("test.summary-json")
+		providerURLScan = viper.GetString("test.provider-url-scan")
+		verifySignature = viper.GetBool("test.verify-signature")
+		signingKey = viper.GetString("test.signing-key")
+		dreddTimeout = viper.GetDuration("test.timeout")
+		skipValidation = viper.GetBool("test.skip-validation")
+		allConsumers = viper.GetBool("test.all-consumers")
+		concurrency = viper.GetInt("test.concurrency")
+		output = viper.GetString("test.output")
+		maxResponseTime = viper.GetDuration("test.max-response-time")
+
+		if dreddTimeout <= 0 {
+			return usageError(errors.New("--timeout must be greater than 0, --timeout was " + dreddTimeout.String()))
+		}
+
+		if maxResponseTime < 0 {
+			return usageError(errors.New("--max-response-time must not be negative, --max-response-time was " + maxResponseTime.String()))
+		}
+
+		if maxResponseTime > 0 && len(pactFile) == 0 && !allConsumers {
+			return usageError(errors.New("--max-response-time can only be used with --pact-file or --all-consumers, dredd does not time the responses of the provider"))
+		}
+
+		if allConsumers && (len(pactFile) != 0 || compileOnly) {
+			return usageError(errors.New("--all-consumers cannot be used with --pact-file or --compile-only"))
+		}
+
+		if output != "text" && output != "json" {
+			return usageError(errors.New("--output must be either \"text\" or \"json\", --output was " + output))
+		}
 
-		err := validateTestFlags(brokerURL, name, version, providerURL)
+		if output == "json" && (len(pactFile) != 0 || allConsumers || compileOnly) {
+			return usageError(errors.New("--output json cannot be used with --pact-file, --all-consumers, or --compile-only"))
+		}
+
+		if concurrency < 1 {
+			return usageError(errors.New("--concurrency must be at least 1, --concurrency was " + strconv.Itoa(concurrency)))
+		}
+
+		if verifySignature {
+			if len(signingKey) == 0 {
+				return usageError(errors.New("No --signing-key was provided. This flag is required with --verify-signature."))
+			}
+
+			var err error
+			client.SigningKey, err = utils.LoadSigningKey(signingKey)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(pactFile) != 0 {
+			if len(providerURL) == 0 && len(providerDiscovery) == 0 && len(providerURLScan) == 0 {
+				return usageError(errors.New("No --provider-url was provided. This is a required flag."))
+			}
+
+			if len(onlyNewSince) != 0 && len(brokerURL) == 0 {
+				return usageError(errors.New("No --broker-url was provided. This flag is required with --only-new-since."))
+			}
+
+			var err error
+			if len(onlyNewSince) != 0 {
+				brokerURL, err = validateBrokerURL(brokerURL)
+				if err != nil {
+					return usageError(err)
+				}
+			}
+
+			providerURL, err = normalizeProviderURL(cmd, providerURL, strict)
+			if err != nil {
+				return usageError(err)
+			}
+
+			providerURLs, err := resolveProviderURLs(cmd, providerURL, providerDiscovery)
+			if err != nil {
+				return err
+			}
+
+			if schemeFallback {
+				providerURLs, err = selectSchemes(cmd, providerURLs)
+				if err != nil {
+					return err
+				}
+			}
+
+			summary, err := replayPactFile(cmd, pactFile, providerURLs)
+			if err != nil {
+				return err
+			}
+
+			err = writeTestSummary(summary)
+			if err != nil {
+				return err
+			}
+
+			if !summary.Passed {
+				return verificationFailed(cmd, "provider verification failed - "+strconv.Itoa(summary.Interactions.Failed)+" of the interactions in "+pactFile+" failed against the provider service")
+			}
+
+			return nil
+		}
+
+		var err error
+		providerURL, err = validateTestFlags(cmd, brokerURL, name, providerURL, providerDiscovery, compileOnly)
 		if err != nil {
-			return err
+			return usageError(err)
 		}
 
-		spec, err := client.GetLatestSpec(brokerURL, name)
+		brokerURL, err = validateBrokerURL(brokerURL)
+		if err != nil {
+			return usageError(err)
+		}
+
+		var providerURLs []string
+		if !compileOnly {
+			providerURLs, err = resolveProviderURLs(cmd, providerURL, providerDiscovery)
+			if err != nil {
+				return err
+			}
+
+			if schemeFallback {
+				providerURLs, err = selectSchemes(cmd, providerURLs)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if !compileOnly {
+			if branch == "auto" || (branch == "" && (version == "auto" || version == "")) {
+				branch, err = utils.SetBranchToCurrentGit(branch)
+				if err != nil {
+					return err
+				}
+			}
+
+			version, err = resolveVersion(cmd, version)
+			if err != nil {
+				return err
+			}
+
+			providerVersion, err = resolveProviderVersion(providerVersion)
+			if err != nil {
+				return err
+			}
+		}
+
+		if allConsumers {
+			summary, err := verifyAllConsumers(cmd, providerURLs)
+			if err != nil {
+				return err
+			}
+
+			err = writeTestSummary(summary)
+			if err != nil {
+				return err
+			}
+
+			if !summary.Passed {
+				return verificationFailed(cmd, "provider verification failed - the contracts of one or more consumers of "+name+" failed against the provider service")
+			}
+
+			return nil
+		}
+
+		spec, err := client.GetLatestSpec(cmd.Context(), brokerURL, name, !noCache)
 		if err != nil {
 			return err
 		}
 
+		// dredd only understands HTTP APIs, so an event-driven AsyncAPI spec cannot be verified
+		if utils.IsAsyncAPI(spec) {
+			return errors.New("verification not supported for asyncapi - the latest API spec of " + name + " is an AsyncAPI document, which dredd cannot verify")
+		}
+
+		if utils.IsGraphQL(spec) {
+			return errors.New("verification not supported for graphql - the latest API spec of " + name + " is a GraphQL schema, which dredd cannot verify")
+		}
+
+		if !skipValidation {
+			err = utils.ValidateOpenAPI(spec)
+			if err != nil {
+				return errors.New(err.Error() + "\n\nThe latest API spec of " + name + " was not verified. Pass --skip-validation to run dredd against it anyway.")
+			}
+		}
+
 		signetRoot, err := getNpmPkgRoot()
 		if err != nil {
 			return err
@@ -67,58 +378,677 @@ var testCmd = &cobra.Command{
 			return errors.New("Failed to write specs/spec file: " + err.Error())
 		}
 
-		testOutput, err := testProvider(dreddPath, specPath, providerURL)
+		if compileOnly {
+			spin := startSpinner(cmd.ErrOrStderr(), "Compiling the API spec of "+name+" with dredd")
+			err = compileSpec(cmd.Context(), dreddPath, specPath)
+			spin.stop()
+			return err
+		}
 
-		if err != nil {
-			fmt.Println(colorRed + "FAIL" + colorReset + ": Provider test failed - the provider service does not correctly implement the API spec")
+		passed := true
+		report := testReport{testSummary: testSummary{Provider: name, Version: version, ProviderVersion: providerVersion}, Results: []dreddResult{}}
+		summary := &report.testSummary
+		for _, instanceURL := range providerURLs {
+			spin := startSpinner(cmd.ErrOrStderr(), "Verifying the provider at "+instanceURL+" with dredd")
+			testOutput, err := testProvider(cmd.Context(), dreddPath, specPath, instanceURL)
+			spin.stop()
+			if err != nil && len(testOutput) == 0 {
+				// dredd timed out, so there are no results to report
+				writeTestResultsAfterError(cmd, report)
+				return err
+			}
+			summary.Interactions = addDreddCounts(summary.Interactions, testOutput)
+			report.Results = append(report.Results, parseDreddResults(instanceURL, testOutput)...)
+			if err == nil {
+				continue
+			}
+
+			passed = false
+			if output == "json" {
+				continue
+			}
+			if len(providerURLs) > 1 {
+				fmt.Println(colorRed + "FAIL" + colorReset + ": Provider test failed - the provider instance at " + instanceURL + " does not correctly implement the API spec")
+			} else {
+				fmt.Println(colorRed + "FAIL" + colorReset + ": Provider test failed - the provider service does not correctly implement the API spec")
+			}
 			fmt.Println()
 			fmt.Println("Breakdown of interactions:")
 			testOutput = utils.SliceOutNodeWarnings(testOutput)
 			fmt.Println(testOutput)
-		} else {
-			fmt.Println(colorGreen + "PASS" + colorReset + ": Provider test passed - the provider service correctly implements the API spec")
+		}
+
+		summary.Passed = passed
+		if passed && output == "json" {
+			err = utils.PublishProvider(cmd.Context(), specPath, brokerURL, name, version, branch, environment)
+			if err != nil {
+				writeTestResultsAfterError(cmd, report)
+				return err
+			}
+
+			summary.Published = true
+		} else if passed {
+			if len(providerURLs) > 1 {
+				fmt.Println(colorGreen + "PASS" + colorReset + ": Provider test passed - all " + strconv.Itoa(len(providerURLs)) + " provider instances correctly implement the API spec")
+			} else {
+				fmt.Println(colorGreen + "PASS" + colorReset + ": Provider test passed - the provider service correctly implements the API spec")
+			}
 			fmt.Println()
+			if len(providerVersion) != 0 {
+				cmd.PrintErrln("Provider build " + providerVersion + " was tested, the Signet broker records its verification as version " + version + " only")
+			}
 			fmt.Println("Informing the Signet broker of successful verification...")
 
-			err = utils.PublishProvider(specPath, brokerURL, name, version, branch)
+			err = utils.PublishProvider(cmd.Context(), specPath, brokerURL, name, version, branch, environment)
 			if err != nil {
+				writeTestResultsAfterError(cmd, report)
 				return err
 			}
 
+			summary.Published = true
 			fmt.Println("Verification results published to Signet broker")
 		}
 
+		err = writeTestSummary(*summary)
+		if err != nil {
+			return err
+		}
+
+		err = writeTestReport(cmd, report)
+		if err != nil {
+			return err
+		}
+
+		if !passed {
+			return verificationFailed(cmd, "provider verification failed - the provider service does not correctly implement the API spec of "+name)
+		}
+
 		return nil
 	},
 }
 
-func validateTestFlags(brokerURL, name, version, providerURL string) error {
+/*
+resolves --provider-version to the version of the provider build that is
+tested. It is left empty when it was not passed or is the same as --version,
+since the tested build is then the version that is published on success. It
+only labels the output and reports, the broker records --version alone.
+*/
+func resolveProviderVersion(providerVersion string) (string, error) {
+	if providerVersion == "auto" {
+		var err error
+		providerVersion, err = utils.SetVersionToGitSha(providerVersion)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if providerVersion == version {
+		return "", nil
+	}
+
+	return providerVersion, nil
+}
+
+/*
+reports that the provider failed verification, which exits with 1. The
+results have already been printed, so the usage is not.
+*/
+func verificationFailed(cmd *cobra.Command, message string) error {
+	cmd.Root().SilenceUsage = true
+	return failedError(message)
+}
+
+func validateTestFlags(cmd *cobra.Command, brokerURL, name, providerURL, providerDiscovery string, compileOnly bool) (string, error) {
 	if len(brokerURL) == 0 {
-		return errors.New("No --broker-url was provided. This is a required flag.")
+		return "", errors.New("No --broker-url was provided. This is a required flag.")
 	}
 
 	if len(name) == 0 {
-		return errors.New("No --name was provided. This is a required flag.")
+		return "", errors.New("No --name was provided. This is a required flag.")
 	}
 
-	if version == "" || version == "auto" {
-		var err error
-		version, err = utils.SetVersionToGitSha(version)
+	if len(providerURL) == 0 && len(providerDiscovery) == 0 && len(providerURLScan) == 0 && !compileOnly {
+		return "", errors.New("No --provider-url was provided. This is a required flag.")
+	}
+
+	return normalizeProviderURL(cmd, providerURL, strict)
+}
+
+/*
+defaults a --provider-url without a scheme (ex. localhost:8080) to http://,
+which dredd would otherwise fail on with an unclear error
+*/
+func normalizeProviderURL(cmd *cobra.Command, providerURL string, strict bool) (string, error) {
+	if len(providerURL) == 0 {
+		return providerURL, nil
+	}
+
+	if !strings.Contains(providerURL, "://") {
+		if strict {
+			return "", errors.New("--provider-url must include a scheme (ex. http://" + providerURL + "), --provider-url was " + providerURL)
+		}
+
+		cmd.PrintErrln("Warning - --provider-url has no scheme, defaulting to http://" + providerURL)
+		providerURL = "http://" + providerURL
+	}
+
+	parsed, err := url.Parse(providerURL)
+	if err != nil || len(parsed.Host) == 0 || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return "", errors.New("--provider-url must be an http or https URL, --provider-url was " + providerURL)
+	}
+
+	return providerURL, nil
+}
+
+/*
+returns the URL of every provider instance to verify: either --provider-url,
+the first responding port of --provider-url-scan, or one URL per SRV record
+target that resolves to an address
+*/
+func resolveProviderURLs(cmd *cobra.Command, providerURL, discovery string) ([]string, error) {
+	if len(providerURLScan) != 0 {
+		if len(providerURL) != 0 || len(discovery) != 0 {
+			return nil, errors.New("--provider-url-scan cannot be used with --provider-url or --provider-discovery")
+		}
+
+		scannedURL, err := scanProviderPorts(cmd, providerURLScan)
 		if err != nil {
-			return err
+			return nil, err
 		}
+
+		return []string{scannedURL}, nil
 	}
 
-	if len(providerURL) == 0 {
-		return errors.New("No --provider-url was provided. This is a required flag.")
+	if len(discovery) == 0 {
+		return []string{providerURL}, nil
+	}
+
+	srvName, found := strings.CutPrefix(discovery, "srv:")
+	if !found || len(srvName) == 0 {
+		return nil, errors.New("--provider-discovery must be in the form srv:<name>, --provider-discovery was " + discovery)
+	}
+
+	_, records, err := lookupSRV("", "", srvName)
+	if err != nil || len(records) == 0 {
+		return nil, errors.New("no SRV records were found for " + srvName)
+	}
+
+	urls := []string{}
+	for _, record := range records {
+		host := strings.TrimSuffix(record.Target, ".")
+		if _, err := lookupHost(host); err != nil {
+			cmd.Println("Warning - skipping provider instance " + host + ", it does not resolve to an address: " + err.Error())
+			continue
+		}
+
+		urls = append(urls, "http://"+net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+	}
+
+	if len(urls) == 0 {
+		return nil, errors.New("none of the SRV records for " + srvName + " resolve to an address")
+	}
+
+	return urls, nil
+}
+
+/*
+checks that each provider instance can be reached over the scheme of its URL,
+and otherwise switches to the other of http and https if that can be reached
+*/
+func selectSchemes(cmd *cobra.Command, providerURLs []string) ([]string, error) {
+	selected := []string{}
+	for _, instanceURL := range providerURLs {
+		parsed, err := url.Parse(instanceURL)
+		if err != nil {
+			return nil, err
+		}
+
+		primary := parsed.Scheme
+		if probeProvider(instanceURL) != nil {
+			if primary == "https" {
+				parsed.Scheme = "http"
+			} else {
+				parsed.Scheme = "https"
+			}
+
+			if err := probeProvider(parsed.String()); err != nil {
+				return nil, errors.New("the provider at " + parsed.Host + " could not be reached over " + primary + " or " + parsed.Scheme)
+			}
+		}
+
+		cmd.Println("Using " + parsed.Scheme + " for the provider at " + parsed.Host)
+		selected = append(selected, parsed.String())
+	}
+
+	return selected, nil
+}
+
+/*
+probes each port of a 'host:startPort-endPort' range in order, and returns
+the URL of the first one where a provider responds
+*/
+func scanProviderPorts(cmd *cobra.Command, scan string) (string, error) {
+	scanErr := errors.New("--provider-url-scan must be in the form host:startPort-endPort (ex. localhost:3000-3010), --provider-url-scan was " + scan)
+
+	host, portRange, err := net.SplitHostPort(scan)
+	if err != nil || len(host) == 0 {
+		return "", scanErr
+	}
+
+	start, end, found := strings.Cut(portRange, "-")
+	startPort, startErr := strconv.Atoi(start)
+	endPort, endErr := strconv.Atoi(end)
+	if !found || startErr != nil || endErr != nil || startPort < 1 || endPort > 65535 || startPort > endPort {
+		return "", scanErr
+	}
+
+	for port := startPort; port <= endPort; port++ {
+		scannedURL := "http://" + net.JoinHostPort(host, strconv.Itoa(port))
+		if probeProvider(scannedURL) == nil {
+			cmd.Println("Found a provider on port " + strconv.Itoa(port) + " of " + host + ", verifying " + scannedURL)
+			return scannedURL, nil
+		}
+	}
+
+	return "", errors.New("no provider responded on ports " + portRange + " of " + host)
+}
+
+// any response, whatever its status, shows that the scheme can be used
+func probeProvider(providerURL string) error {
+	probeClient := &http.Client{Timeout: 5 * time.Second}
+	resp, err := probeClient.Get(providerURL)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+/*
+runs dredd in dry-run mode, which parses the spec and compiles its
+transactions without sending any requests to the provider
+*/
+func compileSpec(ctx context.Context, dreddPath, specPath string) error {
+	stdoutStderr, err := combinedOutputWithTimeout(ctx, dreddTimeout, "npx", dreddPath, specPath, "http://127.0.0.1", "--dry-run", "--loglevel=error")
+	if errors.Is(err, context.DeadlineExceeded) {
+		return errors.New("dredd did not finish compiling the API spec for " + name + " within the --timeout of " + dreddTimeout.String())
+	} else if errors.Is(err, context.Canceled) {
+		return err
+	}
+	compileOutput := utils.SliceOutNodeWarnings(string(stdoutStderr))
+
+	if err != nil {
+		if len(stdoutStderr) == 0 {
+			return errors.New("failed to execute dredd")
+		}
+
+		fmt.Println(compileOutput)
+		return errors.New("the API spec for " + name + " could not be compiled by dredd and cannot be verified")
 	}
 
+	fmt.Println(colorGreen + "VALID" + colorReset + ": the API spec for " + name + " compiles under dredd and can be verified")
 	return nil
 }
 
-func testProvider(dreddPath, specPath, providerURL string) (string, error) {
-	testCmd := exec.Command("npx", dreddPath, specPath, providerURL, "--loglevel=error")
-	stdoutStderr, err := testCmd.CombinedOutput()
+func replayPactFile(cmd *cobra.Command, pactFile string, providerURLs []string) (testSummary, error) {
+	pact, err := utils.LoadPactFile(pactFile)
+	if err != nil {
+		return testSummary{}, err
+	}
+
+	summary := testSummary{Provider: utils.ProviderName(pact), Passed: true}
+	total := len(pact.Interactions.([]interface{}))
+	if len(onlyNewSince) != 0 {
+		pact, err = newInteractionsSince(cmd.Context(), pact, onlyNewSince)
+		if err != nil {
+			return testSummary{}, err
+		}
+
+		if len(pact.Interactions.([]interface{})) == 0 {
+			cmd.Println("Skipped - none of the " + strconv.Itoa(total) + " interactions in " + pactFile + " are new since consumer version " + onlyNewSince)
+			return summary, nil
+		}
+	}
+
+	for _, instanceURL := range providerURLs {
+		if len(providerURLs) > 1 {
+			cmd.Println("Replaying " + pactFile + " against the provider instance at " + instanceURL)
+		}
+
+		verification, err := signet.VerifyPact(cmd.Context(), pact, verifyOptions(instanceURL))
+		if err != nil {
+			return testSummary{}, err
+		}
+
+		results := verification.Interactions
+		if len(results) == 0 {
+			return testSummary{}, failedError("none of the " + strconv.Itoa(verification.Skipped) + " interactions in " + pactFile + " are sent over HTTP, so nothing was verified")
+		}
+
+		failed := printInteractionResults(cmd.OutOrStderr(), results)
+		if verification.Skipped > 0 {
+			cmd.Printf("Skipped - %d message interactions in %s are not sent over HTTP, so they were not verified\n", verification.Skipped, pactFile)
+		}
+
+		summary.Interactions.Total += len(results)
+		summary.Interactions.Passed += len(results) - failed
+		summary.Interactions.Failed += failed
+		summary.Interactions.Skipped += verification.Skipped
+		summary.Passed = summary.Passed && failed == 0
+		summary.Timings = append(summary.Timings, interactionTimings(pact.Consumer.Name, instanceURL, results)...)
+
+		cmd.Println()
+		if failed > 0 {
+			cmd.Printf(colorRed+"FAIL"+colorReset+": %d of %d interactions in %s failed against the provider service\n", failed, len(results), pactFile)
+		} else {
+			cmd.Printf(colorGreen+"PASS"+colorReset+": all %d interactions in %s passed against the provider service\n", len(results), pactFile)
+		}
+	}
+	if len(onlyNewSince) != 0 {
+		verified := len(pact.Interactions.([]interface{}))
+		cmd.Printf("Verified %d of %d interactions - the other %d are unchanged since consumer version %s\n", verified, total, total-verified, onlyNewSince)
+	}
+	cmd.Println("Results of a local pact replay are not published to the Signet broker")
+
+	return summary, nil
+}
+
+// prints whether each replayed interaction passed, and returns how many failed
+func printInteractionResults(out io.Writer, results []utils.InteractionResult) int {
+	failed := 0
+	for _, result := range results {
+		if result.Passed {
+			fmt.Fprintln(out, colorGreen+"PASS"+colorReset+": "+result.Description)
+		} else {
+			failed++
+			fmt.Fprintln(out, colorRed+"FAIL"+colorReset+": "+result.Description)
+			for _, mismatch := range result.Mismatches {
+				fmt.Fprintln(out, "    - "+mismatch)
+			}
+		}
+
+		for _, warning := range result.Warnings {
+			fmt.Fprintln(out, "    Warning - "+warning)
+		}
+	}
+
+	return failed
+}
+
+// the replay of one consumer's contract, which is printed once every replay has finished
+type consumerVerification struct {
+	output *bytes.Buffer
+	counts interactionCounts
+	// none of the interactions of the contract could be replayed
+	unverified bool
+	timings    []interactionTiming
+	err        error
+}
+
+/*
+replays the latest contract of every consumer of the provider against each
+provider instance, with up to --concurrency contracts replayed at once. The
+results are printed in the order the broker listed the contracts.
+*/
+func verifyAllConsumers(cmd *cobra.Command, providerURLs []string) (testSummary, error) {
+	pacts, err := signet.ConsumerContracts(cmd.Context(), brokerURL, name)
+	if err != nil {
+		return testSummary{}, err
+	}
+
+	summary := testSummary{Provider: name, Version: version, ProviderVersion: providerVersion, Passed: true}
+	if len(pacts) == 0 {
+		cmd.Println("Skipped - no consumer contracts have been published for " + name)
+		return summary, nil
+	}
+
+	verifications := make([]consumerVerification, len(pacts))
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	for i, pact := range pacts {
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func(i int, pact utils.Pact) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			verifications[i] = verifyConsumerContract(cmd.Context(), pact, providerURLs)
+		}(i, pact)
+	}
+	wg.Wait()
+
+	failedConsumers := 0
+	for _, verification := range verifications {
+		cmd.Print(verification.output.String())
+		if verification.err != nil {
+			return testSummary{}, verification.err
+		}
+
+		summary.Interactions.Total += verification.counts.Total
+		summary.Interactions.Passed += verification.counts.Passed
+		summary.Interactions.Failed += verification.counts.Failed
+		summary.Interactions.Skipped += verification.counts.Skipped
+		summary.Timings = append(summary.Timings, verification.timings...)
+		if verification.counts.Failed > 0 || verification.unverified {
+			failedConsumers++
+		}
+	}
+
+	summary.Passed = failedConsumers == 0
+	if summary.Passed {
+		cmd.Printf(colorGreen+"PASS"+colorReset+": the contracts of all %d consumers passed against the provider service\n", len(pacts))
+	} else {
+		cmd.Printf(colorRed+"FAIL"+colorReset+": the contracts of %d of %d consumers failed against the provider service\n", failedConsumers, len(pacts))
+	}
+	cmd.Println("Results of consumer contract replays are not published to the Signet broker")
+
+	return summary, nil
+}
+
+func verifyConsumerContract(ctx context.Context, pact utils.Pact, providerURLs []string) consumerVerification {
+	verification := consumerVerification{output: new(bytes.Buffer)}
+
+	for _, instanceURL := range providerURLs {
+		fmt.Fprintln(verification.output, "Replaying the contract of consumer "+pact.Consumer.Name+" against the provider at "+instanceURL)
+
+		replay, err := signet.VerifyPact(ctx, pact, verifyOptions(instanceURL))
+		if err != nil {
+			verification.err = errors.New("could not replay the contract of consumer " + pact.Consumer.Name + ": " + err.Error())
+			return verification
+		}
+
+		failed := printInteractionResults(verification.output, replay.Interactions)
+		if len(replay.Interactions) == 0 {
+			// fails the same way as a --pact-file with nothing to verify
+			fmt.Fprintf(verification.output, colorRed+"FAIL"+colorReset+": none of the %d interactions in the contract of consumer %s are sent over HTTP, so nothing was verified\n", replay.Skipped, pact.Consumer.Name)
+			verification.unverified = true
+		} else if replay.Skipped > 0 {
+			fmt.Fprintf(verification.output, "Skipped - %d message interactions in the contract of consumer %s are not sent over HTTP, so they were not verified\n", replay.Skipped, pact.Consumer.Name)
+		}
+
+		verification.counts.Total += len(replay.Interactions)
+		verification.counts.Passed += len(replay.Interactions) - failed
+		verification.counts.Failed += failed
+		verification.counts.Skipped += replay.Skipped
+		verification.timings = append(verification.timings, interactionTimings(pact.Consumer.Name, instanceURL, replay.Interactions)...)
+		fmt.Fprintln(verification.output)
+	}
+
+	return verification
+}
+
+// the options that pacts are replayed against a provider instance with
+func verifyOptions(instanceURL string) signet.VerifyOptions {
+	return signet.VerifyOptions{
+		ProviderURL:         instanceURL,
+		TeardownURL:         teardownURL,
+		FailOnTeardownError: failOnTeardownError,
+		MaxResponseTime:     maxResponseTime,
+		FailOnSlowResponse:  strict,
+	}
+}
+
+// the time the provider instance took to respond to each replayed interaction of a consumer's contract
+func interactionTimings(consumer, instanceURL string, results []utils.InteractionResult) []interactionTiming {
+	timings := []interactionTiming{}
+	for _, result := range results {
+		timings = append(timings, interactionTiming{
+			Consumer:    consumer,
+			Interaction: result.Description,
+			ProviderURL: instanceURL,
+			DurationMs:  result.Duration.Milliseconds(),
+		})
+	}
+
+	return timings
+}
+
+// adds the interaction counts from the "complete:" line of dredd's output
+func addDreddCounts(counts interactionCounts, testOutput string) interactionCounts {
+	match := dreddComplete.FindStringSubmatch(testOutput)
+	if match == nil {
+		return counts
+	}
+
+	passing, _ := strconv.Atoi(match[1])
+	failing, _ := strconv.Atoi(match[2])
+	errored, _ := strconv.Atoi(match[3])
+	total, _ := strconv.Atoi(match[5])
+
+	counts.Total += total
+	counts.Passed += passing
+	counts.Failed += failing + errored
+	return counts
+}
+
+/*
+parses the transactions that dredd reported, along with the reason each
+failed transaction failed. dredd repeats the failed transactions after
+"Displaying failed tests...", which are not parsed again.
+*/
+func parseDreddResults(providerURL, testOutput string) []dreddResult {
+	results := []dreddResult{}
+	failed := -1
+
+	for _, line := range strings.Split(utils.SliceOutNodeWarnings(testOutput), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.Contains(line, "Displaying failed tests") || strings.HasPrefix(line, "complete: ") {
+			break
+		}
+
+		match := dreddTransaction.FindStringSubmatch(line)
+		if match != nil {
+			status, _ := strconv.Atoi(match[3])
+			results = append(results, dreddResult{
+				ProviderURL: providerURL,
+				Method:      match[2],
+				Path:        match[4],
+				Status:      status,
+				Result:      match[1],
+			})
+
+			failed = -1
+			if match[1] == "fail" || match[1] == "error" {
+				failed = len(results) - 1
+			}
+			continue
+		}
+
+		if failed < 0 {
+			continue
+		}
+
+		// the reason is on the lines that follow the failed transaction
+		reason := strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(line, "fail: "), "error: "))
+		if strings.HasPrefix(line, "info: ") || strings.HasPrefix(line, "warn: ") || strings.HasPrefix(line, "pass: ") || strings.HasPrefix(line, "skip: ") {
+			failed = -1
+		} else if len(reason) != 0 {
+			results[failed].Reason = strings.TrimSpace(results[failed].Reason + "\n" + reason)
+		}
+	}
+
+	return results
+}
+
+// prints the --output json report to stdout
+func writeTestReport(cmd *cobra.Command, report testReport) error {
+	if output != "json" {
+		return nil
+	}
+
+	reportBytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(cmd.OutOrStdout(), string(reportBytes))
+	return nil
+}
+
+/*
+writes --summary-json and the --output json report of a test that stopped
+with an error. test exits with that error, so a failure to write them is
+printed as a warning instead.
+*/
+func writeTestResultsAfterError(cmd *cobra.Command, report testReport) {
+	err := writeTestSummary(report.testSummary)
+	if err != nil {
+		cmd.Println("Warning - " + err.Error())
+	}
+
+	err = writeTestReport(cmd, report)
+	if err != nil {
+		cmd.Println("Warning - failed to write the --output json report: " + err.Error())
+	}
+}
+
+func writeTestSummary(summary testSummary) error {
+	if len(summaryJSON) == 0 {
+		return nil
+	}
+
+	summaryBytes, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(summaryJSON, append(summaryBytes, '\n'), rwPermissions)
+	if err != nil {
+		return errors.New("failed to write --summary-json file: " + err.Error())
+	}
+
+	return nil
+}
+
+/*
+fetches the pact that the same consumer published for the same provider at
+the prior version, and leaves out the interactions that are unchanged since it
+*/
+func newInteractionsSince(ctx context.Context, pact utils.Pact, priorVersion string) (utils.Pact, error) {
+	priorContract, err := client.GetContract(ctx, brokerURL, pact.Consumer.Name, utils.ProviderName(pact), priorVersion)
+	if err != nil {
+		return utils.Pact{}, err
+	}
+
+	var prior utils.Pact
+	err = json.Unmarshal(priorContract, &prior)
+	if err != nil {
+		return utils.Pact{}, errors.New("could not parse the pact for consumer version " + priorVersion + ": " + err.Error())
+	}
+
+	return utils.NewInteractions(pact, prior)
+}
+
+func testProvider(ctx context.Context, dreddPath, specPath, providerURL string) (string, error) {
+	stdoutStderr, err := combinedOutputWithTimeout(ctx, dreddTimeout, "npx", dreddPath, specPath, providerURL, "--loglevel=error")
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "", errors.New("provider verification timed out - dredd did not finish verifying the provider at " + providerURL + " within the --timeout of " + dreddTimeout.String())
+	} else if errors.Is(err, context.Canceled) {
+		// interrupted, so the partial output of dredd is not reported
+		return "", err
+	}
 	testOutput := string(stdoutStderr)
 
 	if err != nil && len(testOutput) == 0 {
@@ -128,15 +1058,81 @@ func testProvider(dreddPath, specPath, providerURL string) (string, error) {
 	return testOutput, err
 }
 
+/*
+runs a command and returns its combined output, stopping the command and
+every process it started once the timeout passes or ctx is cancelled (ex. by
+Ctrl+C). The error is then context.DeadlineExceeded or context.Canceled.
+*/
+func combinedOutputWithTimeout(parent context.Context, timeout time.Duration, command string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	defer cancel()
+
+	timedCmd := exec.CommandContext(ctx, command, args...)
+	setProcessGroup(timedCmd)
+	timedCmd.Cancel = func() error {
+		return stopProcessGroup(timedCmd.Process)
+	}
+	// children that outlive the command would otherwise keep the output open
+	timedCmd.WaitDelay = 5 * time.Second
+
+	output, err := timedCmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return output, ctx.Err()
+	}
+
+	return output, err
+}
+
 func init() {
 	RootCmd.AddCommand(testCmd)
 
 	testCmd.Flags().StringVarP(&name, "name", "n", "", "The name of the service which was deployed")
 	testCmd.Flags().StringVarP(&version, "version", "v", "auto", "The version of the service which was deployed")
 	testCmd.Flags().StringVarP(&branch, "branch", "b", "", "Version control branch (optional)")
+	testCmd.Flags().StringVar(&providerVersion, "provider-version", "", "The version of the provider build that is tested, when it is not --version. It labels the output and reports only, and is not sent to the broker")
+	testCmd.Flags().StringVarP(&environment, "environment", "e", "", "The environment the provider was verified in, which is published with the verification results")
 	testCmd.Flags().StringVarP(&providerURL, "provider-url", "s", "", "The URL where the provider service is running")
+	testCmd.Flags().StringVar(&providerURLScan, "provider-url-scan", "", "'host:startPort-endPort' range that is probed for a responding provider, the first port that responds is verified")
+	testCmd.Flags().StringVar(&providerDiscovery, "provider-discovery", "", "'srv:<name>' to verify every provider instance resolved from DNS SRV records, instead of --provider-url")
+	testCmd.Flags().BoolVar(&verifySignature, "verify-signature", false, "Fail unless the fetched API spec, or pact for --only-new-since, is signed by --signing-key")
+	testCmd.Flags().StringVar(&signingKey, "signing-key", "", "PEM file with the broker's Ed25519 public key")
+	testCmd.Flags().BoolVar(&noCache, "no-cache", false, "Always download the latest API spec instead of reusing a cached copy")
+	testCmd.Flags().StringVar(&versionOutput, "version-output", "", "File that the resolved version is written to, or '-' for stdout")
+	testCmd.Flags().StringVar(&teardownURL, "provider-states-teardown-url", "", "URL that a teardown request is POSTed to after each replayed interaction with a provider state")
+	testCmd.Flags().BoolVar(&failOnTeardownError, "fail-on-teardown-error", false, "Fail an interaction when its provider state teardown fails, instead of warning")
+	testCmd.Flags().BoolVar(&schemeFallback, "scheme-fallback", false, "When the provider cannot be reached over the scheme of --provider-url, retry over the other of http and https")
+	testCmd.Flags().BoolVar(&strict, "strict", false, "Fail when --provider-url has no scheme, instead of defaulting to http://, and fail interactions slower than --max-response-time instead of warning")
+	testCmd.Flags().DurationVar(&maxResponseTime, "max-response-time", 0, "Warn about each replayed interaction whose response is slower than this, with --pact-file or --all-consumers")
+	testCmd.Flags().StringVarP(&output, "output", "o", "text", "Output format, either \"text\" or \"json\"")
+	testCmd.Flags().StringVar(&summaryJSON, "summary-json", "", "File that a JSON summary of the result is written to, whether the test passed or failed")
+	testCmd.Flags().DurationVar(&dreddTimeout, "timeout", defaultDreddTimeout, "The longest time that dredd is given to verify each provider instance")
+	testCmd.Flags().BoolVar(&compileOnly, "compile-only", false, "Only check that the latest API spec compiles under dredd, without contacting the provider")
+	testCmd.Flags().BoolVar(&skipValidation, "skip-validation", false, "Run dredd without first checking that the latest API spec is a valid OpenAPI document")
+	testCmd.Flags().StringVar(&onlyNewSince, "only-new-since", "", "Consumer version whose pact is fetched from the broker, only interactions added or changed since it are replayed")
+	testCmd.Flags().BoolVar(&allConsumers, "all-consumers", false, "Replay the latest contract of every consumer of the provider from the broker, instead of verifying the API spec")
+	testCmd.Flags().IntVar(&concurrency, "concurrency", 1, "How many consumer contracts are replayed in parallel with --all-consumers")
+	testCmd.Flags().StringVar(&pactFile, "pact-file", "", "Replay a local consumer pact against the provider instead of fetching the spec from the broker")
 	testCmd.Flags().Lookup("branch").NoOptDefVal = "auto"
+	testCmd.Flags().Lookup("provider-version").NoOptDefVal = "auto"
 
 	viper.BindPFlag("test.name", testCmd.Flags().Lookup("name"))
+	viper.BindPFlag("test.summary-json", testCmd.Flags().Lookup("summary-json"))
+	viper.BindPFlag("test.output", testCmd.Flags().Lookup("output"))
+	viper.BindPFlag("test.timeout", testCmd.Flags().Lookup("timeout"))
+	viper.BindPFlag("test.skip-validation", testCmd.Flags().Lookup("skip-validation"))
+	viper.BindPFlag("test.environment", testCmd.Flags().Lookup("environment"))
 	viper.BindPFlag("test.provider-url", testCmd.Flags().Lookup("provider-url"))
+	viper.BindPFlag("test.version-output", testCmd.Flags().Lookup("version-output"))
+	viper.BindPFlag("test.provider-version", testCmd.Flags().Lookup("provider-version"))
+	viper.BindPFlag("test.provider-states-teardown-url", testCmd.Flags().Lookup("provider-states-teardown-url"))
+	viper.BindPFlag("test.fail-on-teardown-error", testCmd.Flags().Lookup("fail-on-teardown-error"))
+	viper.BindPFlag("test.max-response-time", testCmd.Flags().Lookup("max-response-time"))
+	viper.BindPFlag("test.pact-file", testCmd.Flags().Lookup("pact-file"))
+	viper.BindPFlag("test.all-consumers", testCmd.Flags().Lookup("all-consumers"))
+	viper.BindPFlag("test.concurrency", testCmd.Flags().Lookup("concurrency"))
+	viper.BindPFlag("test.only-new-since", testCmd.Flags().Lookup("only-new-since"))
+	viper.BindPFlag("test.verify-signature", testCmd.Flags().Lookup("verify-signature"))
+	viper.BindPFlag("test.signing-key", testCmd.Flags().Lookup("signing-key"))
+	viper.BindPFlag("test.provider-url-scan", testCmd.Flags().Lookup("provider-url-scan"))
+	viper.BindPFlag("test.provider-discovery", testCmd.Flags().Lookup("provider-discovery"))
 }
@@ -2,10 +2,28 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"io/fs"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/spf13/viper"
+
+	signet "github.com/signet-framework/signet-cli/signet"
 	utils "github.com/signet-framework/signet-cli/utils"
 )
 
@@ -125,7 +143,7 @@ func TestPublishProviderUtilWithoutVersion(t *testing.T) {
 	version := "auto"
 	branch := "developement"
 
-	err := utils.PublishProvider(path, brokerURL, name, version, branch)
+	err := utils.PublishProvider(context.Background(), path, brokerURL, name, version, branch, "")
 	if err != nil {
 		t.Error()
 	}
@@ -161,3 +179,1439 @@ func TestPublishProviderUtilWithoutVersion(t *testing.T) {
 	})
 	teardown()
 }
+
+func TestSignetTestPactFileNoProviderURL(t *testing.T) {
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: No --provider-url was provided."
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestSignetTestPactFileNotAPact(t *testing.T) {
+	flags := []string{
+		"--pact-file", "../data_test/api-spec.yaml",
+		"--provider-url", "http://localhost:3002",
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: could not parse ../data_test/api-spec.yaml as a pact"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestSignetTestPactFileReplay(t *testing.T) {
+	var req http.Request
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = *r
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"userId": 2, "username": "jimmy", "touchedBy": ["user_service"], "extra": true}`))
+	}))
+	defer provider.Close()
+
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url", provider.URL,
+	}
+	actual := callSignetTest(flags)
+
+	t.Run("replays the interaction request", func(t *testing.T) {
+		if req.Method != "GET" || req.URL.Path != "/users/1" || req.Header.Get("Accept") != "application/json" {
+			t.Error()
+		}
+	})
+
+	t.Run("prints a PASS for the interaction", func(t *testing.T) {
+		expected := colorGreen + "PASS" + colorReset + ": a request for the user with a userId of 1"
+		actual.startsWith(expected, t)
+	})
+
+	t.Run("does not publish the results", func(t *testing.T) {
+		if !strings.Contains(actual.actual, "not published") {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestSignetTestPactFileReplayFailure(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "not found"}`))
+	}))
+	defer provider.Close()
+
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url", provider.URL,
+	}
+	actual := callSignetTest(flags)
+
+	t.Run("prints a FAIL for the interaction", func(t *testing.T) {
+		expected := colorRed + "FAIL" + colorReset + ": a request for the user with a userId of 1"
+		actual.startsWith(expected, t)
+	})
+
+	t.Run("reports the status mismatch", func(t *testing.T) {
+		if !strings.Contains(actual.actual, "expected status 200 but got 404") {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func mockBrokerWithConsumerContracts(t *testing.T) (*httptest.Server, *http.Request) {
+	contractBytes, err := os.ReadFile("../data_test/cons-prov.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a second consumer expects an endpoint that the provider does not have
+	otherContract := strings.ReplaceAll(strings.ReplaceAll(string(contractBytes), "service_1", "service_2"), "/users/1", "/accounts/1")
+
+	var req http.Request
+	broker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = *r
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + string(contractBytes) + "," + otherContract + "]"))
+	}))
+
+	return broker, &req
+}
+
+func TestSignetTestAllConsumers(t *testing.T) {
+	broker, req := mockBrokerWithConsumerContracts(t)
+	defer broker.Close()
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"userId": 2, "username": "jimmy", "touchedBy": ["user_service"]}`))
+	}))
+	defer provider.Close()
+
+	summaryPath := t.TempDir() + "/summary.json"
+	flags := []string{
+		"--broker-url", broker.URL,
+		"--name", "user_service",
+		"--provider-url", provider.URL,
+		"--version=version1",
+		"--branch=main",
+		"--all-consumers",
+		"--concurrency", "2",
+		"--summary-json", summaryPath,
+	}
+	actual := callSignetTest(flags)
+
+	t.Run("fetches the latest contracts of the provider's consumers", func(t *testing.T) {
+		if req.URL.Path != "/api/contracts/latest" || req.URL.Query().Get("provider") != "user_service" {
+			t.Error(req.URL.String())
+		}
+	})
+
+	t.Run("prints the results of each consumer in order", func(t *testing.T) {
+		first := strings.Index(actual.actual, "Replaying the contract of consumer service_1")
+		second := strings.Index(actual.actual, "Replaying the contract of consumer service_2")
+		if first == -1 || second < first {
+			t.Error(actual.actual)
+		}
+	})
+
+	t.Run("fails when any consumer fails", func(t *testing.T) {
+		expected := colorRed + "FAIL" + colorReset + ": the contracts of 1 of 2 consumers failed against the provider service"
+		if !strings.Contains(actual.actual, expected) {
+			t.Error(actual.actual)
+		}
+	})
+
+	summaryBytes, _ := os.ReadFile(summaryPath)
+	var summary testSummary
+	json.Unmarshal(summaryBytes, &summary)
+
+	t.Run("aggregates the interaction counts", func(t *testing.T) {
+		if summary.Passed || summary.Interactions != (interactionCounts{Total: 2, Passed: 1, Failed: 1}) {
+			t.Error(string(summaryBytes))
+		}
+	})
+	teardown()
+}
+
+func TestSignetTestAllConsumersOnlyMessageInteractions(t *testing.T) {
+	contractBytes, err := os.ReadFile("../data_test/cons-prov.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messageContract := `{"consumer": {"name": "service_2"}, "provider": {"name": "user_service"}, "interactions": [` +
+		`{"type": "Asynchronous/Messages", "description": "a user created event", "contents": {"content": {"userId": 1}}}` +
+		`], "metadata": {"pactSpecification": {"version": "4.0"}}}`
+	broker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + string(contractBytes) + "," + messageContract + "]"))
+	}))
+	defer broker.Close()
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"userId": 2, "username": "jimmy", "touchedBy": ["user_service"]}`))
+	}))
+	defer provider.Close()
+
+	flags := []string{
+		"--broker-url", broker.URL,
+		"--name", "user_service",
+		"--provider-url", provider.URL,
+		"--version=version1",
+		"--branch=main",
+		"--all-consumers",
+	}
+	actual := callSignetTest(flags)
+
+	t.Run("fails the contract that has nothing to verify", func(t *testing.T) {
+		expected := colorRed + "FAIL" + colorReset + ": none of the 1 interactions in the contract of consumer service_2 are sent over HTTP, so nothing was verified"
+		if !strings.Contains(actual.actual, expected) {
+			t.Error(actual.actual)
+		}
+	})
+
+	t.Run("fails the test", func(t *testing.T) {
+		expected := colorRed + "FAIL" + colorReset + ": the contracts of 1 of 2 consumers failed against the provider service"
+		if !strings.Contains(actual.actual, expected) {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+
+	t.Run("exits with 1", func(t *testing.T) {
+		exitCode := exitCodeOf(append([]string{"test"}, flags...))
+		if exitCode != exitFailed {
+			t.Error(exitCode)
+		}
+	})
+	teardown()
+}
+
+func TestLibraryVerifyProvider(t *testing.T) {
+	broker, _ := mockBrokerWithConsumerContracts(t)
+	defer broker.Close()
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"userId": 2, "username": "jimmy", "touchedBy": ["user_service"]}`))
+	}))
+	defer provider.Close()
+
+	verification, err := signet.VerifyProvider(context.Background(), broker.URL, "user_service", signet.VerifyOptions{ProviderURL: provider.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("returns the result of each consumer in order", func(t *testing.T) {
+		if len(verification.Consumers) != 2 || verification.Consumers[0].Consumer != "service_1" || verification.Consumers[1].Consumer != "service_2" {
+			t.Error(verification)
+		}
+	})
+
+	t.Run("fails when any consumer fails", func(t *testing.T) {
+		if verification.Passed() || !verification.Consumers[0].Passed() || verification.Consumers[1].Failed() != 1 {
+			t.Error(verification)
+		}
+	})
+
+	t.Run("requires a provider URL", func(t *testing.T) {
+		_, err := signet.VerifyProvider(context.Background(), broker.URL, "user_service", signet.VerifyOptions{})
+		if err == nil || err.Error() != "no ProviderURL was provided, it is required" {
+			t.Error(err)
+		}
+	})
+}
+
+func TestSignetTestProviderVersion(t *testing.T) {
+	broker, _ := mockBrokerWithConsumerContracts(t)
+	defer broker.Close()
+
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer provider.Close()
+
+	summaryPath := t.TempDir() + "/summary.json"
+	flags := []string{
+		"--broker-url", broker.URL,
+		"--name", "user_service",
+		"--provider-url", provider.URL,
+		"--version=1.2.0",
+		"--branch=main",
+		"--all-consumers",
+		"--summary-json", summaryPath,
+	}
+
+	t.Run("records the tested build separately from --version", func(t *testing.T) {
+		callSignetTest(append(flags, "--provider-version=build-abc123"))
+
+		summaryBytes, _ := os.ReadFile(summaryPath)
+		var summary testSummary
+		json.Unmarshal(summaryBytes, &summary)
+		if summary.Version != "1.2.0" || summary.ProviderVersion != "build-abc123" {
+			t.Error(string(summaryBytes))
+		}
+		teardown()
+	})
+
+	t.Run("leaves the tested build out when it is --version", func(t *testing.T) {
+		callSignetTest(append(flags, "--provider-version=1.2.0"))
+
+		summaryBytes, _ := os.ReadFile(summaryPath)
+		if strings.Contains(string(summaryBytes), "providerVersion") {
+			t.Error(string(summaryBytes))
+		}
+		teardown()
+	})
+}
+
+func TestSignetTestAllConsumersWithPactFile(t *testing.T) {
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url", "http://localhost:3002",
+		"--all-consumers",
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: --all-consumers cannot be used with --pact-file or --compile-only"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestSignetTestInvalidOutput(t *testing.T) {
+	flags := []string{
+		"--broker-url", "http://localhost:3000",
+		"--name", "user_service",
+		"--provider-url", "http://localhost:3002",
+		"--output", "xml",
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: --output must be either \"text\" or \"json\", --output was xml"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestSignetTestMaxResponseTimeWithoutReplay(t *testing.T) {
+	flags := []string{
+		"--broker-url", "http://localhost:3000",
+		"--name", "user_service",
+		"--provider-url", "http://localhost:3002",
+		"--max-response-time", "500ms",
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: --max-response-time can only be used with --pact-file or --all-consumers"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestSignetTestOutputJSONWithPactFile(t *testing.T) {
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url", "http://localhost:3002",
+		"--output", "json",
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: --output json cannot be used with --pact-file, --all-consumers, or --compile-only"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestSignetTestOutputJSONKeepsStdoutJSON(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake npx is a shell script")
+	}
+
+	// stands in for dredd, reporting one failed transaction
+	binDir := t.TempDir()
+	fakeNpx := "#!/bin/sh\n" +
+		"echo 'fail: GET (200) /users/1 duration: 5ms'\n" +
+		"echo \"fail: statusCode: Status code is '404' instead of '200'\"\n" +
+		"echo 'complete: 0 passing, 1 failing, 0 errors, 0 skipped, 1 total'\n" +
+		"exit 1\n"
+	err := os.WriteFile(binDir+"/npx", []byte(fakeNpx), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	signetRoot := t.TempDir()
+	err = os.Mkdir(signetRoot+"/specs", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	realGetNpmPkgRoot := getNpmPkgRoot
+	defer func() { getNpmPkgRoot = realGetNpmPkgRoot }()
+	getNpmPkgRoot = func() (string, error) { return signetRoot, nil }
+
+	server, _ := mockServerForGetSpecsReq200OK(t)
+	defer server.Close()
+
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+	RootCmd.SetOut(stdout)
+	RootCmd.SetErr(stderr)
+	RootCmd.SetArgs([]string{
+		"test",
+		"--version=version1",
+		"--name", "user_service",
+		"--broker-url", server.URL,
+		"--provider-url", "localhost:3002",
+		"--output", "json",
+	})
+	_, err = RootCmd.ExecuteC()
+	if err == nil {
+		t.Fatal("expected the test command to fail")
+	}
+	printError(stdout, err)
+
+	t.Run("prints only the JSON report to stdout", func(t *testing.T) {
+		var report testReport
+		err := json.Unmarshal(stdout.Bytes(), &report)
+		if err != nil {
+			t.Fatal(err, stdout.String())
+		}
+
+		if report.Passed || len(report.Results) != 1 || report.Results[0].Result != "fail" {
+			t.Error(report)
+		}
+	})
+
+	t.Run("prints the warnings and the error to stderr", func(t *testing.T) {
+		if !strings.Contains(stderr.String(), "Warning - --provider-url has no scheme") || !strings.Contains(stderr.String(), "Error: ") {
+			t.Error(stderr.String())
+		}
+	})
+	teardown()
+}
+
+func TestSignetTestExitCodes(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error": "not found"}`))
+	}))
+	defer provider.Close()
+
+	t.Run("exits with 1 when verification fails", func(t *testing.T) {
+		exitCode := exitCodeOf([]string{"test", "--pact-file", "../data_test/cons-prov.json", "--provider-url", provider.URL})
+		if exitCode != exitFailed {
+			t.Error(exitCode)
+		}
+		teardown()
+	})
+
+	t.Run("exits with 2 when a flag is missing", func(t *testing.T) {
+		exitCode := exitCodeOf([]string{"test", "--pact-file", "../data_test/cons-prov.json"})
+		if exitCode != exitUsage {
+			t.Error(exitCode)
+		}
+		teardown()
+	})
+
+	t.Run("exits with 2 when a flag is invalid", func(t *testing.T) {
+		exitCode := exitCodeOf([]string{"test", "--pact-file", "../data_test/cons-prov.json", "--provider-url", provider.URL, "--timeout", "0s"})
+		if exitCode != exitUsage {
+			t.Error(exitCode)
+		}
+		teardown()
+	})
+}
+
+func TestSignetTestCompileOnlyDoesNotRequireProviderURL(t *testing.T) {
+	realGetNpmPkgRoot := getNpmPkgRoot
+	realosWriteFile := osWriteFile
+	defer func() {
+		getNpmPkgRoot = realGetNpmPkgRoot
+		osWriteFile = realosWriteFile
+	}()
+
+	getNpmPkgRoot = func() (string, error) { return "/testDir", nil }
+	osWriteFile = func(name string, data []byte, perm fs.FileMode) error {
+		return errors.New("stop this test here")
+	}
+
+	server, _ := mockServerForGetSpecsReq200OK(t)
+	defer server.Close()
+
+	flags := []string{
+		"--version=version1",
+		"--name", "user_service",
+		"--broker-url", server.URL,
+		"--compile-only",
+	}
+	actual := callSignetTest(flags)
+
+	t.Run("fetches the spec without a --provider-url", func(t *testing.T) {
+		expected := "Error: Failed to write specs/spec file: stop this test here"
+		actual.startsWith(expected, t)
+	})
+	teardown()
+}
+
+func mockProviderWithStateHandler(t *testing.T, teardownStatus int) (*httptest.Server, *[]map[string]interface{}) {
+	teardowns := []map[string]interface{}{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_pact/state" {
+			teardown := map[string]interface{}{}
+			err := json.NewDecoder(r.Body).Decode(&teardown)
+			if err != nil {
+				t.Error("Failed to parse teardown request body")
+			}
+			teardowns = append(teardowns, teardown)
+			w.WriteHeader(teardownStatus)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"userId": 1, "username": "mimmy", "touchedBy": ["user_service"]}`))
+	}))
+
+	return server, &teardowns
+}
+
+func TestSignetTestPactFileProviderStateTeardown(t *testing.T) {
+	provider, teardowns := mockProviderWithStateHandler(t, http.StatusOK)
+	defer provider.Close()
+
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url", provider.URL,
+		"--provider-states-teardown-url", provider.URL + "/_pact/state",
+	}
+	actual := callSignetTest(flags)
+
+	t.Run("POSTs a teardown for the interaction's provider state", func(t *testing.T) {
+		if len(*teardowns) != 1 {
+			t.Fatal(len(*teardowns))
+		}
+
+		teardown := (*teardowns)[0]
+		if teardown["action"] != "teardown" || teardown["state"] != "a user with userId = 1 exists" || teardown["consumer"] != "service_1" {
+			t.Error(teardown)
+		}
+	})
+
+	t.Run("passes the interaction", func(t *testing.T) {
+		actual.startsWith(colorGreen+"PASS", t)
+	})
+	teardown()
+}
+
+func TestSignetTestPactFileFailOnTeardownError(t *testing.T) {
+	provider, _ := mockProviderWithStateHandler(t, http.StatusInternalServerError)
+	defer provider.Close()
+
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url", provider.URL,
+		"--provider-states-teardown-url", provider.URL + "/_pact/state",
+		"--fail-on-teardown-error",
+	}
+	actual := callSignetTest(flags)
+
+	t.Run("fails the interaction", func(t *testing.T) {
+		actual.startsWith(colorRed+"FAIL", t)
+	})
+
+	t.Run("reports the teardown failure", func(t *testing.T) {
+		if !strings.Contains(actual.actual, "teardown of provider state \"a user with userId = 1 exists\" failed: 500") {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestSignetTestFailOnTeardownErrorConfig(t *testing.T) {
+	provider, _ := mockProviderWithStateHandler(t, http.StatusInternalServerError)
+	defer provider.Close()
+
+	viper.Set("test.fail-on-teardown-error", true)
+	defer viper.Set("test.fail-on-teardown-error", false)
+
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url", provider.URL,
+		"--provider-states-teardown-url", provider.URL + "/_pact/state",
+	}
+	actual := callSignetTest(flags)
+
+	t.Run("fails the interaction", func(t *testing.T) {
+		actual.startsWith(colorRed+"FAIL", t)
+	})
+	teardown()
+}
+
+func TestSignetTestReusesCachedSpecWhenNotModified(t *testing.T) {
+	realGetNpmPkgRoot := getNpmPkgRoot
+	realosWriteFile := osWriteFile
+	defer func() {
+		getNpmPkgRoot = realGetNpmPkgRoot
+		osWriteFile = realosWriteFile
+	}()
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	getNpmPkgRoot = func() (string, error) { return "/testDir", nil }
+
+	var writtenSpecs [][]byte
+	osWriteFile = func(name string, data []byte, perm fs.FileMode) error {
+		writtenSpecs = append(writtenSpecs, data)
+		return errors.New("stop this test here")
+	}
+
+	var ifNoneMatch []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch = append(ifNoneMatch, r.Header.Get("If-None-Match"))
+		if r.Header.Get("If-None-Match") == `"spec-v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", `"spec-v1"`)
+		w.Write([]byte(`{"openapi": "3.0.2", "info": {"title": "user_service_api", "version": "1"}, "paths": {}}`))
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--version=version1",
+		"--name", "user_service",
+		"--broker-url", server.URL,
+		"--provider-url", "http://localhost:3002",
+	}
+	callSignetTest(flags)
+	callSignetTest(flags)
+	callSignetTest(append(flags, "--no-cache"))
+
+	t.Run("sends If-None-Match only when a cached spec exists and caching is enabled", func(t *testing.T) {
+		if len(ifNoneMatch) != 3 || ifNoneMatch[0] != "" || ifNoneMatch[1] != `"spec-v1"` || ifNoneMatch[2] != "" {
+			t.Error(ifNoneMatch)
+		}
+	})
+
+	t.Run("uses the cached spec after a 304", func(t *testing.T) {
+		if len(writtenSpecs) != 3 || string(writtenSpecs[1]) != `{"openapi": "3.0.2", "info": {"title": "user_service_api", "version": "1"}, "paths": {}}` {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestSignetTestReusesCachedSpecByLastModified(t *testing.T) {
+	realGetNpmPkgRoot := getNpmPkgRoot
+	realosWriteFile := osWriteFile
+	defer func() {
+		getNpmPkgRoot = realGetNpmPkgRoot
+		osWriteFile = realosWriteFile
+	}()
+
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	getNpmPkgRoot = func() (string, error) { return "/testDir", nil }
+
+	var writtenSpecs [][]byte
+	osWriteFile = func(name string, data []byte, perm fs.FileMode) error {
+		writtenSpecs = append(writtenSpecs, data)
+		return errors.New("stop this test here")
+	}
+
+	lastModified := "Wed, 01 May 2024 10:15:00 GMT"
+	var ifModifiedSince []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifModifiedSince = append(ifModifiedSince, r.Header.Get("If-Modified-Since"))
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write([]byte(`{"openapi": "3.0.2", "info": {"title": "user_service_api", "version": "1"}, "paths": {}}`))
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--version=version1",
+		"--name", "user_service",
+		"--broker-url", server.URL,
+		"--provider-url", "http://localhost:3002",
+	}
+	callSignetTest(flags)
+	callSignetTest(flags)
+
+	t.Run("sends If-Modified-Since when the broker sent no ETag", func(t *testing.T) {
+		if len(ifModifiedSince) != 2 || ifModifiedSince[0] != "" || ifModifiedSince[1] != lastModified {
+			t.Error(ifModifiedSince)
+		}
+	})
+
+	t.Run("uses the cached spec after a 304", func(t *testing.T) {
+		if len(writtenSpecs) != 2 || string(writtenSpecs[1]) != `{"openapi": "3.0.2", "info": {"title": "user_service_api", "version": "1"}, "paths": {}}` {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestSignetTestInvalidSpec(t *testing.T) {
+	realGetNpmPkgRoot := getNpmPkgRoot
+	realosWriteFile := osWriteFile
+	defer func() {
+		getNpmPkgRoot = realGetNpmPkgRoot
+		osWriteFile = realosWriteFile
+	}()
+
+	getNpmPkgRoot = func() (string, error) { return "/testDir", nil }
+	written := false
+	osWriteFile = func(name string, data []byte, perm fs.FileMode) error {
+		written = true
+		return errors.New("stop this test here")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"openapi": "3.0.2", "info": {"title": "user_service_api", "version": "1"}, "paths": {"/users": {"get": {"responses": {}}}}}`))
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--version=version1",
+		"--name", "user_service",
+		"--broker-url", server.URL,
+		"--provider-url", "http://localhost:3002",
+		"--no-cache",
+	}
+
+	t.Run("does not run dredd against an invalid spec", func(t *testing.T) {
+		actual := callSignetTest(flags)
+		expected := "Error: the API spec is not a valid OpenAPI document - an operation must have at least one response at /paths/~1users/get/responses"
+
+		actual.startsWith(expected, t)
+		if written {
+			t.Error()
+		}
+		teardown()
+	})
+
+	t.Run("runs dredd with --skip-validation", func(t *testing.T) {
+		callSignetTest(append(flags, "--skip-validation"))
+		if !written {
+			t.Error()
+		}
+		teardown()
+	})
+}
+
+func TestSignetTestAsyncAPISpec(t *testing.T) {
+	realGetNpmPkgRoot := getNpmPkgRoot
+	realosWriteFile := osWriteFile
+	defer func() {
+		getNpmPkgRoot = realGetNpmPkgRoot
+		osWriteFile = realosWriteFile
+	}()
+
+	getNpmPkgRoot = func() (string, error) { return "/testDir", nil }
+	written := false
+	osWriteFile = func(name string, data []byte, perm fs.FileMode) error {
+		written = true
+		return errors.New("stop this test here")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("asyncapi: 2.6.0\ninfo:\n  title: user_service_events\n  version: 1.0.0\nchannels: {}\n"))
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--version=version1",
+		"--name", "user_service",
+		"--broker-url", server.URL,
+		"--provider-url", "http://localhost:3002",
+		"--no-cache",
+		"--skip-validation",
+	}
+
+	actual := callSignetTest(flags)
+	expected := "Error: verification not supported for asyncapi - the latest API spec of user_service is an AsyncAPI document, which dredd cannot verify"
+
+	actual.startsWith(expected, t)
+	if written {
+		t.Error("dredd was run against an AsyncAPI spec")
+	}
+	teardown()
+}
+
+func TestSignetTestGraphQLSpec(t *testing.T) {
+	realGetNpmPkgRoot := getNpmPkgRoot
+	realosWriteFile := osWriteFile
+	defer func() {
+		getNpmPkgRoot = realGetNpmPkgRoot
+		osWriteFile = realosWriteFile
+	}()
+
+	getNpmPkgRoot = func() (string, error) { return "/testDir", nil }
+	written := false
+	osWriteFile = func(name string, data []byte, perm fs.FileMode) error {
+		written = true
+		return errors.New("stop this test here")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("type Query {\n  users: [User]\n}\n\ntype User {\n  id: ID!\n}\n"))
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--version=version1",
+		"--name", "user_service",
+		"--broker-url", server.URL,
+		"--provider-url", "http://localhost:3002",
+		"--no-cache",
+	}
+
+	actual := callSignetTest(flags)
+	expected := "Error: verification not supported for graphql - the latest API spec of user_service is a GraphQL schema, which dredd cannot verify"
+
+	actual.startsWith(expected, t)
+	if written {
+		t.Error("dredd was run against a GraphQL schema")
+	}
+	teardown()
+}
+
+func TestSignetTestProviderDiscoveryInvalid(t *testing.T) {
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-discovery", "consul:myprovider",
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: --provider-discovery must be in the form srv:<name>"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestSignetTestProviderDiscoveryNoRecords(t *testing.T) {
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	defer func() { lookupSRV = net.LookupSRV }()
+
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-discovery", "srv:_http._tcp.myprovider.service.consul",
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: no SRV records were found for _http._tcp.myprovider.service.consul"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestSignetTestProviderDiscoveryReplaysEachInstance(t *testing.T) {
+	requests := 0
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`{"userId": 2, "username": "jimmy", "touchedBy": ["user_service"]}`))
+	}))
+	defer provider.Close()
+
+	providerPort, _ := strconv.Atoi(provider.URL[strings.LastIndex(provider.URL, ":")+1:])
+	lookupSRV = func(service, proto, name string) (string, []*net.SRV, error) {
+		return "", []*net.SRV{
+			{Target: "127.0.0.1.", Port: uint16(providerPort)},
+			{Target: "unresolvable.consul.", Port: 8080},
+			{Target: "localhost.", Port: uint16(providerPort)},
+		}, nil
+	}
+	lookupHost = func(host string) ([]string, error) {
+		if host == "unresolvable.consul" {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return []string{"127.0.0.1"}, nil
+	}
+	defer func() {
+		lookupSRV = net.LookupSRV
+		lookupHost = net.LookupHost
+	}()
+
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-discovery", "srv:_http._tcp.myprovider.service.consul",
+	}
+	actual := callSignetTest(flags)
+
+	t.Run("warns about the instance that does not resolve", func(t *testing.T) {
+		actual.startsWith("Warning - skipping provider instance unresolvable.consul", t)
+	})
+
+	t.Run("replays the pact against each resolved instance", func(t *testing.T) {
+		if requests != 2 {
+			t.Errorf("provider received %d requests", requests)
+		}
+	})
+	teardown()
+}
+
+func TestNormalizeProviderURL(t *testing.T) {
+	t.Run("defaults a URL without a scheme to http", func(t *testing.T) {
+		actual, err := normalizeProviderURL(testCmd, "localhost:8080", false)
+		if err != nil || actual != "http://localhost:8080" {
+			t.Error(actual, err)
+		}
+	})
+
+	t.Run("leaves a URL with a scheme unchanged", func(t *testing.T) {
+		actual, err := normalizeProviderURL(testCmd, "https://provider.internal", false)
+		if err != nil || actual != "https://provider.internal" {
+			t.Error(actual, err)
+		}
+	})
+
+	t.Run("rejects a URL without a scheme under --strict", func(t *testing.T) {
+		_, err := normalizeProviderURL(testCmd, "localhost:8080", true)
+		if err == nil {
+			t.Error()
+		}
+	})
+
+	t.Run("rejects a URL that is not http or https", func(t *testing.T) {
+		_, err := normalizeProviderURL(testCmd, "ftp://localhost:8080", false)
+		if err == nil {
+			t.Error()
+		}
+	})
+}
+
+func TestSignetTestStrictProviderURL(t *testing.T) {
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+		"--name", "user_service",
+		"--provider-url", "localhost:3002",
+		"--strict",
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: --provider-url must include a scheme (ex. http://localhost:3002)"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestSignetTestPactFileOnlyNewSince(t *testing.T) {
+	contract, err := os.ReadFile("../data_test/cons-prov.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var prior map[string]interface{}
+	json.Unmarshal(contract, &prior)
+	prior["interactions"] = []interface{}{}
+
+	var contractQuery url.Values
+	broker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contractQuery = r.URL.Query()
+		json.NewEncoder(w).Encode(prior)
+	}))
+	defer broker.Close()
+
+	replayed := 0
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replayed++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"userId": 2, "username": "jimmy", "touchedBy": ["user_service"]}`))
+	}))
+	defer provider.Close()
+
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url", provider.URL,
+		"--broker-url", broker.URL,
+		"--only-new-since", "1.0.0+build",
+	}
+	actual := callSignetTest(flags)
+
+	t.Run("fetches the prior consumer version's pact", func(t *testing.T) {
+		if contractQuery.Get("consumer") != "service_1" || contractQuery.Get("consumerVersion") != "1.0.0+build" {
+			t.Error()
+		}
+	})
+
+	t.Run("replays the new interaction", func(t *testing.T) {
+		if replayed != 1 {
+			t.Error()
+		}
+	})
+
+	t.Run("reports the count verified vs total", func(t *testing.T) {
+		if !strings.Contains(actual.actual, "Verified 1 of 1 interactions - the other 0 are unchanged since consumer version 1.0.0+build") {
+			t.Error(actual.actual)
+		}
+	})
+	teardown()
+}
+
+func TestSignetTestPactFileOnlyNewSinceNothingNew(t *testing.T) {
+	broker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "../data_test/cons-prov.json")
+	}))
+	defer broker.Close()
+
+	replayed := 0
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replayed++
+	}))
+	defer provider.Close()
+
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url", provider.URL,
+		"--broker-url", broker.URL,
+		"--only-new-since", "1.0.0",
+	}
+	actual := callSignetTest(flags)
+
+	t.Run("prints that no interactions are new", func(t *testing.T) {
+		expected := "Skipped - none of the 1 interactions in ../data_test/cons-prov.json are new since consumer version 1.0.0"
+		actual.startsWith(expected, t)
+	})
+
+	t.Run("does not replay any interactions", func(t *testing.T) {
+		if replayed != 0 {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestSignetTestPactFileOnlyNewSinceNoBrokerURL(t *testing.T) {
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url", "http://localhost:3002",
+		"--only-new-since", "1.0.0",
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: No --broker-url was provided. This flag is required with --only-new-since."
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestSignetTestSchemeFallback(t *testing.T) {
+	replayed := 0
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users/1" {
+			replayed++
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"userId": 2, "username": "jimmy", "touchedBy": ["user_service"]}`))
+	}))
+	defer provider.Close()
+
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url", strings.Replace(provider.URL, "http://", "https://", 1),
+		"--scheme-fallback",
+	}
+	actual := callSignetTest(flags)
+
+	t.Run("reports the scheme that was used", func(t *testing.T) {
+		expected := "Using http for the provider at " + strings.TrimPrefix(provider.URL, "http://")
+		actual.startsWith(expected, t)
+	})
+
+	t.Run("replays against the fallback scheme", func(t *testing.T) {
+		if replayed != 1 {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestSignetTestSchemeFallbackUnreachable(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	host := strings.TrimPrefix(provider.URL, "http://")
+	provider.Close()
+
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url", "http://" + host,
+		"--scheme-fallback",
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: the provider at " + host + " could not be reached over http or https"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestPublishProviderUtilWithEnvironment(t *testing.T) {
+	server, reqBody := mockServerForJSONReq201Created[utils.ProviderBody](t)
+	defer server.Close()
+
+	err := utils.PublishProvider(context.Background(), "../data_test/api-spec.json", server.URL, "user_service", "version1", "main", "staging")
+	if err != nil {
+		t.Error(err)
+	}
+
+	t.Run("sends the environment the provider was verified in", func(t *testing.T) {
+		if reqBody.EnvironmentName != "staging" {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestPublishProviderUtilOmitsUnsetEnvironment(t *testing.T) {
+	var rawBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&rawBody)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	err := utils.PublishProvider(context.Background(), "../data_test/api-spec.json", server.URL, "user_service", "version1", "main", "")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, ok := rawBody["environmentName"]; ok {
+		t.Error()
+	}
+	teardown()
+}
+
+func TestSignetTestPactFileSummaryJSON(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer provider.Close()
+
+	summaryPath := t.TempDir() + "/summary.json"
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url", provider.URL,
+		"--summary-json", summaryPath,
+	}
+	callSignetTest(flags)
+
+	summaryBytes, err := os.ReadFile(summaryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var summary testSummary
+	json.Unmarshal(summaryBytes, &summary)
+
+	t.Run("is written when the test fails", func(t *testing.T) {
+		if summary.Passed || summary.Published {
+			t.Error(string(summaryBytes))
+		}
+	})
+
+	t.Run("has the provider name and interaction counts", func(t *testing.T) {
+		if summary.Provider != "user_service" || summary.Interactions != (interactionCounts{Total: 1, Passed: 0, Failed: 1}) {
+			t.Error(string(summaryBytes))
+		}
+	})
+	teardown()
+}
+
+func TestAddDreddCounts(t *testing.T) {
+	counts := interactionCounts{Total: 2, Passed: 2}
+	output := "pass: GET (200) /users/1\nfail: GET (200) /users/2\ncomplete: 3 passing, 1 failing, 1 errors, 1 skipped, 6 total\ncomplete: Tests took 73ms\n"
+
+	counts = addDreddCounts(counts, output)
+	if counts != (interactionCounts{Total: 8, Passed: 5, Failed: 2}) {
+		t.Error(counts)
+	}
+}
+
+func TestParseDreddResults(t *testing.T) {
+	output := "(node:123) Warning: something deprecated\n" +
+		"pass: GET (200) /users/1 duration: 12ms\n" +
+		"fail: GET (200) /users/2 duration: 5ms\n" +
+		"fail: statusCode: Status code is '404' instead of '200'\n" +
+		"body: At '' Invalid type: null (expected object)\n" +
+		"skip: DELETE (204) /users/3\n" +
+		"error: POST (201) /users duration: 0ms\n" +
+		"error: Error: connect ECONNREFUSED 127.0.0.1:3002\n" +
+		"info: Displaying failed tests...\n" +
+		"fail: GET (200) /users/2 duration: 5ms\n" +
+		"complete: 1 passing, 1 failing, 1 errors, 1 skipped, 4 total\n"
+
+	results := parseDreddResults("http://localhost:3002", output)
+
+	t.Run("has one result for each transaction", func(t *testing.T) {
+		if len(results) != 4 {
+			t.Fatal(results)
+		}
+
+		expected := []string{"pass GET /users/1 200", "fail GET /users/2 200", "skip DELETE /users/3 204", "error POST /users 201"}
+		for i, result := range results {
+			actual := result.Result + " " + result.Method + " " + result.Path + " " + strconv.Itoa(result.Status)
+			if actual != expected[i] || result.ProviderURL != "http://localhost:3002" {
+				t.Error(result)
+			}
+		}
+	})
+
+	t.Run("has the reason that each failed transaction failed", func(t *testing.T) {
+		expected := "statusCode: Status code is '404' instead of '200'\nbody: At '' Invalid type: null (expected object)"
+		if results[1].Reason != expected {
+			t.Error(results[1].Reason)
+		}
+
+		if results[3].Reason != "Error: connect ECONNREFUSED 127.0.0.1:3002" {
+			t.Error(results[3].Reason)
+		}
+
+		if results[0].Reason != "" || results[2].Reason != "" {
+			t.Error(results)
+		}
+	})
+}
+
+func TestSignetTestProviderURLScan(t *testing.T) {
+	replayed := 0
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/users/1" {
+			replayed++
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"userId": 2, "username": "jimmy", "touchedBy": ["user_service"]}`))
+	}))
+	defer provider.Close()
+
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedPort := closed.Listener.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	// the range starts at a closed port when it is just below the live one
+	livePort := provider.Listener.Addr().(*net.TCPAddr).Port
+	startPort := livePort
+	if closedPort < livePort && livePort-closedPort <= 20 {
+		startPort = closedPort
+	}
+
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url-scan", "127.0.0.1:" + strconv.Itoa(startPort) + "-" + strconv.Itoa(livePort),
+	}
+	actual := callSignetTest(flags)
+
+	t.Run("reports the port that was selected", func(t *testing.T) {
+		expected := "Found a provider on port " + strconv.Itoa(livePort) + " of 127.0.0.1"
+		actual.startsWith(expected, t)
+	})
+
+	t.Run("verifies the responding provider", func(t *testing.T) {
+		if replayed != 1 {
+			t.Error()
+		}
+	})
+	teardown()
+}
+
+func TestSignetTestProviderURLScanNoneRespond(t *testing.T) {
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closedPort := strconv.Itoa(closed.Listener.Addr().(*net.TCPAddr).Port)
+	closed.Close()
+
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url-scan", "127.0.0.1:" + closedPort + "-" + closedPort,
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: no provider responded on ports " + closedPort + "-" + closedPort + " of 127.0.0.1"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestSignetTestProviderURLScanInvalidRange(t *testing.T) {
+	flags := []string{
+		"--pact-file", "../data_test/cons-prov.json",
+		"--provider-url-scan", "localhost:3010-3000",
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: --provider-url-scan must be in the form host:startPort-endPort (ex. localhost:3000-3010), --provider-url-scan was localhost:3010-3000"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+// generates an Ed25519 key pair, and writes the public key to a PEM file
+func writeSigningKey(t *testing.T) (ed25519.PrivateKey, string) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyPath := t.TempDir() + "/broker.pub"
+	err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyBytes}), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return privateKey, keyPath
+}
+
+func callSignetTestWithSignedSpec(t *testing.T, signer ed25519.PrivateKey, keyPath string) actualOut {
+	realGetNpmPkgRoot := getNpmPkgRoot
+	realosWriteFile := osWriteFile
+	defer func() {
+		getNpmPkgRoot = realGetNpmPkgRoot
+		osWriteFile = realosWriteFile
+	}()
+
+	getNpmPkgRoot = func() (string, error) { return "/testDir", nil }
+	osWriteFile = func(name string, data []byte, perm fs.FileMode) error {
+		return errors.New("stop this test here")
+	}
+
+	specBytes, err := os.ReadFile("../data_test/api-spec.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Signet-Signature", base64.StdEncoding.EncodeToString(ed25519.Sign(signer, specBytes)))
+		w.Write(specBytes)
+	}))
+	defer server.Close()
+
+	flags := []string{
+		"--version=version1",
+		"--name", "user_service",
+		"--broker-url", server.URL,
+		"--provider-url", "http://localhost:3002",
+		"--no-cache",
+		"--verify-signature",
+		"--signing-key", keyPath,
+	}
+	return callSignetTest(flags)
+}
+
+func TestSignetTestVerifySignature(t *testing.T) {
+	signer, keyPath := writeSigningKey(t)
+	actual := callSignetTestWithSignedSpec(t, signer, keyPath)
+
+	expected := "Error: Failed to write specs/spec file: stop this test here"
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestSignetTestVerifySignatureWrongKey(t *testing.T) {
+	otherSigner, _ := writeSigningKey(t)
+	_, keyPath := writeSigningKey(t)
+	actual := callSignetTestWithSignedSpec(t, otherSigner, keyPath)
+
+	expected := "Error: signature verification failed - the broker's response was not signed by --signing-key"
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestSignetTestVerifySignatureNoSigningKey(t *testing.T) {
+	flags := []string{
+		"--version=version1",
+		"--name", "user_service",
+		"--broker-url=http://localhost:3000",
+		"--provider-url", "http://localhost:3002",
+		"--verify-signature",
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: No --signing-key was provided. This flag is required with --verify-signature."
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestSignetTestInvalidTimeout(t *testing.T) {
+	flags := []string{
+		"--broker-url=http://localhost:3000",
+		"--name", "user_service",
+		"--provider-url", "http://localhost:3002",
+		"--timeout", "0s",
+	}
+	actual := callSignetTest(flags)
+	expected := "Error: --timeout must be greater than 0, --timeout was 0s"
+
+	actual.startsWith(expected, t)
+	teardown()
+}
+
+func TestWriteTestResultsAfterError(t *testing.T) {
+	summaryJSON = t.TempDir() + "/missing/summary.json"
+	actual := new(bytes.Buffer)
+	testCmd.SetOut(actual)
+	testCmd.SetErr(actual)
+
+	writeTestResultsAfterError(testCmd, testReport{testSummary: testSummary{Provider: "user_service"}})
+
+	expected := "Warning - failed to write --summary-json file: "
+	if !strings.HasPrefix(actual.String(), expected) {
+		t.Error(actual.String())
+	}
+
+	testCmd.SetOut(nil)
+	testCmd.SetErr(nil)
+	teardown()
+}
+
+func TestCombinedOutputWithTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh is not available on windows")
+	}
+
+	t.Run("returns the output of a command that finishes in time", func(t *testing.T) {
+		output, err := combinedOutputWithTimeout(context.Background(), 5*time.Second, "sh", "-c", "echo complete")
+		if err != nil || string(output) != "complete\n" {
+			t.Error(string(output), err)
+		}
+	})
+
+	t.Run("stops a command and its children when the timeout passes", func(t *testing.T) {
+		start := time.Now()
+		_, err := combinedOutputWithTimeout(context.Background(), 100*time.Millisecond, "sh", "-c", "sleep 30 & sleep 30; wait")
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Error(err)
+		}
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Errorf("took %s to stop", elapsed)
+		}
+	})
+
+	t.Run("stops a command and its children when it is interrupted", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+
+		start := time.Now()
+		_, err := combinedOutputWithTimeout(ctx, 30*time.Second, "sh", "-c", "sleep 30 & sleep 30; wait")
+		if !errors.Is(err, context.Canceled) {
+			t.Error(err)
+		}
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Errorf("took %s to stop", elapsed)
+		}
+	})
+}
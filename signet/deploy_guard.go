@@ -0,0 +1,68 @@
+package signet
+
+import (
+	"context"
+
+	client "github.com/signet-framework/signet-cli/client"
+)
+
+// a service version, and the environment it is about to be deployed to
+type DeployGuardOptions struct {
+	BrokerURL   string
+	Name        string
+	Version     string
+	Environment string
+	// check the contracts of the service that were published with any of these tags, instead of with Version
+	Tags []string
+	// also check the version against contracts that have not been verified yet
+	IncludePending bool
+	// treat a contract that its provider has never verified as unsafe, instead of ignoring it
+	FailOnUnverified bool
+}
+
+/*
+asks the broker whether a service version can be deployed to an environment
+without breaking any of its consumers or being broken by its providers. The
+version is safe to deploy when the Status of the result is true, and Errors
+lists the incompatibilities when it is not.
+*/
+func CheckDeployGuard(ctx context.Context, options DeployGuardOptions) (client.DeployGuardResponse, error) {
+	if len(options.BrokerURL) == 0 {
+		return client.DeployGuardResponse{}, required("BrokerURL")
+	}
+	if len(options.Name) == 0 {
+		return client.DeployGuardResponse{}, required("Name")
+	}
+	if len(options.Version) == 0 {
+		return client.DeployGuardResponse{}, required("Version")
+	}
+	if len(options.Environment) == 0 {
+		return client.DeployGuardResponse{}, required("Environment")
+	}
+
+	result, err := client.CheckDeployGuard(ctx, options.BrokerURL, options.Name, options.Version, options.Environment, options.Tags, options.IncludePending)
+	if err != nil {
+		return client.DeployGuardResponse{}, err
+	}
+
+	if options.FailOnUnverified {
+		result = FailUnverifiedContracts(result)
+	}
+	return result, nil
+}
+
+/*
+treats contracts which exist but have never been verified by their provider
+as incompatibilities, rather than leaving them out of the compatibility check
+*/
+func FailUnverifiedContracts(result client.DeployGuardResponse) client.DeployGuardResponse {
+	for _, contract := range result.Unverified {
+		result.Status = false
+		result.Errors = append(result.Errors, client.DeployGuardError{
+			Title:   "unverified contract: " + contract.ConsumerName + " -> " + contract.ProviderName,
+			Details: "the contract between consumer " + contract.ConsumerName + " and provider " + contract.ProviderName + " has not been verified",
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,102 @@
+package signet
+
+import (
+	"context"
+	"time"
+
+	client "github.com/signet-framework/signet-cli/client"
+	utils "github.com/signet-framework/signet-cli/utils"
+)
+
+// a consumer contract, and the consumer version and branch it is published as
+type ConsumerContract struct {
+	BrokerURL string
+	// the JSON or YAML pact, or utils.StdinPath
+	Path string
+	// defaults to the git SHA of HEAD
+	Version string
+	// defaults to the git branch of HEAD when Version is not set either
+	Branch string
+	Tags   []string
+	// the contract schema version, defaults to the highest version supported by both signet and the broker
+	SchemaVersion int
+	// how long the broker keeps the contract, forever when it is 0
+	TTL time.Duration
+	// publish a contract that is not a valid pact
+	SkipValidation bool
+	// build the request without contacting the broker, so a SchemaVersion of 0 is the highest version signet supports
+	DryRun bool
+}
+
+// a provider API spec, and the name of the provider it describes
+type ProviderSpec struct {
+	BrokerURL string
+	Name      string
+	// the OpenAPI, GraphQL, or AsyncAPI spec, or utils.StdinPath
+	Path string
+	// either openapi, graphql, or asyncapi, detected from the spec when it is not set
+	ContractType string
+	// publish a spec that is not a valid OpenAPI document
+	SkipValidation bool
+}
+
+// publishes a consumer contract to the broker
+func PublishConsumer(ctx context.Context, contract ConsumerContract) error {
+	if len(contract.BrokerURL) == 0 {
+		return required("BrokerURL")
+	}
+	if len(contract.Path) == 0 {
+		return required("Path")
+	}
+
+	return utils.PublishConsumer(ctx, contract.Path, contract.BrokerURL, contract.Version, contract.Branch, contract.publishOptions())
+}
+
+// the request body that PublishConsumer sends, without sending it
+func PrepareConsumer(ctx context.Context, contract ConsumerContract) ([]byte, error) {
+	if len(contract.BrokerURL) == 0 && !contract.DryRun {
+		return nil, required("BrokerURL")
+	}
+	if len(contract.Path) == 0 {
+		return nil, required("Path")
+	}
+
+	return utils.PrepareConsumerRequest(ctx, contract.Path, contract.BrokerURL, contract.Version, contract.Branch, contract.publishOptions())
+}
+
+func (contract ConsumerContract) publishOptions() utils.PublishOptions {
+	return utils.PublishOptions{
+		Tags:           contract.Tags,
+		SchemaVersion:  contract.SchemaVersion,
+		TTL:            contract.TTL,
+		SkipValidation: contract.SkipValidation,
+		DryRun:         contract.DryRun,
+	}
+}
+
+// checks that a provider spec is valid, and publishes it to the broker
+func PublishProvider(ctx context.Context, spec ProviderSpec) error {
+	if len(spec.BrokerURL) == 0 {
+		return required("BrokerURL")
+	}
+	if len(spec.Name) == 0 {
+		return required("Name")
+	}
+	if len(spec.Path) == 0 {
+		return required("Path")
+	}
+
+	if !spec.SkipValidation {
+		err := utils.ValidateSpecFile(spec.Path, spec.ContractType)
+		if err != nil {
+			return err
+		}
+	}
+
+	requestBody, err := utils.PrepareProviderRequest(spec.Path, spec.ContractType, spec.Name, "", "", "")
+	if err != nil {
+		return err
+	}
+
+	return client.PublishToBroker(ctx, spec.BrokerURL+"/api/specs", requestBody)
+}
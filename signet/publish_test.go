@@ -0,0 +1,259 @@
+package signet
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+)
+
+/* ------------- helpers ------------- */
+
+// a broker that records the path and body of each request it is sent
+func mockBroker(paths *[]string, bodies *[][]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		*paths = append(*paths, r.URL.Path)
+		*bodies = append(*bodies, body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+}
+
+/* ------------- tests ------------- */
+
+func TestPrepareConsumer(t *testing.T) {
+	tests := []struct {
+		name          string
+		contract      ConsumerContract
+		expectedErr   string
+		schemaVersion int
+	}{
+		{
+			name:        "requires a BrokerURL",
+			contract:    ConsumerContract{Path: "../data_test/cons-prov.json", Version: "version1", Branch: "main"},
+			expectedErr: "no BrokerURL was provided, it is required",
+		},
+		{
+			name:        "requires a Path",
+			contract:    ConsumerContract{BrokerURL: "http://localhost:3000", Version: "version1", Branch: "main"},
+			expectedErr: "no Path was provided, it is required",
+		},
+		{
+			name:          "builds the request with the given SchemaVersion",
+			contract:      ConsumerContract{BrokerURL: "http://localhost:3000", Path: "../data_test/cons-prov.json", Version: "version1", Branch: "main", SchemaVersion: 1},
+			schemaVersion: 1,
+		},
+		{
+			name:          "builds the request with the highest SchemaVersion for a DryRun without a BrokerURL",
+			contract:      ConsumerContract{Path: "../data_test/cons-prov.json", Version: "version1", Branch: "main", DryRun: true},
+			schemaVersion: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requestBody, err := PrepareConsumer(context.Background(), test.contract)
+			if len(test.expectedErr) != 0 {
+				if err == nil || err.Error() != test.expectedErr {
+					t.Errorf("expected error %q but got %v", test.expectedErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var body map[string]interface{}
+			err = json.Unmarshal(requestBody, &body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			switch test.schemaVersion {
+			case 1:
+				if body["consumerName"] != "service_1" || body["consumerVersion"] != "version1" {
+					t.Error(string(requestBody))
+				}
+			case 2:
+				consumer, _ := body["consumer"].(map[string]interface{})
+				if body["schemaVersion"] != float64(2) || consumer["name"] != "service_1" || consumer["version"] != "version1" {
+					t.Error(string(requestBody))
+				}
+			}
+		})
+	}
+}
+
+func TestPublishProvider(t *testing.T) {
+	invalidSpecPath := t.TempDir() + "/invalid-spec.json"
+	err := os.WriteFile(invalidSpecPath, []byte(`{"openapi": "3.0.0", "paths": []}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		spec        ProviderSpec
+		expectedErr string
+		invalid     bool
+	}{
+		{
+			name:        "requires a BrokerURL",
+			spec:        ProviderSpec{Name: "user_service", Path: "../data_test/api-spec.json"},
+			expectedErr: "no BrokerURL was provided, it is required",
+		},
+		{
+			name:        "requires a Name",
+			spec:        ProviderSpec{BrokerURL: "broker", Path: "../data_test/api-spec.json"},
+			expectedErr: "no Name was provided, it is required",
+		},
+		{
+			name:        "requires a Path",
+			spec:        ProviderSpec{BrokerURL: "broker", Name: "user_service"},
+			expectedErr: "no Path was provided, it is required",
+		},
+		{
+			name:    "does not publish a spec that is not valid",
+			spec:    ProviderSpec{BrokerURL: "broker", Name: "user_service", Path: invalidSpecPath},
+			invalid: true,
+		},
+		{
+			name: "publishes a spec that is not valid with SkipValidation",
+			spec: ProviderSpec{BrokerURL: "broker", Name: "user_service", Path: invalidSpecPath, SkipValidation: true},
+		},
+		{
+			name: "publishes a valid spec",
+			spec: ProviderSpec{BrokerURL: "broker", Name: "user_service", Path: "../data_test/api-spec.json"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var paths []string
+			var bodies [][]byte
+			broker := mockBroker(&paths, &bodies)
+			defer broker.Close()
+
+			if test.spec.BrokerURL == "broker" {
+				test.spec.BrokerURL = broker.URL
+			}
+
+			err := PublishProvider(context.Background(), test.spec)
+			if len(test.expectedErr) != 0 || test.invalid {
+				if err == nil || (len(test.expectedErr) != 0 && err.Error() != test.expectedErr) {
+					t.Errorf("expected error %q but got %v", test.expectedErr, err)
+				}
+				if len(paths) != 0 {
+					t.Errorf("expected nothing to be published but got %v", paths)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(paths) != 1 || paths[0] != "/api/specs" {
+				t.Fatal(paths)
+			}
+
+			var body map[string]interface{}
+			err = json.Unmarshal(bodies[0], &body)
+			if err != nil || body["providerName"] != "user_service" {
+				t.Error(string(bodies[0]), err)
+			}
+		})
+	}
+}
+
+func TestPublishConsumer(t *testing.T) {
+	tests := []struct {
+		name        string
+		contract    ConsumerContract
+		expectedErr string
+	}{
+		{
+			name:        "requires a BrokerURL",
+			contract:    ConsumerContract{Path: "../data_test/cons-prov.json"},
+			expectedErr: "no BrokerURL was provided, it is required",
+		},
+		{
+			name:        "requires a Path",
+			contract:    ConsumerContract{BrokerURL: "broker"},
+			expectedErr: "no Path was provided, it is required",
+		},
+		{
+			name:     "publishes the contract",
+			contract: ConsumerContract{BrokerURL: "broker", Path: "../data_test/cons-prov.json", Version: "version1", Branch: "main", SchemaVersion: 1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var paths []string
+			var bodies [][]byte
+			broker := mockBroker(&paths, &bodies)
+			defer broker.Close()
+
+			if test.contract.BrokerURL == "broker" {
+				test.contract.BrokerURL = broker.URL
+			}
+
+			err := PublishConsumer(context.Background(), test.contract)
+			if len(test.expectedErr) != 0 {
+				if err == nil || err.Error() != test.expectedErr {
+					t.Errorf("expected error %q but got %v", test.expectedErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(paths) != 1 || paths[0] != "/api/contracts" {
+				t.Error(paths)
+			}
+		})
+	}
+}
+
+func TestPublishConsumerConcurrently(t *testing.T) {
+	var mu sync.Mutex
+	published := 0
+	broker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/capabilities" {
+			w.Write([]byte(`{"contractSchemaVersions": [1, 2]}`))
+			return
+		}
+
+		mu.Lock()
+		published++
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer broker.Close()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = PublishConsumer(context.Background(), ConsumerContract{BrokerURL: broker.URL, Path: "../data_test/cons-prov.json", Version: "version1", Branch: "main"})
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	if published != len(errs) {
+		t.Errorf("expected %d contracts to be published but got %d", len(errs), published)
+	}
+}
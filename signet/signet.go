@@ -0,0 +1,18 @@
+/*
+Package signet runs the core operations of the signet CLI from Go code, so
+that contract checks can be part of a go test suite without running the CLI.
+Each operation takes a plain struct of options and returns a typed result.
+Errors from the broker are *client.BrokerError, and an invalid consumer
+contract is a *utils.PactValidationError.
+
+Settings that the CLI takes as global flags, such as retries and custom
+headers, are read from the variables of the client package.
+*/
+package signet
+
+import "errors"
+
+// an option that the operation cannot run without
+func required(option string) error {
+	return errors.New("no " + option + " was provided, it is required")
+}
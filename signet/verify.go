@@ -0,0 +1,140 @@
+package signet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	client "github.com/signet-framework/signet-cli/client"
+	utils "github.com/signet-framework/signet-cli/utils"
+)
+
+// the running provider that contracts are replayed against
+type VerifyOptions struct {
+	ProviderURL string
+	// URL that a teardown request is POSTed to after each replayed interaction with a provider state
+	TeardownURL string
+	// fail an interaction when its provider state teardown fails, instead of adding a warning to its result
+	FailOnTeardownError bool
+	// a response slower than this adds a warning to the result of its interaction, unlimited when it is 0
+	MaxResponseTime time.Duration
+	// fail an interaction that is slower than MaxResponseTime, instead of adding a warning to its result
+	FailOnSlowResponse bool
+}
+
+// the result of replaying the interactions of one consumer's contract
+type ConsumerVerification struct {
+	Consumer     string
+	Interactions []utils.InteractionResult
+	// message interactions, which are not sent over HTTP and so are not replayed
+	Skipped int
+}
+
+// whether every interaction of the contract passed, which a contract with no HTTP interactions to replay never does
+func (verification ConsumerVerification) Passed() bool {
+	return len(verification.Interactions) != 0 && verification.Failed() == 0
+}
+
+// how many interactions of the contract failed
+func (verification ConsumerVerification) Failed() int {
+	failed := 0
+	for _, result := range verification.Interactions {
+		if !result.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// the results of replaying the latest contract of every consumer of a provider
+type ProviderVerification struct {
+	Provider  string
+	Consumers []ConsumerVerification
+}
+
+// whether the contracts of every consumer passed
+func (verification ProviderVerification) Passed() bool {
+	for _, consumer := range verification.Consumers {
+		if !consumer.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// replays each interaction of a pact against the provider, without contacting the broker
+func VerifyPact(ctx context.Context, pact utils.Pact, options VerifyOptions) (ConsumerVerification, error) {
+	if len(options.ProviderURL) == 0 {
+		return ConsumerVerification{}, required("ProviderURL")
+	}
+
+	results, err := utils.VerifyPact(ctx, pact, options.ProviderURL, utils.VerifyOptions{
+		TeardownURL:         options.TeardownURL,
+		FailOnTeardownError: options.FailOnTeardownError,
+		MaxResponseTime:     options.MaxResponseTime,
+		FailOnSlowResponse:  options.FailOnSlowResponse,
+	})
+	if err != nil {
+		return ConsumerVerification{}, err
+	}
+
+	skipped := 0
+	for _, interaction := range pact.Interactions.([]interface{}) {
+		if !utils.IsHTTPInteraction(interaction.(map[string]interface{})) {
+			skipped++
+		}
+	}
+
+	return ConsumerVerification{Consumer: pact.Consumer.Name, Interactions: results, Skipped: skipped}, nil
+}
+
+// the latest contract of every consumer of a provider, in the order the broker lists them
+func ConsumerContracts(ctx context.Context, brokerURL, providerName string) ([]utils.Pact, error) {
+	contracts, err := client.GetConsumerContracts(ctx, brokerURL, providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	pacts := make([]utils.Pact, len(contracts))
+	for i, contract := range contracts {
+		err = json.Unmarshal(contract, &pacts[i])
+		if _, ok := pacts[i].Interactions.([]interface{}); err != nil || !ok {
+			return nil, fmt.Errorf("consumer contract %d of %s could not be parsed as a pact", i+1, providerName)
+		}
+	}
+
+	return pacts, nil
+}
+
+/*
+replays the latest contract of every consumer of a provider from the broker
+against the running provider. The results are not published to the broker.
+*/
+func VerifyProvider(ctx context.Context, brokerURL, providerName string, options VerifyOptions) (ProviderVerification, error) {
+	if len(brokerURL) == 0 {
+		return ProviderVerification{}, required("brokerURL")
+	}
+	if len(providerName) == 0 {
+		return ProviderVerification{}, required("providerName")
+	}
+	if len(options.ProviderURL) == 0 {
+		return ProviderVerification{}, required("ProviderURL")
+	}
+
+	pacts, err := ConsumerContracts(ctx, brokerURL, providerName)
+	if err != nil {
+		return ProviderVerification{}, err
+	}
+
+	verification := ProviderVerification{Provider: providerName, Consumers: []ConsumerVerification{}}
+	for _, pact := range pacts {
+		consumer, err := VerifyPact(ctx, pact, options)
+		if err != nil {
+			return ProviderVerification{}, fmt.Errorf("could not replay the contract of consumer %s: %w", pact.Consumer.Name, err)
+		}
+		verification.Consumers = append(verification.Consumers, consumer)
+	}
+
+	return verification, nil
+}
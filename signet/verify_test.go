@@ -0,0 +1,261 @@
+package signet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	client "github.com/signet-framework/signet-cli/client"
+	utils "github.com/signet-framework/signet-cli/utils"
+)
+
+/* ------------- helpers ------------- */
+
+func loadPact(t *testing.T, path string) utils.Pact {
+	pactBytes, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var pact utils.Pact
+	err = json.Unmarshal(pactBytes, &pact)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return pact
+}
+
+// a provider that responds to the interaction in cons-prov.json with the username it is given
+func mockProvider(username string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"userId": 1, "username": "` + username + `", "touchedBy": ["user_service"]}`))
+	}))
+}
+
+/* ------------- tests ------------- */
+
+func TestVerifyPact(t *testing.T) {
+	passingProvider := mockProvider("jimmy")
+	defer passingProvider.Close()
+
+	failingProvider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer failingProvider.Close()
+
+	pact := loadPact(t, "../data_test/cons-prov.json")
+
+	tests := []struct {
+		name        string
+		options     VerifyOptions
+		expectedErr string
+		passed      bool
+		failed      int
+	}{
+		{
+			name:        "requires a ProviderURL",
+			options:     VerifyOptions{},
+			expectedErr: "no ProviderURL was provided, it is required",
+		},
+		{
+			name:    "passes when the provider responds as the pact expects",
+			options: VerifyOptions{ProviderURL: passingProvider.URL},
+			passed:  true,
+		},
+		{
+			name:    "fails each interaction the provider responds to differently",
+			options: VerifyOptions{ProviderURL: failingProvider.URL},
+			failed:  1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verification, err := VerifyPact(context.Background(), pact, test.options)
+			if len(test.expectedErr) != 0 {
+				if err == nil || err.Error() != test.expectedErr {
+					t.Errorf("expected error %q but got %v", test.expectedErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verification.Consumer != "service_1" || len(verification.Interactions) != 1 {
+				t.Error(verification)
+			}
+			if verification.Passed() != test.passed || verification.Failed() != test.failed {
+				t.Error(verification)
+			}
+		})
+	}
+}
+
+func TestVerifyProvider(t *testing.T) {
+	pactBytes, err := os.ReadFile("../data_test/cons-prov.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var requestedProvider string
+	broker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedProvider = r.URL.Query().Get("provider")
+		if r.URL.Path != "/api/contracts/latest" || requestedProvider != "user_service" {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error": "not found"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("[" + string(pactBytes) + "]"))
+	}))
+	defer broker.Close()
+
+	passingProvider := mockProvider("jimmy")
+	defer passingProvider.Close()
+
+	failingProvider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failingProvider.Close()
+
+	tests := []struct {
+		name         string
+		brokerURL    string
+		providerName string
+		options      VerifyOptions
+		expectedErr  string
+		brokerErr    bool
+		passed       bool
+	}{
+		{
+			name:         "requires a brokerURL",
+			providerName: "user_service",
+			options:      VerifyOptions{ProviderURL: passingProvider.URL},
+			expectedErr:  "no brokerURL was provided, it is required",
+		},
+		{
+			name:        "requires a providerName",
+			brokerURL:   broker.URL,
+			options:     VerifyOptions{ProviderURL: passingProvider.URL},
+			expectedErr: "no providerName was provided, it is required",
+		},
+		{
+			name:         "requires a ProviderURL",
+			brokerURL:    broker.URL,
+			providerName: "user_service",
+			expectedErr:  "no ProviderURL was provided, it is required",
+		},
+		{
+			name:         "returns the error of the broker",
+			brokerURL:    broker.URL,
+			providerName: "unknown_service",
+			options:      VerifyOptions{ProviderURL: passingProvider.URL},
+			brokerErr:    true,
+		},
+		{
+			name:         "passes when every consumer's contract passes",
+			brokerURL:    broker.URL,
+			providerName: "user_service",
+			options:      VerifyOptions{ProviderURL: passingProvider.URL},
+			passed:       true,
+		},
+		{
+			name:         "fails when a consumer's contract fails",
+			brokerURL:    broker.URL,
+			providerName: "user_service",
+			options:      VerifyOptions{ProviderURL: failingProvider.URL},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verification, err := VerifyProvider(context.Background(), test.brokerURL, test.providerName, test.options)
+			if len(test.expectedErr) != 0 {
+				if err == nil || err.Error() != test.expectedErr {
+					t.Errorf("expected error %q but got %v", test.expectedErr, err)
+				}
+				return
+			}
+
+			if test.brokerErr {
+				var brokerErr *client.BrokerError
+				if !errors.As(err, &brokerErr) {
+					t.Errorf("expected a *client.BrokerError but got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if verification.Provider != "user_service" || len(verification.Consumers) != 1 || verification.Consumers[0].Consumer != "service_1" {
+				t.Error(verification)
+			}
+			if verification.Passed() != test.passed {
+				t.Error(verification)
+			}
+		})
+	}
+}
+
+func TestVerifyPactCancelled(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer provider.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := VerifyPact(ctx, loadPact(t, "../data_test/cons-prov.json"), VerifyOptions{ProviderURL: provider.URL})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled but got %v", err)
+	}
+}
+
+func TestConsumerVerificationPassed(t *testing.T) {
+	tests := []struct {
+		name         string
+		verification ConsumerVerification
+		passed       bool
+	}{
+		{
+			name:         "passes when every interaction passed",
+			verification: ConsumerVerification{Interactions: []utils.InteractionResult{{Passed: true}}, Skipped: 1},
+			passed:       true,
+		},
+		{
+			name:         "fails when an interaction failed",
+			verification: ConsumerVerification{Interactions: []utils.InteractionResult{{Passed: true}, {Passed: false}}},
+		},
+		{
+			name:         "fails when no interaction was replayed",
+			verification: ConsumerVerification{Interactions: []utils.InteractionResult{}, Skipped: 2},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.verification.Passed() != test.passed {
+				t.Error(test.verification)
+			}
+		})
+	}
+}
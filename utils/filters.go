@@ -0,0 +1,198 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+loads a record spec file, which lists the interactions the proxy should
+record as one "METHOD /path/pattern" rule per line. The method may be "*"
+to match any method. Blank lines and lines starting with # are ignored.
+*/
+func LoadRecordSpec(path string) ([]RecordRule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New("could not read --record-spec file: " + err.Error())
+	}
+	defer file.Close()
+
+	rules := []RecordRule{}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "/") {
+			return nil, fmt.Errorf("%s line %d: expected a method and a path pattern (ex. GET /users/*), got %q", path, lineNumber, line)
+		}
+
+		rules = append(rules, RecordRule{Method: strings.ToUpper(fields[0]), Pattern: fields[1]})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(rules) == 0 {
+		return nil, errors.New("--record-spec file " + path + " does not contain any rules")
+	}
+
+	return rules, nil
+}
+
+/*
+converts a path glob to a regular expression: "*" matches within a single
+path segment, "**" matches across segments, and "?" matches one character
+*/
+func GlobToRegexp(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		}
+	}
+
+	sb.WriteString("$")
+	return sb.String()
+}
+
+func MatchGlob(glob, value string) bool {
+	return regexp.MustCompile(GlobToRegexp(glob)).MatchString(value)
+}
+
+// a regular expression matching any of the path globs
+func PathGlobsRegexp(globs []string) string {
+	expressions := []string{}
+	for _, glob := range globs {
+		expressions = append(expressions, GlobToRegexp(glob))
+	}
+	return strings.Join(expressions, "|")
+}
+
+/*
+reports whether the proxy records a request path: it must match one of the
+include globs, when there are any, and none of the exclude globs
+*/
+func PathIsRecorded(path string, includePaths, excludePaths []string) bool {
+	if len(includePaths) != 0 && !regexp.MustCompile(PathGlobsRegexp(includePaths)).MatchString(path) {
+		return false
+	}
+
+	return len(excludePaths) == 0 || !regexp.MustCompile(PathGlobsRegexp(excludePaths)).MatchString(path)
+}
+
+func matchesRecordSpec(interaction map[string]interface{}, rules []RecordRule) bool {
+	request, _ := interaction["request"].(map[string]interface{})
+	method, _ := request["method"].(string)
+	path, _ := request["path"].(string)
+
+	for _, rule := range rules {
+		if (rule.Method == "*" || rule.Method == strings.ToUpper(method)) && MatchGlob(rule.Pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func filterInteractions(interactions []map[string]interface{}, rules []RecordRule) []map[string]interface{} {
+	if len(rules) == 0 {
+		return interactions
+	}
+
+	kept := []map[string]interface{}{}
+	for _, interaction := range interactions {
+		if matchesRecordSpec(interaction, rules) {
+			kept = append(kept, interaction)
+		}
+	}
+	return kept
+}
+
+// a --record-status value, either a status code (ex. 404) or a class of status codes (ex. 2xx)
+var recordStatus = regexp.MustCompile(`^[1-5]([0-9][0-9]|xx)$`)
+
+// checks that every --record-status value is a status code or a class of status codes
+func ValidateRecordStatuses(statuses []string) error {
+	for _, status := range statuses {
+		if !recordStatus.MatchString(strings.ToLower(strings.TrimSpace(status))) {
+			return errors.New("--record-status must be a status code (ex. 404) or a class of status codes (ex. 2xx), --record-status was " + status)
+		}
+	}
+	return nil
+}
+
+// reports whether a response status matches any of the --record-status values
+func StatusIsRecorded(status int, statuses []string) bool {
+	code := strconv.Itoa(status)
+	for _, pattern := range statuses {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == code || (strings.HasSuffix(pattern, "xx") && pattern[0] == code[0] && len(code) == 3) {
+			return true
+		}
+	}
+	return false
+}
+
+// keeps the interactions whose recorded response status matches one of the statuses, or all of them when there are none
+func filterStatuses(interactions []map[string]interface{}, statuses []string) []map[string]interface{} {
+	if len(statuses) == 0 {
+		return interactions
+	}
+
+	kept := []map[string]interface{}{}
+	for _, interaction := range interactions {
+		response, _ := interaction["response"].(map[string]interface{})
+		status, _ := response["status"].(float64)
+		if StatusIsRecorded(int(status), statuses) {
+			kept = append(kept, interaction)
+		}
+	}
+	return kept
+}
+
+/*
+leaves out interactions which are identical to one recorded before them, such
+as a request that the consumer made more than once. Interactions are compared
+by their method, path, query, headers, and bodies, along with the response.
+Returns the interactions that are kept, and how many were left out.
+*/
+func dedupeInteractions(interactions []map[string]interface{}) ([]map[string]interface{}, int) {
+	seen := map[string]bool{}
+	kept := []map[string]interface{}{}
+	for _, interaction := range interactions {
+		// maps are marshalled with sorted keys, so identical interactions marshal the same
+		interactionBytes, err := json.Marshal(interaction)
+		if err == nil && seen[string(interactionBytes)] {
+			continue
+		}
+
+		seen[string(interactionBytes)] = true
+		kept = append(kept, interaction)
+	}
+	return kept, len(interactions) - len(kept)
+}
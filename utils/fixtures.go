@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+loads every .json file in a fixture directory. Each fixture names the
+interactions it applies to with a method (or "*") and a path pattern, and
+holds the request and/or response body to use in place of the recorded one.
+*/
+func LoadFixtures(fixtureDir string) ([]Fixture, error) {
+	fixtures := []Fixture{}
+
+	err := filepath.WalkDir(fixtureDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || filepath.Ext(path) != ".json" {
+			return err
+		}
+
+		fixtureBytes, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var fixture Fixture
+		err = json.Unmarshal(fixtureBytes, &fixture)
+		if err != nil {
+			return errors.New("could not parse fixture " + path + ": " + err.Error())
+		}
+
+		if len(fixture.Method) == 0 || !strings.HasPrefix(fixture.Path, "/") {
+			return errors.New("fixture " + path + " must have a method and a path pattern (ex. \"method\": \"GET\", \"path\": \"/users/*\")")
+		}
+
+		fixture.Method = strings.ToUpper(fixture.Method)
+		fixtures = append(fixtures, fixture)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("could not read --fixture-dir: " + err.Error())
+	}
+
+	if len(fixtures) == 0 {
+		return nil, errors.New("--fixture-dir " + fixtureDir + " does not contain any .json fixtures")
+	}
+
+	return fixtures, nil
+}
+
+/*
+replaces the bodies of each interaction that matches a fixture with the
+fixture's bodies, and returns how many interactions were substituted. The
+first matching fixture, in file name order, is used.
+*/
+func substituteFixtures(interactions []map[string]interface{}, fixtures []Fixture) int {
+	substituted := 0
+
+	for _, interaction := range interactions {
+		request, _ := interaction["request"].(map[string]interface{})
+		response, _ := interaction["response"].(map[string]interface{})
+		method, _ := request["method"].(string)
+		path, _ := request["path"].(string)
+
+		for _, fixture := range fixtures {
+			if (fixture.Method != "*" && fixture.Method != strings.ToUpper(method)) || !MatchGlob(fixture.Path, path) {
+				continue
+			}
+
+			if body, ok := fixture.Request["body"]; ok && request != nil {
+				request["body"] = body
+			}
+
+			if body, ok := fixture.Response["body"]; ok && response != nil {
+				response["body"] = body
+			}
+
+			substituted++
+			break
+		}
+	}
+
+	return substituted
+}
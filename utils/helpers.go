@@ -1,14 +1,32 @@
 package utils
 
 import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/fs"
+	"math/big"
+	"mime"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/htmlindex"
+	"gopkg.in/yaml.v2"
 
 	client "github.com/signet-framework/signet-cli/client"
 )
@@ -24,12 +42,73 @@ func ValidType(serviceType string) error {
 	return nil
 }
 
+/*
+the format of a contract or spec file from its extension, or for a file
+without an extension, from its first non-whitespace byte. The format is empty
+for any other extension.
+*/
+func fileFormat(path string, fileBytes []byte) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".graphql", ".gql":
+		return "graphql"
+	case "":
+		trimmed := bytes.TrimSpace(fileBytes)
+		if len(trimmed) != 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+			return "json"
+		}
+		return "yaml"
+	}
+	return ""
+}
+
+/*
+converts a YAML document to JSON. YAML maps are decoded with interface{}
+keys, which are converted to strings so that the result can be marshalled.
+*/
+func yamlToJSON(yamlBytes []byte) ([]byte, error) {
+	var document interface{}
+	err := yaml.Unmarshal(yamlBytes, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(stringKeys(document))
+}
+
+func stringKeys(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for key, nested := range typed {
+			converted[fmt.Sprint(key)] = stringKeys(nested)
+		}
+		return converted
+	case []interface{}:
+		for i, nested := range typed {
+			typed[i] = stringKeys(nested)
+		}
+	}
+	return value
+}
+
+// consumer contracts are JSON, unless they are YAML by extension or content
 func LoadContract(path string) (contract Pact, err error) {
-	contractBytes, err := os.ReadFile(path)
+	contractBytes, err := readContractFile(path)
 	if err != nil {
 		return Pact{}, err
 	}
 
+	if fileFormat(path, contractBytes) == "yaml" {
+		contractBytes, err = yamlToJSON(contractBytes)
+		if err != nil {
+			return Pact{}, err
+		}
+	}
+
 	err = json.Unmarshal(contractBytes, &contract)
 	if err != nil {
 		return Pact{}, err
@@ -37,22 +116,58 @@ func LoadContract(path string) (contract Pact, err error) {
 	return
 }
 
-func LoadSpec(path string) (spec interface{}, format string, err error) {
-	format = path[len(path)-4:]
-	if format != "json" && format != "yaml" && format != ".yml" {
-		return nil, "", errors.New("spec must be either JSON or YAML")
-	}
+// the path of a contract or spec that is read from standard input
+const StdinPath = "-"
+
+// the contract or spec read from standard input, which is used for StdinPath
+var StdinContents []byte
 
-	if format == ".yml" {
-		format = "yaml"
+/*
+reads a contract or spec file, or StdinContents when the path is StdinPath.
+A contract read from stdin has no file extension, so it is parsed as JSON when
+it starts with { or [, and as YAML otherwise.
+*/
+func readContractFile(path string) ([]byte, error) {
+	if path == StdinPath {
+		return StdinContents, nil
 	}
+	return os.ReadFile(path)
+}
+
+// YAML specs are sent to the broker as the raw YAML text, rather than re-marshalled as JSON
+func LoadSpec(path string) (spec interface{}, format string, err error) {
+	return LoadSpecAs(path, "")
+}
 
-	specBytes, err := os.ReadFile(path)
+/*
+loads a spec of the given contract type, either "openapi", "graphql", or
+"asyncapi". When the contract type is empty, a .graphql or .gql file is a
+GraphQL schema, a document with a top level asyncapi key is an AsyncAPI spec,
+and any other file is an OpenAPI spec. GraphQL schemas are sent as the SDL text.
+*/
+func LoadSpecAs(path string, contractType string) (spec interface{}, format string, err error) {
+	specBytes, err := readContractFile(path)
 	if err != nil {
 		return nil, "", err
 	}
 
-	if format == "json" {
+	format = fileFormat(path, specBytes)
+	if contractType == "graphql" {
+		format = "graphql"
+	} else if (contractType == "openapi" || contractType == "asyncapi") && format == "graphql" {
+		format = fileFormat("", specBytes)
+	}
+
+	if len(format) == 0 {
+		return nil, "", errors.New("spec must be either JSON, YAML, or a GraphQL schema")
+	}
+
+	if format == "graphql" {
+		if len(bytes.TrimSpace(specBytes)) == 0 {
+			return nil, "", errors.New("the GraphQL schema at " + path + " is empty")
+		}
+		spec = string(specBytes)
+	} else if format == "json" {
 		err = json.Unmarshal(specBytes, &spec)
 	} else {
 		spec = string(specBytes)
@@ -62,16 +177,153 @@ func LoadSpec(path string) (spec interface{}, format string, err error) {
 		return nil, "", err
 	}
 
+	if format != "graphql" && (contractType == "asyncapi" || (len(contractType) == 0 && IsAsyncAPI(specBytes))) {
+		format = "asyncapi"
+	}
+
 	return
 }
 
-func CreateConsumerRequestBody(contract Pact, consumerName string, consumerVersion string, consumerBranch string) ([]byte, error) {
+// whether a JSON or YAML spec is an AsyncAPI document, which has a top level asyncapi key
+func IsAsyncAPI(specBytes []byte) bool {
+	var err error
+	if fileFormat("", specBytes) == "yaml" {
+		specBytes, err = yamlToJSON(specBytes)
+		if err != nil {
+			return false
+		}
+	}
+
+	var root map[string]interface{}
+	err = json.Unmarshal(specBytes, &root)
+	if err != nil {
+		return false
+	}
+
+	_, ok := root["asyncapi"]
+	return ok
+}
+
+var graphQLDefinition = regexp.MustCompile(`(?m)^\s*(schema|type|interface|union|enum|input|scalar|directive|extend)\b`)
+
+// whether a spec is a GraphQL schema, which is SDL text rather than a JSON or YAML object
+func IsGraphQL(specBytes []byte) bool {
+	if !graphQLDefinition.Match(specBytes) {
+		return false
+	}
+
+	var err error
+	if fileFormat("", specBytes) == "yaml" {
+		specBytes, err = yamlToJSON(specBytes)
+		if err != nil {
+			return true
+		}
+	}
+
+	var root map[string]interface{}
+	return json.Unmarshal(specBytes, &root) != nil
+}
+
+// the size in bytes of a spec as it is sent to the broker
+func SpecSize(path string, contractType string) (int, error) {
+	spec, _, err := LoadSpecAs(path, contractType)
+	if err != nil {
+		return 0, err
+	}
+
+	specBytes, err := json.Marshal(spec)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(specBytes), nil
+}
+
+// reads an Ed25519 public key from a PEM file, as written by openssl pkey -pubout
+func LoadSigningKey(path string) (ed25519.PublicKey, error) {
+	keyBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("could not read --signing-key: " + err.Error())
+	}
+
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, errors.New("--signing-key " + path + " is not a PEM encoded public key")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("--signing-key " + path + " is not a PEM encoded public key: " + err.Error())
+	}
+
+	signingKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("--signing-key " + path + " must be an Ed25519 public key")
+	}
+
+	return signingKey, nil
+}
+
+// checks that a file is a PEM bundle with at least one CA certificate
+func ValidCACertBundle(path string) error {
+	bundle, err := os.ReadFile(path)
+	if err != nil {
+		return errors.New("could not read --ca-cert: " + err.Error())
+	}
+
+	if !x509.NewCertPool().AppendCertsFromPEM(bundle) {
+		return errors.New("--ca-cert " + path + " does not contain any PEM encoded certificates")
+	}
+
+	return nil
+}
+
+// the version used when --version is not passed
+const versionEnvVar = "SIGNET_VERSION"
+
+// the branch used when there is no git repository to default it from
+const branchEnvVar = "SIGNET_BRANCH"
 
-	requestBody := ConsumerBody{
-		Contract:        contract,
-		ConsumerName:    consumerName,
-		ConsumerVersion: consumerVersion,
-		ConsumerBranch:  consumerBranch,
+// CI systems check out a detached HEAD, and name the branch in one of these instead
+var ciBranchEnvVars = []string{"CI_COMMIT_BRANCH", "GITHUB_HEAD_REF", "GITHUB_REF_NAME"}
+
+// how many characters of the git SHA of HEAD a defaulted version has
+var SHALength = 10
+
+// the contract schema versions that this version of the CLI can publish
+var SupportedSchemaVersions = []int{1, 2}
+
+func CreateConsumerRequestBody(contract Pact, consumerName string, consumerVersion string, consumerBranch string, options PublishOptions) ([]byte, error) {
+	var requestBody interface{}
+	ttl := int64(options.TTL.Seconds())
+	pactSpecification, _ := PactSpecificationVersion(contract)
+
+	switch options.SchemaVersion {
+	case 1:
+		requestBody = ConsumerBody{
+			Contract:          contract,
+			ConsumerName:      consumerName,
+			ConsumerVersion:   consumerVersion,
+			ConsumerBranch:    consumerBranch,
+			Tags:              options.Tags,
+			TTL:               ttl,
+			PactSpecification: pactSpecification,
+		}
+	case 2:
+		requestBody = ConsumerBodyV2{
+			SchemaVersion: options.SchemaVersion,
+			Contract:      contract,
+			Consumer: ParticipantVersion{
+				Name:    consumerName,
+				Version: consumerVersion,
+				Branch:  consumerBranch,
+				Tags:    options.Tags,
+			},
+			TTL:               ttl,
+			PactSpecification: pactSpecification,
+		}
+	default:
+		return nil, fmt.Errorf("contract schema version %d is not supported, supported versions are %v", options.SchemaVersion, SupportedSchemaVersions)
 	}
 
 	jsonData, err := json.Marshal(requestBody)
@@ -82,13 +334,14 @@ func CreateConsumerRequestBody(contract Pact, consumerName string, consumerVersi
 	return jsonData, nil
 }
 
-func CreateProviderRequestBody(spec interface{}, providerName string, providerVersion string, providerBranch string, specFormat string) ([]byte, error) {
+func CreateProviderRequestBody(spec interface{}, providerName string, providerVersion string, providerBranch string, specFormat string, environment string) ([]byte, error) {
 	requestBody := ProviderBody{
 		Spec:            spec,
 		ProviderName:    providerName,
 		ProviderVersion: providerVersion,
 		ProviderBranch:  providerBranch,
 		SpecFormat:      specFormat,
+		EnvironmentName: environment,
 	}
 
 	jsonData, err := json.Marshal(requestBody)
@@ -99,11 +352,34 @@ func CreateProviderRequestBody(spec interface{}, providerName string, providerVe
 	return jsonData, nil
 }
 
+/*
+resolves the version that a service is published, verified, or deployed as.
+A version that was passed wins, except "auto" which asks for the git SHA of
+HEAD. A version that was not passed defaults to SIGNET_VERSION, and then to
+the git SHA of HEAD.
+*/
+func ResolveVersion(version string, passed bool) (string, error) {
+	if passed && version != "" && version != "auto" {
+		return version, nil
+	}
+
+	if envVersion := strings.TrimSpace(os.Getenv(versionEnvVar)); !passed && len(envVersion) != 0 {
+		return envVersion, nil
+	}
+
+	return SetVersionToGitSha(version)
+}
+
+/*
+defaults the version to the git SHA of HEAD. Outside a git repository, such as
+a Docker build context without .git, the version falls back to the current UTC
+time, with a warning instead of an error.
+*/
 func SetVersionToGitSha(version string) (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--short=10", "HEAD")
+	cmd := exec.Command("git", "rev-parse", "--short="+strconv.Itoa(SHALength), "HEAD")
 	gitSHA, err := cmd.Output()
 	if err != nil {
-		return "", errors.New("because this directory is not a git repository, --version cannot default to git commit SHA. --version must be set for this command.")
+		return fallbackVersion(), nil
 	}
 	if len(gitSHA) != 0 {
 		gitSHA = gitSHA[:len(gitSHA)-1]
@@ -112,53 +388,201 @@ func SetVersionToGitSha(version string) (string, error) {
 	return string(gitSHA), nil
 }
 
+func fallbackVersion() string {
+	version := "build-" + time.Now().UTC().Format("20060102T150405Z")
+	fmt.Fprintln(os.Stderr, "Warning - this directory is not a git repository, so the version defaults to "+version+". Set --version or "+versionEnvVar+" for a reproducible version.")
+	return version
+}
+
+// like the version, the branch falls back to SIGNET_BRANCH outside a git repository, and is otherwise left unset
 func SetBranchToCurrentGit(branch string) (string, error) {
 	cmd := exec.Command("git", "branch", "--show-current")
 	currentBranch, err := cmd.Output()
 	if err != nil {
-		return "", errors.New("because this directory is not a git repository, --branch cannot default to current git branch")
+		branch = os.Getenv(branchEnvVar)
+		if len(branch) != 0 {
+			fmt.Fprintln(os.Stderr, "Warning - this directory is not a git repository, so the branch defaults to "+branchEnvVar+" ("+branch+") instead of the git branch of HEAD")
+		} else {
+			fmt.Fprintln(os.Stderr, "Warning - this directory is not a git repository and "+branchEnvVar+" is not set, so no branch is sent")
+		}
+		return branch, nil
 	}
 	if len(currentBranch) != 0 {
 		currentBranch = currentBranch[:len(currentBranch)-1]
 	}
 
+	if len(currentBranch) == 0 {
+		return detachedHeadBranch(), nil
+	}
+
 	return string(currentBranch), nil
 }
 
-func PublishConsumer(path string, brokerURL string, version, branch string) error {
+// a detached HEAD is on no branch, so the branch is read from the CI environment
+func detachedHeadBranch() string {
+	for _, envVar := range ciBranchEnvVars {
+		if branch := os.Getenv(envVar); len(branch) != 0 {
+			return branch
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "Warning - HEAD is detached and none of "+strings.Join(ciBranchEnvVars, ", ")+" are set, so no branch is sent. Set --branch to send one.")
+	return ""
+}
+
+// walks up from dir to the nearest directory containing .git
+func FindGitRoot(dir string) (string, error) {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("because this directory is not in a git repository, paths cannot be relative to the git root")
+		}
+		dir = parent
+	}
+}
+
+/*
+reports whether any of the paths changed since a git ref, according to
+git diff --name-only. Untracked files under the paths count as changed.
+*/
+func ChangedSince(ref string, paths []string) (bool, error) {
+	diffArgs := append([]string{"diff", "--name-only", ref, "--"}, paths...)
+	changed, err := exec.Command("git", diffArgs...).Output()
+	if err != nil {
+		return false, errors.New("could not diff against --changed-since " + ref + ", it must be a git ref in this repository")
+	}
+
+	untrackedArgs := append([]string{"ls-files", "--others", "--exclude-standard", "--"}, paths...)
+	untracked, err := exec.Command("git", untrackedArgs...).Output()
+	if err != nil {
+		return false, err
+	}
+
+	return len(strings.TrimSpace(string(changed))+strings.TrimSpace(string(untracked))) != 0, nil
+}
+
+func PublishConsumer(ctx context.Context, path string, brokerURL string, version, branch string, options PublishOptions) error {
+	requestBody, err := PrepareConsumerRequest(ctx, path, brokerURL, version, branch, options)
+	if err != nil {
+		return err
+	}
+
+	err = client.PublishToBroker(ctx, brokerURL+"/api/contracts", requestBody)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+builds the request body that PublishConsumer sends, without sending it. A
+SchemaVersion of 0 publishes with the highest contract schema version that
+both the CLI and the broker support. A TTL is left out when the broker cannot
+expire contracts. With DryRun, the broker is never contacted, so a
+SchemaVersion of 0 is the highest version the CLI supports, and a TTL is
+always kept.
+*/
+func PrepareConsumerRequest(ctx context.Context, path string, brokerURL string, version, branch string, options PublishOptions) ([]byte, error) {
 	if branch == "auto" || (branch == "" && (version == "auto" || version == "")) {
 		var err error
 		branch, err = SetBranchToCurrentGit(branch)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	if version == "" || version == "auto" {
-		var err error
-		version, err = SetVersionToGitSha(version)
-		if err != nil {
-			return err
-		}
+	version, err := ResolveVersion(version, len(version) != 0)
+	if err != nil {
+		return nil, err
 	}
 
 	contract, err := LoadContract(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	consumerName := contract.Consumer.Name
 
+	if !options.SkipValidation {
+		err = ValidatePact(contract)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if len(consumerName) == 0 {
-		return errors.New("consumer contract does not have a consumer name")
+		return nil, errors.New("consumer contract does not have a consumer name")
 	}
 
-	requestBody, err := CreateConsumerRequestBody(contract, consumerName, version, branch)
+	if options.SchemaVersion == 0 && options.DryRun {
+		options.SchemaVersion = highestSchemaVersion()
+	} else if options.SchemaVersion == 0 {
+		options.SchemaVersion, err = client.NegotiateSchemaVersion(ctx, brokerURL, SupportedSchemaVersions)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.TTL > 0 && !options.DryRun {
+		capabilities, err := client.GetCapabilities(ctx, brokerURL)
+		if err != nil {
+			return nil, err
+		}
+
+		if !capabilities.ContractTTL {
+			fmt.Println("Warning - the Signet broker does not support contract TTLs, the contract is published without one")
+			options.TTL = 0
+		}
+	}
+
+	return CreateConsumerRequestBody(contract, consumerName, version, branch, options)
+}
+
+// the highest contract schema version that this version of the CLI can publish
+func highestSchemaVersion() int {
+	highest := 0
+	for _, schemaVersion := range SupportedSchemaVersions {
+		if schemaVersion > highest {
+			highest = schemaVersion
+		}
+	}
+	return highest
+}
+
+/*
+a new idempotency key for one attempt to record a deployment. Every retry of
+the request sends the same key, while a later deploy, undeploy, or redeploy of
+the same version gets a key of its own, so the broker never mistakes it for
+one it has already recorded.
+*/
+func NewDeploymentIdempotencyKey(deployment DeploymentBody) (string, error) {
+	nonce := make([]byte, 16)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	key := strings.Join([]string{deployment.EnvironmentName, deployment.ParticipantName, deployment.ParticipantVersion, strconv.FormatBool(deployment.Deployed), hex.EncodeToString(nonce)}, "\x00")
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+/*
+an environment is only sent with verification results from test, so that the
+broker can associate the verification with the environment it was run in
+*/
+func PublishProvider(ctx context.Context, path string, brokerURL string, ProviderName, version, branch, environment string) error {
+	requestBody, err := PrepareProviderRequest(path, "", ProviderName, version, branch, environment)
 	if err != nil {
 		return err
 	}
 
-	err = client.PublishToBroker(brokerURL+"/api/contracts", requestBody)
+	err = client.PublishToBroker(ctx, brokerURL+"/api/specs", requestBody)
 	if err != nil {
 		return err
 	}
@@ -166,16 +590,17 @@ func PublishConsumer(path string, brokerURL string, version, branch string) erro
 	return nil
 }
 
-func PublishProvider(path string, brokerURL string, ProviderName, version, branch string) error {
+// builds the request body that PublishProvider sends, without sending it
+func PrepareProviderRequest(path string, contractType string, ProviderName, version, branch, environment string) ([]byte, error) {
 	if len(ProviderName) == 0 {
-		return errors.New("must set --name if --type is \"provider\"")
+		return nil, errors.New("must set --name if --type is \"provider\"")
 	}
 
 	if branch == "auto" || (branch == "" && version == "auto") {
 		var err error
 		branch, err = SetBranchToCurrentGit(branch)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
@@ -183,26 +608,30 @@ func PublishProvider(path string, brokerURL string, ProviderName, version, branc
 		var err error
 		version, err = SetVersionToGitSha(version)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	spec, specFormat, err := LoadSpec(path)
+	spec, specFormat, err := LoadSpecAs(path, contractType)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	requestBody, err := CreateProviderRequestBody(spec, ProviderName, version, branch, specFormat)
-	if err != nil {
-		return err
-	}
+	return CreateProviderRequestBody(spec, ProviderName, version, branch, specFormat, environment)
+}
 
-	err = client.PublishToBroker(brokerURL+"/api/specs", requestBody)
-	if err != nil {
-		return err
+/*
+reads the value of each named environment variable as a tag, skipping
+variables that are unset or empty
+*/
+func TagsFromEnv(envVars []string) []string {
+	tags := []string{}
+	for _, envVar := range envVars {
+		if value := strings.TrimSpace(os.Getenv(strings.TrimSpace(envVar))); len(value) != 0 {
+			tags = append(tags, value)
+		}
 	}
-
-	return nil
+	return tags
 }
 
 func SliceOutNodeWarnings(str string) string {
@@ -226,33 +655,114 @@ func GetNpmPkgRoot() (string, error) {
 	return pkgRoot, nil
 }
 
-func CreatePact(stubsPath string, pactPath string, consumerName string, providerName string) (error, bool) {
+func CreatePact(stubsPath string, pactPath string, consumerName string, providerName string, options PactOptions) (PactSummary, error) {
+	target := ProxyTarget{ProviderName: providerName, ContractPath: pactPath}
 
-	pact := CreateDefaultPact(pactPath, consumerName, providerName)
+	summaries, err := CreatePacts(stubsPath, consumerName, []ProxyTarget{target}, options)
+	if err != nil {
+		return PactSummary{ProviderName: providerName, Path: pactPath}, err
+	}
+
+	return summaries[0], nil
+}
+
+/*
+writes a contract for each proxy target from the recorded interactions. Each
+interaction belongs to the target with the longest path prefix that its
+request path starts with, so a target without a prefix gets every interaction
+that no other target claims. A target that is left with no interactions has
+no contract written.
+*/
+func CreatePacts(stubsPath string, consumerName string, targets []ProxyTarget, options PactOptions) ([]PactSummary, error) {
 	matchPaths, err := GetMatchPaths(stubsPath)
+	if err != nil {
+		return nil, err
+	}
 
+	interactions, err := createInteractions(matchPaths, options)
 	if err != nil {
-		return err, false
+		return nil, err
 	}
 
-	interactions, err := createInteractions(matchPaths)
-	pact["interactions"] = interactions
+	grouped := make([][]map[string]interface{}, len(targets))
+	for _, interaction := range interactions {
+		request, _ := interaction["request"].(map[string]interface{})
+		requestPath, _ := request["path"].(string)
 
-	if err != nil {
-		return err, false
+		// mountebank only passes excluded paths through, but any match it saved for them is left out too
+		if !PathIsRecorded(requestPath, options.IncludePaths, options.ExcludePaths) {
+			continue
+		}
+
+		if i := MatchProxyTarget(targets, requestPath); i != -1 {
+			grouped[i] = append(grouped[i], interaction)
+		}
 	}
 
-	if len(interactions) == 0 {
-		return nil, false
+	summaries := []PactSummary{}
+	for i, target := range targets {
+		summary := PactSummary{ProviderName: target.ProviderName, Path: target.ContractPath}
+
+		recorded, duplicates := dedupeInteractions(grouped[i])
+		summary.Duplicates = duplicates
+		summary.Recorded = len(recorded)
+		targetInteractions := filterInteractions(recorded, options.RecordSpec)
+		summary.Dropped = summary.Recorded - len(targetInteractions)
+		matched := len(targetInteractions)
+		targetInteractions = filterStatuses(targetInteractions, options.RecordStatuses)
+		summary.StatusDropped = matched - len(targetInteractions)
+		summary.Substituted = substituteFixtures(targetInteractions, options.Fixtures)
+
+		if len(targetInteractions) != 0 {
+			pact := CreateDefaultPact(target.ContractPath, consumerName, target.ProviderName)
+			pact["interactions"] = targetInteractions
+
+			err = WritePact(pact, target.ContractPath)
+			if err != nil {
+				return nil, err
+			}
+			summary.Written = true
+		}
+
+		summaries = append(summaries, summary)
 	}
 
-	err = WritePact(pact, pactPath)
+	return summaries, nil
+}
+
+// returns the index of the target with the longest path prefix of requestPath, or -1 if none match
+func MatchProxyTarget(targets []ProxyTarget, requestPath string) int {
+	matched := -1
+	for i, target := range targets {
+		if !pathHasPrefix(requestPath, target.PathPrefix) {
+			continue
+		}
 
-	if err != nil {
-		return err, false
+		if matched == -1 || len(target.PathPrefix) > len(targets[matched].PathPrefix) {
+			matched = i
+		}
+	}
+
+	return matched
+}
+
+/*
+whether requestPath is under prefix by whole path segments, so that the
+prefix /users matches /users and /users/1, but not /users-admin
+*/
+func pathHasPrefix(requestPath, prefix string) bool {
+	if len(prefix) == 0 || strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(requestPath, prefix)
 	}
+	return requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/")
+}
 
-	return nil, true
+// the regular expression that matches the same paths as a path prefix does in MatchProxyTarget
+func PathPrefixPattern(prefix string) string {
+	if strings.HasSuffix(prefix, "/") {
+		return "^" + regexp.QuoteMeta(prefix)
+	}
+	return "^" + regexp.QuoteMeta(prefix) + "(/|$)"
 }
 
 func GetMatchPaths(stubsPath string) ([]string, error) {
@@ -286,7 +796,7 @@ func CreatePactDir(pactDir string) error {
 	return err
 }
 
-func createInteractions(matchPaths []string) ([]map[string]interface{}, error) {
+func createInteractions(matchPaths []string, options PactOptions) ([]map[string]interface{}, error) {
 	interactions := []map[string]interface{}{}
 
 	for _, matchPath := range matchPaths {
@@ -331,10 +841,37 @@ func createInteractions(matchPaths []string) ([]map[string]interface{}, error) {
 			responseHeaders["Content-Type"] = responseContentType
 		}
 
+		scrubHeaders(requestHeaders, options.ScrubHeaders)
+		scrubHeaders(responseHeaders, options.ScrubHeaders)
+
+		if state := providerState(request["headers"].(map[string]any), options.ProviderState); len(state) != 0 {
+			interaction["providerStates"] = []interface{}{map[string]interface{}{"name": state}}
+		}
+
+		requestBody, requestCharset, err := decodeBody(request, requestContentType, options.Encoding)
+		if err != nil {
+			return []map[string]interface{}{}, err
+		}
+
+		responseBody, responseCharset, err := decodeBody(response, responseContentType, options.Encoding)
+		if err != nil {
+			return []map[string]interface{}{}, err
+		}
+
+		var streaming map[string]interface{}
+		if isStreamingResponse(response) {
+			responseBody, streaming = streamedBody(response, responseBody, len(responseCharset) != 0, options.MaxBodySize)
+		}
+
+		if options.NormalizeNumbers {
+			requestBody = normalizeNumbers(requestBody)
+			responseBody = normalizeNumbers(responseBody)
+		}
+
 		interaction["request"] = map[string]interface{}{
 			"method":  request["method"],
 			"path":    request["path"],
-			"body":    request["body"],
+			"body":    requestBody,
 			"query":   request["query"],
 			"headers": requestHeaders,
 		}
@@ -342,7 +879,26 @@ func createInteractions(matchPaths []string) ([]map[string]interface{}, error) {
 		interaction["response"] = map[string]interface{}{
 			"status":  response["statusCode"],
 			"headers": responseHeaders,
-			"body":    response["body"],
+			"body":    responseBody,
+		}
+
+		if len(requestCharset) != 0 && requestHeaders["Content-Type"] != nil {
+			interaction["request"].(map[string]interface{})["matchingRules"] = charsetMatchingRule(requestCharset)
+		}
+
+		if len(responseCharset) != 0 && responseHeaders["Content-Type"] != nil {
+			interaction["response"].(map[string]interface{})["matchingRules"] = charsetMatchingRule(responseCharset)
+		}
+
+		if streaming != nil {
+			interaction["streaming"] = streaming
+		}
+
+		if trailers, ok := response["trailers"].(map[string]interface{}); options.RecordTrailers && ok {
+			scrubHeaders(trailers, options.ScrubHeaders)
+			if len(trailers) != 0 {
+				addTrailers(interaction["response"].(map[string]interface{}), trailers)
+			}
 		}
 
 		interactions = append(interactions, interaction)
@@ -350,6 +906,301 @@ func createInteractions(matchPaths []string) ([]map[string]interface{}, error) {
 	return interactions, nil
 }
 
+var streamingContentTypes = []string{"text/event-stream", "application/x-ndjson", "application/stream+json"}
+
+// responses sent with chunked transfer encoding, or with a streaming media type such as server-sent events
+func isStreamingResponse(response map[string]interface{}) bool {
+	headers, _ := response["headers"].(map[string]interface{})
+	if strings.EqualFold(fmt.Sprint(headerValue(headers, "Transfer-Encoding")), "chunked") {
+		return true
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(fmt.Sprint(headerValue(headers, "Content-Type")))
+	for _, streamingType := range streamingContentTypes {
+		if mediaType == streamingType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func headerValue(headers map[string]interface{}, name string) interface{} {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return nil
+}
+
+/*
+returns the full text of a streamed response body, capped at maxBodySize
+bytes, along with the metadata that marks the interaction as streamed.
+Mountebank records streams it does not recognise as text in binary mode,
+so those bodies are decoded from base64 when they hold UTF-8 text. A capped
+server-sent events body is cut after its last complete event.
+*/
+func streamedBody(response map[string]interface{}, body interface{}, charsetDecoded bool, maxBodySize int) (interface{}, map[string]interface{}) {
+	headers, _ := response["headers"].(map[string]interface{})
+	streaming := map[string]interface{}{"truncated": false}
+	if transferEncoding := headerValue(headers, "Transfer-Encoding"); transferEncoding != nil {
+		streaming["transferEncoding"] = transferEncoding
+	}
+
+	text, ok := body.(string)
+	if !ok {
+		return body, streaming
+	}
+
+	if response["_mode"] == "binary" && !charsetDecoded {
+		if raw, err := base64.StdEncoding.DecodeString(text); err == nil && utf8.Valid(raw) {
+			text = string(raw)
+		}
+	}
+	streaming["size"] = len(text)
+
+	if maxBodySize > 0 && len(text) > maxBodySize {
+		capped := text[:maxBodySize]
+		for !utf8.ValidString(capped) {
+			capped = capped[:len(capped)-1]
+		}
+
+		mediaType, _, _ := mime.ParseMediaType(fmt.Sprint(headerValue(headers, "Content-Type")))
+		if lastEvent := strings.LastIndex(capped, "\n\n"); mediaType == "text/event-stream" && lastEvent != -1 {
+			capped = capped[:lastEvent+2]
+		}
+
+		text = capped
+		streaming["truncated"] = true
+	}
+
+	return text, streaming
+}
+
+/*
+rewrites the numbers in a recorded JSON body to a canonical form, so that
+integral values such as 1.0 are written as 1. Bodies which are not JSON are
+returned unchanged, and JSON bodies are re-serialized with sorted keys.
+*/
+func normalizeNumbers(body interface{}) interface{} {
+	text, ok := body.(string)
+	if !ok {
+		return body
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+
+	var parsed interface{}
+	if err := decoder.Decode(&parsed); err != nil || decoder.More() {
+		return body
+	}
+
+	var normalized strings.Builder
+	encoder := json.NewEncoder(&normalized)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(canonicalNumbers(parsed)); err != nil {
+		return body
+	}
+
+	return strings.TrimSuffix(normalized.String(), "\n")
+}
+
+// the bits of precision that decimal and exponent numbers are compared with, enough for any float64 or int64 to be exact
+const canonicalNumberPrecision = 256
+
+func canonicalNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			v[key] = canonicalNumbers(child)
+		}
+	case []interface{}:
+		for i, child := range v {
+			v[i] = canonicalNumbers(child)
+		}
+	case json.Number:
+		return canonicalNumber(v)
+	}
+
+	return value
+}
+
+/*
+integer literals are left as they are, so that IDs and amounts too large for a
+float64 are not changed. Decimal and exponent forms are rewritten as the
+shortest decimal with the same value, or as an integer when they are integral.
+*/
+func canonicalNumber(number json.Number) json.Number {
+	literal := number.String()
+	if !strings.ContainsAny(literal, ".eE") {
+		return number
+	}
+
+	f, ok := new(big.Float).SetPrec(canonicalNumberPrecision).SetString(literal)
+	if !ok || f.IsInf() {
+		return number
+	}
+
+	// an integral value with a huge exponent (ex. 1e100000) is written in its exponent form, rather than in full
+	if f.IsInt() && f.MantExp(nil) <= canonicalNumberPrecision {
+		integer, _ := f.Int(nil)
+		return json.Number(integer.String())
+	}
+	return json.Number(f.Text('g', -1))
+}
+
+/*
+converts a recorded body from a non-UTF-8 charset to UTF-8. The charset is the
+--contract-encoding option when set, otherwise the charset of the Content-Type
+header. Bodies mountebank recorded in binary mode are base64 encoded, text mode
+bodies can only be recovered when they were not already decoded as UTF-8.
+Returns the body and the original charset when a conversion happened.
+*/
+func decodeBody(message map[string]interface{}, contentType interface{}, encoding string) (interface{}, string, error) {
+	body, ok := message["body"].(string)
+	if !ok || len(body) == 0 {
+		return message["body"], "", nil
+	}
+
+	charset := encoding
+	if len(charset) == 0 {
+		if contentTypeStr, ok := contentType.(string); ok {
+			_, params, err := mime.ParseMediaType(contentTypeStr)
+			if err == nil {
+				charset = params["charset"]
+			}
+		}
+	}
+
+	if len(charset) == 0 || IsUTF8Charset(charset) {
+		return body, "", nil
+	}
+
+	var raw []byte
+	if message["_mode"] == "binary" {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, "", err
+		}
+		raw = decoded
+	} else if !utf8.ValidString(body) {
+		raw = []byte(body)
+	} else {
+		return body, "", nil
+	}
+
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, "", errors.New("unsupported contract encoding: " + charset)
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode body from %s: %v", charset, err)
+	}
+
+	return string(decoded), charset, nil
+}
+
+func IsUTF8Charset(charset string) bool {
+	charset = strings.ToLower(charset)
+	return charset == "utf-8" || charset == "utf8"
+}
+
+func ValidEncoding(encoding string) error {
+	if len(encoding) == 0 || IsUTF8Charset(encoding) {
+		return nil
+	}
+
+	if _, err := htmlindex.Get(encoding); err != nil {
+		return errors.New("--contract-encoding " + encoding + " is not a supported charset")
+	}
+	return nil
+}
+
+// keeps the expectation that the body is served in its original charset
+func charsetMatchingRule(charset string) map[string]interface{} {
+	return map[string]interface{}{
+		"header": map[string]interface{}{
+			"Content-Type": map[string]interface{}{
+				"matchers": []interface{}{
+					map[string]interface{}{
+						"match": "regex",
+						"regex": "(?i).*charset=" + regexp.QuoteMeta(charset) + ".*",
+					},
+				},
+			},
+		},
+	}
+}
+
+/*
+headers that change on every request or carry credentials, which are never
+written to a contract
+*/
+var DefaultScrubHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "Date", "X-Request-Id", "X-Api-Key"}
+
+/*
+the header that a consumer sends with a request while it is being recorded to
+set the provider state of the interaction, ex. "user 1 exists"
+*/
+const ProviderStateHeader = "X-Signet-Provider-State"
+
+// the provider state sent in the ProviderStateHeader of a request, or the default state
+func providerState(requestHeaders map[string]interface{}, defaultState string) string {
+	for header, value := range requestHeaders {
+		if state, ok := value.(string); ok && strings.EqualFold(header, ProviderStateHeader) && len(strings.TrimSpace(state)) != 0 {
+			return strings.TrimSpace(state)
+		}
+	}
+
+	return defaultState
+}
+
+// removes the headers named in the denylist, whatever their case
+func scrubHeaders(headers map[string]interface{}, denylist []string) {
+	for header := range headers {
+		for _, denied := range denylist {
+			if strings.EqualFold(header, denied) {
+				delete(headers, header)
+				break
+			}
+		}
+	}
+}
+
+/*
+adds recorded HTTP trailers (ex. the grpc-status of a gRPC-Web response) to
+an interaction's response. Trailer values must match exactly, except for
+grpc-message which is free text and only has to be present.
+*/
+func addTrailers(response map[string]interface{}, trailers map[string]interface{}) {
+	trailerRules := map[string]interface{}{}
+	for trailer := range trailers {
+		match := "equality"
+		if strings.EqualFold(trailer, "grpc-message") {
+			match = "type"
+		}
+
+		trailerRules[trailer] = map[string]interface{}{
+			"matchers": []interface{}{
+				map[string]interface{}{"match": match},
+			},
+		}
+	}
+
+	matchingRules, _ := response["matchingRules"].(map[string]interface{})
+	if matchingRules == nil {
+		matchingRules = map[string]interface{}{}
+	}
+	matchingRules["trailers"] = trailerRules
+
+	response["trailers"] = trailers
+	response["matchingRules"] = matchingRules
+}
+
 func CreateDefaultPact(pactPath string, consumerName string, providerName string) (contract map[string]interface{}) {
 	return map[string]interface{}{
 		"consumer": map[string]interface{}{
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+/*
+replaces the stubs of a running imposter with only its proxy stubs, which
+discards the stubs and matches that mountebank recorded, and returns the
+number of recorded interactions that were cleared
+*/
+func ResetImposterStubs(adminURL, port string) (int, error) {
+	imposterURL := adminURL + "/imposters/" + port
+
+	resp, err := http.Get(imposterURL)
+	if err != nil {
+		return 0, errors.New("could not reach mountebank, is signet proxy running? " + err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, errors.New("no signet proxy is running on port " + port + ": " + resp.Status)
+	}
+
+	var imposter struct {
+		Stubs []map[string]interface{} `json:"stubs"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&imposter)
+	if err != nil {
+		return 0, err
+	}
+
+	cleared := 0
+	proxyStubs := []map[string]interface{}{}
+	for _, stub := range imposter.Stubs {
+		matches, _ := stub["matches"].([]interface{})
+		cleared += len(matches)
+
+		if isProxyStub(stub) {
+			delete(stub, "matches")
+			delete(stub, "_links")
+			proxyStubs = append(proxyStubs, stub)
+		}
+	}
+
+	reqBody, err := json.Marshal(map[string]interface{}{"stubs": proxyStubs})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, imposterURL+"/stubs", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	putResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer putResp.Body.Close()
+
+	if putResp.StatusCode != http.StatusOK {
+		return 0, errors.New("mountebank failed to reset the recorded stubs: " + putResp.Status)
+	}
+
+	return cleared, nil
+}
+
+func isProxyStub(stub map[string]interface{}) bool {
+	responses, _ := stub["responses"].([]interface{})
+	for _, rawResponse := range responses {
+		if response, ok := rawResponse.(map[string]interface{}); ok && response["proxy"] != nil {
+			return true
+		}
+	}
+
+	return false
+}
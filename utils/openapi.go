@@ -0,0 +1,406 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var responseCode = regexp.MustCompile(`^([1-5][0-9][0-9]|[1-5]XX|default)$`)
+
+var operationMethods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true,
+	"options": true, "head": true, "patch": true, "trace": true,
+}
+
+var pathItemFields = map[string]bool{
+	"$ref": true, "summary": true, "description": true, "servers": true, "parameters": true,
+}
+
+/*
+an OpenAPI document that is not valid. Pointer is the JSON pointer to the
+node that is invalid (ex. /paths/~1users/get/responses).
+*/
+type SpecValidationError struct {
+	Pointer string
+	Message string
+}
+
+func (e *SpecValidationError) Error() string {
+	pointer := e.Pointer
+	if len(pointer) == 0 {
+		pointer = "/"
+	}
+	return "the API spec is not a valid OpenAPI document - " + e.Message + " at " + pointer
+}
+
+/*
+checks the structure of an OpenAPI 3 or Swagger 2.0 document in JSON or YAML:
+the required fields, the shape of paths, operations, parameters, and
+responses, and that local $refs resolve. Schemas themselves are not checked.
+*/
+func ValidateOpenAPI(specBytes []byte) error {
+	var err error
+	if fileFormat("", specBytes) == "yaml" {
+		specBytes, err = yamlToJSON(specBytes)
+		if err != nil {
+			return &SpecValidationError{Message: "it could not be parsed: " + err.Error()}
+		}
+	}
+
+	var document interface{}
+	err = json.Unmarshal(specBytes, &document)
+	if err != nil {
+		return &SpecValidationError{Message: "it could not be parsed: " + err.Error()}
+	}
+
+	root, ok := document.(map[string]interface{})
+	if !ok {
+		return &SpecValidationError{Message: "the document must be an object"}
+	}
+
+	v := openAPIValidator{root: root}
+	return v.validate()
+}
+
+/*
+validates the spec at path, unless it is a GraphQL schema. An AsyncAPI spec
+is only checked for its asyncapi key, which is missing when --contract-type
+asyncapi was passed for another kind of document.
+*/
+func ValidateSpecFile(path string, contractType string) error {
+	_, format, err := LoadSpecAs(path, contractType)
+	if err != nil {
+		return err
+	}
+
+	if format == "graphql" {
+		return nil
+	}
+
+	specBytes, err := readContractFile(path)
+	if err != nil {
+		return err
+	}
+
+	if format == "asyncapi" {
+		if !IsAsyncAPI(specBytes) {
+			return errors.New("the API spec is not a valid AsyncAPI document - asyncapi is required at /asyncapi")
+		}
+		return nil
+	}
+
+	return ValidateOpenAPI(specBytes)
+}
+
+type openAPIValidator struct {
+	root    map[string]interface{}
+	swagger bool
+}
+
+func (v *openAPIValidator) validate() error {
+	openapi, hasOpenAPI := versionString(v.root["openapi"])
+	swagger, hasSwagger := versionString(v.root["swagger"])
+	if _, ok := v.root["swagger"]; ok {
+		v.swagger = true
+		if !hasSwagger || swagger != "2.0" {
+			return &SpecValidationError{Pointer: "/swagger", Message: fmt.Sprintf("swagger must be \"2.0\", swagger was %v", v.root["swagger"])}
+		}
+	} else if _, ok := v.root["openapi"]; !ok {
+		return &SpecValidationError{Message: "openapi is required"}
+	} else if !hasOpenAPI || !strings.HasPrefix(openapi, "3.") {
+		return &SpecValidationError{Pointer: "/openapi", Message: fmt.Sprintf("openapi must be a 3.x version string, openapi was %v", v.root["openapi"])}
+	}
+
+	info, err := v.object(v.root, "", "info", true)
+	if err != nil {
+		return err
+	}
+	err = v.str(info, "/info", "title")
+	if err != nil {
+		return err
+	}
+	if _, ok := info["version"]; !ok {
+		return &SpecValidationError{Pointer: "/info", Message: "version is required"}
+	} else if _, ok := versionString(info["version"]); !ok {
+		return &SpecValidationError{Pointer: "/info/version", Message: "version must be a string"}
+	}
+
+	// an OpenAPI 3.1 document may have only webhooks or components
+	paths, err := v.object(v.root, "", "paths", !strings.HasPrefix(openapi, "3.1"))
+	if err != nil {
+		return err
+	}
+
+	for _, route := range sortedKeys(paths) {
+		pointer := "/paths/" + escapePointer(route)
+		if !strings.HasPrefix(route, "/") {
+			return &SpecValidationError{Pointer: pointer, Message: "path " + route + " must begin with /"}
+		}
+
+		err = v.validatePathItem(paths[route], pointer)
+		if err != nil {
+			return err
+		}
+	}
+
+	return v.validateRefs(v.root, "")
+}
+
+func (v *openAPIValidator) validatePathItem(node interface{}, pointer string) error {
+	pathItem, ok := node.(map[string]interface{})
+	if !ok {
+		return &SpecValidationError{Pointer: pointer, Message: "a path item must be an object"}
+	}
+
+	err := v.validateParameters(pathItem, pointer)
+	if err != nil {
+		return err
+	}
+
+	for _, field := range sortedKeys(pathItem) {
+		if pathItemFields[field] || strings.HasPrefix(field, "x-") {
+			continue
+		}
+
+		if !operationMethods[field] {
+			return &SpecValidationError{Pointer: pointer + "/" + escapePointer(field), Message: "unknown field " + field + " in a path item"}
+		}
+
+		err = v.validateOperation(pathItem[field], pointer+"/"+field)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *openAPIValidator) validateOperation(node interface{}, pointer string) error {
+	operation, ok := node.(map[string]interface{})
+	if !ok {
+		return &SpecValidationError{Pointer: pointer, Message: "an operation must be an object"}
+	}
+
+	err := v.validateParameters(operation, pointer)
+	if err != nil {
+		return err
+	}
+
+	responses, err := v.object(operation, pointer, "responses", true)
+	if err != nil {
+		return err
+	}
+
+	if len(responses) == 0 {
+		return &SpecValidationError{Pointer: pointer + "/responses", Message: "an operation must have at least one response"}
+	}
+
+	for _, code := range sortedKeys(responses) {
+		responsePointer := pointer + "/responses/" + escapePointer(code)
+		if strings.HasPrefix(code, "x-") {
+			continue
+		}
+
+		if !responseCode.MatchString(code) {
+			return &SpecValidationError{Pointer: responsePointer, Message: "response " + code + " must be an HTTP status code, a range such as 4XX, or default"}
+		}
+
+		response, ok := responses[code].(map[string]interface{})
+		if !ok {
+			return &SpecValidationError{Pointer: responsePointer, Message: "a response must be an object"}
+		}
+
+		if _, isRef := response["$ref"]; isRef {
+			continue
+		}
+
+		err = v.str(response, responsePointer, "description")
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *openAPIValidator) validateParameters(node map[string]interface{}, pointer string) error {
+	value, ok := node["parameters"]
+	if !ok {
+		return nil
+	}
+
+	parameters, ok := value.([]interface{})
+	if !ok {
+		return &SpecValidationError{Pointer: pointer + "/parameters", Message: "parameters must be an array"}
+	}
+
+	locations := []string{"query", "header", "path", "cookie"}
+	if v.swagger {
+		locations = []string{"query", "header", "path", "formData", "body"}
+	}
+
+	for i, item := range parameters {
+		parameterPointer := fmt.Sprintf("%s/parameters/%d", pointer, i)
+		parameter, ok := item.(map[string]interface{})
+		if !ok {
+			return &SpecValidationError{Pointer: parameterPointer, Message: "a parameter must be an object"}
+		}
+
+		if _, isRef := parameter["$ref"]; isRef {
+			continue
+		}
+
+		err := v.str(parameter, parameterPointer, "name")
+		if err != nil {
+			return err
+		}
+
+		err = v.str(parameter, parameterPointer, "in")
+		if err != nil {
+			return err
+		}
+
+		in := parameter["in"].(string)
+		known := false
+		for _, location := range locations {
+			known = known || in == location
+		}
+		if !known {
+			return &SpecValidationError{Pointer: parameterPointer + "/in", Message: "in must be one of " + strings.Join(locations, ", ") + ", in was " + in}
+		}
+
+		if in == "path" && parameter["required"] != true {
+			return &SpecValidationError{Pointer: parameterPointer + "/required", Message: "path parameter " + parameter["name"].(string) + " must be required"}
+		}
+	}
+
+	return nil
+}
+
+// every local $ref, anywhere in the document, must point to a node that exists
+func (v *openAPIValidator) validateRefs(node interface{}, pointer string) error {
+	switch typed := node.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(typed) {
+			nested := typed[key]
+			nestedPointer := pointer + "/" + escapePointer(key)
+
+			ref, isString := nested.(string)
+			if key == "$ref" && isString && strings.HasPrefix(ref, "#") {
+				if !v.resolves(strings.TrimPrefix(ref, "#")) {
+					return &SpecValidationError{Pointer: nestedPointer, Message: "$ref " + ref + " does not resolve"}
+				}
+				continue
+			}
+
+			err := v.validateRefs(nested, nestedPointer)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, nested := range typed {
+			err := v.validateRefs(nested, fmt.Sprintf("%s/%d", pointer, i))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *openAPIValidator) resolves(pointer string) bool {
+	if len(pointer) == 0 {
+		return true
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return false
+	}
+
+	var node interface{} = v.root
+	for _, token := range strings.Split(pointer[1:], "/") {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		node, ok = object[token]
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// the object at key, which is an error if it is required and missing, or is not an object
+func (v *openAPIValidator) object(node map[string]interface{}, pointer, key string, required bool) (map[string]interface{}, error) {
+	value, ok := node[key]
+	if !ok {
+		if required {
+			return nil, &SpecValidationError{Pointer: pointer, Message: key + " is required"}
+		}
+		return map[string]interface{}{}, nil
+	}
+
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, &SpecValidationError{Pointer: pointer + "/" + escapePointer(key), Message: key + " must be an object"}
+	}
+
+	return object, nil
+}
+
+// the field at key must be a string
+func (v *openAPIValidator) str(node map[string]interface{}, pointer, key string) error {
+	value, ok := node[key]
+	if !ok {
+		return &SpecValidationError{Pointer: pointer, Message: key + " is required"}
+	}
+
+	if _, ok := value.(string); !ok {
+		return &SpecValidationError{Pointer: pointer + "/" + escapePointer(key), Message: key + " must be a string"}
+	}
+
+	return nil
+}
+
+/*
+a version as a string. An unquoted YAML version such as openapi: 3.0 is
+decoded as a number, so it is formatted back with at least one decimal place.
+*/
+func versionString(value interface{}) (string, bool) {
+	switch typed := value.(type) {
+	case string:
+		return typed, true
+	case float64:
+		if typed == math.Trunc(typed) {
+			return strconv.FormatFloat(typed, 'f', 1, 64), true
+		}
+		return strconv.FormatFloat(typed, 'f', -1, 64), true
+	}
+	return "", false
+}
+
+// escapes a key as a JSON pointer reference token
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
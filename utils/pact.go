@@ -0,0 +1,241 @@
+package utils
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+/*
+a consumer contract that is not a valid pact. Field is the path to the field
+that is missing or invalid (ex. interactions[2].request.method).
+*/
+type PactValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *PactValidationError) Error() string {
+	return "consumer contract is not a valid pact - " + e.Field + " " + e.Message
+}
+
+// the major versions of the pact specification that consumer contracts can be published with
+var SupportedPactSpecifications = []int{2, 3, 4}
+
+// the types of interaction in a version 4 pact
+const (
+	SynchronousHTTP      = "Synchronous/HTTP"
+	AsynchronousMessages = "Asynchronous/Messages"
+	SynchronousMessages  = "Synchronous/Messages"
+)
+
+// the version of the pact specification that a pact follows, in its metadata
+type PactSpecification struct {
+	Version string `json:"version"`
+}
+
+/*
+the metadata that names the pact specification version. Version 3 and 4 pacts
+use pactSpecification, and version 2 pacts use pact-specification.
+*/
+type PactMetadata struct {
+	PactSpecification   *PactSpecification `json:"pactSpecification"`
+	PactSpecificationV2 *PactSpecification `json:"pact-specification"`
+}
+
+/*
+returns the version of the pact specification that a pact follows, and the
+field of its metadata that it was read from. A pact without a version is
+treated as version 2.0.0, which is what pacts from before the version was
+recorded follow.
+*/
+func PactSpecificationVersion(contract Pact) (string, string) {
+	metadataBytes, err := json.Marshal(contract.MetaData)
+	if err != nil {
+		return "2.0.0", ""
+	}
+
+	var metadata PactMetadata
+	if json.Unmarshal(metadataBytes, &metadata) != nil {
+		return "2.0.0", ""
+	}
+
+	if metadata.PactSpecification != nil && len(metadata.PactSpecification.Version) != 0 {
+		return metadata.PactSpecification.Version, "metadata.pactSpecification.version"
+	}
+	if metadata.PactSpecificationV2 != nil && len(metadata.PactSpecificationV2.Version) != 0 {
+		return metadata.PactSpecificationV2.Version, "metadata.pact-specification.version"
+	}
+
+	return "2.0.0", ""
+}
+
+// the major version of a pact specification version (ex. 3 for 3.0.0), or 0 if it has none
+func pactSpecificationMajor(version string) int {
+	majorVersion, _, _ := strings.Cut(strings.TrimPrefix(version, "v"), ".")
+	major, err := strconv.Atoi(majorVersion)
+	if err != nil {
+		return 0
+	}
+	return major
+}
+
+/*
+checks the fields that the pact specification version of a contract
+requires: the names of the consumer and provider, and the description,
+request method and path, and response status of every HTTP interaction.
+Version 3 message pacts and version 4 message interactions must have a
+description. Bodies, headers, and matching rules are not checked.
+*/
+func ValidatePact(contract Pact) error {
+	if len(strings.TrimSpace(contract.Consumer.Name)) == 0 {
+		return &PactValidationError{Field: "consumer.name", Message: "is required"}
+	}
+
+	provider, ok := contract.Provider.(map[string]interface{})
+	if !ok {
+		return &PactValidationError{Field: "provider.name", Message: "is required"}
+	}
+	if err := requireString(provider, "name", "provider"); err != nil {
+		return err
+	}
+
+	version, field := PactSpecificationVersion(contract)
+	major := pactSpecificationMajor(version)
+	supported := false
+	for _, supportedMajor := range SupportedPactSpecifications {
+		supported = supported || major == supportedMajor
+	}
+	if !supported {
+		return &PactValidationError{Field: field, Message: "must be version 2, 3, or 4 of the pact specification, it was " + version}
+	}
+
+	// a version 3 message pact has messages instead of interactions
+	if major == 3 && contract.Interactions == nil && contract.Messages != nil {
+		return validateEach(contract.Messages, "messages", validateMessage)
+	}
+
+	if major == 4 {
+		return validateEach(contract.Interactions, "interactions", validateV4Interaction)
+	}
+	return validateEach(contract.Interactions, "interactions", validateHTTPInteraction)
+}
+
+// checks each of an array of interactions or messages with validate
+func validateEach(value interface{}, field string, validate func(map[string]interface{}, string) error) error {
+	if value == nil {
+		return &PactValidationError{Field: field, Message: "is required"}
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return &PactValidationError{Field: field, Message: "must be an array"}
+	}
+
+	for i, item := range items {
+		itemField := field + "[" + strconv.Itoa(i) + "]"
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return &PactValidationError{Field: itemField, Message: "must be an object"}
+		}
+
+		if err := validate(fields, itemField); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validateHTTPInteraction(interaction map[string]interface{}, field string) error {
+	if err := requireString(interaction, "description", field); err != nil {
+		return err
+	}
+
+	request, err := requireObject(interaction, "request", field)
+	if err != nil {
+		return err
+	}
+	if err := requireString(request, "method", field+".request"); err != nil {
+		return err
+	}
+	if err := requireString(request, "path", field+".request"); err != nil {
+		return err
+	}
+
+	response, err := requireObject(interaction, "response", field)
+	if err != nil {
+		return err
+	}
+	status, ok := response["status"]
+	if !ok {
+		return &PactValidationError{Field: field + ".response.status", Message: "is required"}
+	}
+	if code, ok := status.(float64); !ok || code < 100 || code > 599 || code != float64(int(code)) {
+		return &PactValidationError{Field: field + ".response.status", Message: "must be an HTTP status code"}
+	}
+
+	return nil
+}
+
+func validateMessage(message map[string]interface{}, field string) error {
+	return requireString(message, "description", field)
+}
+
+// version 4 interactions have a type, which decides the fields they need
+func validateV4Interaction(interaction map[string]interface{}, field string) error {
+	if err := requireString(interaction, "type", field); err != nil {
+		return err
+	}
+
+	switch interactionType := interaction["type"].(string); interactionType {
+	case SynchronousHTTP:
+		return validateHTTPInteraction(interaction, field)
+	case AsynchronousMessages:
+		return validateMessage(interaction, field)
+	case SynchronousMessages:
+		if err := validateMessage(interaction, field); err != nil {
+			return err
+		}
+		_, err := requireObject(interaction, "request", field)
+		return err
+	default:
+		return &PactValidationError{Field: field + ".type", Message: "must be " + SynchronousHTTP + ", " + AsynchronousMessages + ", or " + SynchronousMessages + ", it was " + interactionType}
+	}
+}
+
+// an interaction that is replayed against a provider as an HTTP request, which every interaction before version 4 is
+func IsHTTPInteraction(interaction map[string]interface{}) bool {
+	interactionType, ok := interaction["type"].(string)
+	return !ok || interactionType == SynchronousHTTP
+}
+
+func requireString(fields map[string]interface{}, key string, parent string) error {
+	value, ok := fields[key]
+	if !ok || value == nil {
+		return &PactValidationError{Field: parent + "." + key, Message: "is required"}
+	}
+
+	text, ok := value.(string)
+	if !ok {
+		return &PactValidationError{Field: parent + "." + key, Message: "must be a string"}
+	}
+	if len(strings.TrimSpace(text)) == 0 {
+		return &PactValidationError{Field: parent + "." + key, Message: "is required"}
+	}
+
+	return nil
+}
+
+func requireObject(fields map[string]interface{}, key string, parent string) (map[string]interface{}, error) {
+	value, ok := fields[key]
+	if !ok || value == nil {
+		return nil, &PactValidationError{Field: parent + "." + key, Message: "is required"}
+	}
+
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, &PactValidationError{Field: parent + "." + key, Message: "must be an object"}
+	}
+
+	return object, nil
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+)
+
+type RequestLogEntry struct {
+	Timestamp        string      `json:"timestamp,omitempty"`
+	Method           string      `json:"method"`
+	Path             string      `json:"path"`
+	Status           interface{} `json:"status"`
+	ProcessingTimeMs interface{} `json:"processingTimeMs,omitempty"`
+}
+
+/*
+opens the --dump-requests file for appending. With rotate, an existing file
+is first moved to <path>.1, replacing any earlier rotated file.
+*/
+func OpenRequestLog(path string, rotate bool) (*os.File, error) {
+	if rotate {
+		if _, err := os.Stat(path); err == nil {
+			err = os.Rename(path, path+".1")
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+/*
+writes a JSON line for each request/response pair that mountebank recorded
+since the last call. Match files which cannot be parsed yet, because
+mountebank is still writing them, are left for the next call.
+*/
+func LogNewMatches(stubsPath string, logged map[string]bool, w io.Writer) (int, error) {
+	matchPaths, err := GetMatchPaths(stubsPath)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, matchPath := range matchPaths {
+		if logged[matchPath] {
+			continue
+		}
+
+		matchBytes, err := os.ReadFile(matchPath)
+		if err != nil {
+			continue
+		}
+
+		var match struct {
+			Timestamp      string                 `json:"timestamp"`
+			Request        map[string]interface{} `json:"request"`
+			Response       map[string]interface{} `json:"response"`
+			ProcessingTime interface{}            `json:"processingTime"`
+		}
+		if json.Unmarshal(matchBytes, &match) != nil {
+			continue
+		}
+
+		entry, err := json.Marshal(RequestLogEntry{
+			Timestamp:        match.Timestamp,
+			Method:           stringField(match.Request, "method"),
+			Path:             stringField(match.Request, "path"),
+			Status:           match.Response["statusCode"],
+			ProcessingTimeMs: match.ProcessingTime,
+		})
+		if err != nil {
+			return count, err
+		}
+
+		_, err = w.Write(append(entry, '\n'))
+		if err != nil {
+			return count, err
+		}
+
+		logged[matchPath] = true
+		count++
+	}
+
+	return count, nil
+}
+
+func stringField(message map[string]interface{}, field string) string {
+	value, _ := message[field].(string)
+	return value
+}
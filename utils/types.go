@@ -1,5 +1,7 @@
 package utils
 
+import "time"
+
 type Consumer struct {
 	Name string `json:"name"`
 }
@@ -7,15 +9,35 @@ type Consumer struct {
 type Pact struct {
 	Consumer     Consumer    `json:"consumer"`
 	Interactions interface{} `json:"interactions"`
+	Messages     interface{} `json:"messages,omitempty"`
 	MetaData     interface{} `json:"metadata"`
 	Provider     interface{} `json:"provider"`
 }
 
 type ConsumerBody struct {
-	Contract        Pact   `json:"contract"`
-	ConsumerName    string `json:"consumerName"`
-	ConsumerVersion string `json:"consumerVersion"`
-	ConsumerBranch  string `json:"consumerBranch"`
+	Contract          Pact     `json:"contract"`
+	ConsumerName      string   `json:"consumerName"`
+	ConsumerVersion   string   `json:"consumerVersion"`
+	ConsumerBranch    string   `json:"consumerBranch"`
+	Tags              []string `json:"tags,omitempty"`
+	TTL               int64    `json:"ttl,omitempty"`
+	PactSpecification string   `json:"pactSpecification,omitempty"`
+}
+
+// version 2 of the contract schema groups the consumer's details together
+type ConsumerBodyV2 struct {
+	SchemaVersion     int                `json:"schemaVersion"`
+	Contract          Pact               `json:"contract"`
+	Consumer          ParticipantVersion `json:"consumer"`
+	TTL               int64              `json:"ttl,omitempty"`
+	PactSpecification string             `json:"pactSpecification,omitempty"`
+}
+
+type ParticipantVersion struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version"`
+	Branch  string   `json:"branch"`
+	Tags    []string `json:"tags,omitempty"`
 }
 
 type ProviderBody struct {
@@ -24,6 +46,15 @@ type ProviderBody struct {
 	ProviderVersion string      `json:"providerVersion"`
 	ProviderBranch  string      `json:"providerBranch"`
 	SpecFormat      string      `json:"specFormat"`
+	EnvironmentName string      `json:"environmentName,omitempty"`
+}
+
+// sent to the publish --webhook once a contract or spec is published
+type PublishWebhookBody struct {
+	ParticipantName    string `json:"participantName"`
+	ParticipantVersion string `json:"participantVersion"`
+	ParticipantBranch  string `json:"participantBranch"`
+	ContractType       string `json:"contractType"`
 }
 
 type EnvBody struct {
@@ -46,13 +77,111 @@ type MbResponse struct {
 	Proxy MbProxy `json:"proxy"`
 }
 
+/*
+matches requests whose path matches a regular expression. Not and Or
+combine other predicates.
+*/
+type MbPredicate struct {
+	Matches    map[string]string `json:"matches,omitempty"`
+	Not        *MbPredicate      `json:"not,omitempty"`
+	Or         []MbPredicate     `json:"or,omitempty"`
+}
+
 type MbStub struct {
+	Predicates []MbPredicate `json:"predicates,omitempty"`
 	Responses []MbResponse `json:"responses"`
 }
 
+/*
+a provider that signet proxy records interactions with. Requests whose path
+starts with PathPrefix are proxied to URL, and recorded in the contract at
+ContractPath. An empty PathPrefix matches every request.
+*/
+type ProxyTarget struct {
+	PathPrefix   string
+	URL          string
+	ProviderName string
+	ContractPath string
+}
+
 type ProxyConfig struct {
 	Port     int          `json:"port"`
 	Name     string       `json:"name"`
 	Protocol string       `json:"protocol"`
 	Stubs    []MbStub `json:"stubs"`
-}
\ No newline at end of file
+}
+
+type InteractionResult struct {
+	Description string
+	Passed      bool
+	Mismatches  []string
+	Warnings    []string
+	// how long the provider took to respond, including reading the body
+	Duration time.Duration
+}
+
+// an interaction that was added, removed, or changed since a prior pact, with the fields that changed
+type InteractionChange struct {
+	Description string
+	Kind        string
+	Fields      []string
+}
+
+type VerifyOptions struct {
+	TeardownURL         string
+	FailOnTeardownError bool
+	// a response slower than this is reported as a warning, or as a mismatch with FailOnSlowResponse
+	MaxResponseTime    time.Duration
+	FailOnSlowResponse bool
+}
+
+type PactOptions struct {
+	Encoding         string
+	RecordSpec       []RecordRule
+	NormalizeNumbers bool
+	RecordTrailers   bool
+	MaxBodySize      int
+	Fixtures         []Fixture
+	IncludePaths     []string
+	ExcludePaths     []string
+	ScrubHeaders     []string
+	// the provider state of interactions recorded without a ProviderStateHeader
+	ProviderState string
+	// status codes or classes (ex. 2xx) of the responses that are recorded, all of them when empty
+	RecordStatuses []string
+}
+
+type PublishOptions struct {
+	Tags          []string
+	SchemaVersion int
+	TTL           time.Duration
+	// publish a contract that is not a valid pact
+	SkipValidation bool
+	// build the request without contacting the broker
+	DryRun bool
+}
+
+type PactSummary struct {
+	ProviderName string
+	Path        string
+	Recorded    int
+	// interactions left out because they are identical to one that was already recorded
+	Duplicates  int
+	Dropped     int
+	// interactions left out because their response status did not match RecordStatuses
+	StatusDropped int
+	Substituted   int
+	Written       bool
+}
+
+type Fixture struct {
+	Method   string                 `json:"method"`
+	Path     string                 `json:"path"`
+	Request  map[string]interface{} `json:"request"`
+	Response map[string]interface{} `json:"response"`
+}
+
+type RecordRule struct {
+	Method  string
+	Pattern string
+}
@@ -0,0 +1,673 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	client "github.com/signet-framework/signet-cli/client"
+)
+
+var arrayIndex = regexp.MustCompile(`\[\d+\]`)
+
+// pact files that are replayed are always JSON, unlike published consumer contracts which can be YAML
+func LoadPactFile(path string) (Pact, error) {
+	pactBytes, err := os.ReadFile(path)
+	if err != nil {
+		return Pact{}, errors.New("could not parse " + path + " as a pact: " + err.Error())
+	}
+
+	var pact Pact
+	err = json.Unmarshal(pactBytes, &pact)
+	if err != nil {
+		return Pact{}, errors.New("could not parse " + path + " as a pact: " + err.Error())
+	}
+
+	if _, ok := pact.Interactions.([]interface{}); !ok {
+		return Pact{}, errors.New(path + " is not a valid pact - it does not have an interactions array")
+	}
+
+	return pact, nil
+}
+
+/*
+returns a copy of the pact holding only the interactions which are not in the
+prior pact. An interaction that was changed in any way counts as new.
+*/
+func NewInteractions(pact Pact, prior Pact) (Pact, error) {
+	interactions, ok := pact.Interactions.([]interface{})
+	if !ok {
+		return Pact{}, errors.New("pact does not have an interactions array")
+	}
+
+	priorInteractions, ok := prior.Interactions.([]interface{})
+	if !ok {
+		return Pact{}, errors.New("the prior pact does not have an interactions array")
+	}
+
+	seen := map[string]bool{}
+	for _, interaction := range priorInteractions {
+		key, err := json.Marshal(interaction)
+		if err != nil {
+			return Pact{}, err
+		}
+		seen[string(key)] = true
+	}
+
+	newInteractions := []interface{}{}
+	for _, interaction := range interactions {
+		key, err := json.Marshal(interaction)
+		if err != nil {
+			return Pact{}, err
+		}
+
+		if !seen[string(key)] {
+			newInteractions = append(newInteractions, interaction)
+		}
+	}
+
+	pact.Interactions = newInteractions
+	return pact, nil
+}
+
+/*
+compares the interactions of a pact with those of a prior pact, matching
+interactions by description. Changed interactions list each field that
+differs, ex. "response.body.userId: 1 -> 2".
+*/
+func DiffInteractions(pact Pact, prior Pact) ([]InteractionChange, error) {
+	interactions, err := interactionsByDescription(pact)
+	if err != nil {
+		return nil, err
+	}
+
+	priorInteractions, err := interactionsByDescription(prior)
+	if err != nil {
+		return nil, errors.New("the prior pact " + err.Error())
+	}
+
+	changes := []InteractionChange{}
+	for _, description := range sortedKeys(interactions) {
+		priorInteraction, found := priorInteractions[description]
+		if !found {
+			changes = append(changes, InteractionChange{Description: description, Kind: "added"})
+			continue
+		}
+
+		fields := []string{}
+		diffValues("", priorInteraction, interactions[description], &fields)
+		if len(fields) != 0 {
+			changes = append(changes, InteractionChange{Description: description, Kind: "changed", Fields: fields})
+		}
+	}
+
+	for _, description := range sortedKeys(priorInteractions) {
+		if _, found := interactions[description]; !found {
+			changes = append(changes, InteractionChange{Description: description, Kind: "removed"})
+		}
+	}
+
+	return changes, nil
+}
+
+// interactions keyed by description, a repeated description is numbered, ex. "get a user (2)"
+func interactionsByDescription(pact Pact) (map[string]interface{}, error) {
+	interactions, ok := pact.Interactions.([]interface{})
+	if !ok {
+		return nil, errors.New("pact does not have an interactions array")
+	}
+
+	byDescription := map[string]interface{}{}
+	for i, interaction := range interactions {
+		fields, _ := interaction.(map[string]interface{})
+		description, _ := fields["description"].(string)
+		if len(description) == 0 {
+			description = fmt.Sprintf("interaction %d", i+1)
+		}
+
+		key := description
+		for n := 2; byDescription[key] != nil; n++ {
+			key = fmt.Sprintf("%s (%d)", description, n)
+		}
+		byDescription[key] = interaction
+	}
+
+	return byDescription, nil
+}
+
+// appends a "path: old -> new" line for each leaf value that differs between prior and current
+func diffValues(path string, prior, current interface{}, fields *[]string) {
+	priorMap, priorIsMap := prior.(map[string]interface{})
+	currentMap, currentIsMap := current.(map[string]interface{})
+	if priorIsMap && currentIsMap {
+		keys := map[string]interface{}{}
+		for key := range priorMap {
+			keys[key] = nil
+		}
+		for key := range currentMap {
+			keys[key] = nil
+		}
+
+		for _, key := range sortedKeys(keys) {
+			childPath := key
+			if len(path) != 0 {
+				childPath = path + "." + key
+			}
+			diffValues(childPath, priorMap[key], currentMap[key], fields)
+		}
+		return
+	}
+
+	priorArray, priorIsArray := prior.([]interface{})
+	currentArray, currentIsArray := current.([]interface{})
+	if priorIsArray && currentIsArray && len(priorArray) == len(currentArray) {
+		for i := range currentArray {
+			diffValues(fmt.Sprintf("%s[%d]", path, i), priorArray[i], currentArray[i], fields)
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(prior, current) {
+		*fields = append(*fields, path+": "+diffValue(prior)+" -> "+diffValue(current))
+	}
+}
+
+// a value as it is shown in a diff, long values are shortened
+func diffValue(value interface{}) string {
+	if value == nil {
+		return "(none)"
+	}
+
+	valueBytes, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	const maxLength = 60
+	if len(valueBytes) > maxLength {
+		return string(valueBytes[:maxLength]) + "..."
+	}
+	return string(valueBytes)
+}
+
+// the name of a pact's provider, or an empty string if it has none
+func ProviderName(pact Pact) string {
+	provider, _ := pact.Provider.(map[string]interface{})
+	providerName, _ := provider["name"].(string)
+	return providerName
+}
+
+// replayed interactions wait for the provider as long as requests to the broker wait for the broker
+var replayClient = &http.Client{Timeout: client.RequestTimeout}
+
+func VerifyPact(ctx context.Context, pact Pact, providerURL string, options VerifyOptions) ([]InteractionResult, error) {
+	results := []InteractionResult{}
+
+	interactions, ok := pact.Interactions.([]interface{})
+	if !ok {
+		return results, errors.New("pact does not have an interactions array")
+	}
+
+	for i, rawInteraction := range interactions {
+		interaction, ok := rawInteraction.(map[string]interface{})
+		if !ok {
+			return results, fmt.Errorf("interaction %d is not a JSON object", i)
+		}
+
+		// message interactions are not sent over HTTP, so they cannot be replayed
+		if !IsHTTPInteraction(interaction) {
+			continue
+		}
+
+		result, err := ReplayInteraction(ctx, interaction, providerURL)
+		if err != nil {
+			return results, err
+		}
+
+		if options.MaxResponseTime > 0 && result.Duration > options.MaxResponseTime {
+			slow := "response took " + result.Duration.Round(time.Millisecond).String() + ", longer than the maximum response time of " + options.MaxResponseTime.String()
+			if options.FailOnSlowResponse {
+				result.Mismatches = append(result.Mismatches, slow)
+				result.Passed = false
+			} else {
+				result.Warnings = append(result.Warnings, slow)
+			}
+		}
+
+		if len(options.TeardownURL) != 0 {
+			states := ProviderStates(interaction)
+			if len(states) != 0 {
+				err = teardownProviderStates(ctx, options.TeardownURL, pact.Consumer.Name, states)
+				if err != nil && options.FailOnTeardownError {
+					result.Mismatches = append(result.Mismatches, err.Error())
+					result.Passed = false
+				} else if err != nil {
+					result.Warnings = append(result.Warnings, err.Error())
+				}
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+func ReplayInteraction(ctx context.Context, interaction map[string]interface{}, providerURL string) (InteractionResult, error) {
+	result := InteractionResult{}
+	result.Description, _ = interaction["description"].(string)
+
+	request, _ := interaction["request"].(map[string]interface{})
+	response, _ := interaction["response"].(map[string]interface{})
+	if request == nil || response == nil {
+		return result, fmt.Errorf("interaction %q must have a request and a response", result.Description)
+	}
+
+	req, err := buildReplayRequest(ctx, request, providerURL)
+	if err != nil {
+		return result, err
+	}
+
+	start := time.Now()
+	resp, err := replayClient.Do(req)
+	if ctx.Err() != nil {
+		// interrupted, so the interaction has no result
+		return result, ctx.Err()
+	}
+	if err != nil {
+		result.Mismatches = append(result.Mismatches, "request failed: "+err.Error())
+		return result, nil
+	}
+	defer resp.Body.Close()
+
+	actualBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result, err
+	}
+	result.Duration = time.Since(start)
+
+	result.Mismatches = compareResponse(response, resp, actualBody)
+	result.Passed = len(result.Mismatches) == 0
+
+	return result, nil
+}
+
+// pact v3 lists providerStates objects, v2 has a single providerState string
+func ProviderStates(interaction map[string]interface{}) []map[string]interface{} {
+	states := []map[string]interface{}{}
+
+	if state, ok := interaction["providerState"].(string); ok && len(state) != 0 {
+		states = append(states, map[string]interface{}{"name": state})
+	}
+
+	rawStates, _ := interaction["providerStates"].([]interface{})
+	for _, rawState := range rawStates {
+		if state, ok := rawState.(map[string]interface{}); ok {
+			states = append(states, state)
+		}
+	}
+
+	return states
+}
+
+/*
+notifies the provider's state handler that an interaction is complete, so
+that the state it set up can be reset before the next interaction
+*/
+func teardownProviderStates(ctx context.Context, teardownURL, consumerName string, states []map[string]interface{}) error {
+	for _, state := range states {
+		reqBody, err := json.Marshal(map[string]interface{}{
+			"consumer": consumerName,
+			"state":    state["name"],
+			"params":   state["params"],
+			"action":   "teardown",
+		})
+		if err != nil {
+			return err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, teardownURL, bytes.NewBuffer(reqBody))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := replayClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("teardown of provider state %q failed: %v", state["name"], err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("teardown of provider state %q failed: %s", state["name"], resp.Status)
+		}
+	}
+
+	return nil
+}
+
+func buildReplayRequest(ctx context.Context, request map[string]interface{}, providerURL string) (*http.Request, error) {
+	method, _ := request["method"].(string)
+	if len(method) == 0 {
+		method = http.MethodGet
+	}
+	reqPath, _ := request["path"].(string)
+
+	reqURL, err := url.Parse(strings.TrimSuffix(providerURL, "/") + reqPath)
+	if err != nil {
+		return nil, err
+	}
+	reqURL.RawQuery = encodeQuery(request["query"])
+
+	requestBody, err := interactionBody(request["body"])
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	switch reqBody := requestBody.(type) {
+	case nil:
+	case string:
+		body = strings.NewReader(reqBody)
+	default:
+		jsonBody, err := json.Marshal(reqBody)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), reqURL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	headers, _ := request["headers"].(map[string]interface{})
+	for key, value := range headers {
+		for _, headerValue := range headerValues(value) {
+			req.Header.Add(key, headerValue)
+		}
+	}
+
+	return req, nil
+}
+
+/*
+the body of a request or response. A v4 pact wraps it in an object with its
+content, contentType, and encoded fields, where content is base64 when
+encoded is "base64", and a JSON string when encoded is "json".
+*/
+func interactionBody(body interface{}) (interface{}, error) {
+	wrapped, ok := body.(map[string]interface{})
+	if !ok {
+		return body, nil
+	}
+
+	_, hasContentType := wrapped["contentType"]
+	_, hasEncoded := wrapped["encoded"]
+	content, hasContent := wrapped["content"]
+	if !hasContent || (!hasContentType && !hasEncoded) {
+		return body, nil
+	}
+
+	encoded, _ := wrapped["encoded"].(string)
+	contentString, isString := content.(string)
+	switch strings.ToLower(encoded) {
+	case "base64":
+		if !isString {
+			return nil, errors.New("a base64 encoded body must have string content")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(contentString)
+		if err != nil {
+			return nil, errors.New("a base64 encoded body could not be decoded: " + err.Error())
+		}
+		return string(decoded), nil
+	case "json":
+		if !isString {
+			return content, nil
+		}
+		var parsed interface{}
+		err := json.Unmarshal([]byte(contentString), &parsed)
+		if err != nil {
+			return nil, errors.New("a JSON encoded body could not be parsed: " + err.Error())
+		}
+		return parsed, nil
+	}
+
+	return content, nil
+}
+
+// v4 pacts list each header's values, v2 and v3 pacts have a single string
+func headerValues(value interface{}) []string {
+	list, ok := value.([]interface{})
+	if !ok {
+		return []string{fmt.Sprint(value)}
+	}
+
+	values := []string{}
+	for _, item := range list {
+		values = append(values, fmt.Sprint(item))
+	}
+	return values
+}
+
+// pact v2 uses a query string, v3 and mountebank recordings use an object
+func encodeQuery(query interface{}) string {
+	switch q := query.(type) {
+	case string:
+		return q
+	case map[string]interface{}:
+		values := url.Values{}
+		for key, value := range q {
+			if list, ok := value.([]interface{}); ok {
+				for _, item := range list {
+					values.Add(key, fmt.Sprint(item))
+				}
+			} else {
+				values.Add(key, fmt.Sprint(value))
+			}
+		}
+		return values.Encode()
+	}
+	return ""
+}
+
+func compareResponse(expected map[string]interface{}, resp *http.Response, actualBody []byte) []string {
+	mismatches := []string{}
+
+	if status, ok := expected["status"].(float64); ok && int(status) != resp.StatusCode {
+		mismatches = append(mismatches, fmt.Sprintf("expected status %.0f but got %d", status, resp.StatusCode))
+	}
+
+	headers, _ := expected["headers"].(map[string]interface{})
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		expectedValues := headerValues(headers[key])
+		actualValues := resp.Header.Values(key)
+		if len(expectedValues) != len(actualValues) {
+			// a single header line can also hold a comma separated list
+			expectedValues = []string{strings.Join(expectedValues, ", ")}
+			actualValues = []string{strings.Join(actualValues, ", ")}
+		}
+
+		for i, expectedValue := range expectedValues {
+			if !headerValuesMatch(expectedValue, actualValues[i]) {
+				mismatches = append(mismatches, fmt.Sprintf("expected header %s to be %q but got %q", key, strings.Join(headerValues(headers[key]), ", "), strings.Join(resp.Header.Values(key), ", ")))
+				break
+			}
+		}
+	}
+
+	expectedBody, err := interactionBody(expected["body"])
+	if err != nil {
+		return append(mismatches, err.Error())
+	}
+	if expectedBody == nil {
+		return mismatches
+	}
+
+	typeRules := bodyTypeRules(expected["matchingRules"])
+
+	if expectedString, ok := expectedBody.(string); ok {
+		var parsed interface{}
+		if json.Unmarshal([]byte(expectedString), &parsed) != nil {
+			if expectedString != string(actualBody) {
+				mismatches = append(mismatches, fmt.Sprintf("expected body %q but got %q", expectedString, string(actualBody)))
+			}
+			return mismatches
+		}
+		expectedBody = parsed
+	}
+
+	var actual interface{}
+	if err := json.Unmarshal(actualBody, &actual); err != nil {
+		return append(mismatches, "expected a JSON body but got "+fmt.Sprintf("%q", string(actualBody)))
+	}
+
+	return append(mismatches, compareBody(expectedBody, actual, "$", typeRules, false)...)
+}
+
+// media types may carry parameters such as charset, which are compared loosely
+func headerValuesMatch(expected, actual string) bool {
+	if expected == actual {
+		return true
+	}
+	normalize := func(value string) string {
+		return strings.ToLower(strings.ReplaceAll(value, " ", ""))
+	}
+	expectedMedia := strings.Split(normalize(expected), ";")[0]
+	return len(expectedMedia) != 0 && strings.HasPrefix(normalize(actual), expectedMedia)
+}
+
+/*
+collects the body paths with a "type" matcher, supporting both the v2
+("$.body.field") and v3 ({"body": {"$.field": ...}}) matchingRules layouts
+*/
+func bodyTypeRules(rawRules interface{}) map[string]bool {
+	typePaths := map[string]bool{}
+	rules, _ := rawRules.(map[string]interface{})
+
+	for key, rule := range rules {
+		if strings.HasPrefix(key, "$.body") {
+			if ruleMap, ok := rule.(map[string]interface{}); ok && ruleMap["match"] == "type" {
+				typePaths["$"+strings.TrimPrefix(key, "$.body")] = true
+			}
+		}
+	}
+
+	bodyRules, _ := rules["body"].(map[string]interface{})
+	for key, rule := range bodyRules {
+		ruleMap, _ := rule.(map[string]interface{})
+		matchers, _ := ruleMap["matchers"].([]interface{})
+		for _, matcher := range matchers {
+			if matcherMap, ok := matcher.(map[string]interface{}); ok && matcherMap["match"] == "type" {
+				typePaths[key] = true
+			}
+		}
+	}
+
+	return typePaths
+}
+
+/*
+response bodies are matched leniently: unexpected object keys are allowed,
+while arrays must match in length. Paths covered by a "type" matching rule
+only need to match by JSON type.
+*/
+func compareBody(expected, actual interface{}, path string, typeRules map[string]bool, byType bool) []string {
+	if typeRules[path] || typeRules[arrayIndex.ReplaceAllString(path, "[*]")] {
+		byType = true
+	}
+
+	switch expectedValue := expected.(type) {
+	case map[string]interface{}:
+		actualMap, ok := actual.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object but got %s", path, jsonType(actual))}
+		}
+
+		mismatches := []string{}
+		keys := make([]string, 0, len(expectedValue))
+		for key := range expectedValue {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			childPath := path + "." + key
+			actualChild, ok := actualMap[key]
+			if !ok {
+				mismatches = append(mismatches, childPath+": expected key is missing")
+				continue
+			}
+			mismatches = append(mismatches, compareBody(expectedValue[key], actualChild, childPath, typeRules, byType)...)
+		}
+		return mismatches
+	case []interface{}:
+		actualList, ok := actual.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an array but got %s", path, jsonType(actual))}
+		}
+
+		if len(actualList) != len(expectedValue) && !byType {
+			return []string{fmt.Sprintf("%s: expected %d items but got %d", path, len(expectedValue), len(actualList))}
+		}
+
+		mismatches := []string{}
+		for i := range expectedValue {
+			if i >= len(actualList) {
+				break
+			}
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			mismatches = append(mismatches, compareBody(expectedValue[i], actualList[i], childPath, typeRules, byType)...)
+		}
+		return mismatches
+	default:
+		if byType {
+			if jsonType(expected) != jsonType(actual) {
+				return []string{fmt.Sprintf("%s: expected %s but got %s", path, jsonType(expected), jsonType(actual))}
+			}
+			return nil
+		}
+
+		if !reflect.DeepEqual(expected, actual) {
+			return []string{fmt.Sprintf("%s: expected %v but got %v", path, expected, actual)}
+		}
+		return nil
+	}
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "unknown"
+}